@@ -0,0 +1,42 @@
+package attachment
+
+import (
+	"database/sql"
+	"os"
+	"strconv"
+	"time"
+)
+
+const DefaultMaxSize = 10 << 20
+
+var allowedContentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/gif":       true,
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/webp":      true,
+}
+
+type handler struct {
+	DB      *sql.DB
+	Store   Store
+	MaxSize int64
+}
+
+func NewApplication(db *sql.DB, store Store) *handler {
+	maxSize, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_SIZE"), 10, 64)
+	if err != nil || maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	return &handler{DB: db, Store: store, MaxSize: maxSize}
+}
+
+type Attachment struct {
+	ID          int       `json:"id"`
+	ExpenseID   int       `json:"expense_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+}
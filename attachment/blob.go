@@ -0,0 +1,41 @@
+package attachment
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+// Store keeps attachment content addressed by key. Keys are SHA-256 hex
+// digests so identical uploads share one blob.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewStoreFromEnv builds the store selected by ATTACHMENT_STORAGE ("local"
+// by default, or "s3").
+func NewStoreFromEnv() (Store, error) {
+	switch os.Getenv("ATTACHMENT_STORAGE") {
+	case "", "local":
+		dir := os.Getenv("ATTACHMENT_DIR")
+		if dir == "" {
+			dir = "attachments"
+		}
+		return NewLocalStore(dir)
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		})
+	default:
+		return nil, errors.New("unknown ATTACHMENT_STORAGE " + os.Getenv("ATTACHMENT_STORAGE"))
+	}
+}
@@ -0,0 +1,169 @@
+package attachment
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+
+	"github.com/labstack/gommon/log"
+	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/expense"
+)
+
+// blobLock is the advisory lock class serialising the upload and removal of
+// one blob, keyed on its checksum.
+const blobLock = 7283004
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func InitDB(db *sql.DB) error {
+	commands := []string{
+		"CREATE TABLE IF NOT EXISTS attachments ( id SERIAL PRIMARY KEY, expense_id INT NOT NULL REFERENCES expenses (id) ON DELETE CASCADE, filename TEXT, content_type TEXT, size BIGINT, sha256 TEXT NOT NULL, created_at TIMESTAMPTZ NOT NULL DEFAULT now(), UNIQUE (expense_id, sha256));",
+		"CREATE INDEX IF NOT EXISTS attachments_sha256_idx ON attachments (sha256);",
+	}
+	for _, command := range commands {
+		if _, err := db.Exec(command); err != nil {
+			return err
+		}
+		log.Printf("Executed: %s", command)
+	}
+	return nil
+}
+
+func scanAttachment(row scanner) (Attachment, error) {
+	a := Attachment{}
+	err := row.Scan(&a.ID, &a.ExpenseID, &a.Filename, &a.ContentType, &a.Size, &a.SHA256, &a.CreatedAt)
+	return a, err
+}
+
+func GetAttachment(db *sql.DB, expenseID, id int) (Attachment, error) {
+	row := db.QueryRow("SELECT id, expense_id, filename, content_type, size, sha256, created_at FROM attachments WHERE expense_id = $1 AND id = $2", expenseID, id)
+	return scanAttachment(row)
+}
+
+func GetAttachmentBySHA256(db *sql.DB, expenseID int, sum string) (Attachment, error) {
+	row := db.QueryRow("SELECT id, expense_id, filename, content_type, size, sha256, created_at FROM attachments WHERE expense_id = $1 AND sha256 = $2", expenseID, sum)
+	return scanAttachment(row)
+}
+
+func GetAttachments(db *sql.DB, expenseID int) ([]Attachment, error) {
+	attachments := []Attachment{}
+	rows, err := db.Query("SELECT id, expense_id, filename, content_type, size, sha256, created_at FROM attachments WHERE expense_id = $1 ORDER BY id ASC", expenseID)
+	if err != nil {
+		return attachments, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return attachments, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+// GetAttachmentsByExpense lists the attachments of all the given expenses,
+// ordered by expense and then upload.
+func GetAttachmentsByExpense(db *sql.DB, expenseIDs []int) ([]Attachment, error) {
+	attachments := []Attachment{}
+	rows, err := db.Query("SELECT id, expense_id, filename, content_type, size, sha256, created_at FROM attachments WHERE expense_id = ANY($1) ORDER BY expense_id ASC, id ASC", pq.Array(expenseIDs))
+	if err != nil {
+		return attachments, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return attachments, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+func lockBlob(tx *sql.Tx, sum string) error {
+	_, err := tx.Exec("SELECT pg_advisory_xact_lock($1, hashtext($2))", blobLock, sum)
+	return err
+}
+
+func BlobInUse(db expense.Querier, sum string) (bool, error) {
+	var inUse bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM attachments WHERE sha256 = $1)", sum).Scan(&inUse)
+	return inUse, err
+}
+
+// StoreAttachment records a and writes its content to store unless another
+// attachment already references the blob. The blob stays locked until the
+// row is committed, so deleting the last other attachment sharing it cannot
+// remove the blob in between.
+func StoreAttachment(ctx context.Context, db *sql.DB, store Store, a Attachment, content io.Reader) (Attachment, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return a, err
+	}
+	defer tx.Rollback()
+
+	if err := lockBlob(tx, a.SHA256); err != nil {
+		return a, err
+	}
+	inUse, err := BlobInUse(tx, a.SHA256)
+	if err != nil {
+		return a, err
+	}
+	if !inUse {
+		if err := store.Put(ctx, a.SHA256, content, a.Size); err != nil {
+			return a, fmt.Errorf("Unable to store attachment: %w", err)
+		}
+	}
+	if a, err = CreateAttachment(tx, a); err != nil {
+		return a, err
+	}
+	return a, tx.Commit()
+}
+
+func CreateAttachment(db expense.Querier, a Attachment) (Attachment, error) {
+	row := db.QueryRow("INSERT INTO attachments (expense_id, filename, content_type, size, sha256) values ($1, $2, $3, $4, $5) RETURNING id, created_at",
+		a.ExpenseID, a.Filename, a.ContentType, a.Size, a.SHA256)
+	if err := row.Scan(&a.ID, &a.CreatedAt); err != nil {
+		log.Errorf("Insert attachment error: %v", err)
+		return a, err
+	}
+	return a, nil
+}
+
+// DeleteAttachment removes the attachment row, and its blob from store when
+// no other attachment references it. The blob is removed under the same lock
+// StoreAttachment takes, before the delete commits, so a concurrent upload
+// of the same content either commits first and keeps the blob or stores it
+// again afterwards. Failing to remove the blob only leaves it orphaned.
+func DeleteAttachment(ctx context.Context, db *sql.DB, store Store, expenseID, id int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var sum string
+	if err := tx.QueryRow("DELETE FROM attachments WHERE expense_id = $1 AND id = $2 RETURNING sha256", expenseID, id).Scan(&sum); err != nil {
+		return err
+	}
+	if err := lockBlob(tx, sum); err != nil {
+		return err
+	}
+	inUse, err := BlobInUse(tx, sum)
+	if err != nil {
+		return err
+	}
+	if !inUse {
+		if err := store.Delete(ctx, sum); err != nil {
+			log.Errorf("Delete attachment blob %s error: %v", sum, err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,30 @@
+package attachment
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/expense"
+)
+
+func (h *handler) DeleteAttachmentHandler(c echo.Context) error {
+	expenseID, err := visibleExpenseID(c, h.DB)
+	if err != nil {
+		return expenseError(c, err)
+	}
+	id, err := strconv.Atoi(c.Param("attachmentID"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "Attachment ID is invalid"})
+	}
+
+	switch err := DeleteAttachment(c.Request().Context(), h.DB, h.Store, expenseID, id); err {
+	case nil:
+		return c.NoContent(http.StatusNoContent)
+	case sql.ErrNoRows:
+		return c.JSON(http.StatusNotFound, expense.Error{Message: "Attachment not found"})
+	default:
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: err.Error()})
+	}
+}
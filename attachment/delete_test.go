@@ -0,0 +1,79 @@
+//go:build unit
+
+package attachment
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+)
+
+func deleteContext() (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodDelete, "/expenses/1/attachments/5", nil), rec)
+	c.SetParamNames("id", "attachmentID")
+	c.SetParamValues("1", "5")
+	return c, rec
+}
+
+func TestDeleteAttachmentHandler(t *testing.T) {
+	sum := sha256.Sum256(pngHeader)
+	hash := hex.EncodeToString(sum[:])
+
+	cases := []struct {
+		name   string
+		inUse  bool
+		stored bool
+	}{
+		{"Delete last attachment of a blob should remove the blob", false, false},
+		{"Delete attachment sharing a blob should keep the blob", true, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			store, _ := NewLocalStore(t.TempDir())
+			if err := store.Put(context.Background(), hash, bytes.NewReader(pngHeader), int64(len(pngHeader))); err != nil {
+				t.Fatal(err)
+			}
+
+			expectExpense(mock)
+			mock.ExpectBegin()
+			mock.ExpectQuery("DELETE FROM attachments WHERE expense_id = (.+) AND id = (.+) RETURNING sha256").
+				WithArgs(1, 5).
+				WillReturnRows(sqlmock.NewRows([]string{"sha256"}).AddRow(hash))
+			mock.ExpectExec("SELECT pg_advisory_xact_lock").
+				WithArgs(blobLock, hash).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery("SELECT EXISTS").
+				WithArgs(hash).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(tc.inUse))
+			mock.ExpectCommit()
+
+			c, rec := deleteContext()
+			if err := NewApplication(db, store).DeleteAttachmentHandler(c); err != nil {
+				t.Errorf("should not return error but it got %v", err)
+			}
+			if rec.Code != http.StatusNoContent {
+				t.Errorf("should status no content but it got %d: %s", rec.Code, rec.Body.String())
+			}
+			if _, err := store.Get(context.Background(), hash); (err == nil) != tc.stored {
+				t.Errorf("blob stored should be %v but it got %v", tc.stored, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
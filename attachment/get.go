@@ -0,0 +1,84 @@
+package attachment
+
+import (
+	"database/sql"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/expense"
+)
+
+var errInvalidID = errors.New("ID is invalid")
+
+// visibleExpenseID reads the id parameter, the expense the attachments
+// belong to. An expense the caller may not see is sql.ErrNoRows.
+func visibleExpenseID(c echo.Context, db *sql.DB) (int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, errInvalidID
+	}
+	if _, err := expense.GetVisibleExpense(c, db, id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func expenseError(c echo.Context, err error) error {
+	switch err {
+	case errInvalidID:
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: err.Error()})
+	case sql.ErrNoRows:
+		return c.JSON(http.StatusNotFound, expense.Error{Message: "Expense not found"})
+	default:
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: err.Error()})
+	}
+}
+
+func (h *handler) GetAttachmentsHandler(c echo.Context) error {
+	expenseID, err := visibleExpenseID(c, h.DB)
+	if err != nil {
+		return expenseError(c, err)
+	}
+	attachments, err := GetAttachments(h.DB, expenseID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: "Unable to get attachments from database:" + err.Error()})
+	}
+	return c.JSON(http.StatusOK, attachments)
+}
+
+func (h *handler) DownloadAttachmentHandler(c echo.Context) error {
+	expenseID, err := visibleExpenseID(c, h.DB)
+	if err != nil {
+		return expenseError(c, err)
+	}
+	id, err := strconv.Atoi(c.Param("attachmentID"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "Attachment ID is invalid"})
+	}
+
+	a, err := GetAttachment(h.DB, expenseID, id)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		return c.JSON(http.StatusNotFound, expense.Error{Message: "Attachment not found"})
+	default:
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: err.Error()})
+	}
+
+	blob, err := h.Store.Get(c.Request().Context(), a.SHA256)
+	if err == ErrBlobNotFound {
+		return c.JSON(http.StatusNotFound, expense.Error{Message: "Attachment content not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: "Unable to read attachment: " + err.Error()})
+	}
+	defer blob.Close()
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(a.Size, 10))
+	return c.Stream(http.StatusOK, a.ContentType, blob)
+}
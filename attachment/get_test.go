@@ -0,0 +1,81 @@
+//go:build unit
+
+package attachment
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/auth"
+)
+
+func TestOtherOwnersAttachments(t *testing.T) {
+	routes := []struct {
+		name    string
+		method  string
+		handler func(h *handler) echo.HandlerFunc
+	}{
+		{"List", http.MethodGet, func(h *handler) echo.HandlerFunc { return h.GetAttachmentsHandler }},
+		{"Download", http.MethodGet, func(h *handler) echo.HandlerFunc { return h.DownloadAttachmentHandler }},
+		{"Upload", http.MethodPost, func(h *handler) echo.HandlerFunc { return h.UploadAttachmentHandler }},
+		{"Delete", http.MethodDelete, func(h *handler) echo.HandlerFunc { return h.DeleteAttachmentHandler }},
+	}
+	for _, r := range routes {
+		t.Run(r.name+" attachment of another owner's expense should not found", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			expectExpense(mock)
+
+			e := echo.New()
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(r.method, "/expenses/1/attachments/5", nil), rec)
+			c.SetParamNames("id", "attachmentID")
+			c.SetParamValues("1", "5")
+			auth.SetUser(c, auth.User{Name: "bob"})
+			if err := r.handler(NewApplication(db, nil))(c); err != nil {
+				t.Errorf("should not return error but it got %v", err)
+			}
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("should status not found but it got %d: %s", rec.Code, rec.Body.String())
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestDownloadAttachmentHandler(t *testing.T) {
+	t.Run("Download attachment with missing blob should not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		store, _ := NewLocalStore(t.TempDir())
+		expectExpense(mock)
+		mock.ExpectQuery("SELECT (.+) FROM attachments WHERE expense_id = (.+) AND id").
+			WithArgs(1, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "expense_id", "filename", "content_type", "size", "sha256", "created_at"}).
+				AddRow(5, 1, "receipt.png", "image/png", 12, "0000000000000000000000000000000000000000000000000000000000000000", time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)))
+
+		e := echo.New()
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/expenses/1/attachments/5", nil), rec)
+		c.SetParamNames("id", "attachmentID")
+		c.SetParamValues("1", "5")
+		if err := NewApplication(db, store).DownloadAttachmentHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusNotFound || rec.Body.String() != `{"message":"Attachment content not found"}`+"\n" {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+}
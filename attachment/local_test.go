@@ -0,0 +1,43 @@
+//go:build unit
+
+package attachment
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+const testKey = "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("should not return error but it got %v", err)
+	}
+
+	if err := s.Put(ctx, testKey, strings.NewReader("foo"), 3); err != nil {
+		t.Fatalf("should not return error but it got %v", err)
+	}
+	blob, err := s.Get(ctx, testKey)
+	if err != nil {
+		t.Fatalf("should not return error but it got %v", err)
+	}
+	content, _ := io.ReadAll(blob)
+	blob.Close()
+	if string(content) != "foo" {
+		t.Errorf("should read back foo but it got %s", content)
+	}
+
+	if err := s.Delete(ctx, testKey); err != nil {
+		t.Errorf("should not return error but it got %v", err)
+	}
+	if _, err := s.Get(ctx, testKey); err != ErrBlobNotFound {
+		t.Errorf("should return ErrBlobNotFound but it got %v", err)
+	}
+	if err := s.Put(ctx, "../escape", strings.NewReader("foo"), 3); err == nil {
+		t.Errorf("should reject invalid key")
+	}
+}
@@ -0,0 +1,83 @@
+//go:build unit
+
+package attachment
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is a minimal in-memory stand-in for an S3-compatible server.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	auth    []string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeS3{objects: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	s, err := NewS3Store(S3Config{Endpoint: srv.URL, Bucket: "receipts", AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	if err != nil {
+		t.Fatalf("should not return error but it got %v", err)
+	}
+	s.now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	if err := s.Put(ctx, testKey, strings.NewReader("foo"), 3); err != nil {
+		t.Fatalf("should not return error but it got %v", err)
+	}
+	if _, ok := fake.objects["/receipts/"+testKey]; !ok {
+		t.Errorf("object should be stored with path-style key")
+	}
+	wantAuth := "AWS4-HMAC-SHA256 Credential=AKID/20260102/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature="
+	if !strings.HasPrefix(fake.auth[0], wantAuth) {
+		t.Errorf("unexpected authorization header %s", fake.auth[0])
+	}
+
+	blob, err := s.Get(ctx, testKey)
+	if err != nil {
+		t.Fatalf("should not return error but it got %v", err)
+	}
+	content, _ := io.ReadAll(blob)
+	blob.Close()
+	if string(content) != "foo" {
+		t.Errorf("should read back foo but it got %s", content)
+	}
+
+	if err := s.Delete(ctx, testKey); err != nil {
+		t.Errorf("should not return error but it got %v", err)
+	}
+	if _, err := s.Get(ctx, testKey); err != ErrBlobNotFound {
+		t.Errorf("should return ErrBlobNotFound but it got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package attachment
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/expense"
+)
+
+// multipartOverhead leaves room for the multipart boundaries and headers
+// around the file part when capping the request body.
+const multipartOverhead = 64 << 10
+
+func (h *handler) UploadAttachmentHandler(c echo.Context) error {
+	expenseID, err := visibleExpenseID(c, h.DB)
+	if err != nil {
+		return expenseError(c, err)
+	}
+
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, h.MaxSize+multipartOverhead)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return c.JSON(http.StatusRequestEntityTooLarge, expense.Error{Message: "Attachment is too large"})
+		}
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "Field file is required"})
+	}
+	if fh.Size > h.MaxSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, expense.Error{Message: "Attachment is too large"})
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: err.Error()})
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "Attachment is empty"})
+	}
+	contentType := http.DetectContentType(head[:n])
+	if !allowedContentTypes[contentType] {
+		return c.JSON(http.StatusUnsupportedMediaType, expense.Error{Message: "Attachment type " + contentType + " is not allowed"})
+	}
+
+	hash := sha256.New()
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: err.Error()})
+	}
+	if _, err := io.Copy(hash, f); err != nil {
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: err.Error()})
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	switch existing, err := GetAttachmentBySHA256(h.DB, expenseID, sum); err {
+	case nil:
+		return c.JSON(http.StatusOK, existing)
+	case sql.ErrNoRows:
+	default:
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: err.Error()})
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: err.Error()})
+	}
+	a, err := StoreAttachment(req.Context(), h.DB, h.Store, Attachment{
+		ExpenseID:   expenseID,
+		Filename:    filepath.Base(fh.Filename),
+		ContentType: contentType,
+		Size:        fh.Size,
+		SHA256:      sum,
+	}, f)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: err.Error()})
+	}
+	return c.JSON(http.StatusCreated, a)
+}
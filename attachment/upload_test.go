@@ -0,0 +1,192 @@
+//go:build unit
+
+package attachment
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n0000")
+
+func uploadContext(t *testing.T, content []byte) (echo.Context, *httptest.ResponseRecorder) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	part, err := w.CreateFormFile("file", "receipt.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	w.Close()
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/expenses/1/attachments", body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+	return c, rec
+}
+
+func expectExpense(mock sqlmock.Sqlmock) {
+	mock.ExpectPrepare("SELECT (.+) FROM expenses WHERE id").
+		ExpectQuery().
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}).
+			AddRow(1, "taxi", 10.0, "", pq.Array([]string{}), nil, nil, nil, "draft", "anonymous", nil, nil, nil, nil, nil, nil))
+}
+
+func TestUploadAttachmentHandler(t *testing.T) {
+	sum := sha256.Sum256(pngHeader)
+	hash := hex.EncodeToString(sum[:])
+
+	t.Run("Upload attachment should store blob", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		store, _ := NewLocalStore(t.TempDir())
+
+		expectExpense(mock)
+		mock.ExpectQuery("SELECT (.+) FROM attachments WHERE expense_id = (.+) AND sha256").
+			WithArgs(1, hash).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").
+			WithArgs(blobLock, hash).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT EXISTS").
+			WithArgs(hash).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectQuery("INSERT INTO attachments").
+			WithArgs(1, "receipt.png", "image/png", int64(len(pngHeader)), hash).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(5, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)))
+		mock.ExpectCommit()
+
+		h := NewApplication(db, store)
+		c, rec := uploadContext(t, pngHeader)
+		if err := h.UploadAttachmentHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusCreated {
+			t.Errorf("should status created but it got %v: %s", rec.Code, rec.Body.String())
+		}
+		if _, err := store.Get(c.Request().Context(), hash); err != nil {
+			t.Errorf("blob should be stored but it got %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Upload duplicate attachment should return existing", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		expectExpense(mock)
+		mock.ExpectQuery("SELECT (.+) FROM attachments WHERE expense_id = (.+) AND sha256").
+			WithArgs(1, hash).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "expense_id", "filename", "content_type", "size", "sha256", "created_at"}).
+				AddRow(5, 1, "receipt.png", "image/png", len(pngHeader), hash, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)))
+
+		h := NewApplication(db, nil)
+		c, rec := uploadContext(t, pngHeader)
+		if err := h.UploadAttachmentHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusOK {
+			t.Errorf("should status ok but it got %v", rec.Code)
+		}
+	})
+
+	t.Run("Upload of a blob in use should not store it again", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		store, _ := NewLocalStore(t.TempDir())
+
+		expectExpense(mock)
+		mock.ExpectQuery("SELECT (.+) FROM attachments WHERE expense_id = (.+) AND sha256").
+			WithArgs(1, hash).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").
+			WithArgs(blobLock, hash).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT EXISTS").
+			WithArgs(hash).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery("INSERT INTO attachments").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(6, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)))
+		mock.ExpectCommit()
+
+		c, rec := uploadContext(t, pngHeader)
+		if err := NewApplication(db, store).UploadAttachmentHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusCreated {
+			t.Errorf("should status created but it got %v: %s", rec.Code, rec.Body.String())
+		}
+		if _, err := store.Get(c.Request().Context(), hash); err != ErrBlobNotFound {
+			t.Errorf("blob should not be stored again but it got %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Upload unsupported content type should got error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		expectExpense(mock)
+
+		h := NewApplication(db, nil)
+		c, rec := uploadContext(t, []byte("#!/bin/sh\nrm -rf /\n"))
+		if err := h.UploadAttachmentHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("should status unsupported media type but it got %v", rec.Code)
+		}
+	})
+
+	t.Run("Upload too large attachment should got error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		expectExpense(mock)
+
+		h := NewApplication(db, nil)
+		h.MaxSize = 4
+		c, rec := uploadContext(t, pngHeader)
+		if err := h.UploadAttachmentHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("should status request entity too large but it got %v: %s", rec.Code, strconv.Quote(rec.Body.String()))
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/auth"
+)
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+type handler struct {
+	DB *sql.DB
+}
+
+func NewApplication(db *sql.DB) *handler {
+	return &handler{db}
+}
+
+// Querier is satisfied by both *sql.DB and *sql.Tx so audit records can be
+// written in the same transaction as the change they describe.
+type Querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// Actor identifies who made a change and in which request.
+type Actor struct {
+	Name      string
+	RequestID string
+}
+
+type Change struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+type Entry struct {
+	ID        int64     `json:"id"`
+	ExpenseID int       `json:"expense_id"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"`
+	RequestID string    `json:"request_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Changes   []Change  `json:"changes"`
+	PrevHash  string    `json:"prev_hash,omitempty"`
+	Hash      string    `json:"hash,omitempty"`
+}
+
+// ActorFrom identifies the authenticated user and the request ID assigned by
+// the RequestID middleware.
+func ActorFrom(c echo.Context) Actor {
+	actor := Actor{Name: auth.UserFrom(c).Name, RequestID: c.Response().Header().Get(echo.HeaderXRequestID)}
+	if actor.RequestID == "" {
+		actor.RequestID = c.Request().Header.Get(echo.HeaderXRequestID)
+	}
+	if actor.Name == "" {
+		actor.Name = "anonymous"
+	}
+	return actor
+}
+
+// VisibleOwner is the owner whose expenses the caller may see, or an empty
+// string when they may see every expense. Admins and approvers see all
+// expenses, everyone else only their own.
+func VisibleOwner(c echo.Context) string {
+	user := auth.UserFrom(c)
+	if user.HasRole(auth.RoleAdmin) || user.HasRole(auth.RoleApprover) {
+		return ""
+	}
+	return ActorFrom(c).Name
+}
+
+// Diff compares the JSON representations of before and after field by field.
+// Either side may be nil for creates and deletes. The id field is never
+// reported.
+func Diff(before, after any) ([]Change, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for name := range b {
+		names[name] = true
+	}
+	for name := range a {
+		names[name] = true
+	}
+	delete(names, "id")
+
+	changes := []Change{}
+	for name := range names {
+		if !reflect.DeepEqual(b[name], a[name]) {
+			changes = append(changes, Change{Field: name, Before: b[name], After: a[name]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+func fields(v any) (map[string]any, error) {
+	m := map[string]any{}
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil()) {
+		return m, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return m, json.Unmarshal(data, &m)
+}
@@ -0,0 +1,208 @@
+//go:build unit
+
+package audit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/auth"
+)
+
+type record struct {
+	ID     int      `json:"id"`
+	Title  string   `json:"title"`
+	Amount float64  `json:"amount"`
+	Tags   []string `json:"tags"`
+}
+
+func TestDiff(t *testing.T) {
+	t.Run("Diff should report changed fields only", func(t *testing.T) {
+		changes, err := Diff(record{ID: 1, Title: "taxi", Amount: 10, Tags: []string{"a"}}, record{ID: 1, Title: "taxi", Amount: 12, Tags: []string{"a", "b"}})
+		if err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := []Change{
+			{Field: "amount", Before: 10.0, After: 12.0},
+			{Field: "tags", Before: []any{"a"}, After: []any{"a", "b"}},
+		}
+		if !reflect.DeepEqual(changes, want) {
+			t.Errorf("changes were not expected got: %#v", changes)
+		}
+	})
+
+	t.Run("Diff against nil should report every set field but id", func(t *testing.T) {
+		changes, err := Diff(nil, record{ID: 3, Title: "taxi"})
+		if err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := []Change{
+			{Field: "amount", Before: nil, After: 0.0},
+			{Field: "title", Before: nil, After: "taxi"},
+		}
+		if !reflect.DeepEqual(changes, want) {
+			t.Errorf("changes were not expected got: %#v", changes)
+		}
+	})
+}
+
+func TestRecord(t *testing.T) {
+	t.Run("Record should link the entry to the previous hash", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		now = func() time.Time { return created }
+		defer func() { now = time.Now }()
+
+		want := Entry{ID: 5, ExpenseID: 7, Action: ActionDelete, Actor: "alice", RequestID: "req-1", CreatedAt: created,
+			Changes: []Change{{Field: "title", Before: "taxi", After: nil}}, PrevHash: "abc"}
+		hash, _ := want.ComputeHash()
+		mock.ExpectExec("SELECT pg_advisory_xact_lock($1)").WithArgs(chainLock).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT hash FROM expense_audit WHERE hash IS NOT NULL ORDER BY id DESC LIMIT 1").
+			WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow("abc"))
+		mock.ExpectQuery("INSERT INTO expense_audit (expense_id, action, actor, request_id, changes, created_at, prev_hash, hash) values ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id").
+			WithArgs(7, ActionDelete, "alice", "req-1", []byte(`[{"field":"title","before":"taxi","after":null}]`), created, "abc", hash).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+
+		e, err := Record(db, Actor{Name: "alice", RequestID: "req-1"}, ActionDelete, 7, map[string]any{"id": 7, "title": "taxi"}, nil)
+		if err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want.Hash = hash
+		if !reflect.DeepEqual(e, want) {
+			t.Errorf("entry was not expected got: %+v", e)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestGetHistoryHandler(t *testing.T) {
+	t.Run("Get history should list entries oldest first", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		mock.ExpectQuery("SELECT (.+) FROM expense_audit WHERE expense_id = (.+) ORDER BY id ASC").
+			WithArgs(7).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "expense_id", "action", "actor", "request_id", "changes", "created_at", "prev_hash", "hash"}).
+				AddRow(1, 7, "create", "alice", "req-1", []byte(`[{"field":"amount","before":null,"after":10}]`), created, nil, "h1").
+				AddRow(2, 7, "update", "bob", nil, []byte(`[{"field":"amount","before":10,"after":12}]`), created, "h1", "h2"))
+		mock.ExpectQuery("SELECT owner FROM expenses WHERE id = (.+)").WithArgs(7).
+			WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow("alice"))
+
+		c, rec := historyContext("alice")
+		if err := NewApplication(db).GetHistoryHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+
+		want := `[{"id":1,"expense_id":7,"action":"create","actor":"alice","request_id":"req-1","created_at":"2026-01-02T03:04:05Z","changes":[{"field":"amount","before":null,"after":10}],"hash":"h1"},` +
+			`{"id":2,"expense_id":7,"action":"update","actor":"bob","created_at":"2026-01-02T03:04:05Z","changes":[{"field":"amount","before":10,"after":12}],"prev_hash":"h1","hash":"h2"}]` + "\n"
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Get history of another owner's expense should not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT (.+) FROM expense_audit WHERE expense_id = (.+) ORDER BY id ASC").
+			WithArgs(7).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "expense_id", "action", "actor", "request_id", "changes", "created_at", "prev_hash", "hash"}))
+		mock.ExpectQuery("SELECT owner FROM expenses WHERE id = (.+)").WithArgs(7).
+			WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow("alice"))
+
+		c, rec := historyContext("bob")
+		if err := NewApplication(db).GetHistoryHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("should status not found but it got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Get history of another owner's deleted expense should not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT (.+) FROM expense_audit WHERE expense_id = (.+) ORDER BY id ASC").
+			WithArgs(7).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "expense_id", "action", "actor", "request_id", "changes", "created_at", "prev_hash", "hash"}).
+				AddRow(1, 7, "create", "alice", nil, []byte(`[]`), time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), nil, "h1"))
+		mock.ExpectQuery("SELECT owner FROM expenses WHERE id = (.+)").WithArgs(7).
+			WillReturnRows(sqlmock.NewRows([]string{"owner"}))
+
+		c, rec := historyContext("bob")
+		if err := NewApplication(db).GetHistoryHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("should status not found but it got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+func historyContext(user string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/expenses/7/history", nil), rec)
+	c.SetParamNames("id")
+	c.SetParamValues("7")
+	auth.SetUser(c, auth.User{Name: user})
+	return c, rec
+}
+
+func TestGetFeedHandler(t *testing.T) {
+	t.Run("Get feed should apply filters and limit", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		mock.ExpectQuery("SELECT id, expense_id, action, actor, request_id, changes, created_at, prev_hash, hash FROM expense_audit WHERE actor = $1 AND action = $2 AND created_at >= $3 AND id < $4 ORDER BY id DESC LIMIT $5").
+			WithArgs("alice", "update", since, 50, 10).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "expense_id", "action", "actor", "request_id", "changes", "created_at", "prev_hash", "hash"}))
+
+		e := echo.New()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/audit?actor=alice&action=update&since=2026-01-01T00:00:00Z&before_id=50&limit=10", nil)
+		if err := NewApplication(db).GetFeedHandler(e.NewContext(req, rec)); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusOK || rec.Body.String() != "[]\n" {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Get feed with invalid limit should got bad request", func(t *testing.T) {
+		e := echo.New()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/audit?limit=5000", nil)
+		if err := NewApplication(nil).GetFeedHandler(e.NewContext(req, rec)); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusBadRequest || rec.Body.String() != `{"message":"Query limit is invalid"}`+"\n" {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+}
@@ -0,0 +1,271 @@
+package audit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/labstack/gommon/log"
+)
+
+// chainLock is the advisory lock key serialising appends to the chain.
+const chainLock = 7283001
+
+var now = time.Now
+
+// ComputeHash returns the SHA-256 over the record's content and the previous
+// record's hash. The ID is left out because it is assigned by the database
+// after the hash is computed; order is instead fixed by PrevHash.
+func (e Entry) ComputeHash() (string, error) {
+	data, err := json.Marshal(struct {
+		PrevHash  string   `json:"prev_hash"`
+		ExpenseID int      `json:"expense_id"`
+		Action    string   `json:"action"`
+		Actor     string   `json:"actor"`
+		RequestID string   `json:"request_id"`
+		CreatedAt string   `json:"created_at"`
+		Changes   []Change `json:"changes"`
+	}{e.PrevHash, e.ExpenseID, e.Action, e.Actor, e.RequestID, e.CreatedAt.UTC().Format(time.RFC3339Nano), e.Changes})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Checkpoint is a signed statement that the chain ended at AuditID with Hash.
+type Checkpoint struct {
+	ID        int       `json:"id"`
+	AuditID   int64     `json:"audit_id"`
+	Hash      string    `json:"hash"`
+	Signature string    `json:"signature"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func encode(sig []byte) string {
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func checkpointMessage(auditID int64, hash string) []byte {
+	return []byte(strconv.FormatInt(auditID, 10) + ":" + hash)
+}
+
+// Verify reports whether cp was signed by the holder of key.
+func (cp Checkpoint) Verify(key ed25519.PublicKey) bool {
+	sig, err := base64.StdEncoding.DecodeString(cp.Signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(key, checkpointMessage(cp.AuditID, cp.Hash), sig)
+}
+
+// SigningKeyFromEnv reads AUDIT_SIGNING_KEY, a base64 encoded 32 byte
+// Ed25519 seed. It returns nil when the variable is unset.
+func SigningKeyFromEnv() (ed25519.PrivateKey, error) {
+	v := os.Getenv("AUDIT_SIGNING_KEY")
+	if v == "" {
+		return nil, nil
+	}
+	seed, err := base64.StdEncoding.DecodeString(v)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, errors.New("AUDIT_SIGNING_KEY must be a base64 encoded 32 byte seed")
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// PublicKeyFromEnv reads AUDIT_PUBLIC_KEY, falling back to the public half of
+// AUDIT_SIGNING_KEY. It returns nil when neither is set.
+func PublicKeyFromEnv() (ed25519.PublicKey, error) {
+	if v := os.Getenv("AUDIT_PUBLIC_KEY"); v != "" {
+		key, err := base64.StdEncoding.DecodeString(v)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, errors.New("AUDIT_PUBLIC_KEY must be a base64 encoded 32 byte key")
+		}
+		return key, nil
+	}
+	key, err := SigningKeyFromEnv()
+	if key == nil {
+		return nil, err
+	}
+	return key.Public().(ed25519.PublicKey), nil
+}
+
+// WriteCheckpoint signs the current head of the chain. It does nothing when
+// the head is already covered by the latest checkpoint.
+func WriteCheckpoint(db *sql.DB, key ed25519.PrivateKey) (Checkpoint, bool, error) {
+	cp := Checkpoint{}
+	err := db.QueryRow("SELECT id, hash FROM expense_audit WHERE hash IS NOT NULL ORDER BY id DESC LIMIT 1").Scan(&cp.AuditID, &cp.Hash)
+	if err == sql.ErrNoRows {
+		return cp, false, nil
+	}
+	if err != nil {
+		return cp, false, err
+	}
+	var last int64
+	if err := db.QueryRow("SELECT COALESCE(MAX(audit_id), 0) FROM audit_checkpoints").Scan(&last); err != nil {
+		return cp, false, err
+	}
+	if last >= cp.AuditID {
+		return cp, false, nil
+	}
+
+	cp.Signature = encode(ed25519.Sign(key, checkpointMessage(cp.AuditID, cp.Hash)))
+	err = db.QueryRow("INSERT INTO audit_checkpoints (audit_id, hash, signature) values ($1, $2, $3) RETURNING id, created_at",
+		cp.AuditID, cp.Hash, cp.Signature).Scan(&cp.ID, &cp.CreatedAt)
+	return cp, err == nil, err
+}
+
+type Checkpointer struct {
+	DB       *sql.DB
+	Key      ed25519.PrivateKey
+	Interval time.Duration
+}
+
+func NewCheckpointer(db *sql.DB, key ed25519.PrivateKey, interval time.Duration) *Checkpointer {
+	return &Checkpointer{DB: db, Key: key, Interval: interval}
+}
+
+// Run signs the head of the chain immediately and then on every tick until
+// ctx is cancelled.
+func (cp *Checkpointer) Run(ctx context.Context) {
+	ticker := time.NewTicker(cp.Interval)
+	defer ticker.Stop()
+	for {
+		c, written, err := WriteCheckpoint(cp.DB, cp.Key)
+		if err != nil {
+			log.Errorf("Write audit checkpoint error: %v", err)
+		} else if written {
+			log.Printf("Audit checkpoint at record %d", c.AuditID)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Break describes the first point at which the chain does not hold.
+type Break struct {
+	AuditID int64  `json:"audit_id"`
+	Reason  string `json:"reason"`
+}
+
+type Report struct {
+	Records     int    `json:"records"`
+	Unchained   int    `json:"unchained"`
+	Checkpoints int    `json:"checkpoints"`
+	Head        string `json:"head"`
+	Broken      *Break `json:"broken,omitempty"`
+}
+
+// Verify walks the chain in ID order, recomputing every hash and checking
+// each record links to its predecessor. Records written before the chain
+// existed have no hash and are counted as unchained; once the chain starts
+// every record must carry one. Checkpoints are checked against the records
+// they name, and their signatures against key when it is not nil, so
+// truncating the tail of the log is caught as well.
+func Verify(ctx context.Context, db *sql.DB, key ed25519.PublicKey) (Report, error) {
+	report := Report{}
+	checkpoints, err := loadCheckpoints(ctx, db)
+	if err != nil {
+		return report, err
+	}
+	report.Checkpoints = len(checkpoints)
+	for _, cp := range checkpoints {
+		if key != nil && !cp.Verify(key) {
+			report.Broken = &Break{AuditID: cp.AuditID, Reason: fmt.Sprintf("checkpoint %d has an invalid signature", cp.ID)}
+			return report, nil
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT id, expense_id, action, actor, request_id, changes, created_at, prev_hash, hash FROM expense_audit ORDER BY id ASC")
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	prev, chained := "", false
+	var lastID int64
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return report, err
+		}
+		report.Records++
+		lastID = e.ID
+
+		for len(checkpoints) > 0 && checkpoints[0].AuditID < e.ID {
+			report.Broken = &Break{AuditID: checkpoints[0].AuditID, Reason: fmt.Sprintf("record named by checkpoint %d is missing", checkpoints[0].ID)}
+			return report, nil
+		}
+
+		if e.Hash == "" {
+			if chained {
+				report.Broken = &Break{AuditID: e.ID, Reason: "hash is missing"}
+				return report, nil
+			}
+			report.Unchained++
+			continue
+		}
+		chained = true
+
+		if e.PrevHash != prev {
+			report.Broken = &Break{AuditID: e.ID, Reason: "previous hash does not match the preceding record"}
+			return report, nil
+		}
+		hash, err := e.ComputeHash()
+		if err != nil {
+			return report, err
+		}
+		if hash != e.Hash {
+			report.Broken = &Break{AuditID: e.ID, Reason: "content does not match its hash"}
+			return report, nil
+		}
+		prev = e.Hash
+
+		for len(checkpoints) > 0 && checkpoints[0].AuditID == e.ID {
+			if checkpoints[0].Hash != e.Hash {
+				report.Broken = &Break{AuditID: e.ID, Reason: fmt.Sprintf("hash does not match checkpoint %d", checkpoints[0].ID)}
+				return report, nil
+			}
+			checkpoints = checkpoints[1:]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+	if len(checkpoints) > 0 {
+		report.Broken = &Break{AuditID: checkpoints[0].AuditID, Reason: fmt.Sprintf("log ends at record %d before checkpoint %d", lastID, checkpoints[0].ID)}
+		return report, nil
+	}
+	report.Head = prev
+	return report, nil
+}
+
+func loadCheckpoints(ctx context.Context, db *sql.DB) ([]Checkpoint, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, audit_id, hash, signature, created_at FROM audit_checkpoints ORDER BY audit_id ASC, id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	checkpoints := []Checkpoint{}
+	for rows.Next() {
+		cp := Checkpoint{}
+		if err := rows.Scan(&cp.ID, &cp.AuditID, &cp.Hash, &cp.Signature, &cp.CreatedAt); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints, rows.Err()
+}
@@ -0,0 +1,161 @@
+//go:build unit
+
+package audit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var auditColumns = []string{"id", "expense_id", "action", "actor", "request_id", "changes", "created_at", "prev_hash", "hash"}
+
+func chain(t *testing.T, n int) []Entry {
+	entries := []Entry{}
+	prev := ""
+	for i := 1; i <= n; i++ {
+		e := Entry{ID: int64(i), ExpenseID: 1, Action: ActionUpdate, Actor: "alice", PrevHash: prev,
+			CreatedAt: time.Date(2026, 1, i, 0, 0, 0, 123000, time.UTC),
+			Changes:   []Change{{Field: "amount", Before: float64(i), After: float64(i + 1)}}}
+		hash, err := e.ComputeHash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Hash, prev = hash, hash
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func entryRows(t *testing.T, entries []Entry) *sqlmock.Rows {
+	rows := sqlmock.NewRows(auditColumns)
+	for _, e := range entries {
+		changes, err := json.Marshal(e.Changes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var prev, hash any
+		if e.PrevHash != "" {
+			prev = e.PrevHash
+		}
+		if e.Hash != "" {
+			hash = e.Hash
+		}
+		rows.AddRow(e.ID, e.ExpenseID, e.Action, e.Actor, nil, changes, e.CreatedAt.In(time.FixedZone("ICT", 7*3600)), prev, hash)
+	}
+	return rows
+}
+
+func signed(key ed25519.PrivateKey, id int, e Entry) Checkpoint {
+	cp := Checkpoint{ID: id, AuditID: e.ID, Hash: e.Hash}
+	sig := ed25519.Sign(key, checkpointMessage(cp.AuditID, cp.Hash))
+	cp.Signature = encode(sig)
+	return cp
+}
+
+func checkpointRows(cps ...Checkpoint) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "audit_id", "hash", "signature", "created_at"})
+	for _, cp := range cps {
+		rows.AddRow(cp.ID, cp.AuditID, cp.Hash, cp.Signature, time.Now())
+	}
+	return rows
+}
+
+func TestVerify(t *testing.T) {
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	public := key.Public().(ed25519.PublicKey)
+
+	legacy := Entry{ID: 0, ExpenseID: 1, Action: ActionCreate, Actor: "system", CreatedAt: time.Now(), Changes: []Change{}}
+	intact := chain(t, 3)
+	tampered := chain(t, 3)
+	tampered[1].Actor = "mallory"
+	unhashed := chain(t, 3)
+	unhashed[2].Hash = ""
+
+	cases := []struct {
+		name        string
+		entries     []Entry
+		checkpoints []Checkpoint
+		want        *Break
+	}{
+		{"Intact chain should verify", append([]Entry{legacy}, intact...), []Checkpoint{signed(key, 1, intact[1])}, nil},
+		{"Edited record should break the chain", tampered, nil, &Break{AuditID: 2, Reason: "content does not match its hash"}},
+		{"Deleted record should break the chain", []Entry{intact[0], intact[2]}, nil, &Break{AuditID: 3, Reason: "previous hash does not match the preceding record"}},
+		{"Cleared hash should break the chain", unhashed, nil, &Break{AuditID: 3, Reason: "hash is missing"}},
+		{"Truncated tail should be caught by a checkpoint", intact[:2], []Checkpoint{signed(key, 4, intact[2])}, &Break{AuditID: 3, Reason: "log ends at record 2 before checkpoint 4"}},
+		{"Forged checkpoint should be rejected", intact, []Checkpoint{{ID: 5, AuditID: 3, Hash: intact[2].Hash, Signature: encode(make([]byte, ed25519.SignatureSize))}}, &Break{AuditID: 3, Reason: "checkpoint 5 has an invalid signature"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			mock.ExpectQuery("SELECT (.+) FROM audit_checkpoints ORDER BY audit_id").WillReturnRows(checkpointRows(tc.checkpoints...))
+			if tc.want == nil || tc.want.Reason != "checkpoint 5 has an invalid signature" {
+				mock.ExpectQuery("SELECT (.+) FROM expense_audit ORDER BY id ASC").WillReturnRows(entryRows(t, tc.entries))
+			}
+
+			report, err := Verify(context.Background(), db, public)
+			if err != nil {
+				t.Errorf("should not return error but it got %v", err)
+			}
+			if (tc.want == nil) != (report.Broken == nil) || (tc.want != nil && *report.Broken != *tc.want) {
+				t.Errorf("report was not expected got: %+v %+v", report, report.Broken)
+			}
+			if tc.want == nil && (report.Unchained != 1 || report.Head != intact[2].Hash) {
+				t.Errorf("report was not expected got: %+v", report)
+			}
+		})
+	}
+}
+
+func TestWriteCheckpoint(t *testing.T) {
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+
+	t.Run("Checkpoint should sign the head of the chain", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT id, hash FROM expense_audit").WillReturnRows(sqlmock.NewRows([]string{"id", "hash"}).AddRow(9, "h9"))
+		mock.ExpectQuery("SELECT COALESCE").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(4))
+		mock.ExpectQuery("INSERT INTO audit_checkpoints").
+			WithArgs(9, "h9", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(2, time.Now()))
+
+		cp, written, err := WriteCheckpoint(db, key)
+		if err != nil || !written {
+			t.Errorf("should write checkpoint but it got %v %v", written, err)
+		}
+		if !cp.Verify(key.Public().(ed25519.PublicKey)) {
+			t.Errorf("checkpoint signature should verify got: %+v", cp)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Checkpoint should skip when head is already signed", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT id, hash FROM expense_audit").WillReturnRows(sqlmock.NewRows([]string{"id", "hash"}).AddRow(9, "h9"))
+		mock.ExpectQuery("SELECT COALESCE").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(9))
+
+		if _, written, err := WriteCheckpoint(db, key); err != nil || written {
+			t.Errorf("should skip checkpoint but it got %v %v", written, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/gommon/log"
+)
+
+func InitDB(db *sql.DB) error {
+	commands := []string{
+		"CREATE TABLE IF NOT EXISTS expense_audit ( id BIGSERIAL PRIMARY KEY, expense_id INT NOT NULL, action TEXT NOT NULL, actor TEXT NOT NULL, request_id TEXT, changes JSONB NOT NULL, created_at TIMESTAMPTZ NOT NULL DEFAULT now());",
+		"CREATE INDEX IF NOT EXISTS expense_audit_expense_id_idx ON expense_audit (expense_id, id);",
+		"ALTER TABLE expense_audit ADD COLUMN IF NOT EXISTS prev_hash TEXT;",
+		"ALTER TABLE expense_audit ADD COLUMN IF NOT EXISTS hash TEXT;",
+		"CREATE TABLE IF NOT EXISTS audit_checkpoints ( id SERIAL PRIMARY KEY, audit_id BIGINT NOT NULL, hash TEXT NOT NULL, signature TEXT NOT NULL, created_at TIMESTAMPTZ NOT NULL DEFAULT now());",
+	}
+	for _, command := range commands {
+		if _, err := db.Exec(command); err != nil {
+			return err
+		}
+		log.Printf("Executed: %s", command)
+	}
+	return nil
+}
+
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// Record stores the change from before to after made by actor and links it
+// to the previous record's hash. It must run inside a transaction: the chain
+// lock is held until that transaction ends so concurrent writers append one
+// at a time.
+func Record(db Querier, actor Actor, action string, expenseID int, before, after any) (Entry, error) {
+	changes, err := Diff(before, after)
+	if err != nil {
+		return Entry{}, err
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	e := Entry{ExpenseID: expenseID, Action: action, Actor: actor.Name, RequestID: actor.RequestID, Changes: changes,
+		CreatedAt: now().UTC().Truncate(time.Microsecond)}
+	if _, err := db.Exec("SELECT pg_advisory_xact_lock($1)", chainLock); err != nil {
+		return e, err
+	}
+	if e.PrevHash, err = lastHash(db); err != nil {
+		return e, err
+	}
+	if e.Hash, err = e.ComputeHash(); err != nil {
+		return e, err
+	}
+
+	row := db.QueryRow("INSERT INTO expense_audit (expense_id, action, actor, request_id, changes, created_at, prev_hash, hash) values ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id",
+		expenseID, action, actor.Name, nullString(actor.RequestID), data, e.CreatedAt, nullString(e.PrevHash), e.Hash)
+	if err := row.Scan(&e.ID); err != nil {
+		log.Errorf("Insert audit error: %v", err)
+		return e, err
+	}
+	return e, nil
+}
+
+func lastHash(db Querier) (string, error) {
+	var hash string
+	err := db.QueryRow("SELECT hash FROM expense_audit WHERE hash IS NOT NULL ORDER BY id DESC LIMIT 1").Scan(&hash)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return hash, err
+}
+
+// FeedFilter selects audit entries for the admin feed. Entries are returned
+// newest first; BeforeID pages backwards from a previous page's last ID.
+type FeedFilter struct {
+	ExpenseID int
+	Actor     string
+	Action    string
+	Since     time.Time
+	Until     time.Time
+	BeforeID  int64
+	Limit     int
+}
+
+func (f FeedFilter) where() (string, []any) {
+	conditions := []string{}
+	args := []any{}
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.Replace(condition, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+	if f.ExpenseID != 0 {
+		add("expense_id = ?", f.ExpenseID)
+	}
+	if f.Actor != "" {
+		add("actor = ?", f.Actor)
+	}
+	if f.Action != "" {
+		add("action = ?", f.Action)
+	}
+	if !f.Since.IsZero() {
+		add("created_at >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		add("created_at < ?", f.Until)
+	}
+	if f.BeforeID != 0 {
+		add("id < ?", f.BeforeID)
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func scanEntry(rows *sql.Rows) (Entry, error) {
+	e := Entry{}
+	var requestID, prevHash, hash sql.NullString
+	var changes []byte
+	if err := rows.Scan(&e.ID, &e.ExpenseID, &e.Action, &e.Actor, &requestID, &changes, &e.CreatedAt, &prevHash, &hash); err != nil {
+		return e, err
+	}
+	e.RequestID, e.PrevHash, e.Hash = requestID.String, prevHash.String, hash.String
+	return e, json.Unmarshal(changes, &e.Changes)
+}
+
+func scanEntries(rows *sql.Rows) ([]Entry, error) {
+	defer rows.Close()
+	entries := []Entry{}
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// History returns every change of one expense, oldest first.
+func History(db *sql.DB, expenseID int) ([]Entry, error) {
+	rows, err := db.Query("SELECT id, expense_id, action, actor, request_id, changes, created_at, prev_hash, hash FROM expense_audit WHERE expense_id = $1 ORDER BY id ASC", expenseID)
+	if err != nil {
+		return []Entry{}, err
+	}
+	return scanEntries(rows)
+}
+
+func Feed(db *sql.DB, f FeedFilter) ([]Entry, error) {
+	where, args := f.where()
+	args = append(args, f.Limit)
+	rows, err := db.Query("SELECT id, expense_id, action, actor, request_id, changes, created_at, prev_hash, hash FROM expense_audit"+where+" ORDER BY id DESC LIMIT $"+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return []Entry{}, err
+	}
+	return scanEntries(rows)
+}
@@ -0,0 +1,93 @@
+package audit
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultFeedLimit = 100
+	maxFeedLimit     = 1000
+)
+
+type Error struct {
+	Message string `json:"message"`
+}
+
+func (h *handler) GetHistoryHandler(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Error{Message: "ID is invalid"})
+	}
+	entries, err := History(h.DB, id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Error{Message: "Unable to get history from database:" + err.Error()})
+	}
+	if viewer := VisibleOwner(c); viewer != "" {
+		owner, err := historyOwner(h.DB, id, entries)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, Error{Message: err.Error()})
+		}
+		if owner != viewer {
+			return c.JSON(http.StatusNotFound, Error{Message: "Expense not found"})
+		}
+	}
+	return c.JSON(http.StatusOK, entries)
+}
+
+// historyOwner is the owner of expense id, or whoever created it once it
+// has been deleted.
+func historyOwner(db Querier, id int, entries []Entry) (string, error) {
+	var owner sql.NullString
+	err := db.QueryRow("SELECT owner FROM expenses WHERE id = $1", id).Scan(&owner)
+	switch {
+	case err == sql.ErrNoRows:
+		if len(entries) > 0 && entries[0].Action == ActionCreate {
+			return entries[0].Actor, nil
+		}
+		return "", nil
+	case err != nil:
+		return "", err
+	}
+	return owner.String, nil
+}
+
+// GetFeedHandler lists audit entries across all expenses, newest first,
+// filtered by expense_id, actor, action, since and until (RFC 3339) and paged
+// with limit and before_id.
+func (h *handler) GetFeedHandler(c echo.Context) error {
+	f := FeedFilter{Actor: c.QueryParam("actor"), Action: c.QueryParam("action"), Limit: defaultFeedLimit}
+	var err error
+	if v := c.QueryParam("expense_id"); v != "" {
+		if f.ExpenseID, err = strconv.Atoi(v); err != nil {
+			return c.JSON(http.StatusBadRequest, Error{Message: "Query expense_id is invalid"})
+		}
+	}
+	if v := c.QueryParam("before_id"); v != "" {
+		if f.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return c.JSON(http.StatusBadRequest, Error{Message: "Query before_id is invalid"})
+		}
+	}
+	if v := c.QueryParam("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 || f.Limit > maxFeedLimit {
+			return c.JSON(http.StatusBadRequest, Error{Message: "Query limit is invalid"})
+		}
+	}
+	for name, dest := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := c.QueryParam(name); v != "" {
+			if *dest, err = time.Parse(time.RFC3339, v); err != nil {
+				return c.JSON(http.StatusBadRequest, Error{Message: "Query " + name + " is invalid"})
+			}
+		}
+	}
+
+	entries, err := Feed(h.DB, f)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Error{Message: "Unable to get audit feed from database:" + err.Error()})
+	}
+	return c.JSON(http.StatusOK, entries)
+}
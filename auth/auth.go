@@ -1,19 +1,112 @@
 package auth
 
 import (
+	"encoding/json"
+	"net/http"
 	"os"
 
 	"github.com/labstack/echo/v4"
 )
 
-func AuthMiddleware() echo.MiddlewareFunc {
+const (
+	RoleAdmin    = "admin"
+	RoleApprover = "approver"
+
+	// SystemUser is the identity behind the shared AUTHORIZATION token.
+	SystemUser = "system"
+
+	contextKey = "user"
+)
+
+// User is an authenticated caller. Currency, when set, is the reporting
+// currency of the expenses they file.
+type User struct {
+	Name     string   `json:"name"`
+	Roles    []string `json:"roles"`
+	Currency string   `json:"currency,omitempty"`
+}
+
+func (u User) HasRole(role string) bool {
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+var users = map[string]User{}
+
+// LoadUsers parses AUTH_USERS, a JSON object mapping each bearer token to the
+// user it identifies, e.g. {"s3cret":{"name":"alice","roles":["admin"]}}. It
+// is called once at startup; an empty value leaves only the shared token.
+func LoadUsers(v string) error {
+	loaded := map[string]User{}
+	if v != "" {
+		if err := json.Unmarshal([]byte(v), &loaded); err != nil {
+			return err
+		}
+	}
+	users = loaded
+	return nil
+}
+
+// Lookup identifies the caller presenting token: the shared AUTHORIZATION
+// token is the system admin, other tokens are looked up in the loaded users.
+// An empty token never matches, even when AUTHORIZATION is unset.
+func Lookup(token string) (User, bool) {
+	if token == "" {
+		return User{}, false
+	}
+	if token == os.Getenv("AUTHORIZATION") {
+		return User{Name: SystemUser, Roles: []string{RoleAdmin}}, true
+	}
+	user, ok := users[token]
+	return user, ok
+}
+
+// AuthMiddleware authenticates every request except those routed to one of
+// the public paths, given as registered with echo.
+func AuthMiddleware(public ...string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			for _, path := range public {
+				if c.Path() == path {
+					return next(c)
+				}
+			}
 			auth := c.Request().Header.Values("Authorization")
-			if auth != nil && auth[0] == os.Getenv("AUTHORIZATION") {
+			if auth == nil {
+				return echo.ErrUnauthorized
+			}
+			if user, ok := Lookup(auth[0]); ok {
+				c.Set(contextKey, user)
 				return next(c)
 			}
 			return echo.ErrUnauthorized
 		}
 	}
 }
+
+// UserFrom returns the authenticated user, or the zero User when the request
+// did not pass through AuthMiddleware.
+func UserFrom(c echo.Context) User {
+	user, _ := c.Get(contextKey).(User)
+	return user
+}
+
+// SetUser attaches user to c as AuthMiddleware does.
+func SetUser(c echo.Context, user User) {
+	c.Set(contextKey, user)
+}
+
+func RequireRole(role string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !UserFrom(c).HasRole(role) {
+				return c.JSON(http.StatusForbidden, map[string]string{"message": "Role " + role + " is required"})
+			}
+			return next(c)
+		}
+	}
+}
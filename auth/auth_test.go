@@ -0,0 +1,99 @@
+//go:build unit
+
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	t.Setenv("AUTHORIZATION", "legacy")
+	if err := LoadUsers(`{"alice-token":{"name":"alice","roles":["approver"]}}`); err != nil {
+		t.Fatalf("an error '%s' was not expected when loading users", err)
+	}
+	t.Cleanup(func() { LoadUsers("") })
+
+	cases := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"Shared token should be the system admin", "legacy", SystemUser},
+		{"User token should identify the user", "alice-token", "alice"},
+		{"Unknown token should be unauthorized", "nope", ""},
+		{"Empty token should be unauthorized", "", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", tc.token)
+			c := e.NewContext(req, httptest.NewRecorder())
+
+			var got User
+			err := AuthMiddleware()(func(c echo.Context) error {
+				got = UserFrom(c)
+				return nil
+			})(c)
+			if tc.want == "" {
+				if err != echo.ErrUnauthorized {
+					t.Errorf("should return unauthorized but it got %v", err)
+				}
+				return
+			}
+			if err != nil || got.Name != tc.want {
+				t.Errorf("should authenticate %s but it got %+v %v", tc.want, got, err)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	t.Run("Missing token should not match unset AUTHORIZATION", func(t *testing.T) {
+		t.Setenv("AUTHORIZATION", "")
+
+		if user, ok := Lookup(""); ok {
+			t.Errorf("should not authenticate but it got %+v", user)
+		}
+	})
+
+	t.Run("Load malformed users should return error", func(t *testing.T) {
+		if err := LoadUsers(`{"alice-token":`); err == nil {
+			t.Errorf("should return error but it got nil")
+		}
+	})
+}
+
+func TestAuthMiddlewarePublic(t *testing.T) {
+	t.Run("Public path should skip authentication", func(t *testing.T) {
+		e := echo.New()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/docs", nil), httptest.NewRecorder())
+		c.SetPath("/docs")
+
+		called := false
+		if err := AuthMiddleware("/docs")(func(c echo.Context) error { called = true; return nil })(c); err != nil || !called {
+			t.Errorf("should call the handler but it got %v", err)
+		}
+	})
+}
+
+func TestRequireRole(t *testing.T) {
+	t.Run("Require role should reject users without the role", func(t *testing.T) {
+		e := echo.New()
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/audit", nil), rec)
+		SetUser(c, User{Name: "alice"})
+
+		called := false
+		if err := RequireRole(RoleAdmin)(func(c echo.Context) error { called = true; return nil })(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if called || rec.Code != http.StatusForbidden || rec.Body.String() != `{"message":"Role admin is required"}`+"\n" {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+type Attachment struct {
+	ID          int       `json:"id"`
+	ExpenseID   int       `json:"expense_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (c *Client) ListAttachments(ctx context.Context, expenseID int) ([]Attachment, error) {
+	attachments := []Attachment{}
+	err := c.do(ctx, request{method: http.MethodGet, path: path("expenses", expenseID, "attachments")}, &attachments)
+	return attachments, err
+}
+
+// UploadAttachment attaches a receipt to an expense. Uploading the same file
+// again returns the existing attachment.
+func (c *Client) UploadAttachment(ctx context.Context, expenseID int, filename string, r io.Reader) (Attachment, error) {
+	body, contentType, err := upload(filename, r)
+	if err != nil {
+		return Attachment{}, err
+	}
+	a := Attachment{}
+	err = c.do(ctx, request{method: http.MethodPost, path: path("expenses", expenseID, "attachments"), body: body, contentType: contentType}, &a)
+	return a, err
+}
+
+// DownloadAttachment returns the content of an attachment. The caller closes
+// it.
+func (c *Client) DownloadAttachment(ctx context.Context, expenseID, attachmentID int) (io.ReadCloser, error) {
+	return c.download(ctx, request{method: http.MethodGet, path: path("expenses", expenseID, "attachments", attachmentID)})
+}
+
+func (c *Client) DeleteAttachment(ctx context.Context, expenseID, attachmentID int) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: path("expenses", expenseID, "attachments", attachmentID)}, nil)
+}
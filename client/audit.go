@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// AuditEntry is one audited change of an expense. Its Hash covers PrevHash,
+// the hash of the entry before it, so the trail cannot be edited unnoticed.
+type AuditEntry struct {
+	ID        int64         `json:"id"`
+	ExpenseID int           `json:"expense_id"`
+	Action    string        `json:"action"`
+	Actor     string        `json:"actor"`
+	RequestID string        `json:"request_id,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	Changes   []AuditChange `json:"changes"`
+	PrevHash  string        `json:"prev_hash,omitempty"`
+	Hash      string        `json:"hash,omitempty"`
+}
+
+type AuditChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// AuditFilter narrows the audit feed. Limit is the page size, at most 1000;
+// zero uses the server's default of 100.
+type AuditFilter struct {
+	ExpenseID int
+	Actor     string
+	Action    string
+	Since     time.Time
+	Until     time.Time
+	BeforeID  int64
+	Limit     int
+}
+
+const defaultAuditLimit = 100
+
+// ListAudit returns one page of the audit feed, newest first, with entries
+// older than f.BeforeID when it is set. It requires the admin role.
+func (c *Client) ListAudit(ctx context.Context, f AuditFilter) ([]AuditEntry, error) {
+	q := url.Values{}
+	if f.ExpenseID != 0 {
+		q.Set("expense_id", strconv.Itoa(f.ExpenseID))
+	}
+	setString(q, "actor", f.Actor)
+	setString(q, "action", f.Action)
+	if !f.Since.IsZero() {
+		q.Set("since", f.Since.Format(time.RFC3339))
+	}
+	if !f.Until.IsZero() {
+		q.Set("until", f.Until.Format(time.RFC3339))
+	}
+	if f.BeforeID != 0 {
+		q.Set("before_id", strconv.FormatInt(f.BeforeID, 10))
+	}
+	if f.Limit != 0 {
+		q.Set("limit", strconv.Itoa(f.Limit))
+	}
+	entries := []AuditEntry{}
+	err := c.do(ctx, request{method: http.MethodGet, path: "/audit", query: q}, &entries)
+	return entries, err
+}
+
+// AuditFeed walks the whole audit feed matching f, newest first, a page of
+// f.Limit entries at a time.
+func (c *Client) AuditFeed(f AuditFilter) *Iterator[AuditEntry] {
+	if f.Limit == 0 {
+		f.Limit = defaultAuditLimit
+	}
+	return newIterator(func(ctx context.Context) ([]AuditEntry, bool, error) {
+		entries, err := c.ListAudit(ctx, f)
+		if err != nil || len(entries) == 0 {
+			return entries, false, err
+		}
+		f.BeforeID = entries[len(entries)-1].ID
+		return entries, len(entries) == f.Limit, nil
+	})
+}
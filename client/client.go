@@ -0,0 +1,269 @@
+// Package client calls the expense API over HTTP. It keeps its own copies of
+// the server's JSON types, so programs using it do not build in the server.
+//
+//	c := client.New("https://expenses.example.com")
+//	c.Auth = client.Token(os.Getenv("EXPENSE_TOKEN"))
+//	exp, err := c.GetExpense(ctx, 42)
+//	if errors.Is(err, client.ErrNotFound) {
+//		...
+//	}
+//
+// Failed requests are retried as RetryPolicy describes, and errors returned
+// for non-2xx responses are an *Error, or one of the typed errors wrapping it
+// when the server sends details.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client sends requests to the API at BaseURL. Auth adds the credentials of
+// every attempt; HTTPClient defaults to http.DefaultClient.
+type Client struct {
+	BaseURL    string
+	Auth       Authenticator
+	HTTPClient *http.Client
+	Retry      RetryPolicy
+}
+
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), HTTPClient: http.DefaultClient, Retry: DefaultRetry}
+}
+
+// Authenticator adds credentials to a request before it is sent. It is
+// called again for every retry, so it may refresh them.
+type Authenticator interface {
+	Authenticate(r *http.Request) error
+}
+
+type AuthFunc func(r *http.Request) error
+
+func (f AuthFunc) Authenticate(r *http.Request) error {
+	return f(r)
+}
+
+// Token sends token as the Authorization header, as the server expects: the
+// shared AUTHORIZATION token or a user token from AUTH_USERS.
+func Token(token string) Authenticator {
+	return AuthFunc(func(r *http.Request) error {
+		r.Header.Set("Authorization", token)
+		return nil
+	})
+}
+
+// request is one API call. JSON, when set, is sent encoded in place of body.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	header      http.Header
+	json        any
+	body        io.Reader
+	contentType string
+}
+
+// send performs req, retrying as c.Retry allows, and returns the response
+// when its status is 2xx. The caller closes the body.
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	var body []byte
+	contentType := req.contentType
+	switch {
+	case req.json != nil:
+		b, err := json.Marshal(req.json)
+		if err != nil {
+			return nil, err
+		}
+		body, contentType = b, "application/json"
+	case req.body != nil:
+		// Buffered so that a retry can send it again.
+		b, err := io.ReadAll(req.body)
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+	target := c.BaseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	for attempt := 0; ; attempt++ {
+		var r io.Reader
+		if body != nil {
+			r = bytes.NewReader(body)
+		}
+		hr, err := http.NewRequestWithContext(ctx, req.method, target, r)
+		if err != nil {
+			return nil, err
+		}
+		for name, values := range req.header {
+			hr.Header[name] = values
+		}
+		if contentType != "" {
+			hr.Header.Set("Content-Type", contentType)
+		}
+		if c.Auth != nil {
+			if err := c.Auth.Authenticate(hr); err != nil {
+				return nil, err
+			}
+		}
+		res, err := httpClient.Do(hr)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode >= 200 && res.StatusCode < 300 {
+			return res, nil
+		}
+		err = decodeError(res)
+		res.Body.Close()
+		if attempt >= c.Retry.MaxRetries || !retryable(req.method, res.StatusCode) {
+			return nil, err
+		}
+		if err := sleep(ctx, c.Retry.delay(attempt, res.Header.Get("Retry-After"))); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// do performs req and decodes the JSON response into out, unless out is nil.
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	res, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// download performs req and returns the response body for the caller to
+// read and close.
+func (c *Client) download(ctx context.Context, req request) (io.ReadCloser, error) {
+	res, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// upload wraps the file r as the "file" field of a multipart form.
+func upload(filename string, r io.Reader) (io.Reader, string, error) {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	part, err := w.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return nil, "", err
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return &b, w.FormDataContentType(), nil
+}
+
+func path(parts ...any) string {
+	var b strings.Builder
+	for _, p := range parts {
+		b.WriteByte('/')
+		switch p := p.(type) {
+		case int:
+			b.WriteString(strconv.Itoa(p))
+		case int64:
+			b.WriteString(strconv.FormatInt(p, 10))
+		case string:
+			b.WriteString(url.PathEscape(p))
+		}
+	}
+	return b.String()
+}
+
+// setBool sets name to true in q when v is; false is the server's default.
+func setBool(q url.Values, name string, v bool) {
+	if v {
+		q.Set(name, "true")
+	}
+}
+
+func setString(q url.Values, name, v string) {
+	if v != "" {
+		q.Set(name, v)
+	}
+}
+
+type Health struct {
+	Status    string `json:"status"`
+	Timestamp string `json:"timestamp"`
+}
+
+func (c *Client) Health(ctx context.Context) (Health, error) {
+	h := Health{}
+	err := c.do(ctx, request{method: http.MethodGet, path: "/health"}, &h)
+	return h, err
+}
+
+// OpenAPI returns the OpenAPI document of the server.
+func (c *Client) OpenAPI(ctx context.Context) (json.RawMessage, error) {
+	var doc json.RawMessage
+	err := c.do(ctx, request{method: http.MethodGet, path: "/openapi.json"}, &doc)
+	return doc, err
+}
+
+type GraphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// GraphQLResult is the response to a GraphQL request. Data is left encoded
+// for the caller to decode into the shape of its query.
+type GraphQLResult struct {
+	Data       json.RawMessage `json:"data,omitempty"`
+	Errors     []GraphQLError  `json:"errors,omitempty"`
+	Extensions map[string]any  `json:"extensions,omitempty"`
+}
+
+type GraphQLError struct {
+	Message    string         `json:"message"`
+	Path       []any          `json:"path,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+// GraphQL runs a query or mutation. Errors raised while running it are in
+// the result; a document the server refuses is an *Error, and the result
+// then holds the reasons.
+func (c *Client) GraphQL(ctx context.Context, req GraphQLRequest) (GraphQLResult, error) {
+	result := GraphQLResult{}
+	err := c.do(ctx, request{method: http.MethodPost, path: "/graphql", json: req}, &result)
+	if e, ok := asError(err); ok && e.StatusCode == http.StatusBadRequest {
+		_ = json.Unmarshal(e.Body, &result)
+	}
+	return result, err
+}
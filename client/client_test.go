@@ -0,0 +1,366 @@
+//go:build unit
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go/build"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/attachment"
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/auth"
+	"github.com/phanbanchong/assessment/expense"
+	"github.com/phanbanchong/assessment/graph"
+	"github.com/phanbanchong/assessment/group"
+	"github.com/phanbanchong/assessment/health"
+	"github.com/phanbanchong/assessment/openapi"
+	"github.com/phanbanchong/assessment/outbox"
+	"github.com/phanbanchong/assessment/policy"
+	"github.com/phanbanchong/assessment/recurring"
+	"github.com/phanbanchong/assessment/report"
+	"github.com/phanbanchong/assessment/stream"
+	"github.com/phanbanchong/assessment/webhook"
+)
+
+var expenseRows = []string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}
+
+// server serves the real expense and audit handlers backed by a mock
+// database, behind the given middleware.
+func server(t *testing.T, middleware ...echo.MiddlewareFunc) (*Client, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	h := expense.NewApplication(db)
+	e := echo.New()
+	e.Use(middleware...)
+	e.GET("/expenses", h.GetExpensesHandler)
+	e.GET("/expenses/:id", h.GetExpenseHandler)
+	e.POST("/expenses", h.CreateExpenseHandler)
+	e.POST("/expenses/import", h.ImportExpensesHandler)
+	e.GET("/audit", audit.NewApplication(db).GetFeedHandler)
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+	c := New(srv.URL + "/")
+	c.Retry = RetryPolicy{MaxRetries: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	return c, mock
+}
+
+// flaky fails the first n requests with status.
+func flaky(n int, status int, calls *int) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			*calls++
+			if *calls <= n {
+				return c.JSON(status, expense.Error{Message: http.StatusText(status)})
+			}
+			return next(c)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	t.Run("Get expense should decode the expense", func(t *testing.T) {
+		c, mock := server(t)
+		mock.ExpectPrepare("SELECT (.+) FROM expenses WHERE id = \\$1").ExpectQuery().WithArgs(2).
+			WillReturnRows(sqlmock.NewRows(expenseRows).AddRow(2, "taxi", 12.5, "", pq.Array([]string{"travel"}), nil, nil, nil, "draft", "anonymous", nil, nil, nil, nil, nil, nil))
+
+		exp, err := c.GetExpense(context.Background(), 2)
+
+		want := Expense{ID: 2, Title: "taxi", Amount: 12.5, Tags: []string{"travel"}, Status: "draft", Owner: "anonymous"}
+		if err != nil || !reflect.DeepEqual(exp, want) {
+			t.Errorf("should get %+v but it got %+v %v", want, exp, err)
+		}
+	})
+
+	t.Run("Get missing expense should return the server's error", func(t *testing.T) {
+		c, mock := server(t)
+		mock.ExpectPrepare("SELECT (.+) FROM expenses WHERE id = \\$1").ExpectQuery().WithArgs(3).
+			WillReturnRows(sqlmock.NewRows(expenseRows))
+
+		_, err := c.GetExpense(context.Background(), 3)
+
+		var e *Error
+		if !errors.As(err, &e) || e.StatusCode != http.StatusNotFound || e.Message != "Expense not found" {
+			t.Errorf("should return not found but it got %v", err)
+		}
+	})
+
+	t.Run("List expenses should send the filter", func(t *testing.T) {
+		c, mock := server(t)
+		minAmount := 10.0
+		mock.ExpectPrepare("SELECT (.+) FROM expenses WHERE (.+) ORDER BY id ASC").ExpectQuery().
+			WithArgs(pq.Array([]string{"travel"}), minAmount, "anonymous").
+			WillReturnRows(sqlmock.NewRows(expenseRows))
+
+		expenses, err := c.ListExpenses(context.Background(), Filter{Tags: []string{"travel"}, MinAmount: &minAmount})
+
+		if err != nil || len(expenses) != 0 {
+			t.Errorf("should list no expenses but it got %+v %v", expenses, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Import with invalid rows should return the row errors", func(t *testing.T) {
+		c, _ := server(t)
+
+		result, err := c.ImportExpenses(context.Background(), strings.NewReader("title,amount\ntaxi,abc\n"), ImportOptions{})
+
+		var e *Error
+		if !errors.As(err, &e) || e.StatusCode != http.StatusUnprocessableEntity || len(result.Errors) != 1 || result.Errors[0].Row != 2 {
+			t.Errorf("should return the row errors but it got %+v %v", result, err)
+		}
+	})
+
+	t.Run("Missing token should be unauthorized", func(t *testing.T) {
+		t.Setenv("AUTHORIZATION", "s3cret")
+		c, _ := server(t, auth.AuthMiddleware())
+
+		_, err := c.GetExpense(context.Background(), 2)
+
+		if !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("should return ErrUnauthorized but it got %v", err)
+		}
+	})
+
+	t.Run("Token should authenticate every request", func(t *testing.T) {
+		t.Setenv("AUTHORIZATION", "s3cret")
+		c, mock := server(t, auth.AuthMiddleware())
+		c.Auth = Token("s3cret")
+		mock.ExpectPrepare("SELECT (.+) FROM expenses WHERE id = \\$1").ExpectQuery().WithArgs(2).
+			WillReturnRows(sqlmock.NewRows(expenseRows).AddRow(2, "taxi", 12.5, "", pq.Array([]string{}), nil, nil, nil, "draft", "anonymous", nil, nil, nil, nil, nil, nil))
+
+		if _, err := c.GetExpense(context.Background(), 2); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+	})
+
+	t.Run("Get should retry unavailable server", func(t *testing.T) {
+		calls := 0
+		c, mock := server(t, flaky(2, http.StatusServiceUnavailable, &calls))
+		mock.ExpectPrepare("SELECT (.+) FROM expenses WHERE id = \\$1").ExpectQuery().WithArgs(2).
+			WillReturnRows(sqlmock.NewRows(expenseRows).AddRow(2, "taxi", 12.5, "", pq.Array([]string{}), nil, nil, nil, "draft", "anonymous", nil, nil, nil, nil, nil, nil))
+
+		exp, err := c.GetExpense(context.Background(), 2)
+
+		if err != nil || exp.ID != 2 || calls != 3 {
+			t.Errorf("should get the expense on the third call but it got %+v %v after %d calls", exp, err, calls)
+		}
+	})
+
+	t.Run("Get should give up after max retries", func(t *testing.T) {
+		calls := 0
+		c, _ := server(t, flaky(10, http.StatusBadGateway, &calls))
+
+		_, err := c.GetExpense(context.Background(), 2)
+
+		if e, ok := asError(err); !ok || e.StatusCode != http.StatusBadGateway || calls != 4 {
+			t.Errorf("should return bad gateway after 4 calls but it got %v after %d calls", err, calls)
+		}
+	})
+
+	t.Run("Create should not retry internal server error", func(t *testing.T) {
+		calls := 0
+		c, _ := server(t, flaky(1, http.StatusInternalServerError, &calls))
+
+		_, err := c.CreateExpense(context.Background(), Expense{Title: "taxi", Amount: 12.5}, false)
+
+		if e, ok := asError(err); !ok || e.StatusCode != http.StatusInternalServerError || calls != 1 {
+			t.Errorf("should return internal server error after 1 call but it got %v after %d calls", err, calls)
+		}
+	})
+
+	t.Run("Create likely duplicate should return the candidates", func(t *testing.T) {
+		c, mock := server(t)
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE owner = (.+) AND date BETWEEN").
+			WithArgs("anonymous", "THB", 250.0, 2.5, "2026-01-02", "2026-01-08").
+			WillReturnRows(sqlmock.NewRows(expenseRows).
+				AddRow(4, "taxi airport", 251.0, "", pq.Array([]string{}), time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC), nil, nil, "draft", "anonymous", nil, nil, nil, nil, nil, nil))
+		mock.ExpectRollback()
+
+		_, err := c.CreateExpense(context.Background(), Expense{Title: "Taxi to airport", Amount: 250, Tags: []string{}, Date: "2026-01-05"}, false)
+
+		var de *DuplicateError
+		if !errors.As(err, &de) || len(de.Candidates) != 1 || de.Candidates[0].ID != 4 || !errors.Is(err, ErrConflict) {
+			t.Errorf("should return the duplicate candidates but it got %v", err)
+		}
+	})
+
+	t.Run("Request against the contract should return the violations", func(t *testing.T) {
+		c, _ := server(t, openapi.Validator(openapi.Validation{Requests: true}))
+
+		_, err := c.ListExpenses(context.Background(), Filter{From: "05/01/2026"})
+
+		var ve *ValidationError
+		if !errors.As(err, &ve) || len(ve.Violations) != 1 || ve.Violations[0].Pointer != "/from" || !errors.Is(err, ErrBadRequest) {
+			t.Errorf("should return the violations but it got %v", err)
+		}
+	})
+
+	t.Run("Audit feed should page with before_id", func(t *testing.T) {
+		c, mock := server(t)
+		columns := []string{"id", "expense_id", "action", "actor", "request_id", "changes", "created_at", "prev_hash", "hash"}
+		at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		mock.ExpectQuery("SELECT (.+) FROM expense_audit WHERE actor = \\$1 ORDER BY id DESC LIMIT \\$2").WithArgs("alice", 2).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(5, 1, "update", "alice", nil, []byte("[]"), at, nil, nil).
+				AddRow(4, 1, "create", "alice", nil, []byte("[]"), at, nil, nil))
+		mock.ExpectQuery("SELECT (.+) FROM expense_audit WHERE actor = \\$1 AND id < \\$2 ORDER BY id DESC LIMIT \\$3").WithArgs("alice", 4, 2).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(3, 2, "create", "alice", nil, []byte("[]"), at, nil, nil))
+
+		ids := []int64{}
+		it := c.AuditFeed(AuditFilter{Actor: "alice", Limit: 2})
+		for it.Next(context.Background()) {
+			ids = append(ids, it.Value().ID)
+		}
+
+		if it.Err() != nil || !reflect.DeepEqual(ids, []int64{5, 4, 3}) {
+			t.Errorf("should walk every entry but it got %v %v", ids, it.Err())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestStreamExpenses(t *testing.T) {
+	t.Run("Stream should decode replayed events", func(t *testing.T) {
+		hub := stream.NewHub(10)
+		t.Cleanup(hub.Close)
+		for i := int64(1); i <= 2; i++ {
+			data, _ := json.Marshal(expense.Expense{ID: int(i), Title: "taxi", Tags: []string{}, Owner: "anonymous"})
+			hub.Publish(context.Background(), outbox.Message{ID: i, Event: expense.EventCreated, ExpenseID: int(i), Data: data})
+		}
+		e := echo.New()
+		e.GET("/expenses/stream", stream.NewApplication(hub).StreamExpensesHandler)
+		srv := httptest.NewServer(e)
+		t.Cleanup(srv.Close)
+
+		s, err := New(srv.URL).StreamExpenses(context.Background(), Filter{}, 1)
+		if err != nil {
+			t.Fatalf("should not return error but it got %v", err)
+		}
+		defer s.Close()
+
+		if !s.Next() {
+			t.Fatalf("should read an event but it got %v", s.Err())
+		}
+		ev := s.Event()
+		if ev.ID != 2 || ev.Type != expense.EventCreated || ev.Expense.ID != 2 || ev.Expense.Title != "taxi" || s.LastEventID() != 2 {
+			t.Errorf("event was not expected got: %+v", ev)
+		}
+	})
+}
+
+// jsonFields maps the JSON names of the fields of t, including those of
+// embedded structs, to their kinds.
+func jsonFields(t reflect.Type) map[string]reflect.Kind {
+	fields := map[string]reflect.Kind{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		if f.Anonymous {
+			for name, kind := range jsonFields(f.Type) {
+				fields[name] = kind
+			}
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type.Kind()
+	}
+	return fields
+}
+
+func TestWireTypes(t *testing.T) {
+	pairs := []struct {
+		client, server any
+	}{
+		{Expense{}, expense.Expense{}},
+		{Filter{}, expense.Filter{}},
+		{ImportResult{}, expense.ImportResult{}},
+		{BatchResult{}, expense.BatchResult{}},
+		{StatementResult{}, expense.StatementResult{}},
+		{StatementEntry{}, expense.StatementEntry{}},
+		{DuplicateGroup{}, expense.DuplicateGroup{}},
+		{DuplicateCandidate{}, expense.DuplicateCandidate{}},
+		{TransitionRequest{}, expense.TransitionRequest{}},
+		{TransitionResponse{}, expense.TransitionResponse{}},
+		{TransitionRecord{}, expense.TransitionRecord{}},
+		{BatchRequest{}, expense.BatchRequest{}},
+		{BatchOperation{}, expense.BatchOperation{}},
+		{BatchResponse{}, expense.BatchResponse{}},
+		{BatchItemResult{}, expense.BatchItemResult{}},
+		{SyncResponse{}, expense.SyncResponse{}},
+		{SyncExpense{}, expense.SyncExpense{}},
+		{Tombstone{}, expense.Tombstone{}},
+		{PushRequest{}, expense.PushRequest{}},
+		{SyncChange{}, expense.SyncChange{}},
+		{PushResponse{}, expense.PushResponse{}},
+		{PushResult{}, expense.PushResult{}},
+		{Finding{}, policy.Finding{}},
+		{Violation{}, openapi.Violation{}},
+		{AuditEntry{}, audit.Entry{}},
+		{AuditChange{}, audit.Change{}},
+		{AuditFilter{}, audit.FeedFilter{}},
+		{Attachment{}, attachment.Attachment{}},
+		{Report{}, report.Report{}},
+		{ReportItem{}, report.Item{}},
+		{CreateReportRequest{}, report.CreateRequest{}},
+		{Group{}, group.Group{}},
+		{CreateGroupRequest{}, group.CreateRequest{}},
+		{MemberRequest{}, group.MemberRequest{}},
+		{GroupExpense{}, group.Expense{}},
+		{GroupExpenseRequest{}, group.ExpenseRequest{}},
+		{Split{}, group.Split{}},
+		{Balance{}, group.Balance{}},
+		{SettleUp{}, group.SettleUp{}},
+		{Transfer{}, group.Transfer{}},
+		{Settlement{}, group.Settlement{}},
+		{Subscription{}, webhook.Subscription{}},
+		{Delivery{}, webhook.Delivery{}},
+		{DeliveryAttempt{}, webhook.Attempt{}},
+		{RecurringTemplate{}, recurring.Template{}},
+		{Health{}, health.Health{}},
+		{GraphQLRequest{}, graph.Request{}},
+	}
+	for _, p := range pairs {
+		c, s := reflect.TypeOf(p.client), reflect.TypeOf(p.server)
+		if got, want := jsonFields(c), jsonFields(s); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s should match %s but it got %v, want %v", c, s, got, want)
+		}
+	}
+}
+
+func TestImports(t *testing.T) {
+	pkg, err := build.ImportDir(".", 0)
+	if err != nil {
+		t.Fatalf("should not return error but it got %v", err)
+	}
+	for _, path := range pkg.Imports {
+		if strings.Contains(path, ".") {
+			t.Errorf("should only import the standard library but it imports %s", path)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Errors to match with errors.Is against any error for a response with the
+// status they name.
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrRateLimited  = errors.New("rate limited")
+)
+
+var statusErrors = map[int]error{
+	http.StatusBadRequest:      ErrBadRequest,
+	http.StatusUnauthorized:    ErrUnauthorized,
+	http.StatusForbidden:       ErrForbidden,
+	http.StatusNotFound:        ErrNotFound,
+	http.StatusConflict:        ErrConflict,
+	http.StatusTooManyRequests: ErrRateLimited,
+}
+
+// Error is a response with a non-2xx status. Message is the message of the
+// server's error body, or the status text when the body has none; RequestID
+// is the X-Request-Id the server logged the request under.
+type Error struct {
+	StatusCode int
+	Message    string
+	RequestID  string
+	Body       []byte
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s", e.StatusCode, e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	return statusErrors[e.StatusCode] == target
+}
+
+// DuplicateError is the 409 of an expense that looks like one its owner
+// already filed. Create it anyway with force.
+type DuplicateError struct {
+	Err        *Error
+	Candidates []DuplicateCandidate
+}
+
+func (e *DuplicateError) Error() string { return e.Err.Error() }
+func (e *DuplicateError) Unwrap() error { return e.Err }
+
+// PolicyError is the 422 of an expense that breaks a policy rule with error
+// severity.
+type PolicyError struct {
+	Err           *Error
+	PolicyVersion int
+	Violations    []Finding
+}
+
+func (e *PolicyError) Error() string { return e.Err.Error() }
+func (e *PolicyError) Unwrap() error { return e.Err }
+
+// ValidationError is the 400 of a request that does not match the OpenAPI
+// document, when the server validates requests.
+type ValidationError struct {
+	Err        *Error
+	Violations []Violation
+}
+
+// Violation is a part of a request that does not match the OpenAPI
+// document. Pointer locates it in the part named by In.
+type Violation struct {
+	In      string `json:"in"`
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+func (e *ValidationError) Error() string { return e.Err.Error() }
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// decodeError reads the error body of res into the most specific error type.
+func decodeError(res *http.Response) error {
+	b, _ := io.ReadAll(res.Body)
+	e := &Error{StatusCode: res.StatusCode, RequestID: res.Header.Get("X-Request-Id"), Body: b, Message: http.StatusText(res.StatusCode)}
+	var body struct {
+		Message       string               `json:"message"`
+		Candidates    []DuplicateCandidate `json:"candidates"`
+		PolicyVersion *int                 `json:"policy_version"`
+		Violations    json.RawMessage      `json:"violations"`
+		// GraphQL reports refused documents as a result with errors.
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(b, &body) != nil {
+		return e
+	}
+	switch {
+	case body.Message != "":
+		e.Message = body.Message
+	case len(body.Errors) > 0 && body.Errors[0].Message != "":
+		e.Message = body.Errors[0].Message
+	}
+	switch {
+	case res.StatusCode == http.StatusConflict && body.Candidates != nil:
+		return &DuplicateError{Err: e, Candidates: body.Candidates}
+	case res.StatusCode == http.StatusUnprocessableEntity && body.PolicyVersion != nil:
+		pe := &PolicyError{Err: e, PolicyVersion: *body.PolicyVersion}
+		_ = json.Unmarshal(body.Violations, &pe.Violations)
+		return pe
+	case res.StatusCode == http.StatusBadRequest && body.Violations != nil:
+		ve := &ValidationError{Err: e}
+		_ = json.Unmarshal(body.Violations, &ve.Violations)
+		return ve
+	}
+	return e
+}
+
+func asError(err error) (*Error, bool) {
+	var e *Error
+	ok := errors.As(err, &e)
+	return e, ok
+}
@@ -0,0 +1,365 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"time"
+)
+
+// Expense states and the actions that move an expense between them.
+const (
+	StatusDraft      = "draft"
+	StatusSubmitted  = "submitted"
+	StatusApproved   = "approved"
+	StatusRejected   = "rejected"
+	StatusReimbursed = "reimbursed"
+
+	ActionSubmit    = "submit"
+	ActionApprove   = "approve"
+	ActionReject    = "reject"
+	ActionReimburse = "reimburse"
+)
+
+// TagSeparator joins tags in one cell of a CSV import or export.
+const TagSeparator = ";"
+
+type Expense struct {
+	ID          int       `json:"id"`
+	Title       string    `json:"title"`
+	Amount      float64   `json:"amount"`
+	Note        string    `json:"note"`
+	Tags        []string  `json:"tags"`
+	Date        string    `json:"date,omitempty"`
+	RecurringID int       `json:"recurring_id,omitempty"`
+	ExternalRef string    `json:"external_ref,omitempty"`
+	Status      string    `json:"status,omitempty"`
+	Owner       string    `json:"owner,omitempty"`
+	ReportID    int       `json:"report_id,omitempty"`
+	Warnings    []Finding `json:"warnings,omitempty"`
+
+	Currency          string  `json:"currency,omitempty"`
+	ReportingAmount   float64 `json:"reporting_amount,omitempty"`
+	ReportingCurrency string  `json:"reporting_currency,omitempty"`
+	Rate              float64 `json:"rate,omitempty"`
+	RateDate          string  `json:"rate_date,omitempty"`
+}
+
+// Finding is a policy rule an expense breaks. Warnings are saved with the
+// expense; errors refuse it with a *PolicyError.
+type Finding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// Filter narrows the expenses listed, exported or streamed. Empty fields
+// match everything.
+type Filter struct {
+	Tags      []string
+	From      string
+	To        string
+	MinAmount *float64
+	MaxAmount *float64
+	Query     string
+	Status    string
+	Owner     string
+}
+
+type ImportResult struct {
+	DryRun   bool          `json:"dry_run"`
+	Rows     int           `json:"rows"`
+	Imported int           `json:"imported"`
+	Errors   []RowError    `json:"errors"`
+	Batches  []BatchResult `json:"batches,omitempty"`
+}
+
+type RowError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+// BatchResult is the outcome of the import rows from FirstRow to LastRow,
+// which were imported in one transaction.
+type BatchResult struct {
+	FirstRow int    `json:"first_row"`
+	LastRow  int    `json:"last_row"`
+	Imported int    `json:"imported"`
+	Error    string `json:"error,omitempty"`
+}
+
+type StatementResult struct {
+	DryRun   bool             `json:"dry_run"`
+	Format   string           `json:"format"`
+	Account  string           `json:"account,omitempty"`
+	Currency string           `json:"currency,omitempty"`
+	Imported int              `json:"imported"`
+	Entries  []StatementEntry `json:"entries"`
+}
+
+// StatementEntry is one statement line. Candidates are the IDs of expenses
+// it may duplicate; Violations are the policy rules that kept the statement
+// from being imported.
+type StatementEntry struct {
+	Expense    Expense   `json:"expense"`
+	Status     string    `json:"status"`
+	Candidates []int     `json:"candidates,omitempty"`
+	Violations []Finding `json:"violations,omitempty"`
+}
+
+// DuplicateGroup is a set of expenses that look like the same receipt.
+type DuplicateGroup struct {
+	Owner    string    `json:"owner,omitempty"`
+	Expenses []Expense `json:"expenses"`
+}
+
+type DuplicateCandidate struct {
+	ID     int     `json:"id"`
+	Title  string  `json:"title"`
+	Amount float64 `json:"amount"`
+	Date   string  `json:"date,omitempty"`
+	Href   string  `json:"href"`
+}
+
+type TransitionRecord struct {
+	ID        int       `json:"id"`
+	ExpenseID int       `json:"expense_id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Actor     string    `json:"actor"`
+	Reason    string    `json:"reason,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type TransitionRequest struct {
+	Reason string `json:"reason"`
+}
+
+type TransitionResponse struct {
+	Expense    Expense          `json:"expense"`
+	Transition TransitionRecord `json:"transition"`
+}
+
+// Batch operations.
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchOperation creates Expense, or updates or deletes the expense ID.
+type BatchOperation struct {
+	Op      string  `json:"op"`
+	ID      int     `json:"id,omitempty"`
+	Expense Expense `json:"expense"`
+}
+
+type BatchResponse struct {
+	Atomic  bool              `json:"atomic"`
+	Results []BatchItemResult `json:"results"`
+}
+
+// BatchItemResult is the outcome of the operation at Index, with the status
+// it would have had as a request of its own.
+type BatchItemResult struct {
+	Index   int      `json:"index"`
+	Op      string   `json:"op"`
+	Status  int      `json:"status"`
+	Expense *Expense `json:"expense,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// filterQuery encodes f as the query parameters ParseFilter reads.
+func filterQuery(f Filter) url.Values {
+	q := url.Values{}
+	for _, tag := range f.Tags {
+		q.Add("tag", tag)
+	}
+	setString(q, "from", f.From)
+	setString(q, "to", f.To)
+	setString(q, "q", f.Query)
+	setString(q, "status", f.Status)
+	setString(q, "owner", f.Owner)
+	if f.MinAmount != nil {
+		q.Set("min_amount", strconv.FormatFloat(*f.MinAmount, 'f', -1, 64))
+	}
+	if f.MaxAmount != nil {
+		q.Set("max_amount", strconv.FormatFloat(*f.MaxAmount, 'f', -1, 64))
+	}
+	return q
+}
+
+func (c *Client) ListExpenses(ctx context.Context, f Filter) ([]Expense, error) {
+	expenses := []Expense{}
+	err := c.do(ctx, request{method: http.MethodGet, path: "/expenses", query: filterQuery(f)}, &expenses)
+	return expenses, err
+}
+
+// ListDuplicates lists groups of visible expenses that look like the same
+// receipt.
+func (c *Client) ListDuplicates(ctx context.Context) ([]DuplicateGroup, error) {
+	groups := []DuplicateGroup{}
+	err := c.do(ctx, request{method: http.MethodGet, path: "/expenses/duplicates"}, &groups)
+	return groups, err
+}
+
+func (c *Client) GetExpense(ctx context.Context, id int) (Expense, error) {
+	exp := Expense{}
+	err := c.do(ctx, request{method: http.MethodGet, path: path("expenses", id)}, &exp)
+	return exp, err
+}
+
+// GetExpenseHistory lists the audit trail of an expense, oldest first.
+func (c *Client) GetExpenseHistory(ctx context.Context, id int) ([]AuditEntry, error) {
+	entries := []AuditEntry{}
+	err := c.do(ctx, request{method: http.MethodGet, path: path("expenses", id, "history")}, &entries)
+	return entries, err
+}
+
+func (c *Client) GetTransitions(ctx context.Context, id int) ([]TransitionRecord, error) {
+	records := []TransitionRecord{}
+	err := c.do(ctx, request{method: http.MethodGet, path: path("expenses", id, "transitions")}, &records)
+	return records, err
+}
+
+// CreateExpense creates exp. A likely duplicate is refused with a
+// *DuplicateError unless force is set.
+func (c *Client) CreateExpense(ctx context.Context, exp Expense, force bool) (Expense, error) {
+	q := url.Values{}
+	setBool(q, "force", force)
+	created := Expense{}
+	err := c.do(ctx, request{method: http.MethodPost, path: "/expenses", query: q, json: exp}, &created)
+	return created, err
+}
+
+func (c *Client) UpdateExpense(ctx context.Context, exp Expense) (Expense, error) {
+	updated := Expense{}
+	err := c.do(ctx, request{method: http.MethodPut, path: path("expenses", exp.ID), json: exp}, &updated)
+	return updated, err
+}
+
+func (c *Client) DeleteExpense(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: path("expenses", id)}, nil)
+}
+
+// BatchExpenses applies the operations of req. Atomic applies all of them
+// or none.
+func (c *Client) BatchExpenses(ctx context.Context, req BatchRequest, atomic bool) (BatchResponse, error) {
+	q := url.Values{}
+	setBool(q, "atomic", atomic)
+	resp := BatchResponse{}
+	err := c.do(ctx, request{method: http.MethodPost, path: "/expenses:batch", query: q, json: req}, &resp)
+	return resp, err
+}
+
+func (c *Client) transition(ctx context.Context, id int, action, reason string) (TransitionResponse, error) {
+	resp := TransitionResponse{}
+	err := c.do(ctx, request{method: http.MethodPost, path: path("expenses", id, action), json: TransitionRequest{Reason: reason}}, &resp)
+	return resp, err
+}
+
+func (c *Client) SubmitExpense(ctx context.Context, id int, reason string) (TransitionResponse, error) {
+	return c.transition(ctx, id, ActionSubmit, reason)
+}
+
+func (c *Client) ApproveExpense(ctx context.Context, id int, reason string) (TransitionResponse, error) {
+	return c.transition(ctx, id, ActionApprove, reason)
+}
+
+// RejectExpense rejects a submitted expense; the server requires a reason.
+func (c *Client) RejectExpense(ctx context.Context, id int, reason string) (TransitionResponse, error) {
+	return c.transition(ctx, id, ActionReject, reason)
+}
+
+func (c *Client) ReimburseExpense(ctx context.Context, id int, reason string) (TransitionResponse, error) {
+	return c.transition(ctx, id, ActionReimburse, reason)
+}
+
+// ImportOptions tunes a CSV import. Columns maps expense fields to the CSV
+// headers holding them when those differ from the field names.
+type ImportOptions struct {
+	DryRun    bool
+	BatchSize int
+	Columns   map[string]string
+}
+
+// ImportExpenses uploads a CSV of expenses. When rows are invalid the result
+// lists them next to the 422 *Error.
+func (c *Client) ImportExpenses(ctx context.Context, csv io.Reader, opts ImportOptions) (ImportResult, error) {
+	q := url.Values{}
+	setBool(q, "dry_run", opts.DryRun)
+	if opts.BatchSize > 0 {
+		q.Set("batch_size", strconv.Itoa(opts.BatchSize))
+	}
+	for field, column := range opts.Columns {
+		q.Set("column."+field, column)
+	}
+	result := ImportResult{}
+	err := c.do(ctx, request{method: http.MethodPost, path: "/expenses/import", query: q, body: csv, contentType: "text/csv"}, &result)
+	if e, ok := asError(err); ok && e.StatusCode == http.StatusUnprocessableEntity {
+		_ = json.Unmarshal(e.Body, &result)
+	}
+	return result, err
+}
+
+// StatementOptions tunes a bank statement import. Format is detected from
+// the file name and content when empty.
+type StatementOptions struct {
+	Format            string
+	DryRun            bool
+	IncludeCredits    bool
+	IncludeDuplicates bool
+}
+
+// ImportStatement uploads an OFX/QFX, QIF or camt.053 bank statement.
+func (c *Client) ImportStatement(ctx context.Context, filename string, r io.Reader, opts StatementOptions) (StatementResult, error) {
+	q := url.Values{}
+	setString(q, "format", opts.Format)
+	setBool(q, "dry_run", opts.DryRun)
+	setBool(q, "include_credits", opts.IncludeCredits)
+	setBool(q, "include_duplicates", opts.IncludeDuplicates)
+	body, contentType, err := upload(filename, r)
+	if err != nil {
+		return StatementResult{}, err
+	}
+	result := StatementResult{}
+	err = c.do(ctx, request{method: http.MethodPost, path: "/expenses/import/statement", query: q, body: body, contentType: contentType}, &result)
+	return result, err
+}
+
+// ExportExpenses downloads the expenses matching f as csv, jsonl or xlsx.
+// The caller closes the returned body.
+func (c *Client) ExportExpenses(ctx context.Context, f Filter, format string) (io.ReadCloser, error) {
+	q := filterQuery(f)
+	setString(q, "format", format)
+	return c.download(ctx, request{method: http.MethodGet, path: "/expenses/export", query: q})
+}
+
+// LedgerOptions selects the journal format and accounts of a ledger export.
+// Empty fields use the server's defaults.
+type LedgerOptions struct {
+	Format         string
+	DefaultAccount string
+	FundingAccount string
+	Currency       string
+}
+
+// ExportLedger downloads the expenses matching f as a plain-text accounting
+// journal. The caller closes the returned body.
+func (c *Client) ExportLedger(ctx context.Context, f Filter, opts LedgerOptions) (io.ReadCloser, error) {
+	q := filterQuery(f)
+	setString(q, "format", opts.Format)
+	setString(q, "default_account", opts.DefaultAccount)
+	setString(q, "funding_account", opts.FundingAccount)
+	setString(q, "currency", opts.Currency)
+	return c.download(ctx, request{method: http.MethodGet, path: "/expenses/export/ledger", query: q})
+}
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Group is a set of members sharing expenses. Every amount in a group is in
+// its currency.
+type Group struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Currency  string    `json:"currency"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	Members   []string  `json:"members"`
+}
+
+type CreateGroupRequest struct {
+	Name     string   `json:"name"`
+	Currency string   `json:"currency"`
+	Members  []string `json:"members"`
+}
+
+type MemberRequest struct {
+	Member string `json:"member"`
+}
+
+// GroupExpense is an expense paid by PaidBy and shared between the members
+// in Shares.
+type GroupExpense struct {
+	Expense Expense            `json:"expense"`
+	PaidBy  string             `json:"paid_by"`
+	Method  string             `json:"method"`
+	Amount  float64            `json:"amount"`
+	Shares  map[string]float64 `json:"shares"`
+}
+
+// GroupExpenseRequest adds Expense to a group. PaidBy defaults to the
+// caller and Split to an equal split between every member.
+type GroupExpenseRequest struct {
+	Expense Expense `json:"expense"`
+	PaidBy  string  `json:"paid_by"`
+	Split   Split   `json:"split"`
+}
+
+// Split methods.
+const (
+	SplitEqual      = "equal"
+	SplitExact      = "exact"
+	SplitPercentage = "percentage"
+	SplitShares     = "shares"
+)
+
+// Split says how an expense is divided. Equal splits use Members, or every
+// group member when it is empty; the other methods take a value per member
+// in Values: the amount owed, a percentage or a number of shares.
+type Split struct {
+	Method  string             `json:"method"`
+	Members []string           `json:"members,omitempty"`
+	Values  map[string]float64 `json:"values,omitempty"`
+}
+
+// Balance is what a member is owed, or owes when it is negative.
+type Balance struct {
+	Member  string  `json:"member"`
+	Balance float64 `json:"balance"`
+}
+
+type SettleUp struct {
+	Currency  string     `json:"currency"`
+	Transfers []Transfer `json:"transfers"`
+}
+
+type Transfer struct {
+	From   string  `json:"from"`
+	To     string  `json:"to"`
+	Amount float64 `json:"amount"`
+}
+
+type Settlement struct {
+	ID        int       `json:"id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Amount    float64   `json:"amount"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListGroups lists the groups the caller is a member of.
+func (c *Client) ListGroups(ctx context.Context) ([]Group, error) {
+	groups := []Group{}
+	err := c.do(ctx, request{method: http.MethodGet, path: "/groups"}, &groups)
+	return groups, err
+}
+
+func (c *Client) GetGroup(ctx context.Context, id int) (Group, error) {
+	g := Group{}
+	err := c.do(ctx, request{method: http.MethodGet, path: path("groups", id)}, &g)
+	return g, err
+}
+
+func (c *Client) CreateGroup(ctx context.Context, req CreateGroupRequest) (Group, error) {
+	g := Group{}
+	err := c.do(ctx, request{method: http.MethodPost, path: "/groups", json: req}, &g)
+	return g, err
+}
+
+func (c *Client) AddMember(ctx context.Context, id int, req MemberRequest) (Group, error) {
+	g := Group{}
+	err := c.do(ctx, request{method: http.MethodPost, path: path("groups", id, "members"), json: req}, &g)
+	return g, err
+}
+
+// RemoveMember removes a member whose balance is settled.
+func (c *Client) RemoveMember(ctx context.Context, id int, member string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: path("groups", id, "members", member)}, nil)
+}
+
+func (c *Client) ListGroupExpenses(ctx context.Context, id int) ([]GroupExpense, error) {
+	expenses := []GroupExpense{}
+	err := c.do(ctx, request{method: http.MethodGet, path: path("groups", id, "expenses")}, &expenses)
+	return expenses, err
+}
+
+func (c *Client) CreateGroupExpense(ctx context.Context, id int, req GroupExpenseRequest) (GroupExpense, error) {
+	exp := GroupExpense{}
+	err := c.do(ctx, request{method: http.MethodPost, path: path("groups", id, "expenses"), json: req}, &exp)
+	return exp, err
+}
+
+func (c *Client) GetBalances(ctx context.Context, id int) ([]Balance, error) {
+	balances := []Balance{}
+	err := c.do(ctx, request{method: http.MethodGet, path: path("groups", id, "balances")}, &balances)
+	return balances, err
+}
+
+// GetSettleUp suggests the fewest transfers that settle a group.
+func (c *Client) GetSettleUp(ctx context.Context, id int) (SettleUp, error) {
+	s := SettleUp{}
+	err := c.do(ctx, request{method: http.MethodGet, path: path("groups", id, "settle-up")}, &s)
+	return s, err
+}
+
+// SettleUp records the suggested transfers as settlements.
+func (c *Client) SettleUp(ctx context.Context, id int) ([]Settlement, error) {
+	settlements := []Settlement{}
+	err := c.do(ctx, request{method: http.MethodPost, path: path("groups", id, "settle-up")}, &settlements)
+	return settlements, err
+}
+
+func (c *Client) ListSettlements(ctx context.Context, id int) ([]Settlement, error) {
+	settlements := []Settlement{}
+	err := c.do(ctx, request{method: http.MethodGet, path: path("groups", id, "settlements")}, &settlements)
+	return settlements, err
+}
+
+func (c *Client) CreateSettlement(ctx context.Context, id int, s Settlement) (Settlement, error) {
+	created := Settlement{}
+	err := c.do(ctx, request{method: http.MethodPost, path: path("groups", id, "settlements"), json: s}, &created)
+	return created, err
+}
@@ -0,0 +1,53 @@
+package client
+
+import "context"
+
+// Iterator walks a paged list one item at a time, fetching the next page
+// when the current one runs out:
+//
+//	it := c.AuditFeed(client.AuditFilter{Action: "update"})
+//	for it.Next(ctx) {
+//		entry := it.Value()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iterator[T any] struct {
+	// fetch returns the next page and whether another one follows it.
+	fetch func(ctx context.Context) ([]T, bool, error)
+	page  []T
+	i     int
+	more  bool
+	value T
+	err   error
+}
+
+func newIterator[T any](fetch func(ctx context.Context) ([]T, bool, error)) *Iterator[T] {
+	return &Iterator[T]{fetch: fetch, more: true}
+}
+
+// Next advances to the next item and reports whether there is one. It
+// returns false at the end of the list or on an error.
+func (it *Iterator[T]) Next(ctx context.Context) bool {
+	for it.i >= len(it.page) {
+		if !it.more || it.err != nil {
+			return false
+		}
+		it.page, it.more, it.err = it.fetch(ctx)
+		it.i = 0
+		if it.err != nil {
+			return false
+		}
+	}
+	it.value = it.page[it.i]
+	it.i++
+	return true
+}
+
+func (it *Iterator[T]) Value() T {
+	return it.value
+}
+
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
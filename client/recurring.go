@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// RecurringTemplate generates an expense on every occurrence of its RRULE
+// from StartDate on.
+type RecurringTemplate struct {
+	ID                int      `json:"id"`
+	Title             string   `json:"title"`
+	Amount            float64  `json:"amount"`
+	Note              string   `json:"note"`
+	Tags              []string `json:"tags"`
+	Rule              string   `json:"rule"`
+	StartDate         string   `json:"start_date"`
+	NextDate          string   `json:"next_date,omitempty"`
+	Generated         int      `json:"generated"`
+	Owner             string   `json:"owner,omitempty"`
+	ReportingCurrency string   `json:"reporting_currency,omitempty"`
+}
+
+func (c *Client) ListRecurring(ctx context.Context) ([]RecurringTemplate, error) {
+	templates := []RecurringTemplate{}
+	err := c.do(ctx, request{method: http.MethodGet, path: "/recurring-expenses"}, &templates)
+	return templates, err
+}
+
+func (c *Client) GetRecurring(ctx context.Context, id int) (RecurringTemplate, error) {
+	t := RecurringTemplate{}
+	err := c.do(ctx, request{method: http.MethodGet, path: path("recurring-expenses", id)}, &t)
+	return t, err
+}
+
+// CreateRecurring creates a template that generates an expense on every
+// occurrence of its RRULE.
+func (c *Client) CreateRecurring(ctx context.Context, t RecurringTemplate) (RecurringTemplate, error) {
+	created := RecurringTemplate{}
+	err := c.do(ctx, request{method: http.MethodPost, path: "/recurring-expenses", json: t}, &created)
+	return created, err
+}
+
+func (c *Client) UpdateRecurring(ctx context.Context, t RecurringTemplate) (RecurringTemplate, error) {
+	updated := RecurringTemplate{}
+	err := c.do(ctx, request{method: http.MethodPut, path: path("recurring-expenses", t.ID), json: t}, &updated)
+	return updated, err
+}
+
+func (c *Client) DeleteRecurring(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: path("recurring-expenses", id)}, nil)
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Report bundles approved expenses to be paid out together. Total is in
+// Currency.
+type Report struct {
+	ID        int          `json:"id"`
+	Title     string       `json:"title"`
+	Submitter string       `json:"submitter"`
+	Status    string       `json:"status"`
+	Total     float64      `json:"total"`
+	CreatedBy string       `json:"created_by"`
+	CreatedAt time.Time    `json:"created_at"`
+	PaidAt    *time.Time   `json:"paid_at,omitempty"`
+	ClosedAt  *time.Time   `json:"closed_at,omitempty"`
+	Currency  string       `json:"currency,omitempty"`
+	Items     []ReportItem `json:"items,omitempty"`
+}
+
+type ReportItem struct {
+	ExpenseID      int     `json:"expense_id"`
+	Title          string  `json:"title"`
+	Date           string  `json:"date,omitempty"`
+	Amount         float64 `json:"amount"`
+	OriginalAmount float64 `json:"original_amount,omitempty"`
+	Currency       string  `json:"currency,omitempty"`
+	Rate           float64 `json:"rate,omitempty"`
+}
+
+type CreateReportRequest struct {
+	Title      string `json:"title"`
+	ExpenseIDs []int  `json:"expense_ids"`
+}
+
+// ListReports lists reports newest first, narrowed by status and submitter
+// when they are not empty.
+func (c *Client) ListReports(ctx context.Context, status, submitter string) ([]Report, error) {
+	q := url.Values{}
+	setString(q, "status", status)
+	setString(q, "submitter", submitter)
+	reports := []Report{}
+	err := c.do(ctx, request{method: http.MethodGet, path: "/reports", query: q}, &reports)
+	return reports, err
+}
+
+func (c *Client) GetReport(ctx context.Context, id int) (Report, error) {
+	r := Report{}
+	err := c.do(ctx, request{method: http.MethodGet, path: path("reports", id)}, &r)
+	return r, err
+}
+
+// ExportReport downloads a report as json or as a csv payout file. The
+// caller closes the returned body.
+func (c *Client) ExportReport(ctx context.Context, id int, format string) (io.ReadCloser, error) {
+	q := url.Values{}
+	setString(q, "format", format)
+	return c.download(ctx, request{method: http.MethodGet, path: path("reports", id, "export"), query: q})
+}
+
+// CreateReport bundles approved expenses into a report. It requires the
+// approver role, as do PayReport and CloseReport.
+func (c *Client) CreateReport(ctx context.Context, req CreateReportRequest) (Report, error) {
+	r := Report{}
+	err := c.do(ctx, request{method: http.MethodPost, path: "/reports", json: req}, &r)
+	return r, err
+}
+
+func (c *Client) PayReport(ctx context.Context, id int) (Report, error) {
+	r := Report{}
+	err := c.do(ctx, request{method: http.MethodPost, path: path("reports", id, "pay")}, &r)
+	return r, err
+}
+
+func (c *Client) CloseReport(ctx context.Context, id int) (Report, error) {
+	r := Report{}
+	err := c.do(ctx, request{method: http.MethodPost, path: path("reports", id, "close")}, &r)
+	return r, err
+}
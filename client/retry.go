@@ -0,0 +1,46 @@
+package client
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy retries a request that got 429 Too Many Requests or a 5xx,
+// waiting an exponential backoff with jitter between MinBackoff and
+// MaxBackoff, or the Retry-After the server asked for. POST requests are not
+// idempotent, so they are only retried on 429 and 503, which the server
+// sends before doing any work. MaxRetries zero turns retries off.
+type RetryPolicy struct {
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+var DefaultRetry = RetryPolicy{MaxRetries: 3, MinBackoff: 200 * time.Millisecond, MaxBackoff: 5 * time.Second}
+
+func retryable(method string, status int) bool {
+	switch {
+	case status == http.StatusTooManyRequests, status == http.StatusServiceUnavailable:
+		return true
+	case status >= http.StatusInternalServerError:
+		return method != http.MethodPost
+	}
+	return false
+}
+
+// delay is how long to wait before retry attempt+1.
+func (p RetryPolicy) delay(attempt int, retryAfter string) time.Duration {
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	d := p.MinBackoff << attempt
+	if d > p.MaxBackoff || d <= 0 {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Event is one server-sent event of the expense stream. Type is an expense
+// event such as expense.created, or reset when events were missed and the
+// list should be reloaded; reset events carry no expense.
+type Event struct {
+	ID      int64
+	Type    string
+	Expense Expense
+}
+
+// EventStream reads the expense stream. It ends when the server closes it,
+// the context of StreamExpenses is done, or Close is called.
+type EventStream struct {
+	body   io.ReadCloser
+	r      *bufio.Reader
+	event  Event
+	lastID int64
+	err    error
+}
+
+// StreamExpenses opens the stream of changes to the expenses matching f,
+// resuming after lastEventID when it is not zero.
+func (c *Client) StreamExpenses(ctx context.Context, f Filter, lastEventID int64) (*EventStream, error) {
+	header := http.Header{}
+	header.Set("Accept", "text/event-stream")
+	if lastEventID != 0 {
+		header.Set("Last-Event-ID", strconv.FormatInt(lastEventID, 10))
+	}
+	body, err := c.download(ctx, request{method: http.MethodGet, path: "/expenses/stream", query: filterQuery(f), header: header})
+	if err != nil {
+		return nil, err
+	}
+	return &EventStream{body: body, r: bufio.NewReader(body), lastID: lastEventID}, nil
+}
+
+// Next waits for the next event and reports whether there is one.
+func (s *EventStream) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	ev := Event{}
+	var data strings.Builder
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+			} else {
+				s.err = io.EOF
+			}
+			return false
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if ev.Type == "" && data.Len() == 0 {
+				continue
+			}
+			if data.Len() > 0 && ev.Type != "reset" {
+				if err := json.Unmarshal([]byte(data.String()), &ev.Expense); err != nil {
+					s.err = err
+					return false
+				}
+			}
+			if ev.ID != 0 {
+				s.lastID = ev.ID
+			}
+			s.event = ev
+			return true
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			ev.ID, _ = strconv.ParseInt(value, 10, 64)
+		case "event":
+			ev.Type = value
+		case "data":
+			data.WriteString(value)
+		}
+	}
+}
+
+func (s *EventStream) Event() Event {
+	return s.event
+}
+
+// LastEventID is the ID to resume after when the stream is opened again.
+func (s *EventStream) LastEventID() int64 {
+	return s.lastID
+}
+
+// Err returns the error that ended the stream, or nil when the server closed
+// it.
+func (s *EventStream) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
+
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type SyncResponse struct {
+	Token   string        `json:"token"`
+	More    bool          `json:"more"`
+	Changed []SyncExpense `json:"changed"`
+	Deleted []Tombstone   `json:"deleted"`
+}
+
+// SyncExpense is an expense at Version, the version to send back as the
+// BaseVersion of a change to it.
+type SyncExpense struct {
+	Expense
+	Version int64 `json:"version"`
+}
+
+type Tombstone struct {
+	ID      int   `json:"id"`
+	Version int64 `json:"version"`
+}
+
+type PushRequest struct {
+	Changes []SyncChange `json:"changes"`
+}
+
+// SyncChange is a change made offline. Without an ID it creates Expense,
+// identified by ClientID so a retried push does not create it twice; with
+// an ID it updates or deletes the expense, provided BaseVersion is still
+// current.
+type SyncChange struct {
+	ClientID    string  `json:"client_id,omitempty"`
+	ID          int     `json:"id,omitempty"`
+	BaseVersion int64   `json:"base_version,omitempty"`
+	Deleted     bool    `json:"deleted,omitempty"`
+	Expense     Expense `json:"expense"`
+}
+
+type PushResponse struct {
+	Results []PushResult `json:"results"`
+}
+
+// PushResult is the outcome of the change at Index. On a conflict Expense
+// or Deleted is the server's current version.
+type PushResult struct {
+	Index    int          `json:"index"`
+	ClientID string       `json:"client_id,omitempty"`
+	Status   int          `json:"status"`
+	Conflict bool         `json:"conflict,omitempty"`
+	Expense  *SyncExpense `json:"expense,omitempty"`
+	Deleted  *Tombstone   `json:"deleted,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// Sync lists the expenses changed and deleted after the since token, empty
+// for everything visible. Limit zero uses the server's page size. A token
+// the server does not know fails with 410 Gone: sync again from scratch.
+func (c *Client) Sync(ctx context.Context, since string, limit int) (SyncResponse, error) {
+	q := url.Values{}
+	setString(q, "since", since)
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	resp := SyncResponse{}
+	err := c.do(ctx, request{method: http.MethodGet, path: "/sync", query: q}, &resp)
+	return resp, err
+}
+
+// SyncPages pages through every change after since. Each value is one
+// response; the token of the last one is where the next sync starts.
+func (c *Client) SyncPages(since string, limit int) *Iterator[SyncResponse] {
+	return newIterator(func(ctx context.Context) ([]SyncResponse, bool, error) {
+		resp, err := c.Sync(ctx, since, limit)
+		if err != nil {
+			return nil, false, err
+		}
+		since = resp.Token
+		return []SyncResponse{resp}, resp.More, nil
+	})
+}
+
+// Push applies changes made offline. Conflicts are reported per change in
+// the response rather than as an error.
+func (c *Client) Push(ctx context.Context, req PushRequest) (PushResponse, error) {
+	resp := PushResponse{}
+	err := c.do(ctx, request{method: http.MethodPost, path: "/sync", json: req}, &resp)
+	return resp, err
+}
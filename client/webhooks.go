@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Subscription sends the events it names to URL, signed with Secret.
+type Subscription struct {
+	ID        int       `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Delivery struct {
+	ID             int64             `json:"id"`
+	SubscriptionID int               `json:"subscription_id"`
+	Event          string            `json:"event"`
+	ExpenseID      int               `json:"expense_id"`
+	Payload        json.RawMessage   `json:"payload"`
+	Status         string            `json:"status"`
+	Attempts       int               `json:"attempts"`
+	NextAttemptAt  *time.Time        `json:"next_attempt_at,omitempty"`
+	LastStatusCode int               `json:"last_status_code,omitempty"`
+	LastError      string            `json:"last_error,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	DeliveredAt    *time.Time        `json:"delivered_at,omitempty"`
+	Log            []DeliveryAttempt `json:"log,omitempty"`
+}
+
+type DeliveryAttempt struct {
+	Attempt     int       `json:"attempt"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMS  int64     `json:"duration_ms"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// ListWebhooks lists the webhook subscriptions. Every webhook call requires
+// the admin role.
+func (c *Client) ListWebhooks(ctx context.Context) ([]Subscription, error) {
+	subscriptions := []Subscription{}
+	err := c.do(ctx, request{method: http.MethodGet, path: "/webhooks"}, &subscriptions)
+	return subscriptions, err
+}
+
+func (c *Client) GetWebhook(ctx context.Context, id int) (Subscription, error) {
+	s := Subscription{}
+	err := c.do(ctx, request{method: http.MethodGet, path: path("webhooks", id)}, &s)
+	return s, err
+}
+
+func (c *Client) CreateWebhook(ctx context.Context, s Subscription) (Subscription, error) {
+	created := Subscription{}
+	err := c.do(ctx, request{method: http.MethodPost, path: "/webhooks", json: s}, &created)
+	return created, err
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: path("webhooks", id)}, nil)
+}
+
+// ListDeliveries lists the deliveries of a subscription, narrowed by status
+// when it is not empty.
+func (c *Client) ListDeliveries(ctx context.Context, id int, status string) ([]Delivery, error) {
+	q := url.Values{}
+	setString(q, "status", status)
+	deliveries := []Delivery{}
+	err := c.do(ctx, request{method: http.MethodGet, path: path("webhooks", id, "deliveries"), query: q}, &deliveries)
+	return deliveries, err
+}
+
+func (c *Client) GetDelivery(ctx context.Context, id int, deliveryID int64) (Delivery, error) {
+	d := Delivery{}
+	err := c.do(ctx, request{method: http.MethodGet, path: path("webhooks", id, "deliveries", deliveryID)}, &d)
+	return d, err
+}
+
+// Redeliver queues a delivery to be sent again.
+func (c *Client) Redeliver(ctx context.Context, id int, deliveryID int64) (Delivery, error) {
+	d := Delivery{}
+	err := c.do(ctx, request{method: http.MethodPost, path: path("webhooks", id, "deliveries", deliveryID, "redeliver")}, &d)
+	return d, err
+}
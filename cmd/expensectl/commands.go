@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/phanbanchong/assessment/client"
+)
+
+// stringList is a flag that may be given more than once.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// newFlags returns the flags of command name, with -o bound to e.format.
+func newFlags(name string, e *env) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&e.format, "o", e.format, "")
+	return fs
+}
+
+// parse parses args with fs, allowing flags after positional arguments, and
+// returns the positional arguments.
+func parse(fs *flag.FlagSet, e *env, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, usageError{fs.Name() + ": " + err.Error()}
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if !validFormat(e.format) {
+		return nil, usageError{"output format " + e.format + " is not table, json or csv"}
+	}
+	return positional, nil
+}
+
+// parseID parses args as the single ID of an expense.
+func parseID(name string, args []string) (int, error) {
+	if len(args) != 1 {
+		return 0, usageError{name + " takes one expense ID"}
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, usageError{"expense ID " + args[0] + " is invalid"}
+	}
+	return id, nil
+}
+
+// filterFlags registers the filter flags on fs; the returned function reads
+// them once fs is parsed.
+func filterFlags(fs *flag.FlagSet) func() (client.Filter, error) {
+	f := client.Filter{}
+	tags := &stringList{}
+	fs.Var(tags, "tag", "")
+	fs.StringVar(&f.From, "from", "", "")
+	fs.StringVar(&f.To, "to", "", "")
+	fs.StringVar(&f.Query, "q", "", "")
+	fs.StringVar(&f.Status, "status", "", "")
+	fs.StringVar(&f.Owner, "owner", "", "")
+	minAmount := fs.String("min", "", "")
+	maxAmount := fs.String("max", "", "")
+	return func() (client.Filter, error) {
+		f.Tags = *tags
+		for _, a := range []struct {
+			name  string
+			value string
+			dest  **float64
+		}{{"min", *minAmount, &f.MinAmount}, {"max", *maxAmount, &f.MaxAmount}} {
+			if a.value == "" {
+				continue
+			}
+			v, err := strconv.ParseFloat(a.value, 64)
+			if err != nil {
+				return f, usageError{"-" + a.name + " " + a.value + " is not a number"}
+			}
+			*a.dest = &v
+		}
+		return f, nil
+	}
+}
+
+// expenseFlags registers the expense field flags on fs; the returned
+// function applies the ones given, after the -file JSON if any, to exp.
+func expenseFlags(fs *flag.FlagSet, e *env) func(exp *client.Expense) error {
+	file := fs.String("file", "", "")
+	title := fs.String("title", "", "")
+	amount := fs.String("amount", "", "")
+	note := fs.String("note", "", "")
+	tags := &stringList{}
+	fs.Var(tags, "tag", "")
+	date := fs.String("date", "", "")
+	currency := fs.String("currency", "", "")
+	return func(exp *client.Expense) error {
+		if *file != "" {
+			if err := readJSON(e, *file, exp); err != nil {
+				return err
+			}
+		}
+		var err error
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "title":
+				exp.Title = *title
+			case "amount":
+				if exp.Amount, err = strconv.ParseFloat(*amount, 64); err != nil {
+					err = usageError{"-amount " + *amount + " is not a number"}
+				}
+			case "note":
+				exp.Note = *note
+			case "tag":
+				exp.Tags = *tags
+			case "date":
+				exp.Date = *date
+			case "currency":
+				exp.Currency = *currency
+			}
+		})
+		return err
+	}
+}
+
+// open opens path for reading, with - standing for stdin.
+func open(e *env, path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(e.stdin), nil
+	}
+	return os.Open(path)
+}
+
+func readJSON(e *env, path string, v any) error {
+	r, err := open(e, path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		return usageError{path + ": " + err.Error()}
+	}
+	return nil
+}
+
+func listCommand(ctx context.Context, e *env, args []string) error {
+	fs := newFlags("list", e)
+	filter := filterFlags(fs)
+	if _, err := parse(fs, e, args); err != nil {
+		return err
+	}
+	f, err := filter()
+	if err != nil {
+		return err
+	}
+	expenses, err := e.client.ListExpenses(ctx, f)
+	if err != nil {
+		return err
+	}
+	return printExpenses(e.stdout, e.format, expenses)
+}
+
+func getCommand(ctx context.Context, e *env, args []string) error {
+	fs := newFlags("get", e)
+	args, err := parse(fs, e, args)
+	if err != nil {
+		return err
+	}
+	id, err := parseID("get", args)
+	if err != nil {
+		return err
+	}
+	exp, err := e.client.GetExpense(ctx, id)
+	if err != nil {
+		return err
+	}
+	return printExpense(e.stdout, e.format, exp)
+}
+
+func createCommand(ctx context.Context, e *env, args []string) error {
+	fs := newFlags("create", e)
+	apply := expenseFlags(fs, e)
+	force := fs.Bool("force", false, "")
+	args, err := parse(fs, e, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return usageError{"create takes no arguments"}
+	}
+	exp := client.Expense{}
+	if err := apply(&exp); err != nil {
+		return err
+	}
+	exp, err = e.client.CreateExpense(ctx, exp, *force)
+	if err != nil {
+		return err
+	}
+	return printExpense(e.stdout, e.format, exp)
+}
+
+// updateCommand changes only the fields given, keeping the rest as the
+// server has them.
+func updateCommand(ctx context.Context, e *env, args []string) error {
+	fs := newFlags("update", e)
+	apply := expenseFlags(fs, e)
+	args, err := parse(fs, e, args)
+	if err != nil {
+		return err
+	}
+	id, err := parseID("update", args)
+	if err != nil {
+		return err
+	}
+	exp, err := e.client.GetExpense(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := apply(&exp); err != nil {
+		return err
+	}
+	exp.ID = id
+	exp, err = e.client.UpdateExpense(ctx, exp)
+	if err != nil {
+		return err
+	}
+	return printExpense(e.stdout, e.format, exp)
+}
+
+func deleteCommand(ctx context.Context, e *env, args []string) error {
+	fs := newFlags("delete", e)
+	args, err := parse(fs, e, args)
+	if err != nil {
+		return err
+	}
+	id, err := parseID("delete", args)
+	if err != nil {
+		return err
+	}
+	return e.client.DeleteExpense(ctx, id)
+}
+
+func importCommand(ctx context.Context, e *env, args []string) error {
+	fs := newFlags("import", e)
+	dryRun := fs.Bool("dry-run", false, "")
+	batchSize := fs.Int("batch-size", 0, "")
+	args, err := parse(fs, e, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return usageError{"import takes one CSV file"}
+	}
+	r, err := open(e, args[0])
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	result, err := e.client.ImportExpenses(ctx, r, client.ImportOptions{DryRun: *dryRun, BatchSize: *batchSize})
+	if err != nil && len(result.Errors) == 0 {
+		return err
+	}
+	if perr := printImport(e.stdout, e.format, result); perr != nil {
+		return perr
+	}
+	return err
+}
+
+func exportCommand(ctx context.Context, e *env, args []string) error {
+	fs := newFlags("export", e)
+	filter := filterFlags(fs)
+	format := fs.String("format", "csv", "")
+	out := fs.String("out", "-", "")
+	args, err := parse(fs, e, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return usageError{"export takes no arguments"}
+	}
+	f, err := filter()
+	if err != nil {
+		return err
+	}
+	body, err := e.client.ExportExpenses(ctx, f, *format)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if *out == "-" {
+		_, err = io.Copy(e.stdout, body)
+		return err
+	}
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, body); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
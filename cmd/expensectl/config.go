@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Config is the expensectl config file, by default
+// $XDG_CONFIG_HOME/expensectl/config.json:
+//
+//	{"default": "prod", "profiles": {"prod": {"url": "https://expenses.example.com", "token": "..."}}}
+type Config struct {
+	Default  string             `json:"default"`
+	Profiles map[string]Profile `json:"profiles"`
+}
+
+type Profile struct {
+	URL   string `json:"url"`
+	Token string `json:"token"`
+}
+
+func defaultConfigPath() string {
+	if path := os.Getenv("EXPENSECTL_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "expensectl", "config.json")
+}
+
+// loadConfig reads the config at path. A missing file is an empty config,
+// since the server can be given with flags or environment variables alone.
+func loadConfig(path string) (Config, error) {
+	cfg := Config{}
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// resolve picks the profile to use. name, then EXPENSECTL_PROFILE, then the
+// config default select it; url and token, then EXPENSECTL_URL and
+// EXPENSECTL_TOKEN, override its fields.
+func (cfg Config) resolve(name, url, token string) (Profile, error) {
+	explicit := name != ""
+	if name == "" {
+		name = os.Getenv("EXPENSECTL_PROFILE")
+		explicit = name != ""
+	}
+	if name == "" {
+		name = cfg.Default
+	}
+	p, ok := cfg.Profiles[name]
+	if explicit && !ok {
+		return p, fmt.Errorf("profile %s is not in the config file", name)
+	}
+	for _, o := range []struct {
+		dest *string
+		vals []string
+	}{
+		{&p.URL, []string{url, os.Getenv("EXPENSECTL_URL")}},
+		{&p.Token, []string{token, os.Getenv("EXPENSECTL_TOKEN")}},
+	} {
+		for _, v := range o.vals {
+			if v != "" {
+				*o.dest = v
+				break
+			}
+		}
+	}
+	if p.URL == "" {
+		return p, errors.New("no server URL, set one in a profile or with -url")
+	}
+	return p, nil
+}
@@ -0,0 +1,161 @@
+// Command expensectl manages expenses through the expense API.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/phanbanchong/assessment/client"
+)
+
+const usage = `Usage: expensectl [global flags] COMMAND [flags] [ARGS]
+
+Commands:
+  list    [filter flags]                     List expenses
+  get     ID                                 Show an expense
+  create  [expense flags] [-force]           Create an expense
+  update  ID [expense flags]                 Change the given fields of an expense
+  delete  ID                                 Delete an expense
+  import  [-dry-run] [-batch-size N] FILE    Import expenses from CSV, - for stdin
+  export  [-format csv|jsonl|xlsx] [-out FILE] [filter flags]
+                                             Download expenses
+
+Global flags:
+  -config FILE    Config file with profiles, default $EXPENSECTL_CONFIG or
+                  expensectl/config.json in the user config directory
+  -profile NAME   Profile to use, default $EXPENSECTL_PROFILE or the config default
+  -url URL        Server URL, default $EXPENSECTL_URL or the profile's
+  -token TOKEN    Authorization token, default $EXPENSECTL_TOKEN or the profile's
+  -o FORMAT       Output as table, json or csv; also accepted after the command
+
+Filter flags: -tag TAG (repeatable), -from DATE, -to DATE, -min AMOUNT,
+  -max AMOUNT, -q TEXT, -status STATUS, -owner NAME
+Expense flags: -title, -amount, -note, -tag (repeatable), -date, -currency,
+  or -file FILE with the expense as JSON, - for stdin
+
+Exit codes:
+  0  success
+  1  unexpected error, such as the server being unreachable
+  2  usage or configuration error
+  3  not found
+  4  unauthorized or forbidden
+  5  request rejected by the API (400, 409 or 422)
+  6  server error
+`
+
+const (
+	exitOK = iota
+	exitError
+	exitUsage
+	exitNotFound
+	exitAuth
+	exitRejected
+	exitServer
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// env is what commands run with.
+type env struct {
+	client *client.Client
+	format string
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+// usageError reports a command line that cannot be run.
+type usageError struct{ message string }
+
+func (e usageError) Error() string {
+	return e.message
+}
+
+type command func(ctx context.Context, e *env, args []string) error
+
+var commands = map[string]command{
+	"list":   listCommand,
+	"get":    getCommand,
+	"create": createCommand,
+	"update": updateCommand,
+	"delete": deleteCommand,
+	"import": importCommand,
+	"export": exportCommand,
+}
+
+// run executes the command line args and returns the process exit code.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	global := flag.NewFlagSet("expensectl", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	global.Usage = func() { fmt.Fprint(stderr, usage) }
+	configPath := global.String("config", defaultConfigPath(), "")
+	profile := global.String("profile", "", "")
+	url := global.String("url", "", "")
+	token := global.String("token", "", "")
+	format := global.String("o", formatTable, "")
+	if err := global.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if global.NArg() == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	cmd, ok := commands[global.Arg(0)]
+	if !ok {
+		return exitCode(stderr, usageError{"unknown command " + global.Arg(0)})
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return exitCode(stderr, usageError{err.Error()})
+	}
+	p, err := cfg.resolve(*profile, *url, *token)
+	if err != nil {
+		return exitCode(stderr, usageError{err.Error()})
+	}
+	c := client.New(p.URL)
+	if p.Token != "" {
+		c.Auth = client.Token(p.Token)
+	}
+	e := &env{client: c, format: *format, stdin: stdin, stdout: stdout}
+	return exitCode(stderr, cmd(ctx, e, global.Args()[1:]))
+}
+
+// exitCode reports err on stderr and maps it to an exit code.
+func exitCode(stderr io.Writer, err error) int {
+	if err == nil {
+		return exitOK
+	}
+	fmt.Fprintln(stderr, "expensectl:", err)
+	var ue usageError
+	if errors.As(err, &ue) {
+		return exitUsage
+	}
+	var ae *client.Error
+	if !errors.As(err, &ae) {
+		return exitError
+	}
+	switch {
+	case ae.StatusCode == http.StatusNotFound:
+		return exitNotFound
+	case ae.StatusCode == http.StatusUnauthorized || ae.StatusCode == http.StatusForbidden:
+		return exitAuth
+	case ae.StatusCode >= http.StatusInternalServerError:
+		return exitServer
+	default:
+		return exitRejected
+	}
+}
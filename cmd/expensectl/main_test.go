@@ -0,0 +1,154 @@
+//go:build unit
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// api fakes the expense API and records the requests it gets.
+type api struct {
+	requests []string
+	bodies   []string
+}
+
+func (a *api) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	a.requests = append(a.requests, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get("Authorization"))
+	a.bodies = append(a.bodies, string(b))
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method + " " + r.URL.Path {
+	case "GET /expenses":
+		w.Write([]byte(`[{"id":1,"title":"taxi","amount":12.5,"note":"","tags":["travel","client"],"date":"2023-04-01","currency":"THB","status":"draft"}]`))
+	case "GET /expenses/1":
+		w.Write([]byte(`{"id":1,"title":"taxi","amount":12.5,"note":"airport","tags":["travel"],"status":"draft"}`))
+	case "PUT /expenses/1", "POST /expenses":
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	case "POST /expenses/import":
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"dry_run":false,"rows":2,"imported":0,"errors":[{"row":3,"message":"Field amount is invalid"}]}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"Expense not found"}`))
+	}
+}
+
+func runWith(t *testing.T, args ...string) (*api, int, string, string) {
+	t.Setenv("EXPENSECTL_CONFIG", "")
+	t.Setenv("EXPENSECTL_PROFILE", "")
+	t.Setenv("EXPENSECTL_URL", "")
+	t.Setenv("EXPENSECTL_TOKEN", "")
+	a := &api{}
+	srv := httptest.NewServer(a)
+	t.Cleanup(srv.Close)
+	args = append([]string{"-config", "", "-url", srv.URL, "-token", "secret"}, args...)
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, strings.NewReader(""), &stdout, &stderr)
+	return a, code, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+	t.Run("List should print a table", func(t *testing.T) {
+		a, code, stdout, _ := runWith(t, "list", "-tag", "travel", "-min", "10")
+
+		want := "ID  TITLE  AMOUNT  CURRENCY  NOTE  TAGS           DATE        STATUS  OWNER\n" +
+			"1   taxi   12.5    THB             travel;client  2023-04-01  draft   \n"
+		if code != exitOK || stdout != want {
+			t.Errorf("should print the table but it got %d %q", code, stdout)
+		}
+		if a.requests[0] != "GET /expenses?min_amount=10&tag=travel secret" {
+			t.Errorf("should send the filter but it got %s", a.requests[0])
+		}
+	})
+
+	t.Run("Get should print CSV when asked", func(t *testing.T) {
+		_, code, stdout, _ := runWith(t, "get", "1", "-o", "csv")
+
+		want := "id,title,amount,currency,note,tags,date,status,owner\n1,taxi,12.5,,airport,travel,,draft,\n"
+		if code != exitOK || stdout != want {
+			t.Errorf("should print CSV but it got %d %q", code, stdout)
+		}
+	})
+
+	t.Run("Update should change only the given fields", func(t *testing.T) {
+		a, code, _, _ := runWith(t, "-o", "json", "update", "1", "-title", "train")
+
+		want := `{"id":1,"title":"train","amount":12.5,"note":"airport","tags":["travel"],"status":"draft"}`
+		if code != exitOK || len(a.bodies) != 2 || a.bodies[1] != want {
+			t.Errorf("should send %s but it got %d %v", want, code, a.bodies)
+		}
+	})
+
+	t.Run("Missing expense should exit with not found", func(t *testing.T) {
+		_, code, _, stderr := runWith(t, "delete", "7")
+
+		if code != exitNotFound || stderr != "expensectl: 404 Expense not found\n" {
+			t.Errorf("should exit %d but it got %d %q", exitNotFound, code, stderr)
+		}
+	})
+
+	t.Run("Rejected import should print the row errors", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "expenses.csv")
+		os.WriteFile(file, []byte("title,amount\ntaxi,1\nbus,x\n"), 0o600)
+
+		a, code, stdout, _ := runWith(t, "import", file)
+
+		if code != exitRejected || stdout != "Imported 0 of 2 rows\nROW  MESSAGE\n3    Field amount is invalid\n" {
+			t.Errorf("should print the row errors but it got %d %q", code, stdout)
+		}
+		if a.bodies[0] != "title,amount\ntaxi,1\nbus,x\n" {
+			t.Errorf("should upload the file but it got %q", a.bodies[0])
+		}
+	})
+
+	t.Run("Bad usage should exit with usage", func(t *testing.T) {
+		for _, args := range [][]string{{"get"}, {"fly"}, {"list", "-o", "yaml"}, {"create", "-amount", "x"}} {
+			if _, code, _, _ := runWith(t, args...); code != exitUsage {
+				t.Errorf("%v should exit %d but it got %d", args, exitUsage, code)
+			}
+		}
+	})
+}
+
+func TestConfigResolve(t *testing.T) {
+	t.Setenv("EXPENSECTL_PROFILE", "")
+	t.Setenv("EXPENSECTL_URL", "")
+	t.Setenv("EXPENSECTL_TOKEN", "")
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"default":"prod","profiles":{"prod":{"url":"https://prod","token":"p"},"dev":{"url":"http://dev","token":"d"}}}`), 0o600)
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("should not return error but it got %v", err)
+	}
+
+	t.Run("Default profile should be used without a name", func(t *testing.T) {
+		p, err := cfg.resolve("", "", "")
+		if err != nil || p != (Profile{URL: "https://prod", Token: "p"}) {
+			t.Errorf("should use prod but it got %+v %v", p, err)
+		}
+	})
+
+	t.Run("Environment and flags should override the profile", func(t *testing.T) {
+		t.Setenv("EXPENSECTL_PROFILE", "dev")
+		t.Setenv("EXPENSECTL_TOKEN", "env")
+		p, err := cfg.resolve("", "http://flag", "")
+		if err != nil || p != (Profile{URL: "http://flag", Token: "env"}) {
+			t.Errorf("should override dev but it got %+v %v", p, err)
+		}
+	})
+
+	t.Run("Unknown profile should be an error", func(t *testing.T) {
+		if _, err := cfg.resolve("staging", "", ""); err == nil {
+			t.Errorf("should return error for an unknown profile")
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/phanbanchong/assessment/client"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+func validFormat(format string) bool {
+	return format == formatTable || format == formatJSON || format == formatCSV
+}
+
+var expenseColumns = []string{"id", "title", "amount", "currency", "note", "tags", "date", "status", "owner"}
+
+func expenseRow(exp client.Expense) []string {
+	return []string{
+		strconv.Itoa(exp.ID),
+		exp.Title,
+		strconv.FormatFloat(exp.Amount, 'f', -1, 64),
+		exp.Currency,
+		exp.Note,
+		strings.Join(exp.Tags, client.TagSeparator),
+		exp.Date,
+		exp.Status,
+		exp.Owner,
+	}
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeRows writes a header and rows as an aligned table or as CSV.
+func writeRows(w io.Writer, format string, header []string, rows [][]string) error {
+	if format == formatCSV {
+		cw := csv.NewWriter(w)
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t")))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func printExpenses(w io.Writer, format string, expenses []client.Expense) error {
+	if format == formatJSON {
+		return writeJSON(w, expenses)
+	}
+	rows := [][]string{}
+	for _, exp := range expenses {
+		rows = append(rows, expenseRow(exp))
+	}
+	return writeRows(w, format, expenseColumns, rows)
+}
+
+func printExpense(w io.Writer, format string, exp client.Expense) error {
+	if format == formatJSON {
+		return writeJSON(w, exp)
+	}
+	return printExpenses(w, format, []client.Expense{exp})
+}
+
+// printImport writes the outcome of an import. Tables and CSV list the row
+// errors; a table also sums up the rows.
+func printImport(w io.Writer, format string, result client.ImportResult) error {
+	if format == formatJSON {
+		return writeJSON(w, result)
+	}
+	if format == formatTable {
+		if result.DryRun {
+			fmt.Fprintf(w, "Checked %d rows, %d invalid\n", result.Rows, len(result.Errors))
+		} else {
+			fmt.Fprintf(w, "Imported %d of %d rows\n", result.Imported, result.Rows)
+		}
+		if len(result.Errors) == 0 {
+			return nil
+		}
+	}
+	rows := [][]string{}
+	for _, e := range result.Errors {
+		rows = append(rows, []string{strconv.Itoa(e.Row), e.Message})
+	}
+	return writeRows(w, format, []string{"row", "message"}, rows)
+}
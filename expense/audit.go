@@ -0,0 +1,144 @@
+package expense
+
+import (
+	"database/sql"
+
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/auth"
+)
+
+func withTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func getExpenseForUpdate(db Querier, id int) (Expense, error) {
+	row := db.QueryRow("SELECT "+expenseColumns+" FROM expenses WHERE id = $1 FOR UPDATE", id)
+	return scanExpense(row)
+}
+
+// CreateExpenseAudited converts exp to its reporting currency, inserts it
+// owned by actor and records it in the audit trail and the outbox. Run it in
+// a transaction so the expense, its audit record and its event commit
+// together.
+func CreateExpenseAudited(db Querier, actor audit.Actor, exp Expense) (Expense, error) {
+	exp.Owner = actor.Name
+	exp.Warnings = nil
+	exp, err := Convert(db, exp)
+	if err != nil {
+		return exp, err
+	}
+	exp, err = CreateExpense(db, exp)
+	if err != nil {
+		return exp, err
+	}
+	if _, err = audit.Record(db, actor, audit.ActionCreate, exp.ID, nil, exp); err != nil {
+		return exp, err
+	}
+	return exp, WriteEvent(db, EventCreated, exp)
+}
+
+// checkOwner lets only the owner and admins change an expense. Another
+// user's expense is not found for callers who cannot see it, and ErrNotOwner
+// for approvers, who see every expense but act on it only through the
+// workflow.
+func checkOwner(actor audit.Actor, user auth.User, exp Expense) error {
+	switch {
+	case exp.Owner == actor.Name || user.HasRole(auth.RoleAdmin):
+		return nil
+	case user.HasRole(auth.RoleApprover):
+		return ErrNotOwner
+	}
+	return sql.ErrNoRows
+}
+
+// checkGroup returns ErrGroupExpense when expense id is split in a group.
+func checkGroup(db Querier, id int) error {
+	var split bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM group_expenses WHERE expense_id = $1)", id).Scan(&split); err != nil {
+		return err
+	}
+	if split {
+		return ErrGroupExpense
+	}
+	return nil
+}
+
+// UpdateExpenseAudited locks the current row, applies exp and records the
+// field-level difference. Fields the update cannot change are carried over
+// from the stored row, as are the currency when exp has none and the
+// reporting currency once set. Approved or reported expenses are refused
+// with ErrLocked, group expenses with ErrGroupExpense, and other users'
+// expenses as checkOwner describes.
+func UpdateExpenseAudited(db Querier, actor audit.Actor, user auth.User, exp Expense) (Expense, error) {
+	before, err := getExpenseForUpdate(db, exp.ID)
+	if err != nil {
+		return exp, err
+	}
+	if err := checkOwner(actor, user, before); err != nil {
+		return exp, err
+	}
+	if locked(before) {
+		return exp, ErrLocked
+	}
+	if err := checkGroup(db, before.ID); err != nil {
+		return exp, err
+	}
+	exp.RecurringID = before.RecurringID
+	exp.ExternalRef = before.ExternalRef
+	exp.Status = before.Status
+	exp.Owner = before.Owner
+	exp.ReportID = before.ReportID
+	exp.Warnings = nil
+	if exp.Currency == "" {
+		exp.Currency = before.Currency
+	}
+	if before.ReportingCurrency != "" {
+		exp.ReportingCurrency = before.ReportingCurrency
+	}
+	if exp, err = Convert(db, exp); err != nil {
+		return exp, err
+	}
+	if exp, err = UpdateExpense(db, exp); err != nil {
+		return exp, err
+	}
+	if _, err = audit.Record(db, actor, audit.ActionUpdate, exp.ID, before, exp); err != nil {
+		return exp, err
+	}
+	return exp, WriteEvent(db, EventUpdated, exp)
+}
+
+// DeleteExpenseAudited removes the expense, records its last state and
+// returns it. Like updates, deletes of approved expenses are refused with
+// ErrLocked, of group expenses with ErrGroupExpense and of other users'
+// expenses as checkOwner describes.
+func DeleteExpenseAudited(db Querier, actor audit.Actor, user auth.User, id int) (Expense, error) {
+	before, err := getExpenseForUpdate(db, id)
+	if err != nil {
+		return before, err
+	}
+	if err := checkOwner(actor, user, before); err != nil {
+		return before, err
+	}
+	if locked(before) {
+		return before, ErrLocked
+	}
+	if err := checkGroup(db, id); err != nil {
+		return before, err
+	}
+	if err := DeleteExpense(db, id); err != nil {
+		return before, err
+	}
+	if _, err = audit.Record(db, actor, audit.ActionDelete, id, before, nil); err != nil {
+		return before, err
+	}
+	return before, WriteEvent(db, EventDeleted, before)
+}
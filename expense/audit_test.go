@@ -0,0 +1,108 @@
+//go:build unit
+
+package expense
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/auth"
+)
+
+func expectAudit(mock sqlmock.Sqlmock, id int, action string) {
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT hash FROM expense_audit").WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+	mock.ExpectQuery("INSERT INTO expense_audit").
+		WithArgs(id, action, "anonymous", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+}
+
+func expectEvent(mock sqlmock.Sqlmock, id int, event string) {
+	mock.ExpectExec("INSERT INTO outbox").WithArgs(event, id, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO expense_changes").WithArgs(id, sqlmock.AnyArg(), event == EventDeleted, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func expectLock(mock sqlmock.Sqlmock, id int) {
+	mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id = (.+) FOR UPDATE").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}).
+			AddRow(id, "title", 1.0, "note", pq.Array([]string{"tag1"}), nil, nil, nil, "draft", "anonymous", nil, nil, nil, nil, nil, nil))
+	expectNotInGroup(mock, id)
+}
+
+func expectNotInGroup(mock sqlmock.Sqlmock, id int) {
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM group_expenses WHERE expense_id = (.+)\\)").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+}
+
+func TestUpdateExpenseAudited(t *testing.T) {
+	t.Run("Update should keep read-only fields and record the diff", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id = (.+) FOR UPDATE").
+			WithArgs(4).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}).
+				AddRow(4, "rent", 500.0, "", pq.Array([]string{}), nil, 7, "ofx:1:2", "submitted", "alice", nil, nil, nil, nil, nil, nil))
+		expectNotInGroup(mock, 4)
+		mock.ExpectPrepare("UPDATE expenses").ExpectExec().
+			WithArgs(4, "rent", 550.0, "", pq.Array([]string{}), nil, nil, nil, nil, nil, nil).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT hash FROM expense_audit").WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+		mock.ExpectQuery("INSERT INTO expense_audit").
+			WithArgs(4, "update", "alice", "req-1", []byte(`[{"field":"amount","before":500,"after":550}]`), sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO outbox").WithArgs(EventUpdated, 4, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO expense_changes").WithArgs(4, sqlmock.AnyArg(), false, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+
+		exp, err := UpdateExpenseAudited(db, audit.Actor{Name: "alice", RequestID: "req-1"}, auth.User{Name: "alice"}, Expense{ID: 4, Title: "rent", Amount: 550, Tags: []string{}})
+		if err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if exp.RecurringID != 7 || exp.ExternalRef != "ofx:1:2" {
+			t.Errorf("read-only fields were not kept got: %+v", exp)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestChangeOtherOwnersExpense(t *testing.T) {
+	cases := []struct {
+		name string
+		user auth.User
+		want error
+	}{
+		{"Update of another user's expense should not be found", auth.User{Name: "bob"}, sql.ErrNoRows},
+		{"Update by an approver should be forbidden", auth.User{Name: "carol", Roles: []string{auth.RoleApprover}}, ErrNotOwner},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id = (.+) FOR UPDATE").
+				WithArgs(4).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}).
+					AddRow(4, "rent", 500.0, "", pq.Array([]string{}), nil, nil, nil, "submitted", "alice", nil, nil, nil, nil, nil, nil))
+
+			_, err = UpdateExpenseAudited(db, audit.Actor{Name: tc.user.Name}, tc.user, Expense{ID: 4, Title: "rent", Amount: 5000, Tags: []string{}})
+			if err != tc.want {
+				t.Errorf("should return %v but it got %v", tc.want, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package expense
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/auth"
+	"github.com/phanbanchong/assessment/fx"
+	"github.com/phanbanchong/assessment/policy"
+)
+
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+
+	maxBatchItems = 1000
+)
+
+// MaxBodySize caps the JSON request bodies the handlers, and the OpenAPI
+// validator in front of them, read.
+const MaxBodySize = 1 << 20
+
+type BatchOperation struct {
+	Op      string  `json:"op"`
+	ID      int     `json:"id,omitempty"`
+	Expense Expense `json:"expense"`
+}
+
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+type BatchItemResult struct {
+	Index   int      `json:"index"`
+	Op      string   `json:"op"`
+	Status  int      `json:"status"`
+	Expense *Expense `json:"expense,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Atomic  bool              `json:"atomic"`
+	Results []BatchItemResult `json:"results"`
+}
+
+func (r BatchItemResult) failed() bool {
+	return r.Status >= http.StatusBadRequest
+}
+
+// validateOperation runs the same checks as the single-item handlers.
+func validateOperation(op BatchOperation) error {
+	switch op.Op {
+	case OpCreate:
+		return ValidateExpense(op.Expense)
+	case OpUpdate:
+		if op.ID <= 0 {
+			return errors.New("Field ID is invalid")
+		}
+		return ValidateUpdate(op.Expense)
+	case OpDelete:
+		if op.ID <= 0 {
+			return errors.New("Field ID is invalid")
+		}
+		return nil
+	default:
+		return fmt.Errorf("Operation %q is invalid", op.Op)
+	}
+}
+
+func runOperation(db Querier, actor audit.Actor, user auth.User, cfg policy.Config, index int, op BatchOperation) BatchItemResult {
+	result := BatchItemResult{Index: index, Op: op.Op}
+	var err error
+	switch op.Op {
+	case OpCreate:
+		var exp Expense
+		if exp, err = CreateExpenseAudited(db, actor, op.Expense); err != nil {
+			break
+		}
+		if exp.Warnings, err = CheckNewPolicy(db, cfg, exp); err == nil {
+			result.Status, result.Expense = http.StatusCreated, &exp
+		}
+	case OpUpdate:
+		exp := op.Expense
+		exp.ID = op.ID
+		if exp, err = UpdateExpenseAudited(db, actor, user, exp); err != nil {
+			break
+		}
+		if exp.Warnings, err = CheckPolicy(db, cfg, exp); err == nil {
+			result.Status, result.Expense = http.StatusOK, &exp
+		}
+	case OpDelete:
+		if _, err = DeleteExpenseAudited(db, actor, user, op.ID); err == nil {
+			result.Status = http.StatusNoContent
+		}
+	}
+	switch {
+	case err == sql.ErrNoRows:
+		result.Status, result.Error = http.StatusNotFound, "Expense not found"
+	case err == ErrLocked, err == ErrGroupExpense:
+		result.Status, result.Error = http.StatusConflict, err.Error()
+	case err == ErrNotOwner:
+		result.Status, result.Error = http.StatusForbidden, err.Error()
+	case errors.As(err, new(*PolicyError)), errors.Is(err, fx.ErrNoRate):
+		result.Status, result.Error = http.StatusUnprocessableEntity, err.Error()
+	case err != nil:
+		result.Status, result.Error = http.StatusInternalServerError, err.Error()
+	}
+	return result
+}
+
+// runIsolated runs a single operation in its own transaction.
+func runIsolated(db *sql.DB, actor audit.Actor, user auth.User, cfg policy.Config, index int, op BatchOperation) BatchItemResult {
+	tx, err := db.Begin()
+	if err != nil {
+		return BatchItemResult{Index: index, Op: op.Op, Status: http.StatusInternalServerError, Error: err.Error()}
+	}
+	defer tx.Rollback()
+
+	result := runOperation(tx, actor, user, cfg, index, op)
+	if result.failed() {
+		return result
+	}
+	if err := tx.Commit(); err != nil {
+		return BatchItemResult{Index: index, Op: op.Op, Status: http.StatusInternalServerError, Error: err.Error()}
+	}
+	return result
+}
+
+// BatchExpensesHandler applies a list of create, update and delete operations
+// and reports a status per item. By default every operation is attempted on
+// its own; with atomic=true they run in one transaction and any failure rolls
+// all of them back.
+func (h *handler) BatchExpensesHandler(c echo.Context) error {
+	atomic, _ := strconv.ParseBool(c.QueryParam("atomic"))
+
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, MaxBodySize)
+	batch := BatchRequest{}
+	if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return c.JSON(http.StatusRequestEntityTooLarge, Error{Message: "Batch is too large"})
+		}
+		return c.JSON(http.StatusBadRequest, Error{Message: err.Error()})
+	}
+	if len(batch.Operations) == 0 {
+		return c.JSON(http.StatusBadRequest, Error{Message: "Field operations is required"})
+	}
+	if len(batch.Operations) > maxBatchItems {
+		return c.JSON(http.StatusRequestEntityTooLarge, Error{Message: fmt.Sprintf("Batch is limited to %d operations", maxBatchItems)})
+	}
+
+	resp := BatchResponse{Atomic: atomic, Results: make([]BatchItemResult, len(batch.Operations))}
+	invalid := false
+	for i, op := range batch.Operations {
+		resp.Results[i] = BatchItemResult{Index: i, Op: op.Op}
+		if err := validateOperation(op); err != nil {
+			resp.Results[i].Status, resp.Results[i].Error = http.StatusBadRequest, err.Error()
+			invalid = true
+		}
+	}
+
+	actor, user := audit.ActorFrom(c), auth.UserFrom(c)
+	for i := range batch.Operations {
+		batch.Operations[i].Expense.ReportingCurrency = h.reportingCurrency(c)
+	}
+	if !atomic {
+		for i, op := range batch.Operations {
+			if !resp.Results[i].failed() {
+				resp.Results[i] = runIsolated(h.DB, actor, user, h.Policy, i, op)
+			}
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+
+	if invalid {
+		return c.JSON(http.StatusUnprocessableEntity, rollback(resp))
+	}
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Error{Message: err.Error()})
+	}
+	defer tx.Rollback()
+	for i, op := range batch.Operations {
+		resp.Results[i] = runOperation(tx, actor, user, h.Policy, i, op)
+		if resp.Results[i].failed() {
+			return c.JSON(http.StatusUnprocessableEntity, rollback(resp))
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, Error{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// rollback marks every item that did not fail itself as not applied.
+func rollback(resp BatchResponse) BatchResponse {
+	for i, r := range resp.Results {
+		if !r.failed() {
+			resp.Results[i] = BatchItemResult{Index: r.Index, Op: r.Op, Status: http.StatusFailedDependency, Error: "Rolled back"}
+		}
+	}
+	return resp
+}
@@ -0,0 +1,186 @@
+//go:build unit
+
+package expense
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+const batchJSON = `{"operations":[
+	{"op":"create","expense":{"title":"title","amount":1,"note":"note","tags":["tag1","tag2"]}},
+	{"op":"update","id":7,"expense":{"title":"new","amount":2,"note":"","tags":[]}},
+	{"op":"delete","id":8},
+	{"op":"delete"}
+]}`
+
+func batchRequest(query, body string) (*echo.Echo, *http.Request) {
+	req := httptest.NewRequest(http.MethodPost, "/expenses:batch?"+query, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	return echo.New(), req
+}
+
+func TestBatchExpensesHandler(t *testing.T) {
+	t.Run("Best effort batch should report status per item", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO expenses").
+			WithArgs("title", 1.0, "note", pq.Array([]string{"tag1", "tag2"}), nil, nil, "anonymous", "THB", 1.0, "THB", 1.0, nil, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("9"))
+		expectAudit(mock, 9, "create")
+		expectEvent(mock, 9, EventCreated)
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id = (.+) FOR UPDATE").
+			WithArgs(7).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		expectLock(mock, 8)
+		mock.ExpectExec("DELETE FROM expenses").
+			WithArgs(8).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit(mock, 8, "delete")
+		expectEvent(mock, 8, EventDeleted)
+		mock.ExpectCommit()
+
+		h := NewApplication(db)
+		e, req := batchRequest("", batchJSON)
+		e.POST("/expenses\\:batch", h.BatchExpensesHandler)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		want := `{"atomic":false,"results":[` +
+			`{"index":0,"op":"create","status":201,"expense":{"id":9,"title":"title","amount":1,"note":"note","tags":["tag1","tag2"],"status":"draft","owner":"anonymous","currency":"THB","reporting_amount":1,"reporting_currency":"THB","rate":1}},` +
+			`{"index":1,"op":"update","status":404,"error":"Expense not found"},` +
+			`{"index":2,"op":"delete","status":204},` +
+			`{"index":3,"op":"delete","status":400,"error":"Field ID is invalid"}]}` + "\n"
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Atomic batch with invalid item should not touch database", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		h := NewApplication(db)
+		e, req := batchRequest("atomic=true", batchJSON)
+		c := e.NewContext(req, httptest.NewRecorder())
+		rec := c.Response().Writer.(*httptest.ResponseRecorder)
+		if err := h.BatchExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `{"index":0,"op":"create","status":424,"error":"Rolled back"}`) {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Atomic batch should roll back on failure", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO expenses").WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("9"))
+		expectAudit(mock, 9, "create")
+		expectEvent(mock, 9, EventCreated)
+		expectLock(mock, 8)
+		mock.ExpectExec("DELETE FROM expenses").WithArgs(8).WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		h := NewApplication(db)
+		body := `{"operations":[{"op":"create","expense":{"title":"a","amount":1}},{"op":"delete","id":8}]}`
+		e, req := batchRequest("atomic=true", body)
+		c := e.NewContext(req, httptest.NewRecorder())
+		rec := c.Response().Writer.(*httptest.ResponseRecorder)
+		if err := h.BatchExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"atomic":true,"results":[{"index":0,"op":"create","status":424,"error":"Rolled back"},{"index":1,"op":"delete","status":500,"error":"sql: connection is already closed"}]}` + "\n"
+		if rec.Code != http.StatusUnprocessableEntity || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Atomic batch should commit on success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		expectLock(mock, 8)
+		mock.ExpectExec("DELETE FROM expenses").WithArgs(8).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit(mock, 8, "delete")
+		expectEvent(mock, 8, EventDeleted)
+		mock.ExpectCommit()
+
+		h := NewApplication(db)
+		e, req := batchRequest("atomic=true", `{"operations":[{"op":"delete","id":8}]}`)
+		c := e.NewContext(req, httptest.NewRecorder())
+		rec := c.Response().Writer.(*httptest.ResponseRecorder)
+		if err := h.BatchExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusOK {
+			t.Errorf("should status ok but it got %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Batch over item limit should got error", func(t *testing.T) {
+		ops := strings.TrimSuffix(strings.Repeat(`{"op":"delete","id":1},`, maxBatchItems+1), ",")
+		h := NewApplication(nil)
+		e, req := batchRequest("", fmt.Sprintf(`{"operations":[%s]}`, ops))
+		c := e.NewContext(req, httptest.NewRecorder())
+		rec := c.Response().Writer.(*httptest.ResponseRecorder)
+		if err := h.BatchExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("should status request entity too large but it got %d", rec.Code)
+		}
+	})
+
+	t.Run("Batch over payload limit should got error", func(t *testing.T) {
+		note := strings.Repeat("x", MaxBodySize)
+		h := NewApplication(nil)
+		e, req := batchRequest("", `{"operations":[{"op":"create","expense":{"title":"a","note":"`+note+`"}}]}`)
+		c := e.NewContext(req, httptest.NewRecorder())
+		rec := c.Response().Writer.(*httptest.ResponseRecorder)
+		if err := h.BatchExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("should status request entity too large but it got %d", rec.Code)
+		}
+	})
+}
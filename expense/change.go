@@ -0,0 +1,70 @@
+package expense
+
+import (
+	"database/sql"
+	"strconv"
+)
+
+// changeLock is the advisory lock key serialising sync versions. It is held
+// until the writing transaction commits, so versions become visible in order
+// and a client that has seen version N has seen everything before it.
+const changeLock = 7283003
+
+// Change is the latest version of an expense in the change log.
+type Change struct {
+	ExpenseID int
+	Version   int64
+	Owner     string
+	Deleted   bool
+}
+
+func recordChange(db Querier, exp Expense, deleted bool) error {
+	_, err := db.Exec("INSERT INTO expense_changes (expense_id, version, owner, deleted, changed_at) SELECT $1, nextval('expense_change_seq'), $2, $3, now() FROM pg_advisory_xact_lock($4) ON CONFLICT (expense_id) DO UPDATE SET version = EXCLUDED.version, owner = EXCLUDED.owner, deleted = EXCLUDED.deleted, changed_at = EXCLUDED.changed_at",
+		exp.ID, nullString(exp.Owner), deleted, changeLock)
+	return err
+}
+
+// GetChange returns the latest version of the expense with the given ID, or
+// sql.ErrNoRows when it has never been recorded.
+func GetChange(db Querier, id int) (Change, error) {
+	ch := Change{ExpenseID: id}
+	var owner sql.NullString
+	err := db.QueryRow("SELECT version, owner, deleted FROM expense_changes WHERE expense_id = $1", id).Scan(&ch.Version, &owner, &ch.Deleted)
+	ch.Owner = owner.String
+	return ch, err
+}
+
+// LatestVersion is the highest sync version recorded so far.
+func LatestVersion(db Querier) (int64, error) {
+	var version int64
+	err := db.QueryRow("SELECT COALESCE(max(version), 0) FROM expense_changes").Scan(&version)
+	return version, err
+}
+
+// GetChanges lists up to limit changes after since and up to until in
+// version order. With an owner only that owner's expenses are listed.
+func GetChanges(db Querier, since, until int64, owner string, limit int) ([]Change, error) {
+	query := "SELECT expense_id, version, deleted FROM expense_changes WHERE version > $1 AND version <= $2"
+	args := []any{since, until}
+	if owner != "" {
+		query += " AND owner = $3"
+		args = append(args, owner)
+	}
+	args = append(args, limit)
+	query += " ORDER BY version ASC LIMIT $" + strconv.Itoa(len(args))
+
+	changes := []Change{}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return changes, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		ch := Change{}
+		if err := rows.Scan(&ch.ExpenseID, &ch.Version, &ch.Deleted); err != nil {
+			return changes, err
+		}
+		changes = append(changes, ch)
+	}
+	return changes, rows.Err()
+}
@@ -1,11 +1,54 @@
 package expense
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/fx"
+	"github.com/phanbanchong/assessment/policy"
 )
 
+// CreateExpenseChecked creates exp owned by actor in its own transaction.
+// Unless force is set, an expense that looks like one the actor already has
+// is refused with a *DuplicateError; a blocking policy violation is refused
+// with a *PolicyError.
+func CreateExpenseChecked(db *sql.DB, actor audit.Actor, cfg policy.Config, exp Expense, force bool) (Expense, error) {
+	err := withTx(db, func(tx *sql.Tx) error {
+		var err error
+		exp, err = CreateExpenseCheckedTx(tx, actor, cfg, exp, force)
+		return err
+	})
+	return exp, err
+}
+
+// CreateExpenseCheckedTx is CreateExpenseChecked inside the caller's
+// transaction, for callers that write more rows along with the expense.
+func CreateExpenseCheckedTx(tx Querier, actor audit.Actor, cfg policy.Config, exp Expense, force bool) (Expense, error) {
+	if !force {
+		exp.Owner = actor.Name
+		if err := lockDuplicates(tx, exp.Owner); err != nil {
+			return exp, err
+		}
+		duplicates, err := FindDuplicates(tx, exp)
+		if err != nil {
+			return exp, err
+		}
+		if len(duplicates) > 0 {
+			return exp, duplicateError(duplicates)
+		}
+	}
+	exp, err := CreateExpenseAudited(tx, actor, exp)
+	if err != nil {
+		return exp, err
+	}
+	exp.Warnings, err = CheckNewPolicy(tx, cfg, exp)
+	return exp, err
+}
+
 func (h *handler) CreateExpenseHandler(c echo.Context) error {
 	exp := Expense{}
 	err := c.Bind(&exp)
@@ -15,8 +58,24 @@ func (h *handler) CreateExpenseHandler(c echo.Context) error {
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, Error{Message: err.Error()})
 	}
+	if err := ValidateExpense(exp); err != nil {
+		return c.JSON(http.StatusBadRequest, Error{Message: err.Error()})
+	}
 
-	exp, err = CreateExpense(h.DB, exp)
+	force, _ := strconv.ParseBool(c.QueryParam("force"))
+	exp.ReportingCurrency = h.reportingCurrency(c)
+	exp, err = CreateExpenseChecked(h.DB, audit.ActorFrom(c), h.Policy, exp, force)
+	var violation *PolicyError
+	if errors.As(err, &violation) {
+		return c.JSON(http.StatusUnprocessableEntity, violation)
+	}
+	var duplicate *DuplicateError
+	if errors.As(err, &duplicate) {
+		return c.JSON(http.StatusConflict, duplicate)
+	}
+	if errors.Is(err, fx.ErrNoRate) {
+		return c.JSON(http.StatusUnprocessableEntity, Error{Message: err.Error()})
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, Error{Message: err.Error()})
 	}
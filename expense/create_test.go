@@ -53,9 +53,19 @@ func TestCreateExpenseHandler(t *testing.T) {
 			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 		}
 		defer db.Close()
+		mock.ExpectBegin()
+		expectNoDuplicates(mock)
 		mock.ExpectQuery("INSERT INTO expenses").
-			WithArgs("title", 1.0, "note", pq.Array([]string{"tag1", "tag2"})).
+			WithArgs("title", 1.0, "note", pq.Array([]string{"tag1", "tag2"}), nil, nil, "anonymous", "THB", 1.0, "THB", 1.0, nil, nil).
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT hash FROM expense_audit").WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+		mock.ExpectQuery("INSERT INTO expense_audit").
+			WithArgs(1, "create", "anonymous", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO outbox").WithArgs(EventCreated, 1, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO expense_changes").WithArgs(1, sqlmock.AnyArg(), false, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 
 		h := NewApplication(db)
 		//Mock Echo Context
@@ -68,6 +78,12 @@ func TestCreateExpenseHandler(t *testing.T) {
 		if err = h.CreateExpenseHandler(c); err != nil {
 			t.Errorf("should not return error but it got %v", err)
 		}
+		if rec.Code != http.StatusCreated {
+			t.Errorf("should status created but it got %v", rec.Code)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
 	})
 
 	t.Run("Create expense with invalid ID should got error", func(t *testing.T) {
@@ -124,9 +140,12 @@ func TestCreateExpenseHandler(t *testing.T) {
 			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 		}
 		defer db.Close()
+		mock.ExpectBegin()
+		expectNoDuplicates(mock)
 		mock.ExpectQuery("INSERT INTO expenses").
-			WithArgs("title", 1.0, "note", pq.Array([]string{"tag1", "tag2"})).
+			WithArgs("title", 1.0, "note", pq.Array([]string{"tag1", "tag2"}), nil, nil, "anonymous", "THB", 1.0, "THB", 1.0, nil, nil).
 			WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
 		h := NewApplication(db)
 
 		//Mock Echo Context
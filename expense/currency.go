@@ -0,0 +1,60 @@
+package expense
+
+import (
+	"math"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/auth"
+	"github.com/phanbanchong/assessment/fx"
+)
+
+// validCurrency reports whether code is shaped like an ISO 4217 code.
+func validCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Convert fills in the reporting amount of exp and the rate used, looking the
+// rate up for the expense date, or today when it has none. An expense without
+// a currency is in its reporting currency; one without a reporting currency
+// is left unconverted.
+func Convert(db Querier, exp Expense) (Expense, error) {
+	if exp.ReportingCurrency == "" {
+		return exp, nil
+	}
+	if exp.Currency == "" {
+		exp.Currency = exp.ReportingCurrency
+	}
+	if exp.Currency == exp.ReportingCurrency {
+		exp.ReportingAmount, exp.Rate, exp.RateDate = exp.Amount, 1, ""
+		return exp, nil
+	}
+	date := exp.Date
+	if date == "" {
+		date = time.Now().UTC().Format(DateLayout)
+	}
+	rate, rateDate, err := fx.Lookup(db, exp.Currency, exp.ReportingCurrency, date)
+	if err != nil {
+		return exp, err
+	}
+	exp.Rate, exp.RateDate = rate, rateDate
+	exp.ReportingAmount = math.Round(exp.Amount*rate*100) / 100
+	return exp, nil
+}
+
+// reportingCurrency is the currency new expenses of the caller are reported
+// in: their own when configured, otherwise the organisation's.
+func (h *handler) reportingCurrency(c echo.Context) string {
+	if currency := auth.UserFrom(c).Currency; currency != "" {
+		return currency
+	}
+	return h.Currency
+}
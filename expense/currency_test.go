@@ -0,0 +1,103 @@
+//go:build unit
+
+package expense
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/auth"
+)
+
+func currencyContext(body string, user auth.User) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/expenses?force=true", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	auth.SetUser(c, user)
+	return c, rec
+}
+
+func TestCreateExpenseCurrency(t *testing.T) {
+	t.Run("Create foreign expense should convert to the owner reporting currency", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT rate, date FROM exchange_rates").
+			WithArgs("USD", "2026-01-04").
+			WillReturnRows(sqlmock.NewRows([]string{"rate", "date"}).AddRow(1.09, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)))
+		mock.ExpectQuery("SELECT rate, date FROM exchange_rates").
+			WithArgs("THB", "2026-01-04").
+			WillReturnRows(sqlmock.NewRows([]string{"rate", "date"}).AddRow(37.5, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)))
+		mock.ExpectQuery("INSERT INTO expenses").
+			WithArgs("hotel", 100.0, "", pq.Array([]string{}), "2026-01-04", nil, "alice", "USD", 3440.37, "THB", 34.40367, "2026-01-02", nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT hash FROM expense_audit").WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+		mock.ExpectQuery("INSERT INTO expense_audit").
+			WithArgs(1, "create", "alice", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO outbox").WithArgs(EventCreated, 1, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO expense_changes").WithArgs(1, sqlmock.AnyArg(), false, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		h := NewApplication(db)
+		h.Currency = "EUR"
+		c, rec := currencyContext(`{"title":"hotel","amount":100,"tags":[],"date":"2026-01-04","currency":"USD"}`, auth.User{Name: "alice", Currency: "THB"})
+		if err := h.CreateExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"id":1,"title":"hotel","amount":100,"note":"","tags":[],"date":"2026-01-04","status":"draft","owner":"alice",` +
+			`"currency":"USD","reporting_amount":3440.37,"reporting_currency":"THB","rate":34.40367,"rate_date":"2026-01-02"}` + "\n"
+		if rec.Code != http.StatusCreated || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Create expense without a rate should be unprocessable", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT rate, date FROM exchange_rates").
+			WithArgs("JPY", "2026-01-04").
+			WillReturnRows(sqlmock.NewRows([]string{"rate", "date"}))
+		mock.ExpectRollback()
+
+		c, rec := currencyContext(`{"title":"sushi","amount":3000,"tags":[],"date":"2026-01-04","currency":"JPY"}`, auth.User{Name: "alice"})
+		if err := NewApplication(db).CreateExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusUnprocessableEntity || rec.Body.String() != `{"message":"No exchange rate for JPY on 2026-01-04"}`+"\n" {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Create expense with invalid currency should got error", func(t *testing.T) {
+		c, rec := currencyContext(`{"title":"sushi","amount":3000,"currency":"yen"}`, auth.User{Name: "alice"})
+		if err := NewApplication(nil).CreateExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusBadRequest || rec.Body.String() != `{"message":"Field currency is invalid"}`+"\n" {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+}
@@ -1,48 +1,145 @@
 package expense
 
 import (
+	"context"
 	"database/sql"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/labstack/gommon/log"
 
 	"github.com/lib/pq"
 )
 
+const DateLayout = "2006-01-02"
+
+const expenseColumns = "id, title, amount, note, tags, date, recurring_id, external_ref, status, owner, report_id, currency, reporting_amount, reporting_currency, rate, rate_date"
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
 func InitDB() (*sql.DB, error) {
 	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
 	if err != nil {
 		log.Fatal("Connection to database error", err)
 	}
 
-	command := "CREATE TABLE IF NOT EXISTS expenses ( id SERIAL PRIMARY KEY, title TEXT, amount FLOAT, note TEXT, tags TEXT[]);"
+	commands := []string{
+		"CREATE TABLE IF NOT EXISTS expenses ( id SERIAL PRIMARY KEY, title TEXT, amount FLOAT, note TEXT, tags TEXT[]);",
+		"ALTER TABLE expenses ADD COLUMN IF NOT EXISTS date DATE;",
+		"ALTER TABLE expenses ADD COLUMN IF NOT EXISTS recurring_id INT;",
+		"CREATE UNIQUE INDEX IF NOT EXISTS expenses_recurring_id_date_key ON expenses (recurring_id, date);",
+		"ALTER TABLE expenses ADD COLUMN IF NOT EXISTS external_ref TEXT;",
+		"CREATE UNIQUE INDEX IF NOT EXISTS expenses_external_ref_key ON expenses (external_ref);",
+		"ALTER TABLE expenses ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'draft';",
+		"ALTER TABLE expenses ADD COLUMN IF NOT EXISTS owner TEXT;",
+		"CREATE TABLE IF NOT EXISTS expense_transitions ( id SERIAL PRIMARY KEY, expense_id INT NOT NULL REFERENCES expenses (id) ON DELETE CASCADE, from_status TEXT NOT NULL, to_status TEXT NOT NULL, actor TEXT NOT NULL, reason TEXT, request_id TEXT, created_at TIMESTAMPTZ NOT NULL DEFAULT now());",
+		"CREATE INDEX IF NOT EXISTS expense_transitions_expense_id_idx ON expense_transitions (expense_id, id);",
+		"ALTER TABLE expenses ADD COLUMN IF NOT EXISTS report_id INT;",
+		"CREATE INDEX IF NOT EXISTS expenses_owner_date_idx ON expenses (owner, date);",
+		"ALTER TABLE expenses ADD COLUMN IF NOT EXISTS currency TEXT;",
+		"ALTER TABLE expenses ADD COLUMN IF NOT EXISTS reporting_amount FLOAT;",
+		"ALTER TABLE expenses ADD COLUMN IF NOT EXISTS reporting_currency TEXT;",
+		"ALTER TABLE expenses ADD COLUMN IF NOT EXISTS rate FLOAT;",
+		"ALTER TABLE expenses ADD COLUMN IF NOT EXISTS rate_date DATE;",
+		"CREATE SEQUENCE IF NOT EXISTS expense_change_seq;",
+		"CREATE TABLE IF NOT EXISTS expense_changes ( expense_id INT PRIMARY KEY, version BIGINT NOT NULL, owner TEXT, deleted BOOLEAN NOT NULL DEFAULT false, changed_at TIMESTAMPTZ NOT NULL DEFAULT now());",
+		"CREATE INDEX IF NOT EXISTS expense_changes_version_idx ON expense_changes (version);",
+		"INSERT INTO expense_changes (expense_id, version, owner) SELECT id, nextval('expense_change_seq'), owner FROM expenses e WHERE NOT EXISTS (SELECT 1 FROM expense_changes c WHERE c.expense_id = e.id);",
+	}
 
-	_, err = db.Exec(string(command))
-	if err != nil {
-		log.Fatal("Unable to create table", err)
-	} else {
-		log.Printf("Executed: %s", command)
+	for _, command := range commands {
+		_, err = db.Exec(command)
+		if err != nil {
+			log.Fatal("Unable to create table", err)
+		} else {
+			log.Printf("Executed: %s", command)
+		}
 	}
 	return db, err
 }
 
-func GetExpenseByID(db *sql.DB, id int) (Expense, error) {
+func scanExpense(row scanner) (Expense, error) {
 	exp := Expense{}
-	stmt, err := db.Prepare("SELECT id, title, amount, note, tags FROM expenses WHERE id = $1")
+	var date, rateDate sql.NullTime
+	var recurringID, reportID sql.NullInt64
+	var externalRef, owner, currency, reportingCurrency sql.NullString
+	var reportingAmount, rate sql.NullFloat64
+	err := row.Scan(&exp.ID, &exp.Title, &exp.Amount, &exp.Note, pq.Array(&exp.Tags), &date, &recurringID, &externalRef, &exp.Status, &owner, &reportID,
+		&currency, &reportingAmount, &reportingCurrency, &rate, &rateDate)
+	if date.Valid {
+		exp.Date = date.Time.Format(DateLayout)
+	}
+	if rateDate.Valid {
+		exp.RateDate = rateDate.Time.Format(DateLayout)
+	}
+	exp.RecurringID = int(recurringID.Int64)
+	exp.ExternalRef = externalRef.String
+	exp.Owner = owner.String
+	exp.ReportID = int(reportID.Int64)
+	exp.Currency = currency.String
+	exp.ReportingAmount = reportingAmount.Float64
+	exp.ReportingCurrency = reportingCurrency.String
+	exp.Rate = rate.Float64
+	return exp, err
+}
+
+func nullDate(date string) any {
+	return nullString(date)
+}
+
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nullID(id int) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// nullFloat stores v only for expenses converted to a reporting currency, so
+// unconverted rows keep NULL rather than a misleading zero.
+func nullFloat(reportingCurrency string, v float64) any {
+	if reportingCurrency == "" {
+		return nil
+	}
+	return v
+}
+
+func ValidDate(date string) bool {
+	if date == "" {
+		return true
+	}
+	_, err := time.Parse(DateLayout, date)
+	return err == nil
+}
+
+func GetExpenseByID(db Querier, id int) (Expense, error) {
+	stmt, err := db.Prepare("SELECT " + expenseColumns + " FROM expenses WHERE id = $1")
 	if err != nil {
-		return exp, err
+		return Expense{}, err
 	}
 
 	rows := stmt.QueryRow(id)
 	if rows.Err() != nil {
-		return exp, rows.Err()
+		return Expense{}, rows.Err()
 	}
-	err = rows.Scan(&exp.ID, &exp.Title, &exp.Amount, &exp.Note, pq.Array(&exp.Tags))
-	return exp, err
+	return scanExpense(rows)
 }
 
-func CreateExpense(db *sql.DB, exp Expense) (Expense, error) {
-	row := db.QueryRow("INSERT INTO expenses (title, amount, note, tags) values ($1, $2, $3, $4) RETURNING id", exp.Title, exp.Amount, exp.Note, pq.Array(exp.Tags))
+// CreateExpense inserts exp as a draft.
+func CreateExpense(db Querier, exp Expense) (Expense, error) {
+	exp.Status = StatusDraft
+	row := db.QueryRow("INSERT INTO expenses (title, amount, note, tags, date, external_ref, owner, currency, reporting_amount, reporting_currency, rate, rate_date, recurring_id) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id",
+		exp.Title, exp.Amount, exp.Note, pq.Array(exp.Tags), nullDate(exp.Date), nullString(exp.ExternalRef), nullString(exp.Owner),
+		nullString(exp.Currency), nullFloat(exp.ReportingCurrency, exp.ReportingAmount), nullString(exp.ReportingCurrency), nullFloat(exp.ReportingCurrency, exp.Rate), nullDate(exp.RateDate), nullID(exp.RecurringID))
 	err := row.Scan(&exp.ID)
 	if err != nil {
 		log.Errorf("Insert expense error: %v", err)
@@ -51,34 +148,51 @@ func CreateExpense(db *sql.DB, exp Expense) (Expense, error) {
 	return exp, nil
 }
 
-func UpdateExpense(db *sql.DB, exp Expense) (Expense, error) {
-	stmt, err := db.Prepare("UPDATE expenses SET title=$2, amount=$3, note=$4, tags=$5 WHERE id = $1")
+func UpdateExpense(db Querier, exp Expense) (Expense, error) {
+	stmt, err := db.Prepare("UPDATE expenses SET title=$2, amount=$3, note=$4, tags=$5, date=$6, currency=$7, reporting_amount=$8, reporting_currency=$9, rate=$10, rate_date=$11 WHERE id = $1")
 	if err != nil {
 		return exp, err
 	}
 
-	if _, err := stmt.Exec(exp.ID, exp.Title, exp.Amount, exp.Note, pq.Array(exp.Tags)); err != nil {
+	res, err := stmt.Exec(exp.ID, exp.Title, exp.Amount, exp.Note, pq.Array(exp.Tags), nullDate(exp.Date),
+		nullString(exp.Currency), nullFloat(exp.ReportingCurrency, exp.ReportingAmount), nullString(exp.ReportingCurrency), nullFloat(exp.ReportingCurrency, exp.Rate), nullDate(exp.RateDate))
+	if err != nil {
 		log.Errorf("Update expense error: %v", err)
 		return exp, err
 	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return exp, sql.ErrNoRows
+	}
 	return exp, nil
 }
 
-func GetExpenses(db *sql.DB) ([]Expense, error) {
+func DeleteExpense(db Querier, id int) error {
+	res, err := db.Exec("DELETE FROM expenses WHERE id = $1", id)
+	if err != nil {
+		log.Errorf("Delete expense error: %v", err)
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func GetExpenses(db *sql.DB, f Filter) ([]Expense, error) {
 	expenses := []Expense{}
-	stmt, err := db.Prepare("SELECT id, title, amount, note, tags FROM expenses ORDER BY id ASC")
+	where, args := f.where()
+	stmt, err := db.Prepare("SELECT " + expenseColumns + " FROM expenses" + where + " ORDER BY id ASC")
 	if err != nil {
 		return expenses, err
 	}
 
-	rows, err := stmt.Query()
+	rows, err := stmt.Query(args...)
 	if err != nil {
 		return expenses, err
 	}
 
 	for rows.Next() {
-		expense := Expense{}
-		err := rows.Scan(&expense.ID, &expense.Title, &expense.Amount, &expense.Note, pq.Array(&expense.Tags))
+		expense, err := scanExpense(rows)
 		if err != nil {
 			return expenses, err
 		}
@@ -86,3 +200,169 @@ func GetExpenses(db *sql.DB) ([]Expense, error) {
 	}
 	return expenses, nil
 }
+
+// GetExpensesPage lists up to limit expenses matching f with IDs above after
+// in ID order, so a page can resume where the previous one ended.
+func GetExpensesPage(db Querier, f Filter, after, limit int) ([]Expense, error) {
+	where, args := f.where()
+	if where == "" {
+		where = " WHERE "
+	} else {
+		where += " AND "
+	}
+	args = append(args, after)
+	where += "id > $" + strconv.Itoa(len(args))
+	args = append(args, limit)
+	return queryExpenses(db, "SELECT "+expenseColumns+" FROM expenses"+where+" ORDER BY id ASC LIMIT $"+strconv.Itoa(len(args)), args...)
+}
+
+// Total sums the expenses reported in one currency. Expenses stored before
+// currencies were tracked are summed by their plain amount under an empty
+// currency.
+type Total struct {
+	Currency string  `json:"currency"`
+	Count    int     `json:"count"`
+	Amount   float64 `json:"amount"`
+}
+
+// TagTotal is a Total of the expenses carrying Tag.
+type TagTotal struct {
+	Tag string `json:"tag"`
+	Total
+}
+
+// GetTotals sums the expenses matching f per reporting currency.
+func GetTotals(db Querier, f Filter) ([]Total, error) {
+	totals := []Total{}
+	where, args := f.where()
+	rows, err := db.Query("SELECT COALESCE(reporting_currency, ''), count(*), COALESCE(sum(COALESCE(reporting_amount, amount)), 0) FROM expenses"+where+" GROUP BY 1 ORDER BY 1", args...)
+	if err != nil {
+		return totals, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		t := Total{}
+		if err := rows.Scan(&t.Currency, &t.Count, &t.Amount); err != nil {
+			return totals, err
+		}
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
+
+// GetTagTotals sums the expenses matching f per tag and reporting currency,
+// in tag order.
+func GetTagTotals(db Querier, f Filter) ([]TagTotal, error) {
+	totals := []TagTotal{}
+	where, args := f.where()
+	rows, err := db.Query("SELECT tag, COALESCE(reporting_currency, ''), count(*), COALESCE(sum(COALESCE(reporting_amount, amount)), 0) FROM expenses CROSS JOIN unnest(tags) AS tag"+where+" GROUP BY 1, 2 ORDER BY 1, 2", args...)
+	if err != nil {
+		return totals, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		t := TagTotal{}
+		if err := rows.Scan(&t.Tag, &t.Currency, &t.Count, &t.Amount); err != nil {
+			return totals, err
+		}
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
+
+// EachExpense streams the expenses matching f from a database cursor, calling
+// fn for every row without loading the whole result set.
+func EachExpense(ctx context.Context, db *sql.DB, f Filter, fn func(Expense) error) error {
+	where, args := f.where()
+	rows, err := db.QueryContext(ctx, "SELECT "+expenseColumns+" FROM expenses"+where+" ORDER BY id ASC", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		exp, err := scanExpense(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(exp); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// FindStatementMatches looks for rows a bank transaction may already be
+// recorded as: the row imported under the same external reference, and rows
+// with the same date and amount entered some other way.
+func FindStatementMatches(db Querier, exp Expense) (existing int, candidates []int, err error) {
+	err = db.QueryRow("SELECT id FROM expenses WHERE external_ref = $1", exp.ExternalRef).Scan(&existing)
+	switch err {
+	case nil:
+		return existing, nil, nil
+	case sql.ErrNoRows:
+	default:
+		return 0, nil, err
+	}
+
+	rows, err := db.Query("SELECT id FROM expenses WHERE date = $1 AND abs(amount - $2) < 0.005 ORDER BY id ASC", nullDate(exp.Date), exp.Amount)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return 0, nil, err
+		}
+		candidates = append(candidates, id)
+	}
+	return 0, candidates, rows.Err()
+}
+
+// CreateExpenseIfNew inserts exp unless a row with the same external_ref
+// exists, reporting whether it was inserted.
+func CreateExpenseIfNew(db Querier, exp Expense) (Expense, bool, error) {
+	exp.Status = StatusDraft
+	row := db.QueryRow("INSERT INTO expenses (title, amount, note, tags, date, external_ref, owner, currency, reporting_amount, reporting_currency, rate, rate_date) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) ON CONFLICT (external_ref) DO NOTHING RETURNING id",
+		exp.Title, exp.Amount, exp.Note, pq.Array(exp.Tags), nullDate(exp.Date), nullString(exp.ExternalRef), nullString(exp.Owner),
+		nullString(exp.Currency), nullFloat(exp.ReportingCurrency, exp.ReportingAmount), nullString(exp.ReportingCurrency), nullFloat(exp.ReportingCurrency, exp.Rate), nullDate(exp.RateDate))
+	switch err := row.Scan(&exp.ID); err {
+	case nil:
+		return exp, true, nil
+	case sql.ErrNoRows:
+		return exp, false, nil
+	default:
+		log.Errorf("Insert expense error: %v", err)
+		return exp, false, err
+	}
+}
+
+// LockExpenses loads the expenses with the given IDs, locking them for the
+// rest of the caller's transaction. Missing IDs are left out.
+func LockExpenses(db Querier, ids []int) ([]Expense, error) {
+	return queryExpenses(db, "SELECT "+expenseColumns+" FROM expenses WHERE id = ANY($1) ORDER BY id ASC FOR UPDATE", pq.Array(ids))
+}
+
+// GetExpensesByID loads the expenses with the given IDs in ID order. Missing
+// IDs are left out.
+func GetExpensesByID(db Querier, ids []int) ([]Expense, error) {
+	return queryExpenses(db, "SELECT "+expenseColumns+" FROM expenses WHERE id = ANY($1) ORDER BY id ASC", pq.Array(ids))
+}
+
+func queryExpenses(db Querier, query string, args ...any) ([]Expense, error) {
+	expenses := []Expense{}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return expenses, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		exp, err := scanExpense(rows)
+		if err != nil {
+			return expenses, err
+		}
+		expenses = append(expenses, exp)
+	}
+	return expenses, rows.Err()
+}
@@ -23,7 +23,7 @@ func TestCreateExpense(t *testing.T) {
 	defer db.Close()
 
 	mock.ExpectQuery("INSERT INTO expenses").
-		WithArgs("title", 1.0, "note", pq.Array([]string{"tag1", "tag2"})).
+		WithArgs("title", 1.0, "note", pq.Array([]string{"tag1", "tag2"}), nil, nil, nil, nil, nil, nil, nil, nil, nil).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
 
 	// Now we execute our method
@@ -46,10 +46,10 @@ func TestGetExpense(t *testing.T) {
 	}
 	defer db.Close()
 
-	mockRows := sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags"}).
-		AddRow(2, "expense 2", 2.0, "note 2", pq.Array([]string{"tag1", "tag2"}))
+	mockRows := sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}).
+		AddRow(2, "expense 2", 2.0, "note 2", pq.Array([]string{"tag1", "tag2"}), nil, nil, nil, "draft", nil, nil, nil, nil, nil, nil, nil)
 
-	mock.ExpectPrepare("SELECT id, title, amount, note, tags FROM expenses WHERE id = $1").
+	mock.ExpectPrepare("SELECT id, title, amount, note, tags, date, recurring_id, external_ref, status, owner, report_id, currency, reporting_amount, reporting_currency, rate, rate_date FROM expenses WHERE id = $1").
 		ExpectQuery().
 		WithArgs(ID).
 		WillReturnRows(mockRows)
@@ -79,9 +79,9 @@ func TestUpdateExpense(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectPrepare("UPDATE expenses SET title=$2, amount=$3, note=$4, tags=$5 WHERE id = $1").
+	mock.ExpectPrepare("UPDATE expenses SET title=$2, amount=$3, note=$4, tags=$5, date=$6, currency=$7, reporting_amount=$8, reporting_currency=$9, rate=$10, rate_date=$11 WHERE id = $1").
 		ExpectExec().
-		WithArgs(exp.ID, exp.Title, exp.Amount, exp.Note, pq.Array(exp.Tags)).
+		WithArgs(exp.ID, exp.Title, exp.Amount, exp.Note, pq.Array(exp.Tags), nil, nil, nil, nil, nil, nil).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	// Now we execute our method
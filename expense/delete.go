@@ -0,0 +1,34 @@
+package expense
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/auth"
+)
+
+func (h *handler) DeleteExpenseHandler(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Error{Message: "ID is invalid"})
+	}
+	err = withTx(h.DB, func(tx *sql.Tx) error {
+		_, err := DeleteExpenseAudited(tx, audit.ActorFrom(c), auth.UserFrom(c), id)
+		return err
+	})
+	switch err {
+	case nil:
+		return c.NoContent(http.StatusNoContent)
+	case sql.ErrNoRows:
+		return c.JSON(http.StatusNotFound, Error{Message: "Expense not found"})
+	case ErrLocked, ErrGroupExpense:
+		return c.JSON(http.StatusConflict, Error{Message: err.Error()})
+	case ErrNotOwner:
+		return c.JSON(http.StatusForbidden, Error{Message: err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError, Error{Message: err.Error()})
+	}
+}
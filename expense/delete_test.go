@@ -0,0 +1,153 @@
+//go:build unit
+
+package expense
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/auth"
+)
+
+func TestDeleteExpenseHandler(t *testing.T) {
+	t.Run("Delete expense should be success", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT id, title, amount, note, tags, date, recurring_id, external_ref, status, owner, report_id, currency, reporting_amount, reporting_currency, rate, rate_date FROM expenses WHERE id = $1 FOR UPDATE").
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}).
+				AddRow(3, "title", 1.0, "note", pq.Array([]string{"tag1"}), nil, nil, nil, "draft", "anonymous", nil, nil, nil, nil, nil, nil))
+		mock.ExpectQuery("SELECT EXISTS (SELECT 1 FROM group_expenses WHERE expense_id = $1)").
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectExec("DELETE FROM expenses WHERE id = $1").
+			WithArgs(3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("SELECT pg_advisory_xact_lock($1)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT hash FROM expense_audit WHERE hash IS NOT NULL ORDER BY id DESC LIMIT 1").WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+		mock.ExpectQuery("INSERT INTO expense_audit (expense_id, action, actor, request_id, changes, created_at, prev_hash, hash) values ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id").
+			WithArgs(3, "delete", "anonymous", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO outbox (event, expense_id, payload) values ($1, $2, $3)").WithArgs(EventDeleted, 3, []byte(`{"id":3,"title":"title","amount":1,"note":"note","tags":["tag1"],"status":"draft","owner":"anonymous"}`)).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO expense_changes (expense_id, version, owner, deleted, changed_at) SELECT $1, nextval('expense_change_seq'), $2, $3, now() FROM pg_advisory_xact_lock($4) ON CONFLICT (expense_id) DO UPDATE SET version = EXCLUDED.version, owner = EXCLUDED.owner, deleted = EXCLUDED.deleted, changed_at = EXCLUDED.changed_at").WithArgs(3, sqlmock.AnyArg(), true, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		h := NewApplication(db)
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodDelete, "/expenses/3", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("3")
+
+		if err := h.DeleteExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("should status no content but it got %v", rec.Code)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Delete missing expense should got error", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT id, title, amount, note, tags, date, recurring_id, external_ref, status, owner, report_id, currency, reporting_amount, reporting_currency, rate, rate_date FROM expenses WHERE id = $1 FOR UPDATE").
+			WithArgs(3).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		h := NewApplication(db)
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodDelete, "/expenses/3", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("3")
+
+		if err := h.DeleteExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"message":"Expense not found"}` + "\n"
+		if rec.Code != http.StatusNotFound || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %s", rec.Body.String())
+		}
+	})
+
+	t.Run("Delete group expense should be a conflict", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		expectStatus(mock, 3, StatusDraft, "alice")
+		mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM group_expenses").
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectRollback()
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodDelete, "/expenses/3", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("3")
+		auth.SetUser(c, auth.User{Name: "alice"})
+
+		if err := NewApplication(db).DeleteExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"message":"Expense is split in a group and can only change through the group"}` + "\n"
+		if rec.Code != http.StatusConflict || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Delete another user's expense should got not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		expectStatus(mock, 3, StatusDraft, "alice")
+		mock.ExpectRollback()
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodDelete, "/expenses/3", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("3")
+		auth.SetUser(c, auth.User{Name: "bob"})
+
+		if err := NewApplication(db).DeleteExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("should status not found but it got %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
@@ -0,0 +1,234 @@
+package expense
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	duplicateDays      = 3
+	duplicateAmountPct = 0.01
+	duplicateTitle     = 0.75
+)
+
+// duplicateLock is the advisory lock class serialising the duplicate check
+// and insert of one owner's expenses, keyed on the owner.
+const duplicateLock = 7283005
+
+type DuplicateCandidate struct {
+	ID     int     `json:"id"`
+	Title  string  `json:"title"`
+	Amount float64 `json:"amount"`
+	Date   string  `json:"date,omitempty"`
+	Href   string  `json:"href"`
+}
+
+// DuplicateError is returned when a new expense looks like one its owner
+// already filed. It is written as the body of the 409 response.
+type DuplicateError struct {
+	Message    string               `json:"message"`
+	Candidates []DuplicateCandidate `json:"candidates"`
+}
+
+func (e *DuplicateError) Error() string {
+	return e.Message
+}
+
+type DuplicateGroup struct {
+	Owner    string    `json:"owner,omitempty"`
+	Expenses []Expense `json:"expenses"`
+}
+
+func amountTolerance(amount float64) float64 {
+	return math.Max(0.01, math.Abs(amount)*duplicateAmountPct)
+}
+
+// normalizeTitle lowercases s and reduces it to letters and digits separated
+// by single spaces, so punctuation and spacing do not hide a match.
+func normalizeTitle(s string) string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(fields, " ")
+}
+
+// titleSimilarity returns 1 minus the edit distance of the normalized titles
+// relative to the longer one.
+func titleSimilarity(a, b string) float64 {
+	ra, rb := []rune(normalizeTitle(a)), []rune(normalizeTitle(b))
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func similarTitle(a, b string) bool {
+	return titleSimilarity(a, b) >= duplicateTitle
+}
+
+// FindDuplicates returns the stored expenses of the same owner that exp most
+// likely duplicates: the same currency, an amount within 1%, a date at most
+// three days apart and a similar title. Undated expenses only match undated
+// ones. An expense without a currency is in its reporting currency.
+func FindDuplicates(db Querier, exp Expense) ([]Expense, error) {
+	currency := exp.Currency
+	if currency == "" {
+		currency = exp.ReportingCurrency
+	}
+	query := "SELECT " + expenseColumns + " FROM expenses WHERE owner = $1 AND coalesce(currency, reporting_currency, '') = $2 AND abs(amount - $3) <= $4"
+	args := []any{exp.Owner, currency, exp.Amount, amountTolerance(exp.Amount)}
+	if exp.Date == "" {
+		query += " AND date IS NULL"
+	} else {
+		date, err := time.Parse(DateLayout, exp.Date)
+		if err != nil {
+			return nil, err
+		}
+		query += " AND date BETWEEN $5 AND $6"
+		args = append(args, date.AddDate(0, 0, -duplicateDays).Format(DateLayout), date.AddDate(0, 0, duplicateDays).Format(DateLayout))
+	}
+	candidates, err := queryExpenses(db, query+" ORDER BY id ASC", args...)
+	if err != nil {
+		return nil, err
+	}
+	duplicates := []Expense{}
+	for _, candidate := range candidates {
+		if similarTitle(exp.Title, candidate.Title) {
+			duplicates = append(duplicates, candidate)
+		}
+	}
+	return duplicates, nil
+}
+
+// lockDuplicates holds the duplicate lock of owner until the end of the
+// transaction, so two copies of one expense created at once cannot both
+// pass the duplicate check.
+func lockDuplicates(tx Querier, owner string) error {
+	_, err := tx.Exec("SELECT pg_advisory_xact_lock($1, hashtext($2))", duplicateLock, owner)
+	return err
+}
+
+func duplicateError(duplicates []Expense) *DuplicateError {
+	err := &DuplicateError{Message: "Expense looks like a duplicate, retry with force=true to create it anyway"}
+	for _, d := range duplicates {
+		err.Candidates = append(err.Candidates, DuplicateCandidate{
+			ID:     d.ID,
+			Title:  d.Title,
+			Amount: d.Amount,
+			Date:   d.Date,
+			Href:   fmt.Sprintf("/expenses/%d", d.ID),
+		})
+	}
+	return err
+}
+
+// GetDuplicateGroups scans the expenses of owner, or all expenses when owner
+// is empty, for likely duplicates using the same rules as FindDuplicates and
+// groups every connected set of matches.
+func GetDuplicateGroups(db Querier, owner string) ([]DuplicateGroup, error) {
+	query := "SELECT a.id, a.title, b.id, b.title FROM expenses a JOIN expenses b ON b.id > a.id AND a.owner IS NOT DISTINCT FROM b.owner AND coalesce(a.currency, a.reporting_currency, '') = coalesce(b.currency, b.reporting_currency, '') AND abs(a.amount - b.amount) <= greatest(0.01, abs(a.amount) * $1) AND ((a.date IS NULL AND b.date IS NULL) OR abs(a.date - b.date) <= $2)"
+	args := []any{duplicateAmountPct, duplicateDays}
+	if owner != "" {
+		query += " WHERE a.owner = $3"
+		args = append(args, owner)
+	}
+	rows, err := db.Query(query+" ORDER BY a.id ASC, b.id ASC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	parent := map[int]int{}
+	var find func(id int) int
+	find = func(id int) int {
+		if p, ok := parent[id]; ok && p != id {
+			parent[id] = find(p)
+			return parent[id]
+		}
+		parent[id] = id
+		return id
+	}
+	for rows.Next() {
+		var a, b int
+		var titleA, titleB string
+		if err := rows.Scan(&a, &titleA, &b, &titleB); err != nil {
+			return nil, err
+		}
+		if similarTitle(titleA, titleB) {
+			parent[find(b)] = find(a)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := []int{}
+	for id := range parent {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	expenses, err := GetExpensesByID(db, ids)
+	if err != nil {
+		return nil, err
+	}
+	groups := []DuplicateGroup{}
+	index := map[int]int{}
+	for _, exp := range expenses {
+		root := find(exp.ID)
+		i, ok := index[root]
+		if !ok {
+			i = len(groups)
+			index[root] = i
+			groups = append(groups, DuplicateGroup{Owner: exp.Owner})
+		}
+		groups[i].Expenses = append(groups[i].Expenses, exp)
+	}
+	return groups, nil
+}
+
+// GetDuplicatesHandler reports groups of existing expenses that look like
+// the same receipt filed more than once.
+func (h *handler) GetDuplicatesHandler(c echo.Context) error {
+	groups, err := GetDuplicateGroups(h.DB, VisibleOwner(c))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Error{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, groups)
+}
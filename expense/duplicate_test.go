@@ -0,0 +1,203 @@
+//go:build unit
+
+package expense
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/auth"
+)
+
+var duplicateColumns = []string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}
+
+func expectNoDuplicates(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WithArgs(duplicateLock, "anonymous").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT (.+) FROM expenses WHERE owner = (.+)").WillReturnRows(sqlmock.NewRows(duplicateColumns))
+}
+
+func TestTitleSimilarity(t *testing.T) {
+	cases := []struct {
+		a, b    string
+		similar bool
+	}{
+		{"Taxi to airport", "taxi - to airport!", true},
+		{"Taxi to airport", "Taxi airport", true},
+		{"Grab taxi", "Grab taxi.", true},
+		{"Hotel", "Taxi", false},
+		{"Lunch with team", "Dinner with client", false},
+	}
+	for _, tc := range cases {
+		if got := similarTitle(tc.a, tc.b); got != tc.similar {
+			t.Errorf("similarTitle(%q, %q) should be %v but it got %v", tc.a, tc.b, tc.similar, got)
+		}
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	cases := []struct {
+		name     string
+		exp      Expense
+		currency string
+	}{
+		{"Find duplicates should only match the same currency", Expense{Owner: "alice", Amount: 10, Currency: "USD", ReportingCurrency: "THB"}, "USD"},
+		{"Find duplicates without a currency should match the reporting currency", Expense{Owner: "alice", Amount: 10, ReportingCurrency: "THB"}, "THB"},
+		{"Find duplicates without any currency should match legacy expenses", Expense{Owner: "alice", Amount: 10}, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			mock.ExpectQuery("SELECT (.+) FROM expenses WHERE owner = \\$1 AND coalesce\\(currency, reporting_currency, ''\\) = \\$2 AND (.+) AND date IS NULL").
+				WithArgs("alice", tc.currency, 10.0, 0.1).
+				WillReturnRows(sqlmock.NewRows(duplicateColumns))
+
+			if _, err := FindDuplicates(db, tc.exp); err != nil {
+				t.Errorf("should not return error but it got %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestCreateExpenseDuplicates(t *testing.T) {
+	body := `{"title":"Taxi to airport","amount":250,"tags":[],"date":"2026-01-05"}`
+
+	t.Run("Create likely duplicate should be a conflict with candidates", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").WithArgs(duplicateLock, "anonymous").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE owner = (.+) AND date BETWEEN").
+			WithArgs("anonymous", "THB", 250.0, 2.5, "2026-01-02", "2026-01-08").
+			WillReturnRows(sqlmock.NewRows(duplicateColumns).
+				AddRow(4, "taxi airport", 251.0, "", pq.Array([]string{}), time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC), nil, nil, "draft", "anonymous", nil, nil, nil, nil, nil, nil).
+				AddRow(5, "Hotel", 250.0, "", pq.Array([]string{}), time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC), nil, nil, "draft", "anonymous", nil, nil, nil, nil, nil, nil))
+		mock.ExpectRollback()
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/expenses", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		if err := NewApplication(db).CreateExpenseHandler(e.NewContext(req, rec)); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"message":"Expense looks like a duplicate, retry with force=true to create it anyway","candidates":[` +
+			`{"id":4,"title":"taxi airport","amount":251,"date":"2026-01-04","href":"/expenses/4"}]}` + "\n"
+		if rec.Code != http.StatusConflict || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Create with force should skip duplicate check", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO expenses").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("6"))
+		expectAudit(mock, 6, "create")
+		expectEvent(mock, 6, EventCreated)
+		mock.ExpectCommit()
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/expenses?force=true", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		if err := NewApplication(db).CreateExpenseHandler(e.NewContext(req, rec)); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusCreated {
+			t.Errorf("should status created but it got %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestGetDuplicatesHandler(t *testing.T) {
+	t.Run("Duplicate report should group connected matches", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT a.id, a.title, b.id, b.title FROM expenses a JOIN expenses b").
+			WithArgs(0.01, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "id", "title"}).
+				AddRow(1, "Taxi", 2, "taxi").
+				AddRow(2, "taxi", 3, "Taxi!").
+				AddRow(4, "Hotel", 5, "Lunch").
+				AddRow(6, "Coffee", 7, "coffee"))
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id = ANY").
+			WithArgs(pq.Array([]int{1, 2, 3, 6, 7})).
+			WillReturnRows(sqlmock.NewRows(duplicateColumns).
+				AddRow(1, "Taxi", 10.0, "", pq.Array([]string{}), nil, nil, nil, "draft", "alice", nil, nil, nil, nil, nil, nil).
+				AddRow(2, "taxi", 10.0, "", pq.Array([]string{}), nil, nil, nil, "draft", "alice", nil, nil, nil, nil, nil, nil).
+				AddRow(3, "Taxi!", 10.0, "", pq.Array([]string{}), nil, nil, nil, "draft", "alice", nil, nil, nil, nil, nil, nil).
+				AddRow(6, "Coffee", 3.0, "", pq.Array([]string{}), nil, nil, nil, "draft", "bob", nil, nil, nil, nil, nil, nil).
+				AddRow(7, "coffee", 3.0, "", pq.Array([]string{}), nil, nil, nil, "draft", "bob", nil, nil, nil, nil, nil, nil))
+
+		e := echo.New()
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/expenses/duplicates", nil), rec)
+		auth.SetUser(c, auth.User{Name: "carol", Roles: []string{auth.RoleApprover}})
+		if err := NewApplication(db).GetDuplicatesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		body := rec.Body.String()
+		if rec.Code != http.StatusOK || strings.Count(body, `"owner":"alice","expenses"`) != 1 || strings.Count(body, `"id":`) != 5 ||
+			!strings.HasPrefix(body, `[{"owner":"alice","expenses":[{"id":1,`) {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, body)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Duplicate report should only scan the caller's own expenses", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT a.id, a.title, b.id, b.title FROM expenses a JOIN expenses b (.+) WHERE a.owner = \\$3").
+			WithArgs(0.01, 3, "alice").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "id", "title"}))
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id = ANY").WillReturnRows(sqlmock.NewRows(duplicateColumns))
+
+		e := echo.New()
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/expenses/duplicates", nil), rec)
+		auth.SetUser(c, auth.User{Name: "alice"})
+		if err := NewApplication(db).GetDuplicatesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusOK || rec.Body.String() != "[]\n" {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
@@ -0,0 +1,19 @@
+package expense
+
+import "github.com/phanbanchong/assessment/outbox"
+
+const (
+	EventCreated = "expense.created"
+	EventUpdated = "expense.updated"
+	EventDeleted = "expense.deleted"
+)
+
+// WriteEvent records event about exp in the outbox and moves the expense to
+// a new sync version. Run it in the transaction making the change; deletes
+// carry the last state of the expense.
+func WriteEvent(db Querier, event string, exp Expense) error {
+	if err := outbox.Write(db, event, exp.ID, exp); err != nil {
+		return err
+	}
+	return recordChange(db, exp, event == EventDeleted)
+}
@@ -1,21 +1,72 @@
 package expense
 
-import "database/sql"
+import (
+	"database/sql"
+	"os"
+
+	"github.com/phanbanchong/assessment/policy"
+)
+
+// DefaultCurrency is the organisation's reporting currency when
+// REPORTING_CURRENCY is not set.
+const DefaultCurrency = "THB"
+
+// ReportingCurrency reads REPORTING_CURRENCY, falling back to
+// DefaultCurrency. Every transport takes the reporting currency from here.
+func ReportingCurrency() string {
+	if currency := os.Getenv("REPORTING_CURRENCY"); currency != "" {
+		return currency
+	}
+	return DefaultCurrency
+}
 
 type handler struct {
-	DB *sql.DB
+	DB       *sql.DB
+	Policy   policy.Config
+	Currency string
 }
 
 func NewApplication(db *sql.DB) *handler {
-	return &handler{db}
+	return &handler{DB: db, Policy: policy.Config{Version: policy.Version}, Currency: ReportingCurrency()}
+}
+
+// Querier is satisfied by both *sql.DB and *sql.Tx so store functions can run
+// inside a caller's transaction.
+type Querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Prepare(query string) (*sql.Stmt, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
 }
 
 type Expense struct {
-	ID     int      `json:"id"`
-	Title  string   `json:"title"`
-	Amount float64  `json:"amount"`
-	Note   string   `json:"note"`
-	Tags   []string `json:"tags"`
+	ID          int              `json:"id"`
+	Title       string           `json:"title"`
+	Amount      float64          `json:"amount"`
+	Note        string           `json:"note"`
+	Tags        []string         `json:"tags"`
+	Date        string           `json:"date,omitempty"`
+	RecurringID int              `json:"recurring_id,omitempty"`
+	ExternalRef string           `json:"external_ref,omitempty"`
+	Status      string           `json:"status,omitempty"`
+	Owner       string           `json:"owner,omitempty"`
+	ReportID    int              `json:"report_id,omitempty"`
+	Warnings    []policy.Finding `json:"warnings,omitempty"`
+
+	Currency          string  `json:"currency,omitempty"`
+	ReportingAmount   float64 `json:"reporting_amount,omitempty"`
+	ReportingCurrency string  `json:"reporting_currency,omitempty"`
+	Rate              float64 `json:"rate,omitempty"`
+	RateDate          string  `json:"rate_date,omitempty"`
+}
+
+// ReportAmount is the amount in the reporting currency. Expenses stored
+// before currencies were tracked report their plain amount.
+func (exp Expense) ReportAmount() float64 {
+	if exp.ReportingCurrency == "" {
+		return exp.Amount
+	}
+	return exp.ReportingAmount
 }
 
 type Error struct {
@@ -0,0 +1,169 @@
+package expense
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+const exportFlushRows = 100
+
+var exportColumns = []string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "currency", "reporting_amount", "reporting_currency", "rate"}
+
+var exportFormats = map[string]string{
+	"csv":   "text/csv; charset=UTF-8",
+	"jsonl": "application/x-ndjson",
+	"xlsx":  "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+type exportEncoder interface {
+	Encode(exp Expense) error
+	Close() error
+}
+
+func newExportEncoder(format string, w io.Writer) (exportEncoder, error) {
+	switch format {
+	case "jsonl":
+		return jsonlEncoder{json.NewEncoder(w)}, nil
+	case "xlsx":
+		x, err := newXLSXWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		return xlsxEncoder{x}, x.WriteRow(stringCells(exportColumns)...)
+	default:
+		cw := csv.NewWriter(w)
+		return csvEncoder{cw}, cw.Write(exportColumns)
+	}
+}
+
+type csvEncoder struct{ w *csv.Writer }
+
+func (e csvEncoder) Encode(exp Expense) error {
+	return e.w.Write([]string{
+		strconv.Itoa(exp.ID),
+		exp.Title,
+		strconv.FormatFloat(exp.Amount, 'f', -1, 64),
+		exp.Note,
+		strings.Join(exp.Tags, TagSeparator),
+		exp.Date,
+		optionalID(exp.RecurringID),
+		exp.ExternalRef,
+		exp.Currency,
+		optionalAmount(exp.ReportingAmount),
+		exp.ReportingCurrency,
+		optionalAmount(exp.Rate),
+	})
+}
+
+func (e csvEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type jsonlEncoder struct{ enc *json.Encoder }
+
+func (e jsonlEncoder) Encode(exp Expense) error { return e.enc.Encode(exp) }
+
+func (e jsonlEncoder) Close() error { return nil }
+
+type xlsxEncoder struct{ w *xlsxWriter }
+
+func (e xlsxEncoder) Encode(exp Expense) error {
+	return e.w.WriteRow(exp.ID, exp.Title, exp.Amount, exp.Note, strings.Join(exp.Tags, TagSeparator), exp.Date, optionalID(exp.RecurringID), exp.ExternalRef,
+		exp.Currency, optionalCell(exp.ReportingAmount), exp.ReportingCurrency, optionalCell(exp.Rate))
+}
+
+func (e xlsxEncoder) Close() error { return e.w.Close() }
+
+func stringCells(values []string) []any {
+	cells := make([]any, len(values))
+	for i, v := range values {
+		cells[i] = v
+	}
+	return cells
+}
+
+func optionalID(id int) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.Itoa(id)
+}
+
+// optionalAmount leaves the conversion fields of expenses stored before
+// they had a reporting currency empty.
+func optionalAmount(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func optionalCell(v float64) any {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+// ExportExpensesHandler streams the expenses matching the list filters as a
+// csv (default), jsonl or xlsx download. In the tabular formats tags are
+// flattened into one cell joined by TagSeparator, the same convention the CSV
+// import reads.
+func (h *handler) ExportExpensesHandler(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = "csv"
+	}
+	contentType, ok := exportFormats[format]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, Error{Message: "Query format is invalid"})
+	}
+	f, err := ParseFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Error{Message: err.Error()})
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, contentType)
+	res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="expenses.`+format+`"`)
+	buf := bufio.NewWriterSize(res, 32<<10)
+
+	enc, err := newExportEncoder(format, buf)
+	if err == nil {
+		rows := 0
+		err = EachExpense(c.Request().Context(), h.DB, f, func(exp Expense) error {
+			if err := enc.Encode(exp); err != nil {
+				return err
+			}
+			if rows++; rows%exportFlushRows == 0 {
+				if err := buf.Flush(); err != nil {
+					return err
+				}
+				res.Flush()
+			}
+			return nil
+		})
+	}
+	if err == nil {
+		err = enc.Close()
+	}
+	if err != nil {
+		log.Errorf("Export expenses error: %v", err)
+		if !res.Committed {
+			res.Header().Del(echo.HeaderContentType)
+			res.Header().Del(echo.HeaderContentDisposition)
+			return c.JSON(http.StatusInternalServerError, Error{Message: "Unable to export expenses:" + err.Error()})
+		}
+		return nil
+	}
+	return buf.Flush()
+}
@@ -0,0 +1,173 @@
+//go:build unit
+
+package expense
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/auth"
+)
+
+func exportRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}).
+		AddRow(1, "taxi", 12.5, "to \"airport\"", pq.Array([]string{"travel", "work"}), time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), nil, nil, "draft", nil, nil, "USD", 437.5, "THB", 35.0, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)).
+		AddRow(2, "rent", 500.0, "", pq.Array([]string{}), nil, 7, nil, "draft", nil, nil, nil, nil, nil, nil, nil)
+}
+
+func exportContext(query string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/expenses/export?"+query, nil)
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+func TestExportExpensesHandler(t *testing.T) {
+	t.Run("Export CSV should honour filters", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT id, title, amount, note, tags, date, recurring_id, external_ref, status, owner, report_id, currency, reporting_amount, reporting_currency, rate, rate_date FROM expenses WHERE tags @> $1 AND date >= $2 AND amount <= $3 AND owner = $4 ORDER BY id ASC").
+			WithArgs(pq.Array([]string{"travel"}), "2026-01-01", 600.0, "anonymous").
+			WillReturnRows(exportRows())
+
+		h := NewApplication(db)
+		c, rec := exportContext("format=csv&tag=travel&from=2026-01-01&max_amount=600")
+		if err := h.ExportExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+
+		want := "id,title,amount,note,tags,date,recurring_id,external_ref,currency,reporting_amount,reporting_currency,rate\n" +
+			"1,taxi,12.5,\"to \"\"airport\"\"\",travel;work,2026-01-02,,,USD,437.5,THB,35\n" +
+			"2,rent,500,,,,7,,,,,\n"
+		if rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %s", rec.Body.String())
+		}
+		if got := rec.Header().Get(echo.HeaderContentDisposition); got != `attachment; filename="expenses.csv"` {
+			t.Errorf("unexpected content disposition %s", got)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Export JSON Lines should write one expense per line", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE owner = (.+) ORDER BY id ASC").WithArgs("anonymous").WillReturnRows(exportRows())
+
+		h := NewApplication(db)
+		c, rec := exportContext("format=jsonl")
+		if err := h.ExportExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+
+		want := `{"id":1,"title":"taxi","amount":12.5,"note":"to \"airport\"","tags":["travel","work"],"date":"2026-01-02","status":"draft",` +
+			`"currency":"USD","reporting_amount":437.5,"reporting_currency":"THB","rate":35,"rate_date":"2026-01-02"}` + "\n" +
+			`{"id":2,"title":"rent","amount":500,"note":"","tags":[],"recurring_id":7,"status":"draft"}` + "\n"
+		if rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %s", rec.Body.String())
+		}
+	})
+
+	t.Run("Export XLSX should write a workbook", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE owner = (.+) ORDER BY id ASC").WithArgs("anonymous").WillReturnRows(exportRows())
+
+		h := NewApplication(db)
+		c, rec := exportContext("format=xlsx")
+		if err := h.ExportExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+
+		zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+		if err != nil {
+			t.Fatalf("should be a zip archive but it got %v", err)
+		}
+		var sheet string
+		for _, f := range zr.File {
+			if f.Name == "xl/worksheets/sheet1.xml" {
+				r, _ := f.Open()
+				b, _ := io.ReadAll(r)
+				sheet = string(b)
+			}
+		}
+		for _, want := range []string{`<c><v>12.5</v></c>`, `to &#34;airport&#34;`, `travel;work`, `<c t="inlineStr"><is><t xml:space="preserve">7</t></is></c>`,
+			`<t xml:space="preserve">USD</t></is></c><c><v>437.5</v></c><c t="inlineStr"><is><t xml:space="preserve">THB</t></is></c><c><v>35</v></c>`} {
+			if !strings.Contains(sheet, want) {
+				t.Errorf("sheet should contain %s but it got %s", want, sheet)
+			}
+		}
+	})
+
+	t.Run("Export of another owner's expenses should match nothing", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE owner = \\$1 AND owner = \\$2 ORDER BY id ASC").
+			WithArgs("bob", "alice").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}))
+
+		c, rec := exportContext("format=jsonl&owner=bob")
+		auth.SetUser(c, auth.User{Name: "alice"})
+		if err := NewApplication(db).ExportExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+			t.Errorf("should export nothing but it got %d: %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Export with database error should got error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT (.+) FROM expenses").WillReturnError(io.ErrUnexpectedEOF)
+
+		h := NewApplication(db)
+		c, rec := exportContext("")
+		if err := h.ExportExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusInternalServerError || rec.Header().Get(echo.HeaderContentType) != echo.MIMEApplicationJSONCharsetUTF8 {
+			t.Errorf("should status internal server error but it got %v", rec.Code)
+		}
+	})
+
+	t.Run("Export unknown format should got error", func(t *testing.T) {
+		h := NewApplication(nil)
+		c, rec := exportContext("format=pdf")
+		if err := h.ExportExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"message":"Query format is invalid"}` + "\n"
+		if rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %s", rec.Body.String())
+		}
+	})
+}
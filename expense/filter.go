@@ -0,0 +1,134 @@
+package expense
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+// Filter narrows the expenses returned by the list and export endpoints.
+type Filter struct {
+	Tags      []string
+	From      string
+	To        string
+	MinAmount *float64
+	MaxAmount *float64
+	Query     string
+	Status    string
+	Owner     string
+
+	// visibleOwner, when set, further limits the filter to the expenses of
+	// the owner the caller may see.
+	visibleOwner string
+}
+
+// ParseFilter reads tag (repeatable, all must match), from and to (inclusive
+// dates), min_amount, max_amount, q (title substring), status and owner
+// query parameters. The filter only ever matches expenses the caller may
+// see.
+func ParseFilter(c echo.Context) (Filter, error) {
+	f := Filter{
+		Tags:   c.QueryParams()["tag"],
+		From:   c.QueryParam("from"),
+		To:     c.QueryParam("to"),
+		Query:  c.QueryParam("q"),
+		Status: c.QueryParam("status"),
+		Owner:  c.QueryParam("owner"),
+	}
+	if !ValidDate(f.From) {
+		return f, errors.New("Query from is invalid")
+	}
+	if !ValidDate(f.To) {
+		return f, errors.New("Query to is invalid")
+	}
+	if owner := VisibleOwner(c); owner != "" {
+		// Asking for another owner's expenses matches nothing.
+		if f.Owner != "" && f.Owner != owner {
+			f.visibleOwner = owner
+		}
+		if f.Owner == "" {
+			f.Owner = owner
+		}
+	}
+	for name, dest := range map[string]**float64{"min_amount": &f.MinAmount, "max_amount": &f.MaxAmount} {
+		if v := c.QueryParam(name); v != "" {
+			amount, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return f, errors.New("Query " + name + " is invalid")
+			}
+			*dest = &amount
+		}
+	}
+	return f, nil
+}
+
+// where renders the filter as a SQL condition, or an empty string when the
+// filter matches everything.
+func (f Filter) where() (string, []any) {
+	conditions := []string{}
+	args := []any{}
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.Replace(condition, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+	if len(f.Tags) > 0 {
+		add("tags @> ?", pq.Array(f.Tags))
+	}
+	if f.From != "" {
+		add("date >= ?", f.From)
+	}
+	if f.To != "" {
+		add("date <= ?", f.To)
+	}
+	if f.MinAmount != nil {
+		add("amount >= ?", *f.MinAmount)
+	}
+	if f.MaxAmount != nil {
+		add("amount <= ?", *f.MaxAmount)
+	}
+	if f.Query != "" {
+		add("title ILIKE ?", "%"+f.Query+"%")
+	}
+	if f.Status != "" {
+		add("status = ?", f.Status)
+	}
+	if f.Owner != "" {
+		add("owner = ?", f.Owner)
+	}
+	if f.visibleOwner != "" {
+		add("owner = ?", f.visibleOwner)
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// Matches reports whether exp passes the filter, the same way where does in
+// SQL, for expenses that are not read from the database.
+func (f Filter) Matches(exp Expense) bool {
+	for _, want := range f.Tags {
+		found := false
+		for _, tag := range exp.Tags {
+			found = found || tag == want
+		}
+		if !found {
+			return false
+		}
+	}
+	switch {
+	case f.From != "" && (exp.Date == "" || exp.Date < f.From),
+		f.To != "" && (exp.Date == "" || exp.Date > f.To),
+		f.MinAmount != nil && exp.Amount < *f.MinAmount,
+		f.MaxAmount != nil && exp.Amount > *f.MaxAmount,
+		f.Query != "" && !strings.Contains(strings.ToLower(exp.Title), strings.ToLower(f.Query)),
+		f.Status != "" && exp.Status != f.Status,
+		f.Owner != "" && exp.Owner != f.Owner,
+		f.visibleOwner != "" && exp.Owner != f.visibleOwner:
+		return false
+	}
+	return true
+}
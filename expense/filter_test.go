@@ -0,0 +1,33 @@
+//go:build unit
+
+package expense
+
+import "testing"
+
+func TestFilterMatches(t *testing.T) {
+	exp := Expense{Title: "Team Lunch", Amount: 120, Tags: []string{"food", "team"}, Date: "2023-03-10", Status: StatusDraft, Owner: "alice"}
+	min, max := 100.0, 110.0
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"empty filter", Filter{}, true},
+		{"all tags", Filter{Tags: []string{"team", "food"}}, true},
+		{"missing tag", Filter{Tags: []string{"food", "travel"}}, false},
+		{"date range", Filter{From: "2023-03-01", To: "2023-03-10"}, true},
+		{"before from", Filter{From: "2023-03-11"}, false},
+		{"min amount", Filter{MinAmount: &min}, true},
+		{"max amount", Filter{MaxAmount: &max}, false},
+		{"title query", Filter{Query: "lunch"}, true},
+		{"status", Filter{Status: StatusSubmitted}, false},
+		{"owner", Filter{Owner: "bob"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" should match the same as the query", func(t *testing.T) {
+			if got := tt.filter.Matches(exp); got != tt.want {
+				t.Errorf("should match %v but it got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -15,7 +15,7 @@ func (h *handler) GetExpenseHandler(c echo.Context) error {
 		log.Errorf("error: %v", err)
 		return c.JSON(http.StatusInternalServerError, Error{Message: "ID is invalid"})
 	}
-	exp, err := GetExpenseByID(h.DB, id)
+	exp, err := GetVisibleExpense(c, h.DB, id)
 	switch err {
 	case sql.ErrNoRows:
 		return c.JSON(http.StatusNotFound, Error{Message: "Expense not found"})
@@ -27,7 +27,11 @@ func (h *handler) GetExpenseHandler(c echo.Context) error {
 }
 
 func (h *handler) GetExpensesHandler(c echo.Context) error {
-	expenses, err := GetExpenses(h.DB)
+	f, err := ParseFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Error{Message: err.Error()})
+	}
+	expenses, err := GetExpenses(h.DB, f)
 	if err != nil {
 		log.Errorf("Unable to get expenses from db:" + err.Error())
 		return c.JSON(http.StatusInternalServerError, Error{Message: "Unable to get expenses from database:" + err.Error()})
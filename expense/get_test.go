@@ -12,6 +12,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/labstack/echo/v4"
 	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/auth"
 )
 
 func TestGetExpenseHandler(t *testing.T) {
@@ -25,10 +26,10 @@ func TestGetExpenseHandler(t *testing.T) {
 		defer db.Close()
 		h := NewApplication(db)
 
-		mockRows := sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags"}).
-			AddRow(2, "expense 2", 2.0, "note 2", pq.Array([]string{"tag1", "tag2"}))
+		mockRows := sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}).
+			AddRow(2, "expense 2", 2.0, "note 2", pq.Array([]string{"tag1", "tag2"}), nil, nil, nil, "draft", "anonymous", nil, nil, nil, nil, nil, nil)
 
-		mock.ExpectPrepare("SELECT id, title, amount, note, tags FROM expenses WHERE id = $1").
+		mock.ExpectPrepare("SELECT id, title, amount, note, tags, date, recurring_id, external_ref, status, owner, report_id, currency, reporting_amount, reporting_currency, rate, rate_date FROM expenses WHERE id = $1").
 			ExpectQuery().
 			WithArgs(ID).
 			WillReturnRows(mockRows)
@@ -47,7 +48,7 @@ func TestGetExpenseHandler(t *testing.T) {
 		}
 
 		resp := rec.Body.String()
-		want := `{"id":2,"title":"expense 2","amount":2,"note":"note 2","tags":["tag1","tag2"]}` + "\n"
+		want := `{"id":2,"title":"expense 2","amount":2,"note":"note 2","tags":["tag1","tag2"],"status":"draft","owner":"anonymous"}` + "\n"
 		if resp != want {
 			t.Errorf("response error was not expected got: %s", resp)
 		}
@@ -62,7 +63,7 @@ func TestGetExpenseHandler(t *testing.T) {
 		}
 		defer db.Close()
 
-		mock.ExpectPrepare("SELECT id, title, amount, note, tags FROM expenses WHERE id = $1").
+		mock.ExpectPrepare("SELECT id, title, amount, note, tags, date, recurring_id, external_ref, status, owner, report_id, currency, reporting_amount, reporting_currency, rate, rate_date FROM expenses WHERE id = $1").
 			ExpectQuery().
 			WithArgs(ID).
 			WillReturnError(sql.ErrNoRows)
@@ -97,7 +98,7 @@ func TestGetExpenseHandler(t *testing.T) {
 		}
 		defer db.Close()
 
-		mock.ExpectPrepare("SELECT id, title, amount, note, tags FROM expenses WHERE id = $1").
+		mock.ExpectPrepare("SELECT id, title, amount, note, tags, date, recurring_id, external_ref, status, owner, report_id, currency, reporting_amount, reporting_currency, rate, rate_date FROM expenses WHERE id = $1").
 			ExpectQuery().
 			WithArgs(ID).
 			WillReturnError(sql.ErrConnDone)
@@ -154,11 +155,11 @@ func TestGetExpenseHandler(t *testing.T) {
 		}
 		defer db.Close()
 
-		mockRows := sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags"}).
-			AddRow(1, "expense 2", 1.0, "note 1", pq.Array([]string{"tag1", "tag2"})).
-			AddRow(2, "expense 2", 2.0, "note 2", pq.Array([]string{"tag1", "tag2"}))
+		mockRows := sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}).
+			AddRow(1, "expense 2", 1.0, "note 1", pq.Array([]string{"tag1", "tag2"}), nil, nil, nil, "draft", nil, nil, nil, nil, nil, nil, nil).
+			AddRow(2, "expense 2", 2.0, "note 2", pq.Array([]string{"tag1", "tag2"}), nil, nil, nil, "draft", nil, nil, nil, nil, nil, nil, nil)
 
-		mock.ExpectPrepare("SELECT id, title, amount, note, tags FROM expenses ORDER BY id ASC").
+		mock.ExpectPrepare("SELECT id, title, amount, note, tags, date, recurring_id, external_ref, status, owner, report_id, currency, reporting_amount, reporting_currency, rate, rate_date FROM expenses ORDER BY id ASC").
 			ExpectQuery().
 			WillReturnRows(mockRows)
 		h := NewApplication(db)
@@ -169,13 +170,69 @@ func TestGetExpenseHandler(t *testing.T) {
 		rec := httptest.NewRecorder()
 
 		c := e.NewContext(req, rec)
+		auth.SetUser(c, auth.User{Name: "root", Roles: []string{auth.RoleAdmin}})
 
 		if err = h.GetExpensesHandler(c); err != nil {
 			t.Errorf("should not return error but it got %v", err)
 		}
 
 		resp := rec.Body.String()
-		want := `[{"id":1,"title":"expense 2","amount":1,"note":"note 1","tags":["tag1","tag2"]},{"id":2,"title":"expense 2","amount":2,"note":"note 2","tags":["tag1","tag2"]}]` + "\n"
+		want := `[{"id":1,"title":"expense 2","amount":1,"note":"note 1","tags":["tag1","tag2"],"status":"draft"},{"id":2,"title":"expense 2","amount":2,"note":"note 2","tags":["tag1","tag2"],"status":"draft"}]` + "\n"
+		if resp != want {
+			t.Errorf("response error was not expected got: %s", resp)
+		}
+	})
+
+	t.Run("Get all expense with filters should be success", func(t *testing.T) {
+		//Mock Database
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mockRows := sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}).
+			AddRow(2, "expense 2", 2.0, "note 2", pq.Array([]string{"tag1", "tag2"}), nil, nil, nil, "draft", nil, nil, nil, nil, nil, nil, nil)
+
+		mock.ExpectPrepare("SELECT id, title, amount, note, tags, date, recurring_id, external_ref, status, owner, report_id, currency, reporting_amount, reporting_currency, rate, rate_date FROM expenses WHERE tags @> $1 AND date <= $2 AND amount >= $3 AND title ILIKE $4 AND owner = $5 ORDER BY id ASC").
+			ExpectQuery().
+			WithArgs(pq.Array([]string{"tag1", "tag2"}), "2026-12-31", 1.5, "%expense%", "anonymous").
+			WillReturnRows(mockRows)
+		h := NewApplication(db)
+
+		//Mock Echo Context
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/expenses?tag=tag1&tag=tag2&to=2026-12-31&min_amount=1.5&q=expense", nil)
+		rec := httptest.NewRecorder()
+
+		c := e.NewContext(req, rec)
+
+		if err = h.GetExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+
+		resp := rec.Body.String()
+		want := `[{"id":2,"title":"expense 2","amount":2,"note":"note 2","tags":["tag1","tag2"],"status":"draft"}]` + "\n"
+		if resp != want {
+			t.Errorf("response error was not expected got: %s", resp)
+		}
+	})
+
+	t.Run("Get all expense with invalid filter should got error", func(t *testing.T) {
+		h := NewApplication(nil)
+		//Mock Echo Context
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/expenses?from=yesterday", nil)
+		rec := httptest.NewRecorder()
+
+		c := e.NewContext(req, rec)
+
+		if err := h.GetExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+
+		resp := rec.Body.String()
+		want := `{"message":"Query from is invalid"}` + "\n"
 		if resp != want {
 			t.Errorf("response error was not expected got: %s", resp)
 		}
@@ -189,7 +246,7 @@ func TestGetExpenseHandler(t *testing.T) {
 		}
 		defer db.Close()
 
-		mock.ExpectPrepare("SELECT id, title, amount, note, tags FROM expenses ORDER BY id ASC").
+		mock.ExpectPrepare("SELECT id, title, amount, note, tags, date, recurring_id, external_ref, status, owner, report_id, currency, reporting_amount, reporting_currency, rate, rate_date FROM expenses ORDER BY id ASC").
 			ExpectQuery().
 			WillReturnError(sql.ErrConnDone)
 
@@ -200,6 +257,7 @@ func TestGetExpenseHandler(t *testing.T) {
 		rec := httptest.NewRecorder()
 
 		c := e.NewContext(req, rec)
+		auth.SetUser(c, auth.User{Name: "root", Roles: []string{auth.RoleAdmin}})
 
 		if err = h.GetExpensesHandler(c); err != nil {
 			t.Errorf("should not return error but it got %v", err)
@@ -212,3 +270,98 @@ func TestGetExpenseHandler(t *testing.T) {
 		}
 	})
 }
+
+func TestGetOtherOwnersExpense(t *testing.T) {
+	columns := []string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}
+	userContext := func(target, user string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("2")
+		auth.SetUser(c, auth.User{Name: user})
+		return c, rec
+	}
+
+	t.Run("Get expense of another owner should got not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectPrepare("SELECT (.+) FROM expenses WHERE id = (.+)").ExpectQuery().WithArgs(2).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(2, "taxi", 2.0, "", pq.Array([]string{}), nil, nil, nil, "draft", "bob", nil, nil, nil, nil, nil, nil))
+
+		c, rec := userContext("/expenses/2", "alice")
+		if err := NewApplication(db).GetExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("should status not found but it got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("List expenses should only return the caller's own", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectPrepare("SELECT (.+) FROM expenses WHERE owner = \\$1 ORDER BY id ASC").ExpectQuery().WithArgs("alice").
+			WillReturnRows(sqlmock.NewRows(columns))
+
+		c, rec := userContext("/expenses", "alice")
+		if err := NewApplication(db).GetExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusOK {
+			t.Errorf("should status ok but it got %d: %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("List expenses of another owner should match nothing", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectPrepare("SELECT (.+) FROM expenses WHERE owner = \\$1 AND owner = \\$2 ORDER BY id ASC").ExpectQuery().WithArgs("bob", "alice").
+			WillReturnRows(sqlmock.NewRows(columns))
+
+		c, rec := userContext("/expenses?owner=bob", "alice")
+		if err := NewApplication(db).GetExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusOK || rec.Body.String() != "[]\n" {
+			t.Errorf("should list nothing but it got %d: %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Get transitions of another owner's expense should got not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectPrepare("SELECT (.+) FROM expenses WHERE id = (.+)").ExpectQuery().WithArgs(2).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(2, "taxi", 2.0, "", pq.Array([]string{}), nil, nil, nil, "draft", "bob", nil, nil, nil, nil, nil, nil))
+
+		c, rec := userContext("/expenses/2/transitions", "alice")
+		if err := NewApplication(db).GetTransitionsHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("should status not found but it got %d: %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
@@ -0,0 +1,271 @@
+package expense
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/policy"
+)
+
+const (
+	// TagSeparator joins an expense's tags into a single CSV cell.
+	TagSeparator = ";"
+
+	maxImportSize = 10 << 20
+)
+
+var importFields = []string{"title", "amount", "note", "tags", "date", "currency"}
+
+var errImportPolicy = errors.New("Rows violate policy")
+
+type RowError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+type BatchResult struct {
+	FirstRow int    `json:"first_row"`
+	LastRow  int    `json:"last_row"`
+	Imported int    `json:"imported"`
+	Error    string `json:"error,omitempty"`
+}
+
+type ImportResult struct {
+	DryRun   bool          `json:"dry_run"`
+	Rows     int           `json:"rows"`
+	Imported int           `json:"imported"`
+	Errors   []RowError    `json:"errors"`
+	Batches  []BatchResult `json:"batches,omitempty"`
+}
+
+// ColumnMapping maps expense fields to CSV header names. Fields that are not
+// mapped are read from a column with the field's own name.
+type ColumnMapping map[string]string
+
+func (m ColumnMapping) column(field string) string {
+	if name, ok := m[field]; ok {
+		return name
+	}
+	return field
+}
+
+type ImportRow struct {
+	Line    int
+	Expense Expense
+}
+
+// ParseCSV reads expenses from r, returning the rows that passed validation
+// and an error for every row that did not.
+func ParseCSV(r io.Reader, m ColumnMapping) ([]ImportRow, []RowError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read header: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.TrimSpace(name)] = i
+	}
+	columns := map[string]int{}
+	for _, field := range importFields {
+		if i, ok := index[m.column(field)]; ok {
+			columns[field] = i
+		}
+	}
+	for _, field := range []string{"title", "amount"} {
+		if _, ok := columns[field]; !ok {
+			return nil, nil, fmt.Errorf("column %q for field %s not found", m.column(field), field)
+		}
+	}
+
+	rows := []ImportRow{}
+	rowErrors := []RowError{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rowErrors = append(rowErrors, RowError{Row: parseErr.Line, Message: parseErr.Err.Error()})
+				continue
+			}
+			return nil, nil, err
+		}
+
+		exp, err := parseRecord(record, columns)
+		if err == nil {
+			err = ValidateExpense(exp)
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{Row: line, Message: err.Error()})
+			continue
+		}
+		rows = append(rows, ImportRow{Line: line, Expense: exp})
+	}
+	return rows, rowErrors, nil
+}
+
+func parseRecord(record []string, columns map[string]int) (Expense, error) {
+	value := func(field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	exp := Expense{Title: value("title"), Note: value("note"), Date: value("date"), Currency: value("currency"), Tags: []string{}}
+	amount, err := strconv.ParseFloat(value("amount"), 64)
+	if err != nil {
+		return exp, errors.New("Field amount is invalid")
+	}
+	exp.Amount = amount
+	for _, tag := range strings.Split(value("tags"), TagSeparator) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			exp.Tags = append(exp.Tags, tag)
+		}
+	}
+	return exp, nil
+}
+
+// ImportExpenses inserts rows in transactions of batchSize rows, or in a
+// single transaction when batchSize is zero, and checks every row against
+// cfg. It stops at the first batch that fails; earlier batches stay
+// committed. A batch with rows that break the policy is rolled back and
+// those rows are returned.
+func ImportExpenses(db *sql.DB, actor audit.Actor, cfg policy.Config, rows []ImportRow, batchSize int) ([]BatchResult, []RowError, int) {
+	if batchSize <= 0 {
+		batchSize = len(rows)
+	}
+	batches := []BatchResult{}
+	imported := 0
+	for start := 0; start < len(rows); start += batchSize {
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		batch := BatchResult{FirstRow: rows[start].Line, LastRow: rows[end-1].Line}
+		violations, err := importBatch(db, actor, cfg, rows[start:end])
+		if err != nil {
+			batch.Error = err.Error()
+			batches = append(batches, batch)
+			return batches, violations, imported
+		}
+		batch.Imported = end - start
+		imported += batch.Imported
+		batches = append(batches, batch)
+	}
+	return batches, nil, imported
+}
+
+func importBatch(db *sql.DB, actor audit.Actor, cfg policy.Config, rows []ImportRow) ([]RowError, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	violations := []RowError{}
+	for _, row := range rows {
+		exp, err := CreateExpenseAudited(tx, actor, row.Expense)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row.Line, err)
+		}
+		_, err = CheckNewPolicy(tx, cfg, exp)
+		var violation *PolicyError
+		if errors.As(err, &violation) {
+			violations = append(violations, RowError{Row: row.Line, Message: violation.Message})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row.Line, err)
+		}
+	}
+	if len(violations) > 0 {
+		return violations, errImportPolicy
+	}
+	return nil, tx.Commit()
+}
+
+// uploadedFile returns the "file" part of a multipart upload, or the raw
+// request body for any other content type, capped at maxImportSize.
+func uploadedFile(c echo.Context) (io.ReadCloser, string, error) {
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, maxImportSize)
+	if !strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		return req.Body, "", nil
+	}
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return nil, "", errors.New("Field file is required")
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	return f, fh.Filename, nil
+}
+
+func (h *handler) ImportExpensesHandler(c echo.Context) error {
+	dryRun, _ := strconv.ParseBool(c.QueryParam("dry_run"))
+	batchSize := 0
+	if v := c.QueryParam("batch_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return c.JSON(http.StatusBadRequest, Error{Message: "Query batch_size is invalid"})
+		}
+		batchSize = n
+	}
+	mapping := ColumnMapping{}
+	for _, field := range importFields {
+		if name := c.QueryParam("column." + field); name != "" {
+			mapping[field] = name
+		}
+	}
+
+	body, _, err := uploadedFile(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Error{Message: err.Error()})
+	}
+	defer body.Close()
+
+	rows, rowErrors, err := ParseCSV(body, mapping)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Error{Message: "CSV is invalid: " + err.Error()})
+	}
+	result := ImportResult{DryRun: dryRun, Rows: len(rows) + len(rowErrors), Errors: rowErrors}
+	if dryRun {
+		return c.JSON(http.StatusOK, result)
+	}
+	if len(rowErrors) > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, result)
+	}
+
+	for i := range rows {
+		rows[i].Expense.ReportingCurrency = h.reportingCurrency(c)
+	}
+	var violations []RowError
+	result.Batches, violations, result.Imported = ImportExpenses(h.DB, audit.ActorFrom(c), h.Policy, rows, batchSize)
+	if len(violations) > 0 {
+		result.Errors = append(result.Errors, violations...)
+		return c.JSON(http.StatusUnprocessableEntity, result)
+	}
+	if result.Imported < len(rows) {
+		return c.JSON(http.StatusInternalServerError, result)
+	}
+	return c.JSON(http.StatusCreated, result)
+}
@@ -0,0 +1,175 @@
+//go:build unit
+
+package expense
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+const importCSV = `Description,Total,Labels,When
+taxi,12.5,travel;work,2026-01-02
+lunch,abc,food,2026-01-03
+hotel,80,,2026-13-01
+coffee,3,food,
+`
+
+func importContext(query, body string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/expenses/import?"+query, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, "text/csv")
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+func TestImportExpensesHandler(t *testing.T) {
+	mapping := "column.title=Description&column.amount=Total&column.tags=Labels&column.date=When"
+
+	t.Run("Import dry run should report row errors without writing", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		h := NewApplication(db)
+		c, rec := importContext("dry_run=true&"+mapping, importCSV)
+		if err := h.ImportExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+
+		want := `{"dry_run":true,"rows":4,"imported":0,"errors":[{"row":3,"message":"Field amount is invalid"},{"row":4,"message":"Field date is invalid"}]}` + "\n"
+		if rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %s", rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Import with invalid rows should not write", func(t *testing.T) {
+		h := NewApplication(nil)
+		c, rec := importContext(mapping, importCSV)
+		if err := h.ImportExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("should status unprocessable entity but it got %v", rec.Code)
+		}
+	})
+
+	t.Run("Import without mapped column should got error", func(t *testing.T) {
+		h := NewApplication(nil)
+		c, rec := importContext("", importCSV)
+		if err := h.ImportExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"message":"CSV is invalid: column \"title\" for field title not found"}` + "\n"
+		if rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %s", rec.Body.String())
+		}
+	})
+
+	t.Run("Import should insert in a single transaction", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO expenses").
+			WithArgs("title", 1.0, "note", pq.Array([]string{"tag1", "tag2"}), nil, nil, "anonymous", "THB", 1.0, "THB", 1.0, nil, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+		expectAudit(mock, 1, "create")
+		expectEvent(mock, 1, EventCreated)
+		mock.ExpectQuery("INSERT INTO expenses").
+			WithArgs("other", 2.0, "", pq.Array([]string{}), "2026-01-02", nil, "anonymous", "THB", 2.0, "THB", 1.0, nil, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("2"))
+		expectAudit(mock, 2, "create")
+		expectEvent(mock, 2, EventCreated)
+		mock.ExpectCommit()
+
+		h := NewApplication(db)
+		c, rec := importContext("", "title,amount,note,tags,date\ntitle,1,note,tag1;tag2,\nother,2,,,2026-01-02\n")
+		if err := h.ImportExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"dry_run":false,"rows":2,"imported":2,"errors":[],"batches":[{"first_row":2,"last_row":3,"imported":2}]}` + "\n"
+		if rec.Code != http.StatusCreated || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Import in batches should stop at failed batch", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO expenses").WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+		expectAudit(mock, 1, "create")
+		expectEvent(mock, 1, EventCreated)
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO expenses").WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		h := NewApplication(db)
+		c, rec := importContext("batch_size=1", "title,amount\na,1\nb,2\nc,3\n")
+		if err := h.ImportExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"dry_run":false,"rows":3,"imported":1,"errors":[],"batches":[{"first_row":2,"last_row":2,"imported":1},{"first_row":3,"last_row":3,"imported":0,"error":"row 3: sql: connection is already closed"}]}` + "\n"
+		if rec.Code != http.StatusInternalServerError || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Import with rows over the policy should roll back and report them", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO expenses").WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+		expectAudit(mock, 1, "create")
+		expectEvent(mock, 1, EventCreated)
+		mock.ExpectQuery("INSERT INTO expenses").WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("2"))
+		expectAudit(mock, 2, "create")
+		expectEvent(mock, 2, EventCreated)
+		mock.ExpectRollback()
+
+		h := NewApplication(db)
+		h.Policy = testPolicy
+		c, rec := importContext("", "title,amount,tags\nlunch,50,\ntaxi,900,taxi\n")
+		if err := h.ImportExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"dry_run":false,"rows":2,"imported":0,"errors":[{"row":3,"message":"Expense violates policy: Amount 900.00 exceeds the limit of 500.00"}],` +
+			`"batches":[{"first_row":2,"last_row":3,"imported":0,"error":"Rows violate policy"}]}` + "\n"
+		if rec.Code != http.StatusUnprocessableEntity || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
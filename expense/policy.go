@@ -0,0 +1,63 @@
+package expense
+
+import (
+	"strings"
+
+	"github.com/phanbanchong/assessment/policy"
+)
+
+// PolicyError is returned when an expense breaks a policy with error
+// severity. It is written as the body of the 422 response.
+type PolicyError struct {
+	Message    string           `json:"message"`
+	Version    int              `json:"policy_version"`
+	Violations []policy.Finding `json:"violations"`
+}
+
+func (e *PolicyError) Error() string {
+	return e.Message
+}
+
+func countReceipts(db Querier, id int) (int, error) {
+	var n int
+	err := db.QueryRow("SELECT count(*) FROM attachments WHERE expense_id = $1", id).Scan(&n)
+	return n, err
+}
+
+// CheckPolicy evaluates cfg against exp in its reporting currency and returns
+// the soft warnings, or a *PolicyError when a hard rule is broken. Attachments
+// of the expense count as receipts.
+func CheckPolicy(db Querier, cfg policy.Config, exp Expense) ([]policy.Finding, error) {
+	return checkPolicy(db, cfg, exp, false)
+}
+
+// CheckNewPolicy is CheckPolicy for an expense that was just created. It has
+// no receipts yet, so receipt rules only warn until it is updated or
+// submitted.
+func CheckNewPolicy(db Querier, cfg policy.Config, exp Expense) ([]policy.Finding, error) {
+	return checkPolicy(db, cfg, exp, true)
+}
+
+func checkPolicy(db Querier, cfg policy.Config, exp Expense, created bool) ([]policy.Finding, error) {
+	s := policy.Subject{Title: exp.Title, Amount: exp.ReportAmount(), Tags: exp.Tags, Date: exp.Date, New: created}
+	if exp.ID != 0 && cfg.NeedsReceipts(s) {
+		n, err := countReceipts(db, exp.ID)
+		if err != nil {
+			return nil, err
+		}
+		s.Receipts = n
+	}
+	result := cfg.Evaluate(s)
+	if !result.Failed() {
+		return result.Warnings, nil
+	}
+	messages := make([]string, len(result.Violations))
+	for i, v := range result.Violations {
+		messages[i] = v.Message
+	}
+	return nil, &PolicyError{
+		Message:    "Expense violates policy: " + strings.Join(messages, "; "),
+		Version:    cfg.Version,
+		Violations: result.Violations,
+	}
+}
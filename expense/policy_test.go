@@ -0,0 +1,253 @@
+//go:build unit
+
+package expense
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/auth"
+	"github.com/phanbanchong/assessment/policy"
+)
+
+var testPolicy = policy.Config{
+	Version: policy.Version,
+	Rules: []policy.Rule{
+		{Name: "taxi-limit", Kind: policy.KindMaxAmount, Severity: policy.SeverityError, Tags: []string{"taxi"}, Limit: 500},
+		{Name: "receipt", Kind: policy.KindReceipt, Severity: policy.SeverityError, Limit: 100},
+		{Name: "weekend", Kind: policy.KindWeekend, Severity: policy.SeverityWarning},
+	},
+}
+
+func policyContext(method, body string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(method, "/expenses", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+	return c, rec
+}
+
+func TestExpensePolicy(t *testing.T) {
+	t.Run("Create expense over limit should be unprocessable", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		expectNoDuplicates(mock)
+		mock.ExpectQuery("INSERT INTO expenses").WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+		expectAudit(mock, 1, "create")
+		expectEvent(mock, 1, EventCreated)
+		mock.ExpectRollback()
+
+		h := NewApplication(db)
+		h.Policy = testPolicy
+		c, rec := policyContext(http.MethodPost, `{"title":"taxi","amount":10000,"tags":["taxi"]}`)
+		if err := h.CreateExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"message":"Expense violates policy: Amount 10000.00 exceeds the limit of 500.00","policy_version":1,"violations":[` +
+			`{"rule":"taxi-limit","severity":"error","message":"Amount 10000.00 exceeds the limit of 500.00"}]}` + "\n"
+		if rec.Code != http.StatusUnprocessableEntity || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Create weekend expense should attach warning", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		expectNoDuplicates(mock)
+		mock.ExpectQuery("INSERT INTO expenses").
+			WithArgs("lunch", 50.0, "", pq.Array([]string{}), "2026-01-03", nil, "anonymous", "THB", 50.0, "THB", 1.0, nil, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+		expectAudit(mock, 1, "create")
+		expectEvent(mock, 1, EventCreated)
+		mock.ExpectCommit()
+
+		h := NewApplication(db)
+		h.Policy = testPolicy
+		c, rec := policyContext(http.MethodPost, `{"title":"lunch","amount":50,"tags":[],"date":"2026-01-03"}`)
+		if err := h.CreateExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"id":1,"title":"lunch","amount":50,"note":"","tags":[],"date":"2026-01-03","status":"draft","owner":"anonymous",` +
+			`"warnings":[{"rule":"weekend","severity":"warning","message":"Expense is dated on a Saturday"}],` +
+			`"currency":"THB","reporting_amount":50,"reporting_currency":"THB","rate":1}` + "\n"
+		if rec.Code != http.StatusCreated || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Create expense without receipt should only warn", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		expectNoDuplicates(mock)
+		mock.ExpectQuery("INSERT INTO expenses").WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+		expectAudit(mock, 1, "create")
+		expectEvent(mock, 1, EventCreated)
+		mock.ExpectCommit()
+
+		h := NewApplication(db)
+		h.Policy = testPolicy
+		c, rec := policyContext(http.MethodPost, `{"title":"laptop","amount":300,"tags":[]}`)
+		if err := h.CreateExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"warnings":[{"rule":"receipt","severity":"warning"`) {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Update expense with receipt attached should pass", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		expectLock(mock, 1)
+		mock.ExpectPrepare("UPDATE expenses").ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit(mock, 1, "update")
+		expectEvent(mock, 1, EventUpdated)
+		mock.ExpectQuery("SELECT count\\(\\*\\) FROM attachments").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectCommit()
+
+		h := NewApplication(db)
+		h.Policy = testPolicy
+		c, rec := policyContext(http.MethodPut, `{"title":"laptop","amount":300,"tags":[]}`)
+		if err := h.UpdateExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "warnings") {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Update expense without receipt should roll back", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		expectLock(mock, 1)
+		mock.ExpectPrepare("UPDATE expenses").ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit(mock, 1, "update")
+		expectEvent(mock, 1, EventUpdated)
+		mock.ExpectQuery("SELECT count\\(\\*\\) FROM attachments").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectRollback()
+
+		h := NewApplication(db)
+		h.Policy = testPolicy
+		c, rec := policyContext(http.MethodPut, `{"title":"laptop","amount":300,"tags":[]}`)
+		if err := h.UpdateExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `"rule":"receipt"`) {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestSubmitPolicy(t *testing.T) {
+	expectSubmit := func(mock sqlmock.Sqlmock) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id = (.+) FOR UPDATE").
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}).
+				AddRow(3, "laptop", 300.0, "", pq.Array([]string{}), nil, nil, nil, StatusDraft, "alice", nil, nil, nil, nil, nil, nil))
+		mock.ExpectExec("UPDATE expenses SET status").WithArgs(3, StatusSubmitted, "alice").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO expense_transitions").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(4, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)))
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT hash FROM expense_audit").WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+		mock.ExpectQuery("INSERT INTO expense_audit").WithArgs(3, ActionSubmit, "alice", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		expectEvent(mock, 3, EventUpdated)
+	}
+
+	t.Run("Submit expense without receipt should be unprocessable", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		expectSubmit(mock)
+		mock.ExpectQuery("SELECT count\\(\\*\\) FROM attachments").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectRollback()
+
+		h := NewApplication(db)
+		h.Policy = testPolicy
+		c, rec := transitionContext(ActionSubmit, "", auth.User{Name: "alice"})
+		if err := h.SubmitExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `"rule":"receipt","severity":"error"`) {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Submit expense with receipt should pass", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		expectSubmit(mock)
+		mock.ExpectQuery("SELECT count\\(\\*\\) FROM attachments").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectCommit()
+
+		h := NewApplication(db)
+		h.Policy = testPolicy
+		c, rec := transitionContext(ActionSubmit, "", auth.User{Name: "alice"})
+		if err := h.SubmitExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusOK {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
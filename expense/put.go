@@ -1,12 +1,33 @@
 package expense
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/auth"
+	"github.com/phanbanchong/assessment/fx"
+	"github.com/phanbanchong/assessment/policy"
 )
 
+// UpdateExpenseChecked applies exp in its own transaction and checks the
+// result against cfg, refusing it with a *PolicyError on a blocking
+// violation.
+func UpdateExpenseChecked(db *sql.DB, actor audit.Actor, user auth.User, cfg policy.Config, exp Expense) (Expense, error) {
+	err := withTx(db, func(tx *sql.Tx) error {
+		var err error
+		if exp, err = UpdateExpenseAudited(tx, actor, user, exp); err != nil {
+			return err
+		}
+		exp.Warnings, err = CheckPolicy(tx, cfg, exp)
+		return err
+	})
+	return exp, err
+}
+
 func (h *handler) UpdateExpenseHandler(c echo.Context) error {
 	exp := Expense{}
 	err := c.Bind(&exp)
@@ -17,10 +38,29 @@ func (h *handler) UpdateExpenseHandler(c echo.Context) error {
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, Error{Message: "Field ID is invalid"})
 	}
+	if err := ValidateUpdate(exp); err != nil {
+		return c.JSON(http.StatusBadRequest, Error{Message: err.Error()})
+	}
 
-	exp, err = UpdateExpense(h.DB, exp)
-	if err != nil {
+	exp.ReportingCurrency = h.reportingCurrency(c)
+	exp, err = UpdateExpenseChecked(h.DB, audit.ActorFrom(c), auth.UserFrom(c), h.Policy, exp)
+	var violation *PolicyError
+	if errors.As(err, &violation) {
+		return c.JSON(http.StatusUnprocessableEntity, violation)
+	}
+	if errors.Is(err, fx.ErrNoRate) {
+		return c.JSON(http.StatusUnprocessableEntity, Error{Message: err.Error()})
+	}
+	switch err {
+	case nil:
+		return c.JSON(http.StatusOK, exp)
+	case sql.ErrNoRows:
+		return c.JSON(http.StatusNotFound, Error{Message: "Expense not found"})
+	case ErrLocked, ErrGroupExpense:
+		return c.JSON(http.StatusConflict, Error{Message: err.Error()})
+	case ErrNotOwner:
+		return c.JSON(http.StatusForbidden, Error{Message: err.Error()})
+	default:
 		return c.JSON(http.StatusInternalServerError, Error{Message: err.Error()})
 	}
-	return c.JSON(http.StatusOK, exp)
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/labstack/echo/v4"
 	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/auth"
 )
 
 func TestUpdateExpenseHandler(t *testing.T) {
@@ -30,10 +31,26 @@ func TestUpdateExpenseHandler(t *testing.T) {
 			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 		}
 		defer db.Close()
-		mock.ExpectPrepare("UPDATE expenses SET title=$2, amount=$3, note=$4, tags=$5 WHERE id = $1").
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT id, title, amount, note, tags, date, recurring_id, external_ref, status, owner, report_id, currency, reporting_amount, reporting_currency, rate, rate_date FROM expenses WHERE id = $1 FOR UPDATE").
+			WithArgs(exp.ID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}).
+				AddRow(exp.ID, "old title", 2.0, "note", pq.Array([]string{"tag1"}), nil, nil, nil, "draft", "anonymous", nil, "THB", 2.0, "THB", 1.0, nil))
+		mock.ExpectQuery("SELECT EXISTS (SELECT 1 FROM group_expenses WHERE expense_id = $1)").
+			WithArgs(exp.ID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectPrepare("UPDATE expenses SET title=$2, amount=$3, note=$4, tags=$5, date=$6, currency=$7, reporting_amount=$8, reporting_currency=$9, rate=$10, rate_date=$11 WHERE id = $1").
 			ExpectExec().
-			WithArgs(exp.ID, exp.Title, exp.Amount, exp.Note, pq.Array(exp.Tags)).
+			WithArgs(exp.ID, exp.Title, exp.Amount, exp.Note, pq.Array(exp.Tags), nil, "THB", exp.Amount, "THB", 1.0, nil).
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("SELECT pg_advisory_xact_lock($1)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT hash FROM expense_audit WHERE hash IS NOT NULL ORDER BY id DESC LIMIT 1").WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+		mock.ExpectQuery("INSERT INTO expense_audit (expense_id, action, actor, request_id, changes, created_at, prev_hash, hash) values ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id").
+			WithArgs(exp.ID, "update", "anonymous", nil, []byte(`[{"field":"amount","before":2,"after":1},{"field":"reporting_amount","before":2,"after":1},{"field":"tags","before":["tag1"],"after":["tag1","tag2"]},{"field":"title","before":"old title","after":"title"}]`), sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO outbox (event, expense_id, payload) values ($1, $2, $3)").WithArgs(EventUpdated, exp.ID, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO expense_changes (expense_id, version, owner, deleted, changed_at) SELECT $1, nextval('expense_change_seq'), $2, $3, now() FROM pg_advisory_xact_lock($4) ON CONFLICT (expense_id) DO UPDATE SET version = EXCLUDED.version, owner = EXCLUDED.owner, deleted = EXCLUDED.deleted, changed_at = EXCLUDED.changed_at").WithArgs(exp.ID, sqlmock.AnyArg(), false, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 
 		h := NewApplication(db)
 
@@ -51,10 +68,13 @@ func TestUpdateExpenseHandler(t *testing.T) {
 		}
 
 		resp := rec.Body.String()
-		want := `{"id":1,"title":"title","amount":1,"note":"note","tags":["tag1","tag2"]}` + "\n"
+		want := `{"id":1,"title":"title","amount":1,"note":"note","tags":["tag1","tag2"],"status":"draft","owner":"anonymous","currency":"THB","reporting_amount":1,"reporting_currency":"THB","rate":1}` + "\n"
 		if resp != want {
 			t.Errorf("response error was not expected got: %s", resp)
 		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
 	})
 
 	t.Run("Update expense with invalid ID should got error", func(t *testing.T) {
@@ -109,6 +129,38 @@ func TestUpdateExpenseHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("Update missing expense should got not found", func(t *testing.T) {
+		//Mock Database
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT id, title, amount, note, tags, date, recurring_id, external_ref, status, owner, report_id, currency, reporting_amount, reporting_currency, rate, rate_date FROM expenses WHERE id = $1 FOR UPDATE").
+			WithArgs(9).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		h := NewApplication(db)
+
+		//Mock Echo Context
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPut, "/expenses/9", strings.NewReader(GoodExpenseJSON))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("9")
+
+		if err = h.UpdateExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if c.Response().Status != http.StatusNotFound {
+			t.Errorf("should status not found but it got %v", c.Response().Status)
+		}
+	})
+
 	t.Run("Update expense and database loss should be fail", func(t *testing.T) {
 		exp := Expense{
 			ID:     1,
@@ -123,10 +175,19 @@ func TestUpdateExpenseHandler(t *testing.T) {
 			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 		}
 		defer db.Close()
-		mock.ExpectPrepare("UPDATE expenses SET title=$2, amount=$3, note=$4, tags=$5 WHERE id = $1").
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT id, title, amount, note, tags, date, recurring_id, external_ref, status, owner, report_id, currency, reporting_amount, reporting_currency, rate, rate_date FROM expenses WHERE id = $1 FOR UPDATE").
+			WithArgs(exp.ID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}).
+				AddRow(exp.ID, "old title", 2.0, "note", pq.Array([]string{"tag1"}), nil, nil, nil, "draft", "anonymous", nil, nil, nil, nil, nil, nil))
+		mock.ExpectQuery("SELECT EXISTS (SELECT 1 FROM group_expenses WHERE expense_id = $1)").
+			WithArgs(exp.ID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectPrepare("UPDATE expenses SET title=$2, amount=$3, note=$4, tags=$5, date=$6, currency=$7, reporting_amount=$8, reporting_currency=$9, rate=$10, rate_date=$11 WHERE id = $1").
 			ExpectExec().
-			WithArgs(exp.ID, exp.Title, exp.Amount, exp.Note, pq.Array(exp.Tags)).
+			WithArgs(exp.ID, exp.Title, exp.Amount, exp.Note, pq.Array(exp.Tags), nil, "THB", exp.Amount, "THB", 1.0, nil).
 			WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
 
 		h := NewApplication(db)
 
@@ -153,4 +214,67 @@ func TestUpdateExpenseHandler(t *testing.T) {
 			t.Errorf("response error was not expected got: %s", resp)
 		}
 	})
+
+	t.Run("Update another user's expense as approver should be forbidden", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		expectStatus(mock, 3, StatusSubmitted, "alice")
+		mock.ExpectRollback()
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPut, "/expenses/3", strings.NewReader(GoodExpenseJSON))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("3")
+		auth.SetUser(c, auth.User{Name: "carol", Roles: []string{auth.RoleApprover}})
+
+		if err := NewApplication(db).UpdateExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusForbidden || rec.Body.String() != `{"message":"Only the owner can change this expense"}`+"\n" {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Update group expense should be a conflict", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		expectStatus(mock, 3, StatusDraft, "alice")
+		mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM group_expenses").
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectRollback()
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPut, "/expenses/3", strings.NewReader(GoodExpenseJSON))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("3")
+		auth.SetUser(c, auth.User{Name: "alice"})
+
+		if err := NewApplication(db).UpdateExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusConflict || rec.Body.String() != `{"message":"Expense is split in a group and can only change through the group"}`+"\n" {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
 }
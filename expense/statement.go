@@ -0,0 +1,169 @@
+package expense
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/fx"
+	"github.com/phanbanchong/assessment/policy"
+	"github.com/phanbanchong/assessment/statement"
+)
+
+const (
+	EntryNew       = "new"
+	EntryCreated   = "created"
+	EntryExisting  = "existing"
+	EntryDuplicate = "duplicate"
+	EntrySkipped   = "skipped"
+)
+
+// StatementEntry is one statement line. Violations are the policy rules it
+// breaks, which fail the whole import.
+type StatementEntry struct {
+	Expense    Expense          `json:"expense"`
+	Status     string           `json:"status"`
+	Candidates []int            `json:"candidates,omitempty"`
+	Violations []policy.Finding `json:"violations,omitempty"`
+}
+
+type StatementResult struct {
+	DryRun   bool             `json:"dry_run"`
+	Format   string           `json:"format"`
+	Account  string           `json:"account,omitempty"`
+	Currency string           `json:"currency,omitempty"`
+	Imported int              `json:"imported"`
+	Entries  []StatementEntry `json:"entries"`
+}
+
+// statementExpense maps a bank transaction to an expense. Money leaving the
+// account becomes a positive amount; the external reference is scoped by
+// format and account so banks reusing transaction IDs do not collide.
+func statementExpense(s statement.Statement, txn statement.Transaction) Expense {
+	exp := Expense{
+		Title:       txn.Payee,
+		Amount:      -txn.Amount,
+		Note:        txn.Memo,
+		Tags:        []string{},
+		Date:        txn.Date.Format(DateLayout),
+		ExternalRef: s.Format + ":" + s.Account + ":" + txn.ID(),
+		Currency:    s.Currency,
+	}
+	if exp.Title == "" {
+		exp.Title = txn.Memo
+	}
+	if txn.Category != "" {
+		exp.Tags = append(exp.Tags, txn.Category)
+	}
+	return exp
+}
+
+// ImportStatementHandler imports an OFX/QFX, QIF or camt.053 bank statement.
+// With dry_run=true it only previews how every transaction would be handled.
+// Credits are skipped unless include_credits=true, and transactions matching
+// an existing expense by date and amount are left out unless
+// include_duplicates=true. Re-importing a statement never creates a row twice.
+// Nothing is imported when a transaction breaks the policy; the response
+// lists the violations of every such entry.
+func (h *handler) ImportStatementHandler(c echo.Context) error {
+	dryRun, _ := strconv.ParseBool(c.QueryParam("dry_run"))
+	includeCredits, _ := strconv.ParseBool(c.QueryParam("include_credits"))
+	includeDuplicates, _ := strconv.ParseBool(c.QueryParam("include_duplicates"))
+
+	body, filename, err := uploadedFile(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Error{Message: err.Error()})
+	}
+	defer body.Close()
+
+	s, err := statement.Parse(c.QueryParam("format"), filename, body)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Error{Message: "Statement is invalid: " + err.Error()})
+	}
+
+	result := StatementResult{DryRun: dryRun, Format: s.Format, Account: s.Account, Currency: s.Currency, Entries: []StatementEntry{}}
+	for _, txn := range s.Transactions {
+		entry := StatementEntry{Expense: statementExpense(s, txn), Status: EntryNew}
+		if txn.Amount > 0 && !includeCredits {
+			entry.Status = EntrySkipped
+			result.Entries = append(result.Entries, entry)
+			continue
+		}
+		existing, candidates, err := FindStatementMatches(h.DB, entry.Expense)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, Error{Message: err.Error()})
+		}
+		switch {
+		case existing != 0:
+			entry.Status = EntryExisting
+			entry.Expense.ID = existing
+		case len(candidates) > 0:
+			entry.Status = EntryDuplicate
+			entry.Candidates = candidates
+		}
+		result.Entries = append(result.Entries, entry)
+	}
+	if dryRun {
+		return c.JSON(http.StatusOK, result)
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Error{Message: err.Error()})
+	}
+	defer tx.Rollback()
+	actor := audit.ActorFrom(c)
+	preview := append([]StatementEntry(nil), result.Entries...)
+	failed := false
+	for i, entry := range result.Entries {
+		if entry.Status != EntryNew && !(entry.Status == EntryDuplicate && includeDuplicates) {
+			continue
+		}
+		entry.Expense.Owner = actor.Name
+		entry.Expense.ReportingCurrency = h.reportingCurrency(c)
+		exp, err := Convert(tx, entry.Expense)
+		if errors.Is(err, fx.ErrNoRate) {
+			return c.JSON(http.StatusUnprocessableEntity, Error{Message: err.Error()})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, Error{Message: err.Error()})
+		}
+		exp, created, err := CreateExpenseIfNew(tx, exp)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, Error{Message: err.Error()})
+		}
+		if created {
+			if _, err := audit.Record(tx, actor, audit.ActionCreate, exp.ID, nil, exp); err != nil {
+				return c.JSON(http.StatusInternalServerError, Error{Message: err.Error()})
+			}
+			if err := WriteEvent(tx, EventCreated, exp); err != nil {
+				return c.JSON(http.StatusInternalServerError, Error{Message: err.Error()})
+			}
+			var violation *PolicyError
+			exp.Warnings, err = CheckNewPolicy(tx, h.Policy, exp)
+			if errors.As(err, &violation) {
+				preview[i].Violations = violation.Violations
+				failed = true
+				continue
+			}
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, Error{Message: err.Error()})
+			}
+			result.Entries[i].Expense = exp
+			result.Entries[i].Status = EntryCreated
+			result.Imported++
+		} else {
+			result.Entries[i].Status = EntryExisting
+		}
+	}
+	if failed {
+		result.Imported, result.Entries = 0, preview
+		return c.JSON(http.StatusUnprocessableEntity, result)
+	}
+	if err := tx.Commit(); err != nil {
+		return c.JSON(http.StatusInternalServerError, Error{Message: err.Error()})
+	}
+	return c.JSON(http.StatusCreated, result)
+}
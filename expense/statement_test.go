@@ -0,0 +1,163 @@
+//go:build unit
+
+package expense
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/policy"
+)
+
+const statementQIF = `!Type:Bank
+D01/02/2026
+T-12.50
+PCity Taxi
+N1001
+^
+D01/03/2026
+T-4.20
+PCoffee Shop
+N1002
+^
+D01/04/2026
+T-80
+PHotel
+N1003
+^
+D01/05/2026
+T1000
+PPayroll
+N1004
+^
+`
+
+func statementContext(query string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/expenses/import/statement?"+query, strings.NewReader(statementQIF))
+	req.Header.Set(echo.HeaderContentType, "application/qif")
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+func expectStatementMatches(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT id FROM expenses WHERE external_ref").
+		WithArgs("qif::1001").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectQuery("SELECT id FROM expenses WHERE external_ref").
+		WithArgs("qif::1002").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT id FROM expenses WHERE date").
+		WithArgs("2026-01-03", 4.2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery("SELECT id FROM expenses WHERE external_ref").
+		WithArgs("qif::1003").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT id FROM expenses WHERE date").
+		WithArgs("2026-01-04", 80.0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+}
+
+func TestImportStatementHandler(t *testing.T) {
+	t.Run("Preview statement should classify transactions", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		expectStatementMatches(mock)
+
+		h := NewApplication(db)
+		c, rec := statementContext("dry_run=true&format=qif")
+		if err := h.ImportStatementHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+
+		want := `{"dry_run":true,"format":"qif","imported":0,"entries":[` +
+			`{"expense":{"id":9,"title":"City Taxi","amount":12.5,"note":"","tags":[],"date":"2026-01-02","external_ref":"qif::1001"},"status":"existing"},` +
+			`{"expense":{"id":0,"title":"Coffee Shop","amount":4.2,"note":"","tags":[],"date":"2026-01-03","external_ref":"qif::1002"},"status":"duplicate","candidates":[3]},` +
+			`{"expense":{"id":0,"title":"Hotel","amount":80,"note":"","tags":[],"date":"2026-01-04","external_ref":"qif::1003"},"status":"new"},` +
+			`{"expense":{"id":0,"title":"Payroll","amount":-1000,"note":"","tags":[],"date":"2026-01-05","external_ref":"qif::1004"},"status":"skipped"}]}` + "\n"
+		if rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %s", rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Import statement should only insert new transactions", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		expectStatementMatches(mock)
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO expenses (.+) ON CONFLICT \\(external_ref\\) DO NOTHING").
+			WithArgs("Hotel", 80.0, "", pq.Array([]string{}), "2026-01-04", "qif::1003", "anonymous", "THB", 80.0, "THB", 1.0, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+		expectAudit(mock, 11, "create")
+		expectEvent(mock, 11, EventCreated)
+		mock.ExpectCommit()
+
+		h := NewApplication(db)
+		c, rec := statementContext("format=qif")
+		if err := h.ImportStatementHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"imported":1,`) ||
+			!strings.Contains(rec.Body.String(), `{"expense":{"id":11,"title":"Hotel","amount":80,"note":"","tags":[],"date":"2026-01-04","external_ref":"qif::1003","status":"draft","owner":"anonymous","currency":"THB","reporting_amount":80,"reporting_currency":"THB","rate":1},"status":"created"}`) {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Import statement over the policy should roll back and report the entry", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		expectStatementMatches(mock)
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO expenses (.+) ON CONFLICT \\(external_ref\\) DO NOTHING").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+		expectAudit(mock, 11, "create")
+		expectEvent(mock, 11, EventCreated)
+		mock.ExpectRollback()
+
+		h := NewApplication(db)
+		h.Policy = policy.Config{Version: policy.Version, Rules: []policy.Rule{{Name: "limit", Kind: policy.KindMaxAmount, Severity: policy.SeverityError, Limit: 50}}}
+		c, rec := statementContext("format=qif")
+		if err := h.ImportStatementHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `"imported":0,`) ||
+			!strings.Contains(rec.Body.String(), `"status":"new","violations":[{"rule":"limit","severity":"error","message":"Amount 80.00 exceeds the limit of 50.00"}]}`) {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Import unknown statement format should got error", func(t *testing.T) {
+		h := NewApplication(nil)
+		c, rec := statementContext("format=mt940")
+		if err := h.ImportStatementHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"message":"Statement is invalid: unknown statement format"}` + "\n"
+		if rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %s", rec.Body.String())
+		}
+	})
+}
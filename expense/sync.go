@@ -0,0 +1,353 @@
+package expense
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/auth"
+	"github.com/phanbanchong/assessment/fx"
+	"github.com/phanbanchong/assessment/policy"
+)
+
+const (
+	defaultSyncLimit = 500
+	maxSyncLimit     = 1000
+	syncRefPrefix    = "sync:"
+)
+
+// SyncExpense is an expense with the sync version it was read at. Clients
+// send the version back as base_version when they push a change to it.
+type SyncExpense struct {
+	Expense
+	Version int64 `json:"version"`
+}
+
+type Tombstone struct {
+	ID      int   `json:"id"`
+	Version int64 `json:"version"`
+}
+
+type SyncResponse struct {
+	Token   string        `json:"token"`
+	More    bool          `json:"more"`
+	Changed []SyncExpense `json:"changed"`
+	Deleted []Tombstone   `json:"deleted"`
+}
+
+// SyncChange is a change made on a client. Without an ID it creates an
+// expense, identified by client_id so a retried push does not create it
+// twice; with an ID it updates the expense, or deletes it when deleted is
+// set, provided base_version is still the expense's current version.
+type SyncChange struct {
+	ClientID    string  `json:"client_id,omitempty"`
+	ID          int     `json:"id,omitempty"`
+	BaseVersion int64   `json:"base_version,omitempty"`
+	Deleted     bool    `json:"deleted,omitempty"`
+	Expense     Expense `json:"expense"`
+}
+
+type PushRequest struct {
+	Changes []SyncChange `json:"changes"`
+}
+
+// PushResult reports one pushed change. On a conflict Expense, or Deleted
+// when the expense is gone, is the server's current copy.
+type PushResult struct {
+	Index    int          `json:"index"`
+	ClientID string       `json:"client_id,omitempty"`
+	Status   int          `json:"status"`
+	Conflict bool         `json:"conflict,omitempty"`
+	Expense  *SyncExpense `json:"expense,omitempty"`
+	Deleted  *Tombstone   `json:"deleted,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+type PushResponse struct {
+	Results []PushResult `json:"results"`
+}
+
+// VisibleOwner is audit.VisibleOwner: the owner whose expenses the caller
+// may see, or an empty string when they may see every expense.
+func VisibleOwner(c echo.Context) string {
+	return audit.VisibleOwner(c)
+}
+
+func visibleTo(owner string, expenseOwner string) bool {
+	return owner == "" || owner == expenseOwner
+}
+
+// GetVisibleExpense reads expense id when the caller may see it. Other
+// users' expenses are sql.ErrNoRows, so callers answer 404 and do not tell
+// which IDs exist.
+func GetVisibleExpense(c echo.Context, db Querier, id int) (Expense, error) {
+	exp, err := GetExpenseByID(db, id)
+	if err == nil && !visibleTo(VisibleOwner(c), exp.Owner) {
+		return Expense{}, sql.ErrNoRows
+	}
+	return exp, err
+}
+
+// SyncHandler lists the expenses changed and deleted after the since token,
+// oldest change first. The response token is passed as since on the next
+// call; more is set when the page was cut at limit and the client should call
+// again straight away. Without since every visible expense is returned.
+func (h *handler) SyncHandler(c echo.Context) error {
+	var since int64
+	if v := c.QueryParam("since"); v != "" {
+		var err error
+		if since, err = strconv.ParseInt(v, 10, 64); err != nil || since < 0 {
+			return c.JSON(http.StatusBadRequest, Error{Message: "Query since is invalid"})
+		}
+	}
+	limit := defaultSyncLimit
+	if v := c.QueryParam("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 || limit > maxSyncLimit {
+			return c.JSON(http.StatusBadRequest, Error{Message: fmt.Sprintf("Query limit must be between 1 and %d", maxSyncLimit)})
+		}
+	}
+
+	resp, err := getSync(c.Request().Context(), h.DB, since, VisibleOwner(c), limit)
+	if err == errTokenAhead {
+		return c.JSON(http.StatusGone, Error{Message: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Error{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+var errTokenAhead = errors.New("Sync token is not known to the server, sync again without since")
+
+// getSync reads the changes from one snapshot so the versions returned
+// match the expense rows returned with them.
+func getSync(ctx context.Context, db *sql.DB, since int64, owner string, limit int) (SyncResponse, error) {
+	resp := SyncResponse{Changed: []SyncExpense{}, Deleted: []Tombstone{}}
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return resp, err
+	}
+	defer tx.Rollback()
+
+	latest, err := LatestVersion(tx)
+	if err != nil {
+		return resp, err
+	}
+	if since > latest {
+		return resp, errTokenAhead
+	}
+	changes, err := GetChanges(tx, since, latest, owner, limit+1)
+	if err != nil {
+		return resp, err
+	}
+	resp.Token = strconv.FormatInt(latest, 10)
+	if len(changes) > limit {
+		changes = changes[:limit]
+		resp.More = true
+		resp.Token = strconv.FormatInt(changes[limit-1].Version, 10)
+	}
+
+	ids := []int{}
+	for _, ch := range changes {
+		if !ch.Deleted {
+			ids = append(ids, ch.ExpenseID)
+		}
+	}
+	expenses, err := GetExpensesByID(tx, ids)
+	if err != nil {
+		return resp, err
+	}
+	byID := map[int]Expense{}
+	for _, exp := range expenses {
+		byID[exp.ID] = exp
+	}
+	for _, ch := range changes {
+		if ch.Deleted {
+			resp.Deleted = append(resp.Deleted, Tombstone{ID: ch.ExpenseID, Version: ch.Version})
+		} else if exp, ok := byID[ch.ExpenseID]; ok {
+			resp.Changed = append(resp.Changed, SyncExpense{Expense: exp, Version: ch.Version})
+		}
+	}
+	return resp, tx.Commit()
+}
+
+func validateSyncChange(ch SyncChange) error {
+	switch {
+	case ch.ID < 0:
+		return errors.New("Field id is invalid")
+	case ch.ID == 0 && ch.Deleted:
+		return errors.New("Field id is required to delete")
+	case ch.ID == 0 && ch.ClientID == "":
+		return errors.New("Field client_id is required to create")
+	case ch.ID == 0:
+		return ValidateExpense(ch.Expense)
+	case ch.Deleted:
+		return nil
+	default:
+		return ValidateUpdate(ch.Expense)
+	}
+}
+
+// applyChange applies ch unless the expense has moved on from its base
+// version. Conflicts are resolved in the server's favour: nothing is written
+// and the result carries the current server copy for the client to merge and
+// push again against the new version. A delete of an expense that is already
+// deleted succeeds, as both sides agree.
+func applyChange(db Querier, actor audit.Actor, user auth.User, owner string, cfg policy.Config, ch SyncChange) (PushResult, error) {
+	result := PushResult{ClientID: ch.ClientID}
+	if ch.ID == 0 {
+		return createSynced(db, actor, cfg, ch)
+	}
+
+	current, err := getExpenseForUpdate(db, ch.ID)
+	if err == sql.ErrNoRows {
+		change, err := GetChange(db, ch.ID)
+		if err != nil || !change.Deleted || !visibleTo(owner, change.Owner) {
+			return result, sql.ErrNoRows
+		}
+		result.Deleted = &Tombstone{ID: ch.ID, Version: change.Version}
+		if ch.Deleted {
+			result.Status = http.StatusOK
+		} else {
+			result.Status, result.Conflict = http.StatusConflict, true
+		}
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	if !visibleTo(owner, current.Owner) {
+		return result, sql.ErrNoRows
+	}
+	change, err := GetChange(db, ch.ID)
+	if err != nil {
+		return result, err
+	}
+	if change.Version != ch.BaseVersion {
+		result.Status, result.Conflict = http.StatusConflict, true
+		result.Expense = &SyncExpense{Expense: current, Version: change.Version}
+		return result, nil
+	}
+
+	if ch.Deleted {
+		if _, err := DeleteExpenseAudited(db, actor, user, ch.ID); err != nil {
+			return result, err
+		}
+		if change, err = GetChange(db, ch.ID); err != nil {
+			return result, err
+		}
+		result.Status, result.Deleted = http.StatusOK, &Tombstone{ID: ch.ID, Version: change.Version}
+		return result, nil
+	}
+	exp := ch.Expense
+	exp.ID = ch.ID
+	if exp, err = UpdateExpenseAudited(db, actor, user, exp); err != nil {
+		return result, err
+	}
+	if exp.Warnings, err = CheckPolicy(db, cfg, exp); err != nil {
+		return result, err
+	}
+	if change, err = GetChange(db, ch.ID); err != nil {
+		return result, err
+	}
+	result.Status, result.Expense = http.StatusOK, &SyncExpense{Expense: exp, Version: change.Version}
+	return result, nil
+}
+
+// createSynced creates the expense for a client_id once. The client_id is
+// kept as the expense's external reference, so a retried push finds the
+// expense it created the first time.
+func createSynced(db Querier, actor audit.Actor, cfg policy.Config, ch SyncChange) (PushResult, error) {
+	result := PushResult{ClientID: ch.ClientID}
+	ref := syncRefPrefix + actor.Name + ":" + ch.ClientID
+	exp, err := scanExpense(db.QueryRow("SELECT "+expenseColumns+" FROM expenses WHERE external_ref = $1", ref))
+	switch err {
+	case nil:
+		result.Status = http.StatusOK
+	case sql.ErrNoRows:
+		exp = ch.Expense
+		exp.ExternalRef = ref
+		if exp, err = CreateExpenseAudited(db, actor, exp); err != nil {
+			return result, err
+		}
+		if exp.Warnings, err = CheckNewPolicy(db, cfg, exp); err != nil {
+			return result, err
+		}
+		result.Status = http.StatusCreated
+	default:
+		return result, err
+	}
+	change, err := GetChange(db, exp.ID)
+	if err != nil {
+		return result, err
+	}
+	result.Expense = &SyncExpense{Expense: exp, Version: change.Version}
+	return result, nil
+}
+
+// PushHandler applies changes made on a client, each in its own
+// transaction, and reports a result per change in request order. A change
+// whose base_version is stale comes back with status 409, conflict set and
+// the server's copy; see applyChange.
+func (h *handler) PushHandler(c echo.Context) error {
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, MaxBodySize)
+	push := PushRequest{}
+	if err := json.NewDecoder(req.Body).Decode(&push); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return c.JSON(http.StatusRequestEntityTooLarge, Error{Message: "Push is too large"})
+		}
+		return c.JSON(http.StatusBadRequest, Error{Message: err.Error()})
+	}
+	if len(push.Changes) == 0 {
+		return c.JSON(http.StatusBadRequest, Error{Message: "Field changes is required"})
+	}
+	if len(push.Changes) > maxBatchItems {
+		return c.JSON(http.StatusRequestEntityTooLarge, Error{Message: fmt.Sprintf("Push is limited to %d changes", maxBatchItems)})
+	}
+
+	actor, user, owner := audit.ActorFrom(c), auth.UserFrom(c), VisibleOwner(c)
+	resp := PushResponse{Results: make([]PushResult, len(push.Changes))}
+	for i, ch := range push.Changes {
+		ch.Expense.ReportingCurrency = h.reportingCurrency(c)
+		resp.Results[i] = h.push(actor, user, owner, ch)
+		resp.Results[i].Index = i
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *handler) push(actor audit.Actor, user auth.User, owner string, ch SyncChange) PushResult {
+	if err := validateSyncChange(ch); err != nil {
+		return PushResult{ClientID: ch.ClientID, Status: http.StatusBadRequest, Error: err.Error()}
+	}
+	var result PushResult
+	err := withTx(h.DB, func(tx *sql.Tx) error {
+		var err error
+		result, err = applyChange(tx, actor, user, owner, h.Policy, ch)
+		return err
+	})
+	switch {
+	case err == nil:
+		return result
+	case err == sql.ErrNoRows:
+		result.Status, result.Error = http.StatusNotFound, "Expense not found"
+	case err == ErrLocked, err == ErrGroupExpense:
+		result.Status, result.Error = http.StatusConflict, err.Error()
+	case err == ErrNotOwner:
+		result.Status, result.Error = http.StatusForbidden, err.Error()
+	case errors.As(err, new(*PolicyError)), errors.Is(err, fx.ErrNoRate):
+		result.Status, result.Error = http.StatusUnprocessableEntity, err.Error()
+	default:
+		result.Status, result.Error = http.StatusInternalServerError, err.Error()
+	}
+	result.ClientID, result.Expense, result.Deleted = ch.ClientID, nil, nil
+	return result
+}
@@ -0,0 +1,245 @@
+//go:build unit
+
+package expense
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/auth"
+)
+
+var expenseRowColumns = []string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}
+
+func syncContext(method, target, body string, user auth.User) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	auth.SetUser(c, user)
+	return c, rec
+}
+
+func expectVersion(mock sqlmock.Sqlmock, id int, version int64, deleted bool) {
+	mock.ExpectQuery("SELECT version, owner, deleted FROM expense_changes").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "owner", "deleted"}).AddRow(version, "alice", deleted))
+}
+
+func expectOwnedLock(mock sqlmock.Sqlmock, id int) {
+	mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id = (.+) FOR UPDATE").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(expenseRowColumns).
+			AddRow(id, "taxi", 120.0, "", pq.Array([]string{}), nil, nil, nil, "draft", "alice", nil, nil, nil, nil, nil, nil))
+}
+
+func TestSyncHandler(t *testing.T) {
+	t.Run("Sync should list the caller's changes and deletes since the token", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT COALESCE\\(max\\(version\\), 0\\) FROM expense_changes").
+			WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(12))
+		mock.ExpectQuery("SELECT expense_id, version, deleted FROM expense_changes WHERE version > \\$1 AND version <= \\$2 AND owner = \\$3 ORDER BY version ASC LIMIT \\$4").
+			WithArgs(int64(5), int64(12), "alice", 501).
+			WillReturnRows(sqlmock.NewRows([]string{"expense_id", "version", "deleted"}).AddRow(3, 7, true).AddRow(4, 9, false))
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id = ANY").
+			WithArgs(pq.Array([]int{4})).
+			WillReturnRows(sqlmock.NewRows(expenseRowColumns).
+				AddRow(4, "taxi", 120.0, "", pq.Array([]string{}), nil, nil, nil, "draft", "alice", nil, nil, nil, nil, nil, nil))
+		mock.ExpectCommit()
+
+		h := NewApplication(db)
+		c, rec := syncContext(http.MethodGet, "/sync?since=5", "", auth.User{Name: "alice"})
+
+		if err := h.SyncHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"token":"12","more":false,"changed":[{"id":4,"title":"taxi","amount":120,"note":"","tags":[],"status":"draft","owner":"alice","version":9}],"deleted":[{"id":3,"version":7}]}` + "\n"
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Sync cut at limit should return the last version as token", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT COALESCE").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(12))
+		mock.ExpectQuery("SELECT expense_id, version, deleted FROM expense_changes").
+			WithArgs(int64(0), int64(12), 3).
+			WillReturnRows(sqlmock.NewRows([]string{"expense_id", "version", "deleted"}).AddRow(1, 2, true).AddRow(2, 3, true).AddRow(5, 4, true))
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id = ANY").WillReturnRows(sqlmock.NewRows(expenseRowColumns))
+		mock.ExpectCommit()
+
+		h := NewApplication(db)
+		c, rec := syncContext(http.MethodGet, "/sync?limit=2", "", auth.User{Name: "carol", Roles: []string{auth.RoleAdmin}})
+
+		if err := h.SyncHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"token":"3","more":true,"changed":[],"deleted":[{"id":1,"version":2},{"id":2,"version":3}]}` + "\n"
+		if rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %s", rec.Body.String())
+		}
+	})
+
+	t.Run("Sync with a token ahead of the server should got gone", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT COALESCE").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(12))
+		mock.ExpectRollback()
+
+		h := NewApplication(db)
+		c, rec := syncContext(http.MethodGet, "/sync?since=40", "", auth.User{Name: "alice"})
+
+		if err := h.SyncHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusGone {
+			t.Errorf("should status gone but it got %v", rec.Code)
+		}
+	})
+
+	t.Run("Sync with invalid token should got error", func(t *testing.T) {
+		h := NewApplication(nil)
+		c, rec := syncContext(http.MethodGet, "/sync?since=abc", "", auth.User{Name: "alice"})
+
+		if err := h.SyncHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"message":"Query since is invalid"}` + "\n"
+		if rec.Code != http.StatusBadRequest || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %s", rec.Body.String())
+		}
+	})
+}
+
+func TestPushHandler(t *testing.T) {
+	t.Run("Push should apply changes and report conflicts per record", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		// Stale base version: nothing is written and the server copy returned.
+		mock.ExpectBegin()
+		expectOwnedLock(mock, 4)
+		expectVersion(mock, 4, 9, false)
+		mock.ExpectCommit()
+		// Current base version: the update is applied.
+		mock.ExpectBegin()
+		expectOwnedLock(mock, 5)
+		expectVersion(mock, 5, 6, false)
+		expectOwnedLock(mock, 5)
+		expectNotInGroup(mock, 5)
+		mock.ExpectPrepare("UPDATE expenses").ExpectExec().
+			WithArgs(5, "bus", 30.0, "", pq.Array([]string{}), nil, "THB", 30.0, "THB", 1.0, nil).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT hash FROM expense_audit").WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+		mock.ExpectQuery("INSERT INTO expense_audit").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO outbox").WithArgs(EventUpdated, 5, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO expense_changes").WithArgs(5, "alice", false, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		expectVersion(mock, 5, 13, false)
+		mock.ExpectCommit()
+		// Update of an expense deleted on the server conflicts.
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id = (.+) FOR UPDATE").WithArgs(6).WillReturnError(sql.ErrNoRows)
+		expectVersion(mock, 6, 11, true)
+		mock.ExpectCommit()
+
+		h := NewApplication(db)
+		body := `{"changes":[
+			{"id":4,"base_version":8,"expense":{"title":"taxi home","amount":120,"tags":[]}},
+			{"id":5,"base_version":6,"expense":{"title":"bus","amount":30,"tags":[]}},
+			{"id":6,"base_version":10,"expense":{"title":"lunch","amount":10,"tags":[]}},
+			{"expense":{"title":"no client id","amount":1}}
+		]}`
+		c, rec := syncContext(http.MethodPost, "/sync", body, auth.User{Name: "alice"})
+
+		if err := h.PushHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"results":[` +
+			`{"index":0,"status":409,"conflict":true,"expense":{"id":4,"title":"taxi","amount":120,"note":"","tags":[],"status":"draft","owner":"alice","version":9}},` +
+			`{"index":1,"status":200,"expense":{"id":5,"title":"bus","amount":30,"note":"","tags":[],"status":"draft","owner":"alice","currency":"THB","reporting_amount":30,"reporting_currency":"THB","rate":1,"version":13}},` +
+			`{"index":2,"status":409,"conflict":true,"deleted":{"id":6,"version":11}},` +
+			`{"index":3,"status":400,"error":"Field client_id is required to create"}]}` + "\n"
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Push retrying a create should return the expense created first", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE external_ref = \\$1").
+			WithArgs("sync:alice:tmp-1").
+			WillReturnRows(sqlmock.NewRows(expenseRowColumns).
+				AddRow(7, "coffee", 3.0, "", pq.Array([]string{}), nil, nil, "sync:alice:tmp-1", "draft", "alice", nil, nil, nil, nil, nil, nil))
+		expectVersion(mock, 7, 15, false)
+		mock.ExpectCommit()
+		// Deleting an expense already deleted on the server agrees with it.
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id = (.+) FOR UPDATE").WithArgs(8).WillReturnError(sql.ErrNoRows)
+		expectVersion(mock, 8, 14, true)
+		mock.ExpectCommit()
+
+		h := NewApplication(db)
+		body := `{"changes":[{"client_id":"tmp-1","expense":{"title":"coffee","amount":3,"tags":[]}},{"id":8,"base_version":2,"deleted":true}]}`
+		c, rec := syncContext(http.MethodPost, "/sync", body, auth.User{Name: "alice"})
+
+		if err := h.PushHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"results":[` +
+			`{"index":0,"client_id":"tmp-1","status":200,"expense":{"id":7,"title":"coffee","amount":3,"note":"","tags":[],"external_ref":"sync:alice:tmp-1","status":"draft","owner":"alice","version":15}},` +
+			`{"index":1,"status":200,"deleted":{"id":8,"version":14}}]}` + "\n"
+		if rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %s", rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Push without changes should got error", func(t *testing.T) {
+		h := NewApplication(nil)
+		c, rec := syncContext(http.MethodPost, "/sync", `{"changes":[]}`, auth.User{Name: "alice"})
+
+		if err := h.PushHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"message":"Field changes is required"}` + "\n"
+		if rec.Code != http.StatusBadRequest || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %s", rec.Body.String())
+		}
+	})
+}
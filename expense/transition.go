@@ -0,0 +1,96 @@
+package expense
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/auth"
+)
+
+type TransitionRequest struct {
+	Reason string `json:"reason"`
+}
+
+type TransitionResponse struct {
+	Expense    Expense          `json:"expense"`
+	Transition TransitionRecord `json:"transition"`
+}
+
+func (h *handler) SubmitExpenseHandler(c echo.Context) error {
+	return h.transition(c, ActionSubmit)
+}
+
+func (h *handler) ApproveExpenseHandler(c echo.Context) error {
+	return h.transition(c, ActionApprove)
+}
+
+// RejectExpenseHandler requires a reason in the request body.
+func (h *handler) RejectExpenseHandler(c echo.Context) error {
+	return h.transition(c, ActionReject)
+}
+
+func (h *handler) ReimburseExpenseHandler(c echo.Context) error {
+	return h.transition(c, ActionReimburse)
+}
+
+func (h *handler) transition(c echo.Context, action string) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Error{Message: "ID is invalid"})
+	}
+	req := TransitionRequest{}
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, Error{Message: err.Error()})
+		}
+	}
+
+	resp := TransitionResponse{}
+	err = withTx(h.DB, func(tx *sql.Tx) error {
+		resp.Expense, resp.Transition, err = TransitionExpense(tx, audit.ActorFrom(c), auth.UserFrom(c), id, action, req.Reason, 0)
+		if err != nil || action != ActionSubmit {
+			return err
+		}
+		// Receipt rules only warn while the expense is a draft, so they
+		// are enforced when it is handed in.
+		resp.Expense.Warnings, err = CheckPolicy(tx, h.Policy, resp.Expense)
+		return err
+	})
+	var te *TransitionError
+	var violation *PolicyError
+	switch {
+	case err == nil:
+		return c.JSON(http.StatusOK, resp)
+	case err == sql.ErrNoRows:
+		return c.JSON(http.StatusNotFound, Error{Message: "Expense not found"})
+	case errors.As(err, &te):
+		return c.JSON(te.Status, Error{Message: te.Message})
+	case errors.As(err, &violation):
+		return c.JSON(http.StatusUnprocessableEntity, violation)
+	default:
+		return c.JSON(http.StatusInternalServerError, Error{Message: err.Error()})
+	}
+}
+
+func (h *handler) GetTransitionsHandler(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Error{Message: "ID is invalid"})
+	}
+	switch _, err := GetVisibleExpense(c, h.DB, id); err {
+	case nil:
+	case sql.ErrNoRows:
+		return c.JSON(http.StatusNotFound, Error{Message: "Expense not found"})
+	default:
+		return c.JSON(http.StatusInternalServerError, Error{Message: err.Error()})
+	}
+	records, err := GetTransitions(h.DB, id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Error{Message: "Unable to get transitions from database:" + err.Error()})
+	}
+	return c.JSON(http.StatusOK, records)
+}
@@ -0,0 +1,39 @@
+package expense
+
+import "errors"
+
+// ValidateExpense applies the checks every newly created expense must pass,
+// whichever endpoint it arrives through.
+func ValidateExpense(exp Expense) error {
+	if exp.ID != 0 {
+		return errors.New("Field ID is invalid")
+	}
+	if exp.RecurringID != 0 {
+		return errors.New("Field recurring_id is read-only")
+	}
+	if exp.Status != "" {
+		return errors.New("Field status is read-only")
+	}
+	if exp.Owner != "" {
+		return errors.New("Field owner is read-only")
+	}
+	if !ValidDate(exp.Date) {
+		return errors.New("Field date is invalid")
+	}
+	if exp.Currency != "" && !validCurrency(exp.Currency) {
+		return errors.New("Field currency is invalid")
+	}
+	return nil
+}
+
+// ValidateUpdate applies the checks an update to an existing expense must
+// pass.
+func ValidateUpdate(exp Expense) error {
+	if !ValidDate(exp.Date) {
+		return errors.New("Field date is invalid")
+	}
+	if exp.Currency != "" && !validCurrency(exp.Currency) {
+		return errors.New("Field currency is invalid")
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package expense
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/auth"
+)
+
+const (
+	StatusDraft      = "draft"
+	StatusSubmitted  = "submitted"
+	StatusApproved   = "approved"
+	StatusRejected   = "rejected"
+	StatusReimbursed = "reimbursed"
+
+	ActionSubmit    = "submit"
+	ActionApprove   = "approve"
+	ActionReject    = "reject"
+	ActionReimburse = "reimburse"
+)
+
+var ErrLocked = errors.New("Expense is final and can no longer be changed")
+
+var ErrNotOwner = errors.New("Only the owner can change this expense")
+
+// ErrGroupExpense refuses changes to an expense split in a group outside the
+// group API, which would leave the members' shares stale.
+var ErrGroupExpense = errors.New("Expense is split in a group and can only change through the group")
+
+// locked reports whether exp is final: approved, reimbursed or bundled into a
+// reimbursement report.
+func locked(exp Expense) bool {
+	return exp.Status == StatusApproved || exp.Status == StatusReimbursed || exp.ReportID != 0
+}
+
+// Transition describes how an action moves an expense between statuses and
+// who may perform it.
+type Transition struct {
+	From []string
+	To   string
+	// Role is required of the actor when set.
+	Role string
+	// NotOwner forbids the expense's submitter from taking the action.
+	NotOwner bool
+	// OwnerOnly restricts the action to the expense's submitter.
+	OwnerOnly   bool
+	NeedsReason bool
+}
+
+var Transitions = map[string]Transition{
+	ActionSubmit:    {From: []string{StatusDraft, StatusRejected}, To: StatusSubmitted, OwnerOnly: true},
+	ActionApprove:   {From: []string{StatusSubmitted}, To: StatusApproved, Role: auth.RoleApprover, NotOwner: true},
+	ActionReject:    {From: []string{StatusSubmitted}, To: StatusRejected, Role: auth.RoleApprover, NotOwner: true, NeedsReason: true},
+	ActionReimburse: {From: []string{StatusApproved}, To: StatusReimbursed, Role: auth.RoleApprover},
+}
+
+func (t Transition) allowed(from string) bool {
+	for _, s := range t.From {
+		if s == from {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionError is a refused transition and the HTTP status it maps to.
+type TransitionError struct {
+	Status  int
+	Message string
+}
+
+func (e *TransitionError) Error() string {
+	return e.Message
+}
+
+type TransitionRecord struct {
+	ID        int       `json:"id"`
+	ExpenseID int       `json:"expense_id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Actor     string    `json:"actor"`
+	Reason    string    `json:"reason,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TransitionExpense applies action to expense id on behalf of user. The row
+// is locked for the duration of the caller's transaction, the guards of the
+// transition are checked, and the change is written to both the transition
+// log and the audit trail. reportID names the reimbursement report the caller
+// acts for, or 0; expenses in a report can only move with their report.
+func TransitionExpense(db Querier, actor audit.Actor, user auth.User, id int, action, reason string, reportID int) (Expense, TransitionRecord, error) {
+	t, ok := Transitions[action]
+	if !ok {
+		return Expense{}, TransitionRecord{}, &TransitionError{http.StatusBadRequest, "Action " + action + " is invalid"}
+	}
+	before, err := getExpenseForUpdate(db, id)
+	if err != nil {
+		return before, TransitionRecord{}, err
+	}
+	reason = strings.TrimSpace(reason)
+	switch {
+	case before.ReportID != reportID:
+		return before, TransitionRecord{}, &TransitionError{http.StatusConflict, "Expense is part of report " + strconv.Itoa(before.ReportID)}
+	case t.Role != "" && !user.HasRole(t.Role):
+		return before, TransitionRecord{}, &TransitionError{http.StatusForbidden, "Role " + t.Role + " is required"}
+	case t.NotOwner && before.Owner == actor.Name:
+		return before, TransitionRecord{}, &TransitionError{http.StatusForbidden, "Submitter cannot " + action + " own expense"}
+	case t.OwnerOnly && before.Owner != "" && before.Owner != actor.Name:
+		return before, TransitionRecord{}, &TransitionError{http.StatusForbidden, "Only the owner can " + action + " this expense"}
+	case before.Owner == "" && !user.HasRole(auth.RoleAdmin):
+		// Legacy expenses predate owners and are claimed by whoever moves
+		// them first, so only an admin may do that.
+		return before, TransitionRecord{}, &TransitionError{http.StatusForbidden, "Role " + auth.RoleAdmin + " is required to claim an expense without an owner"}
+	case !t.allowed(before.Status):
+		return before, TransitionRecord{}, &TransitionError{http.StatusConflict, "Cannot " + action + " an expense that is " + before.Status}
+	case t.NeedsReason && reason == "":
+		return before, TransitionRecord{}, &TransitionError{http.StatusBadRequest, "Field reason is required"}
+	}
+
+	after := before
+	after.Status = t.To
+	if after.Owner == "" {
+		after.Owner = actor.Name
+	}
+	if _, err := db.Exec("UPDATE expenses SET status=$2, owner=$3 WHERE id = $1", id, after.Status, after.Owner); err != nil {
+		log.Errorf("Update expense status error: %v", err)
+		return before, TransitionRecord{}, err
+	}
+
+	rec := TransitionRecord{ExpenseID: id, From: before.Status, To: after.Status, Actor: actor.Name, Reason: reason, RequestID: actor.RequestID}
+	err = db.QueryRow("INSERT INTO expense_transitions (expense_id, from_status, to_status, actor, reason, request_id) values ($1, $2, $3, $4, $5, $6) RETURNING id, created_at",
+		id, rec.From, rec.To, rec.Actor, nullString(reason), nullString(actor.RequestID)).Scan(&rec.ID, &rec.CreatedAt)
+	if err != nil {
+		return before, rec, err
+	}
+	if _, err := audit.Record(db, actor, action, id, before, after); err != nil {
+		return before, rec, err
+	}
+	if err := WriteEvent(db, EventUpdated, after); err != nil {
+		return before, rec, err
+	}
+	return after, rec, nil
+}
+
+// GetTransitions lists the status changes of one expense, oldest first.
+func GetTransitions(db *sql.DB, id int) ([]TransitionRecord, error) {
+	records := []TransitionRecord{}
+	rows, err := db.Query("SELECT id, expense_id, from_status, to_status, actor, reason, request_id, created_at FROM expense_transitions WHERE expense_id = $1 ORDER BY id ASC", id)
+	if err != nil {
+		return records, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		rec := TransitionRecord{}
+		var reason, requestID sql.NullString
+		if err := rows.Scan(&rec.ID, &rec.ExpenseID, &rec.From, &rec.To, &rec.Actor, &reason, &requestID, &rec.CreatedAt); err != nil {
+			return records, err
+		}
+		rec.Reason, rec.RequestID = reason.String, requestID.String
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
@@ -0,0 +1,177 @@
+//go:build unit
+
+package expense
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/auth"
+)
+
+func expectStatus(mock sqlmock.Sqlmock, id int, status, owner string) {
+	mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id = (.+) FOR UPDATE").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}).
+			AddRow(id, "taxi", 10.0, "", pq.Array([]string{}), nil, nil, nil, status, owner, nil, nil, nil, nil, nil, nil))
+}
+
+func transitionContext(action, body string, user auth.User) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/expenses/3/"+action, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("3")
+	auth.SetUser(c, user)
+	return c, rec
+}
+
+func TestTransitionHandlers(t *testing.T) {
+	approver := auth.User{Name: "bob", Roles: []string{auth.RoleApprover}}
+
+	t.Run("Approve should move a submitted expense and record the transition", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		expectStatus(mock, 3, StatusSubmitted, "alice")
+		mock.ExpectExec("UPDATE expenses SET status").
+			WithArgs(3, StatusApproved, "alice").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO expense_transitions").
+			WithArgs(3, StatusSubmitted, StatusApproved, "bob", nil, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(4, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)))
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT hash FROM expense_audit").WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+		mock.ExpectQuery("INSERT INTO expense_audit").
+			WithArgs(3, ActionApprove, "bob", nil, []byte(`[{"field":"status","before":"submitted","after":"approved"}]`), sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO outbox").WithArgs(EventUpdated, 3, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO expense_changes").WithArgs(3, sqlmock.AnyArg(), false, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		c, rec := transitionContext("approve", "", approver)
+		if err := NewApplication(db).ApproveExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"expense":{"id":3,"title":"taxi","amount":10,"note":"","tags":[],"status":"approved","owner":"alice"},` +
+			`"transition":{"id":4,"expense_id":3,"from":"submitted","to":"approved","actor":"bob","created_at":"2026-01-02T00:00:00Z"}}` + "\n"
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Approve ownerless expense by an admin should claim it", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		expectStatus(mock, 3, StatusSubmitted, "")
+		mock.ExpectExec("UPDATE expenses SET status").
+			WithArgs(3, StatusApproved, "dave").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO expense_transitions").
+			WithArgs(3, StatusSubmitted, StatusApproved, "dave", nil, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(4, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)))
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT hash FROM expense_audit").WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+		mock.ExpectQuery("INSERT INTO expense_audit").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO outbox").WithArgs(EventUpdated, 3, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO expense_changes").WithArgs(3, sqlmock.AnyArg(), false, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		c, rec := transitionContext("approve", "", auth.User{Name: "dave", Roles: []string{auth.RoleAdmin, auth.RoleApprover}})
+		if err := NewApplication(db).ApproveExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusOK {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	cases := []struct {
+		name   string
+		action string
+		body   string
+		user   auth.User
+		status string
+		owner  string
+		code   int
+		want   string
+	}{
+		{"Approve without approver role should be forbidden", ActionApprove, "", auth.User{Name: "carol"}, StatusSubmitted, "alice", http.StatusForbidden, "Role approver is required"},
+		{"Approve own expense should be forbidden", ActionApprove, "", auth.User{Name: "alice", Roles: []string{auth.RoleApprover}}, StatusSubmitted, "alice", http.StatusForbidden, "Submitter cannot approve own expense"},
+		{"Approve draft should be a conflict", ActionApprove, "", approver, StatusDraft, "alice", http.StatusConflict, "Cannot approve an expense that is draft"},
+		{"Reject without reason should be a bad request", ActionReject, `{"reason":" "}`, approver, StatusSubmitted, "alice", http.StatusBadRequest, "Field reason is required"},
+		{"Submit by another user should be forbidden", ActionSubmit, "", auth.User{Name: "carol"}, StatusDraft, "alice", http.StatusForbidden, "Only the owner can submit this expense"},
+		{"Submit ownerless expense by a non-admin should be forbidden", ActionSubmit, "", auth.User{Name: "carol"}, StatusDraft, "", http.StatusForbidden, "Role admin is required to claim an expense without an owner"},
+		{"Approve ownerless expense by a non-admin should be forbidden", ActionApprove, "", approver, StatusSubmitted, "", http.StatusForbidden, "Role admin is required to claim an expense without an owner"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			mock.ExpectBegin()
+			expectStatus(mock, 3, tc.status, tc.owner)
+			mock.ExpectRollback()
+
+			c, rec := transitionContext(tc.action, tc.body, tc.user)
+			if err := NewApplication(db).transition(c, tc.action); err != nil {
+				t.Errorf("should not return error but it got %v", err)
+			}
+			if rec.Code != tc.code || rec.Body.String() != `{"message":"`+tc.want+`"}`+"\n" {
+				t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+
+	t.Run("Update approved expense should be a conflict", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		expectStatus(mock, 3, StatusApproved, "alice")
+		mock.ExpectRollback()
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPut, "/expenses/3", strings.NewReader(GoodExpenseJSON))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("3")
+		auth.SetUser(c, auth.User{Name: "alice"})
+		if err := NewApplication(db).UpdateExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusConflict || rec.Body.String() != `{"message":"Expense is final and can no longer be changed"}`+"\n" {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+}
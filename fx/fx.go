@@ -0,0 +1,208 @@
+package fx
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/gommon/log"
+)
+
+// Base is the currency ECB reference rates are quoted against.
+const Base = "EUR"
+
+// MaxAge is how far back a rate is looked up. ECB skips weekends and TARGET
+// holidays, so the rate of an expense is the latest one published before it.
+const MaxAge = 7 * 24 * time.Hour
+
+const dateLayout = "2006-01-02"
+
+var ErrNoRate = errors.New("No exchange rate")
+
+// Rate is the number of units of Currency one Base buys on Date.
+type Rate struct {
+	Date     string
+	Currency string
+	Rate     float64
+}
+
+// Querier is satisfied by both *sql.DB and *sql.Tx.
+type Querier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func InitDB(db *sql.DB) error {
+	commands := []string{
+		"CREATE TABLE IF NOT EXISTS exchange_rates ( date DATE NOT NULL, currency TEXT NOT NULL, rate FLOAT NOT NULL, PRIMARY KEY (currency, date));",
+	}
+	for _, command := range commands {
+		if _, err := db.Exec(command); err != nil {
+			return err
+		}
+		log.Printf("Executed: %s", command)
+	}
+	return nil
+}
+
+// Parse reads ECB reference rates in the eurofxref CSV or XML layout, picking
+// the format from the file extension or, failing that, the content.
+func Parse(filename string, r io.Reader) ([]Rate, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.EqualFold(filepath.Ext(filename), ".xml"),
+		filepath.Ext(filename) == "" && bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")):
+		return ParseXML(bytes.NewReader(data))
+	default:
+		return ParseCSV(bytes.NewReader(data))
+	}
+}
+
+// ParseCSV reads eurofxref.csv and eurofxref-hist.csv: a Date column followed
+// by one column per currency. Empty and N/A cells are skipped.
+func ParseCSV(r io.Reader) ([]Rate, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(header) == 0 || strings.TrimSpace(header[0]) != "Date" {
+		return nil, errors.New("first column must be Date")
+	}
+	rates := []Rate{}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		date, err := parseDate(record[0])
+		if err != nil {
+			return nil, err
+		}
+		for i := 1; i < len(record) && i < len(header); i++ {
+			currency, value := strings.TrimSpace(header[i]), strings.TrimSpace(record[i])
+			if currency == "" || value == "" || value == "N/A" {
+				continue
+			}
+			rate, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", date, currency, err)
+			}
+			rates = append(rates, Rate{Date: date, Currency: currency, Rate: rate})
+		}
+	}
+}
+
+func parseDate(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{dateLayout, "02 January 2006", "2 January 2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(dateLayout), nil
+		}
+	}
+	return "", fmt.Errorf("date %q is invalid", s)
+}
+
+type ecbEnvelope struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string  `xml:"currency,attr"`
+			Rate     float64 `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+// ParseXML reads the gesmes envelope of eurofxref-daily.xml and the
+// historical XML files.
+func ParseXML(r io.Reader) ([]Rate, error) {
+	env := ecbEnvelope{}
+	if err := xml.NewDecoder(r).Decode(&env); err != nil {
+		return nil, err
+	}
+	rates := []Rate{}
+	for _, day := range env.Days {
+		date, err := parseDate(day.Time)
+		if err != nil {
+			return nil, err
+		}
+		for _, rate := range day.Rates {
+			rates = append(rates, Rate{Date: date, Currency: rate.Currency, Rate: rate.Rate})
+		}
+	}
+	return rates, nil
+}
+
+// Import upserts rates in one transaction so a re-published file corrects
+// earlier values, returning the number of rows written.
+func Import(db *sql.DB, rates []Rate) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	for _, r := range rates {
+		if _, err := tx.Exec("INSERT INTO exchange_rates (date, currency, rate) values ($1, $2, $3) ON CONFLICT (currency, date) DO UPDATE SET rate = EXCLUDED.rate", r.Date, r.Currency, r.Rate); err != nil {
+			return 0, err
+		}
+	}
+	return len(rates), tx.Commit()
+}
+
+func baseRate(db Querier, currency string, date time.Time) (float64, time.Time, error) {
+	if currency == Base {
+		return 1, date, nil
+	}
+	var rate float64
+	var found time.Time
+	err := db.QueryRow("SELECT rate, date FROM exchange_rates WHERE currency = $1 AND date <= $2 ORDER BY date DESC LIMIT 1", currency, date.Format(dateLayout)).Scan(&rate, &found)
+	if err == sql.ErrNoRows || err == nil && date.Sub(found) > MaxAge {
+		return 0, found, fmt.Errorf("%w for %s on %s", ErrNoRate, currency, date.Format(dateLayout))
+	}
+	return rate, found, err
+}
+
+// Lookup returns how many units of to one unit of from buys on date, crossed
+// through Base, and the publication date of the older rate it used.
+func Lookup(db Querier, from, to, date string) (float64, string, error) {
+	day, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return 0, "", err
+	}
+	if from == to {
+		return 1, date, nil
+	}
+	fromRate, fromDate, err := baseRate(db, from, day)
+	if err != nil {
+		return 0, "", err
+	}
+	toRate, toDate, err := baseRate(db, to, day)
+	if err != nil {
+		return 0, "", err
+	}
+	if toDate.After(fromDate) {
+		toDate = fromDate
+	}
+	return round(toRate/fromRate, 6), toDate.Format(dateLayout), nil
+}
+
+func round(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
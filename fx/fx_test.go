@@ -0,0 +1,119 @@
+//go:build unit
+
+package fx
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func parseFile(t *testing.T, name string) []Rate {
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rates, err := Parse(name, f)
+	if err != nil {
+		t.Fatalf("should not return error but it got %v", err)
+	}
+	return rates
+}
+
+func TestParse(t *testing.T) {
+	daily := []Rate{
+		{Date: "2026-01-05", Currency: "USD", Rate: 1.095},
+		{Date: "2026-01-05", Currency: "JPY", Rate: 161.5},
+		{Date: "2026-01-05", Currency: "THB", Rate: 37.8},
+	}
+
+	t.Run("Parse daily CSV should be success", func(t *testing.T) {
+		if got := parseFile(t, "eurofxref.csv"); !reflect.DeepEqual(got, daily) {
+			t.Errorf("want %+v but it got %+v", daily, got)
+		}
+	})
+
+	t.Run("Parse daily XML should be success", func(t *testing.T) {
+		if got := parseFile(t, "eurofxref-daily.xml"); !reflect.DeepEqual(got, daily) {
+			t.Errorf("want %+v but it got %+v", daily, got)
+		}
+	})
+
+	t.Run("Parse historical CSV should skip missing rates", func(t *testing.T) {
+		got := parseFile(t, "eurofxref-hist.csv")
+		if len(got) != 6 || got[3] != (Rate{Date: "2026-01-02", Currency: "USD", Rate: 1.09}) {
+			t.Errorf("rates were not expected got: %+v", got)
+		}
+	})
+}
+
+func TestLookup(t *testing.T) {
+	t.Run("Lookup should cross rates through EUR", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT rate, date FROM exchange_rates").
+			WithArgs("USD", "2026-01-04").
+			WillReturnRows(sqlmock.NewRows([]string{"rate", "date"}).AddRow(1.09, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)))
+		mock.ExpectQuery("SELECT rate, date FROM exchange_rates").
+			WithArgs("THB", "2026-01-04").
+			WillReturnRows(sqlmock.NewRows([]string{"rate", "date"}).AddRow(37.5, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)))
+
+		rate, date, err := Lookup(db, "USD", "THB", "2026-01-04")
+		if err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rate != 34.40367 || date != "2026-01-02" {
+			t.Errorf("rate was not expected got: %v %s", rate, date)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Lookup from EUR should only read the target rate", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT rate, date FROM exchange_rates").
+			WithArgs("THB", "2026-01-05").
+			WillReturnRows(sqlmock.NewRows([]string{"rate", "date"}).AddRow(37.8, time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)))
+
+		rate, date, err := Lookup(db, "EUR", "THB", "2026-01-05")
+		if err != nil || rate != 37.8 || date != "2026-01-05" {
+			t.Errorf("rate was not expected got: %v %s %v", rate, date, err)
+		}
+	})
+
+	t.Run("Lookup with stale rate should got error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT rate, date FROM exchange_rates").
+			WillReturnRows(sqlmock.NewRows([]string{"rate", "date"}).AddRow(1.09, time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)))
+
+		_, _, err = Lookup(db, "USD", "EUR", "2026-01-04")
+		if !errors.Is(err, ErrNoRate) || err.Error() != "No exchange rate for USD on 2026-01-04" {
+			t.Errorf("should return no rate error but it got %v", err)
+		}
+	})
+
+	t.Run("Lookup same currency should not query", func(t *testing.T) {
+		rate, date, err := Lookup(nil, "THB", "THB", "2026-01-04")
+		if err != nil || rate != 1 || date != "2026-01-04" {
+			t.Errorf("rate was not expected got: %v %s %v", rate, date, err)
+		}
+	})
+}
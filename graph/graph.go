@@ -0,0 +1,160 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/attachment"
+	"github.com/phanbanchong/assessment/auth"
+	"github.com/phanbanchong/assessment/expense"
+	"github.com/phanbanchong/assessment/ledger"
+	"github.com/phanbanchong/assessment/policy"
+	"github.com/phanbanchong/assessment/report"
+)
+
+const (
+	defaultMaxDepth      = 8
+	defaultMaxComplexity = 2000
+)
+
+type handler struct {
+	DB            *sql.DB
+	Ledger        ledger.Config
+	Policy        policy.Config
+	Currency      string
+	MaxDepth      int
+	MaxComplexity int
+}
+
+// NewApplication creates the GraphQL handler. Expense categories are the
+// ledger accounts cfg posts them to.
+func NewApplication(db *sql.DB, cfg ledger.Config) *handler {
+	return &handler{
+		DB:            db,
+		Ledger:        cfg,
+		Policy:        policy.Config{Version: policy.Version},
+		Currency:      expense.ReportingCurrency(),
+		MaxDepth:      defaultMaxDepth,
+		MaxComplexity: defaultMaxComplexity,
+	}
+}
+
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+type contextKey struct{}
+
+// request is the state resolvers share within one GraphQL request, including
+// the loaders batching lookups made per expense.
+type request struct {
+	h           *handler
+	c           echo.Context
+	reports     *loader[*report.Report]
+	attachments *loader[[]attachment.Attachment]
+}
+
+func requestFrom(ctx context.Context) *request {
+	return ctx.Value(contextKey{}).(*request)
+}
+
+func (h *handler) newRequest(c echo.Context) *request {
+	r := &request{h: h, c: c}
+	r.reports = newLoader(func(ids []int) (map[int]*report.Report, error) {
+		reports, err := report.GetReportsByID(h.DB, ids)
+		byID := map[int]*report.Report{}
+		for i := range reports {
+			byID[reports[i].ID] = &reports[i]
+		}
+		return byID, err
+	})
+	r.attachments = newLoader(func(ids []int) (map[int][]attachment.Attachment, error) {
+		attachments, err := attachment.GetAttachmentsByExpense(h.DB, ids)
+		byExpense := map[int][]attachment.Attachment{}
+		for _, a := range attachments {
+			byExpense[a.ExpenseID] = append(byExpense[a.ExpenseID], a)
+		}
+		return byExpense, err
+	})
+	return r
+}
+
+// reportingCurrency is the currency new expenses of the caller are reported
+// in, as for the REST endpoints.
+func (r *request) reportingCurrency() string {
+	if currency := auth.UserFrom(r.c).Currency; currency != "" {
+		return currency
+	}
+	return r.h.Currency
+}
+
+// GraphQLHandler runs a query or mutation sent as JSON in a POST body, or as
+// query, operationName and variables parameters of a GET. Documents that do
+// not parse, validate or stay within the depth and complexity limits are
+// refused with 400 before anything runs; errors raised while running are
+// reported next to the data with 200, as GraphQL clients expect.
+func (h *handler) GraphQLHandler(c echo.Context) error {
+	req := Request{}
+	if c.Request().Method == http.MethodGet {
+		req.Query = c.QueryParam("query")
+		req.OperationName = c.QueryParam("operationName")
+		if v := c.QueryParam("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				return c.JSON(http.StatusBadRequest, failed(err))
+			}
+		}
+	} else if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, failed(err))
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"})})
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, failed(err))
+	}
+	if result := graphql.ValidateDocument(&schema, doc, nil); !result.IsValid {
+		return c.JSON(http.StatusBadRequest, &graphql.Result{Errors: result.Errors})
+	}
+	if c.Request().Method == http.MethodGet && isMutation(doc, req.OperationName) {
+		return c.JSON(http.StatusMethodNotAllowed, failed(errMutationOverGet))
+	}
+	if err := checkLimits(doc, req.Variables, h.MaxDepth, h.MaxComplexity); err != nil {
+		return c.JSON(http.StatusBadRequest, failed(err))
+	}
+
+	ctx := context.WithValue(c.Request().Context(), contextKey{}, h.newRequest(c))
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+	return c.JSON(http.StatusOK, result)
+}
+
+func failed(err error) *graphql.Result {
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)}}
+}
+
+var errMutationOverGet = errors.New("Mutations must be sent with POST")
+
+func isMutation(doc *ast.Document, operationName string) bool {
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if ok && (operationName == "" || (op.Name != nil && op.Name.Value == operationName)) && op.Operation == ast.OperationTypeMutation {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,225 @@
+//go:build unit
+
+package graph
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/auth"
+	"github.com/phanbanchong/assessment/ledger"
+)
+
+var expenseColumns = []string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}
+
+func graphqlContext(query string, variables map[string]any, user auth.User) (echo.Context, *httptest.ResponseRecorder) {
+	body, _ := json.Marshal(Request{Query: query, Variables: variables})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	auth.SetUser(c, user)
+	return c, rec
+}
+
+func TestGraphQLHandler(t *testing.T) {
+	admin := auth.User{Name: "admin", Roles: []string{auth.RoleAdmin}}
+
+	t.Run("Query should page expenses and batch report and attachment lookups", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		// Sibling fields resolve in no fixed order; batching shows as one query per lookup.
+		mock.MatchExpectationsInOrder(false)
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE tags @> \\$1 AND id > \\$2 ORDER BY id ASC LIMIT \\$3").
+			WithArgs(pq.Array([]string{"travel"}), 0, 3).
+			WillReturnRows(sqlmock.NewRows(expenseColumns).
+				AddRow(1, "taxi", 100.0, "", pq.Array([]string{"travel"}), nil, nil, nil, "approved", "alice", 5, nil, nil, nil, nil, nil).
+				AddRow(2, "train", 200.0, "", pq.Array([]string{"travel"}), nil, nil, nil, "approved", "bob", 5, nil, nil, nil, nil, nil).
+				AddRow(3, "bus", 10.0, "", pq.Array([]string{"travel"}), nil, nil, nil, "draft", "bob", nil, nil, nil, nil, nil, nil))
+		mock.ExpectQuery("SELECT (.+) FROM reports WHERE id = ANY").
+			WithArgs(pq.Array([]int{5})).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "submitter", "status", "total", "created_by", "created_at", "paid_at", "closed_at", "currency"}).
+				AddRow(5, "trip", "alice", "open", 300.0, "admin", time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), nil, nil, "THB"))
+		mock.ExpectQuery("SELECT (.+) FROM attachments WHERE expense_id = ANY").
+			WithArgs(pq.Array([]int{1, 2})).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "expense_id", "filename", "content_type", "size", "sha256", "created_at"}).
+				AddRow(7, 2, "ticket.pdf", "application/pdf", 10, "abc", time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)))
+		mock.ExpectQuery("SELECT COALESCE\\(reporting_currency, ''\\), count\\(\\*\\)").
+			WithArgs(pq.Array([]string{"travel"})).
+			WillReturnRows(sqlmock.NewRows([]string{"currency", "count", "amount"}).AddRow("", 3, 310.0))
+
+		cfg := ledger.DefaultConfig()
+		cfg.Accounts["travel"] = "Expenses:Travel"
+		h := NewApplication(db, cfg)
+		query := `{ expenses(filter: {tags: ["travel"]}, first: 2) {
+			nodes { id title category report { id title } attachments { filename } }
+			pageInfo { endCursor hasNextPage }
+			totalCount
+		} }`
+		c, rec := graphqlContext(query, nil, admin)
+
+		if err := h.GraphQLHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"data":{"expenses":{"nodes":[` +
+			`{"attachments":[],"category":"Expenses:Travel","id":1,"report":{"id":5,"title":"trip"},"title":"taxi"},` +
+			`{"attachments":[{"filename":"ticket.pdf"}],"category":"Expenses:Travel","id":2,"report":{"id":5,"title":"trip"},"title":"train"}],` +
+			`"pageInfo":{"endCursor":"` + encodeCursor(2) + `","hasNextPage":true},"totalCount":3}}}` + "\n"
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Query should only see the caller's own expenses", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT tag, (.+) FROM expenses CROSS JOIN unnest\\(tags\\) AS tag WHERE owner = \\$1").
+			WithArgs("alice").
+			WillReturnRows(sqlmock.NewRows([]string{"tag", "currency", "count", "amount"}).
+				AddRow("food", "THB", 2, 150.0).AddRow("food", "USD", 1, 10.0).AddRow("travel", "THB", 1, 100.0))
+
+		h := NewApplication(db, ledger.DefaultConfig())
+		c, rec := graphqlContext(`{ tags { tag count totals { currency amount } } bob: expenses(filter: {owner: "bob"}) { totalCount } }`, nil, auth.User{Name: "alice"})
+
+		if err := h.GraphQLHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"data":{"bob":{"totalCount":0},"tags":[` +
+			`{"count":3,"tag":"food","totals":[{"amount":150,"currency":"THB"},{"amount":10,"currency":"USD"}]},` +
+			`{"count":1,"tag":"travel","totals":[{"amount":100,"currency":"THB"}]}]}}` + "\n"
+		if rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %s", rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Mutation with invalid input should report a coded error", func(t *testing.T) {
+		h := NewApplication(nil, ledger.DefaultConfig())
+		c, rec := graphqlContext(`mutation($input: ExpenseInput!) { createExpense(input: $input) { id } }`,
+			map[string]any{"input": map[string]any{"title": "lunch", "amount": 10, "date": "yesterday"}}, admin)
+
+		if err := h.GraphQLHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"message":"Field date is invalid"`) || !strings.Contains(rec.Body.String(), `"extensions":{"code":"BAD_USER_INPUT"}`) {
+			t.Errorf("response error was not expected got: %s", rec.Body.String())
+		}
+	})
+
+	t.Run("Mutation should create through the expense store", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE owner = \\$1").WillReturnRows(sqlmock.NewRows(expenseColumns))
+		mock.ExpectQuery("INSERT INTO expenses").
+			WithArgs("lunch", 10.0, "", pq.Array([]string{"food"}), nil, nil, "alice", "THB", 10.0, "THB", 1.0, nil, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT hash FROM expense_audit").WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+		mock.ExpectQuery("INSERT INTO expense_audit").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO outbox").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO expense_changes").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		h := NewApplication(db, ledger.DefaultConfig())
+		c, rec := graphqlContext(`mutation { createExpense(input: {title: "lunch", amount: 10, tags: ["food"]}) { id owner status reportingAmount } }`, nil, auth.User{Name: "alice"})
+
+		if err := h.GraphQLHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"data":{"createExpense":{"id":4,"owner":"alice","reportingAmount":10,"status":"draft"}}}` + "\n"
+		if rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %s", rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Query deeper than the limit should got error", func(t *testing.T) {
+		h := NewApplication(nil, ledger.DefaultConfig())
+		h.MaxDepth = 3
+		c, rec := graphqlContext(`{ expenses { nodes { report { id } } } }`, nil, admin)
+
+		if err := h.GraphQLHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "Query depth 4 exceeds the limit of 3") {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Query over the complexity limit should got error", func(t *testing.T) {
+		h := NewApplication(nil, ledger.DefaultConfig())
+		h.MaxComplexity = 100
+		c, rec := graphqlContext(`query($n: Int) { expenses(first: $n) { nodes { ...fields } } } fragment fields on Expense { id title }`, map[string]any{"n": 50}, admin)
+
+		if err := h.GraphQLHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "Query complexity 151 exceeds the limit of 100") {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Negative first on an alias should not offset the complexity", func(t *testing.T) {
+		h := NewApplication(nil, ledger.DefaultConfig())
+		h.MaxComplexity = 100
+		c, rec := graphqlContext(`{ a: expenses(first: 50) { nodes { id title } } b: expenses(first: -50) { nodes { id title } } }`, nil, admin)
+
+		if err := h.GraphQLHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "Query complexity 152 exceeds the limit of 100") {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Invalid query should got error", func(t *testing.T) {
+		h := NewApplication(nil, ledger.DefaultConfig())
+		c, rec := graphqlContext(`{ expenses { nodes { missing } } }`, nil, admin)
+
+		if err := h.GraphQLHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `Cannot query field \"missing\" on type \"Expense\"`) {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Mutation over GET should got error", func(t *testing.T) {
+		h := NewApplication(nil, ledger.DefaultConfig())
+		req := httptest.NewRequest(http.MethodGet, "/graphql?query="+`mutation{createExpense(input:{title:"a",amount:1}){id}}`, nil)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+
+		if err := h.GraphQLHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("should status method not allowed but it got %v", rec.Code)
+		}
+	})
+}
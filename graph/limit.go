@@ -0,0 +1,112 @@
+package graph
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// pageSize is the number of items a paginated field is expected to return,
+// for costing: its first argument clamped to [0, maxPageSize], or the default
+// page size. Clamping keeps a negative first on one alias from cancelling
+// the cost of the others; the resolver rejects out of range values later.
+func pageSize(field *ast.Field, variables map[string]any) int {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		n := defaultPageSize
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			// Too large for an int is too large for a page.
+			n = maxPageSize
+			if i, err := strconv.Atoi(v.Value); err == nil {
+				n = i
+			}
+		case *ast.Variable:
+			switch i := variables[v.Name.Value].(type) {
+			case float64:
+				n = int(i)
+			case int:
+				n = i
+			}
+		}
+		switch {
+		case n < 0:
+			return 0
+		case n > maxPageSize:
+			return maxPageSize
+		}
+		return n
+	}
+	return defaultPageSize
+}
+
+type limiter struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+}
+
+// cost walks set and returns its depth and complexity. Every field costs one
+// and the fields under a paginated field count once per item of a page.
+// Introspection fields are free so tools can always load the schema.
+func (l limiter) cost(set *ast.SelectionSet, visiting map[string]bool) (depth, complexity int) {
+	if set == nil {
+		return 0, 0
+	}
+	for _, selection := range set.Selections {
+		var d, c int
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name.Value, "__") {
+				continue
+			}
+			d, c = l.cost(s.SelectionSet, visiting)
+			if paginated[s.Name.Value] {
+				c *= pageSize(s, l.variables)
+			}
+			d, c = d+1, c+1
+		case *ast.InlineFragment:
+			d, c = l.cost(s.SelectionSet, visiting)
+		case *ast.FragmentSpread:
+			name := s.Name.Value
+			if fragment, ok := l.fragments[name]; ok && !visiting[name] {
+				visiting[name] = true
+				d, c = l.cost(fragment.SelectionSet, visiting)
+				delete(visiting, name)
+			}
+		}
+		if d > depth {
+			depth = d
+		}
+		complexity += c
+	}
+	return depth, complexity
+}
+
+// checkLimits rejects documents whose operations nest deeper than maxDepth or
+// cost more than maxComplexity.
+func checkLimits(doc *ast.Document, variables map[string]any, maxDepth, maxComplexity int) error {
+	l := limiter{fragments: map[string]*ast.FragmentDefinition{}, variables: variables}
+	for _, def := range doc.Definitions {
+		if fragment, ok := def.(*ast.FragmentDefinition); ok {
+			l.fragments[fragment.Name.Value] = fragment
+		}
+	}
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		depth, complexity := l.cost(op.SelectionSet, map[string]bool{})
+		if depth > maxDepth {
+			return fmt.Errorf("Query depth %d exceeds the limit of %d", depth, maxDepth)
+		}
+		if complexity > maxComplexity {
+			return fmt.Errorf("Query complexity %d exceeds the limit of %d", complexity, maxComplexity)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package graph
+
+// loader batches lookups by key within one request. load records a key and
+// returns a thunk; the executor runs the thunks of a level after resolving
+// the whole level, so the first thunk fetches every key recorded so far in a
+// single query. Resolvers run one at a time, so no locking is needed.
+type loader[V any] struct {
+	fetch   func(keys []int) (map[int]V, error)
+	pending []int
+	queued  map[int]bool
+	results map[int]V
+	err     error
+}
+
+func newLoader[V any](fetch func(keys []int) (map[int]V, error)) *loader[V] {
+	return &loader[V]{fetch: fetch, queued: map[int]bool{}, results: map[int]V{}}
+}
+
+func (l *loader[V]) load(key int) func() (any, error) {
+	if _, ok := l.results[key]; !ok && !l.queued[key] {
+		l.pending = append(l.pending, key)
+		l.queued[key] = true
+	}
+	return func() (any, error) {
+		if len(l.pending) > 0 {
+			keys := l.pending
+			l.pending, l.queued = nil, map[int]bool{}
+			values, err := l.fetch(keys)
+			if err != nil {
+				l.err = err
+			}
+			for _, k := range keys {
+				l.results[k] = values[k]
+			}
+		}
+		if l.err != nil {
+			return nil, l.err
+		}
+		return l.results[key], nil
+	}
+}
@@ -0,0 +1,606 @@
+package graph
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/phanbanchong/assessment/attachment"
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/auth"
+	"github.com/phanbanchong/assessment/expense"
+	"github.com/phanbanchong/assessment/fx"
+	"github.com/phanbanchong/assessment/policy"
+	"github.com/phanbanchong/assessment/report"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// paginated names the fields whose selections are repeated once per item of
+// a page when costing a query.
+var paginated = map[string]bool{"expenses": true}
+
+const (
+	CodeBadInput        = "BAD_USER_INPUT"
+	CodeNotFound        = "NOT_FOUND"
+	CodeConflict        = "CONFLICT"
+	CodeDuplicate       = "DUPLICATE"
+	CodePolicyViolation = "POLICY_VIOLATION"
+	CodeUnprocessable   = "UNPROCESSABLE"
+	CodeForbidden       = "FORBIDDEN"
+)
+
+// Error is a resolver error with a machine readable code, reported in the
+// error's extensions along with any details.
+type Error struct {
+	Message string
+	Code    string
+	Details any
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Extensions() map[string]any {
+	ext := map[string]any{"code": e.Code}
+	if e.Details != nil {
+		ext["details"] = e.Details
+	}
+	return ext
+}
+
+// storeError maps the errors of the expense store to the codes the REST
+// handlers express as status codes.
+func storeError(err error) error {
+	var violation *expense.PolicyError
+	var duplicate *expense.DuplicateError
+	switch {
+	case err == sql.ErrNoRows:
+		return &Error{Message: "Expense not found", Code: CodeNotFound}
+	case err == expense.ErrLocked, err == expense.ErrGroupExpense:
+		return &Error{Message: err.Error(), Code: CodeConflict}
+	case err == expense.ErrNotOwner:
+		return &Error{Message: err.Error(), Code: CodeForbidden}
+	case errors.As(err, &duplicate):
+		return &Error{Message: duplicate.Message, Code: CodeDuplicate, Details: duplicate.Candidates}
+	case errors.As(err, &violation):
+		return &Error{Message: violation.Message, Code: CodePolicyViolation, Details: violation.Violations}
+	case errors.Is(err, fx.ErrNoRate):
+		return &Error{Message: err.Error(), Code: CodeUnprocessable}
+	}
+	return err
+}
+
+func optString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func optInt(v int) any {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+func optFloat(v float64) any {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+func optTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.Format(time.RFC3339)
+}
+
+func expenseField(t graphql.Output, fn func(r *request, exp expense.Expense) any) *graphql.Field {
+	return &graphql.Field{Type: t, Resolve: func(p graphql.ResolveParams) (any, error) {
+		return fn(requestFrom(p.Context), p.Source.(expense.Expense)), nil
+	}}
+}
+
+var (
+	nonNullString = graphql.NewNonNull(graphql.String)
+	nonNullInt    = graphql.NewNonNull(graphql.Int)
+	nonNullFloat  = graphql.NewNonNull(graphql.Float)
+	stringList    = graphql.NewNonNull(graphql.NewList(nonNullString))
+)
+
+var findingType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PolicyFinding",
+	Fields: graphql.Fields{
+		"rule":     &graphql.Field{Type: nonNullString, Resolve: func(p graphql.ResolveParams) (any, error) { return p.Source.(policy.Finding).Rule, nil }},
+		"severity": &graphql.Field{Type: nonNullString, Resolve: func(p graphql.ResolveParams) (any, error) { return p.Source.(policy.Finding).Severity, nil }},
+		"message":  &graphql.Field{Type: nonNullString, Resolve: func(p graphql.ResolveParams) (any, error) { return p.Source.(policy.Finding).Message, nil }},
+	},
+})
+
+var reportType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Report",
+	Fields: graphql.Fields{
+		"id":        &graphql.Field{Type: nonNullInt, Resolve: reportField(func(r *report.Report) any { return r.ID })},
+		"title":     &graphql.Field{Type: nonNullString, Resolve: reportField(func(r *report.Report) any { return r.Title })},
+		"submitter": &graphql.Field{Type: nonNullString, Resolve: reportField(func(r *report.Report) any { return r.Submitter })},
+		"status":    &graphql.Field{Type: nonNullString, Resolve: reportField(func(r *report.Report) any { return r.Status })},
+		"total":     &graphql.Field{Type: nonNullFloat, Resolve: reportField(func(r *report.Report) any { return r.Total })},
+		"currency":  &graphql.Field{Type: graphql.String, Resolve: reportField(func(r *report.Report) any { return optString(r.Currency) })},
+		"createdAt": &graphql.Field{Type: nonNullString, Resolve: reportField(func(r *report.Report) any { return optTime(&r.CreatedAt) })},
+		"paidAt":    &graphql.Field{Type: graphql.String, Resolve: reportField(func(r *report.Report) any { return optTime(r.PaidAt) })},
+	},
+})
+
+func reportField(fn func(r *report.Report) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		return fn(p.Source.(*report.Report)), nil
+	}
+}
+
+var attachmentType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Attachment",
+	Fields: graphql.Fields{
+		"id":          &graphql.Field{Type: nonNullInt, Resolve: attachmentField(func(a attachment.Attachment) any { return a.ID })},
+		"filename":    &graphql.Field{Type: nonNullString, Resolve: attachmentField(func(a attachment.Attachment) any { return a.Filename })},
+		"contentType": &graphql.Field{Type: nonNullString, Resolve: attachmentField(func(a attachment.Attachment) any { return a.ContentType })},
+		"size":        &graphql.Field{Type: nonNullInt, Resolve: attachmentField(func(a attachment.Attachment) any { return a.Size })},
+		"sha256":      &graphql.Field{Type: nonNullString, Resolve: attachmentField(func(a attachment.Attachment) any { return a.SHA256 })},
+		"createdAt":   &graphql.Field{Type: nonNullString, Resolve: attachmentField(func(a attachment.Attachment) any { return optTime(&a.CreatedAt) })},
+	},
+})
+
+func attachmentField(fn func(a attachment.Attachment) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		return fn(p.Source.(attachment.Attachment)), nil
+	}
+}
+
+var expenseType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Expense",
+	Fields: graphql.Fields{
+		"id":                expenseField(nonNullInt, func(_ *request, exp expense.Expense) any { return exp.ID }),
+		"title":             expenseField(nonNullString, func(_ *request, exp expense.Expense) any { return exp.Title }),
+		"amount":            expenseField(nonNullFloat, func(_ *request, exp expense.Expense) any { return exp.Amount }),
+		"note":              expenseField(nonNullString, func(_ *request, exp expense.Expense) any { return exp.Note }),
+		"tags":              expenseField(stringList, func(_ *request, exp expense.Expense) any { return append([]string{}, exp.Tags...) }),
+		"date":              expenseField(graphql.String, func(_ *request, exp expense.Expense) any { return optString(exp.Date) }),
+		"status":            expenseField(nonNullString, func(_ *request, exp expense.Expense) any { return exp.Status }),
+		"owner":             expenseField(graphql.String, func(_ *request, exp expense.Expense) any { return optString(exp.Owner) }),
+		"externalRef":       expenseField(graphql.String, func(_ *request, exp expense.Expense) any { return optString(exp.ExternalRef) }),
+		"recurringId":       expenseField(graphql.Int, func(_ *request, exp expense.Expense) any { return optInt(exp.RecurringID) }),
+		"currency":          expenseField(graphql.String, func(_ *request, exp expense.Expense) any { return optString(exp.Currency) }),
+		"reportingAmount":   expenseField(nonNullFloat, func(_ *request, exp expense.Expense) any { return exp.ReportAmount() }),
+		"reportingCurrency": expenseField(graphql.String, func(_ *request, exp expense.Expense) any { return optString(exp.ReportingCurrency) }),
+		"rate":              expenseField(graphql.Float, func(_ *request, exp expense.Expense) any { return optFloat(exp.Rate) }),
+		"rateDate":          expenseField(graphql.String, func(_ *request, exp expense.Expense) any { return optString(exp.RateDate) }),
+		"category":          expenseField(nonNullString, func(r *request, exp expense.Expense) any { return r.h.Ledger.Account(exp.Tags) }),
+		"warnings": expenseField(graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(findingType))), func(_ *request, exp expense.Expense) any {
+			return append([]policy.Finding{}, exp.Warnings...)
+		}),
+		"report": &graphql.Field{Type: reportType, Resolve: func(p graphql.ResolveParams) (any, error) {
+			exp := p.Source.(expense.Expense)
+			if exp.ReportID == 0 {
+				return nil, nil
+			}
+			return requestFrom(p.Context).reports.load(exp.ReportID), nil
+		}},
+		"attachments": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(attachmentType))), Resolve: func(p graphql.ResolveParams) (any, error) {
+			load := requestFrom(p.Context).attachments.load(p.Source.(expense.Expense).ID)
+			return func() (any, error) {
+				v, err := load()
+				attachments, _ := v.([]attachment.Attachment)
+				if attachments == nil {
+					attachments = []attachment.Attachment{}
+				}
+				return attachments, err
+			}, nil
+		}},
+	},
+})
+
+var totalType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "Total",
+	Description: "Sum of expenses in one reporting currency. Expenses recorded before currencies were tracked are summed under a null currency.",
+	Fields: graphql.Fields{
+		"currency": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) { return optString(p.Source.(expense.Total).Currency), nil }},
+		"count":    &graphql.Field{Type: nonNullInt, Resolve: func(p graphql.ResolveParams) (any, error) { return p.Source.(expense.Total).Count, nil }},
+		"amount":   &graphql.Field{Type: nonNullFloat, Resolve: func(p graphql.ResolveParams) (any, error) { return p.Source.(expense.Total).Amount, nil }},
+	},
+})
+
+var totalList = graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(totalType)))
+
+// summary is the count and totals of the expenses sharing a tag or category.
+type summary struct {
+	Name   string
+	Count  int
+	Totals []expense.Total
+}
+
+func summaryType(name, key string) *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: name,
+		Fields: graphql.Fields{
+			key:      &graphql.Field{Type: nonNullString, Resolve: func(p graphql.ResolveParams) (any, error) { return p.Source.(summary).Name, nil }},
+			"count":  &graphql.Field{Type: nonNullInt, Resolve: func(p graphql.ResolveParams) (any, error) { return p.Source.(summary).Count, nil }},
+			"totals": &graphql.Field{Type: totalList, Resolve: func(p graphql.ResolveParams) (any, error) { return p.Source.(summary).Totals, nil }},
+		},
+	})
+}
+
+var pageInfoType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PageInfo",
+	Fields: graphql.Fields{
+		"endCursor":   &graphql.Field{Type: graphql.String},
+		"hasNextPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+	},
+})
+
+var connectionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ExpenseConnection",
+	Fields: graphql.Fields{
+		"nodes": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(expenseType))), Resolve: func(p graphql.ResolveParams) (any, error) {
+			nodes, _, err := p.Source.(*connection).page()
+			return nodes, err
+		}},
+		"pageInfo": &graphql.Field{Type: graphql.NewNonNull(pageInfoType), Resolve: func(p graphql.ResolveParams) (any, error) {
+			nodes, more, err := p.Source.(*connection).page()
+			info := map[string]any{"endCursor": nil, "hasNextPage": more}
+			if len(nodes) > 0 {
+				info["endCursor"] = encodeCursor(nodes[len(nodes)-1].ID)
+			}
+			return info, err
+		}},
+		"totalCount": &graphql.Field{Type: nonNullInt, Resolve: func(p graphql.ResolveParams) (any, error) {
+			totals, err := p.Source.(*connection).totals()
+			count := 0
+			for _, t := range totals {
+				count += t.Count
+			}
+			return count, err
+		}},
+		"totals": &graphql.Field{Type: totalList, Resolve: func(p graphql.ResolveParams) (any, error) {
+			return p.Source.(*connection).totals()
+		}},
+	},
+})
+
+var filterType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "ExpenseFilter",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"tags":      &graphql.InputObjectFieldConfig{Type: graphql.NewList(nonNullString), Description: "All must match."},
+		"from":      &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Inclusive date, YYYY-MM-DD."},
+		"to":        &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Inclusive date, YYYY-MM-DD."},
+		"minAmount": &graphql.InputObjectFieldConfig{Type: graphql.Float},
+		"maxAmount": &graphql.InputObjectFieldConfig{Type: graphql.Float},
+		"q":         &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Title substring."},
+		"status":    &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"owner":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+	},
+})
+
+var inputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "ExpenseInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"title":    &graphql.InputObjectFieldConfig{Type: nonNullString},
+		"amount":   &graphql.InputObjectFieldConfig{Type: nonNullFloat},
+		"note":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"tags":     &graphql.InputObjectFieldConfig{Type: graphql.NewList(nonNullString)},
+		"date":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"currency": &graphql.InputObjectFieldConfig{Type: graphql.String},
+	},
+})
+
+var filterArgs = graphql.FieldConfigArgument{"filter": &graphql.ArgumentConfig{Type: filterType}}
+
+var queryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Query",
+	Fields: graphql.Fields{
+		"expense": &graphql.Field{
+			Type: expenseType,
+			Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: nonNullInt}},
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				r := requestFrom(p.Context)
+				exp, err := expense.GetExpenseByID(r.h.DB, p.Args["id"].(int))
+				if err == sql.ErrNoRows || (err == nil && !r.visible(exp)) {
+					return nil, nil
+				}
+				return exp, err
+			},
+		},
+		"expenses": &graphql.Field{
+			Type: graphql.NewNonNull(connectionType),
+			Args: graphql.FieldConfigArgument{
+				"filter": &graphql.ArgumentConfig{Type: filterType},
+				"first":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageSize},
+				"after":  &graphql.ArgumentConfig{Type: graphql.String},
+			},
+			Resolve: resolveExpenses,
+		},
+		"tags": &graphql.Field{
+			Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(summaryType("TagSummary", "tag")))),
+			Args:    filterArgs,
+			Resolve: resolveTags,
+		},
+		"categories": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(summaryType("CategorySummary", "category")))),
+			Description: "Expenses grouped by the ledger account they are posted to.",
+			Args:        filterArgs,
+			Resolve:     resolveCategories,
+		},
+	},
+})
+
+var mutationType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Mutation",
+	Fields: graphql.Fields{
+		"createExpense": &graphql.Field{
+			Type: graphql.NewNonNull(expenseType),
+			Args: graphql.FieldConfigArgument{
+				"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(inputType)},
+				"force": &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false, Description: "Create the expense even if it looks like a duplicate."},
+			},
+			Resolve: resolveCreate,
+		},
+		"updateExpense": &graphql.Field{
+			Type: graphql.NewNonNull(expenseType),
+			Args: graphql.FieldConfigArgument{
+				"id":    &graphql.ArgumentConfig{Type: nonNullInt},
+				"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(inputType)},
+			},
+			Resolve: resolveUpdate,
+		},
+	},
+})
+
+var schema = func() graphql.Schema {
+	s, err := graphql.NewSchema(graphql.SchemaConfig{Query: queryType, Mutation: mutationType})
+	if err != nil {
+		panic(err)
+	}
+	return s
+}()
+
+// visible reports whether the caller may see exp, as in the expense stream
+// and sync.
+func (r *request) visible(exp expense.Expense) bool {
+	owner := expense.VisibleOwner(r.c)
+	return owner == "" || owner == exp.Owner
+}
+
+// filter reads the filter argument and narrows it to the expenses the caller
+// may see. ok is false when it asks for expenses of an owner the caller may
+// not see, which match nothing.
+func (r *request) filter(args map[string]any) (f expense.Filter, ok bool, err error) {
+	in, _ := args["filter"].(map[string]any)
+	for _, tag := range asList(in["tags"]) {
+		f.Tags = append(f.Tags, tag.(string))
+	}
+	f.From, _ = in["from"].(string)
+	f.To, _ = in["to"].(string)
+	f.Query, _ = in["q"].(string)
+	f.Status, _ = in["status"].(string)
+	f.Owner, _ = in["owner"].(string)
+	if v, set := in["minAmount"].(float64); set {
+		f.MinAmount = &v
+	}
+	if v, set := in["maxAmount"].(float64); set {
+		f.MaxAmount = &v
+	}
+	if !expense.ValidDate(f.From) {
+		return f, false, &Error{Message: "Filter from is invalid", Code: CodeBadInput}
+	}
+	if !expense.ValidDate(f.To) {
+		return f, false, &Error{Message: "Filter to is invalid", Code: CodeBadInput}
+	}
+	if owner := expense.VisibleOwner(r.c); owner != "" {
+		if f.Owner != "" && f.Owner != owner {
+			return f, false, nil
+		}
+		f.Owner = owner
+	}
+	return f, true, nil
+}
+
+func asList(v any) []any {
+	list, _ := v.([]any)
+	return list
+}
+
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("expense:" + strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil && len(b) > len("expense:") && string(b[:len("expense:")]) == "expense:" {
+		if id, err := strconv.Atoi(string(b[len("expense:"):])); err == nil {
+			return id, nil
+		}
+	}
+	return 0, &Error{Message: "Argument after is invalid", Code: CodeBadInput}
+}
+
+// connection is a page of expenses. The page and the totals are each loaded
+// only when a field needs them.
+type connection struct {
+	r      *request
+	filter expense.Filter
+	empty  bool
+	first  int
+	after  int
+
+	loaded   bool
+	nodes    []expense.Expense
+	more     bool
+	pageErr  error
+	summed   bool
+	sums     []expense.Total
+	totalErr error
+}
+
+func (conn *connection) page() ([]expense.Expense, bool, error) {
+	if conn.empty {
+		return []expense.Expense{}, false, nil
+	}
+	if !conn.loaded {
+		conn.loaded = true
+		conn.nodes, conn.pageErr = expense.GetExpensesPage(conn.r.h.DB, conn.filter, conn.after, conn.first+1)
+		if len(conn.nodes) > conn.first {
+			conn.nodes, conn.more = conn.nodes[:conn.first], true
+		}
+	}
+	return conn.nodes, conn.more, conn.pageErr
+}
+
+func (conn *connection) totals() ([]expense.Total, error) {
+	if conn.empty {
+		return []expense.Total{}, nil
+	}
+	if !conn.summed {
+		conn.summed = true
+		conn.sums, conn.totalErr = expense.GetTotals(conn.r.h.DB, conn.filter)
+	}
+	return conn.sums, conn.totalErr
+}
+
+func resolveExpenses(p graphql.ResolveParams) (any, error) {
+	r := requestFrom(p.Context)
+	f, ok, err := r.filter(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	conn := &connection{r: r, filter: f, empty: !ok, first: p.Args["first"].(int)}
+	if conn.first < 0 || conn.first > maxPageSize {
+		return nil, &Error{Message: "Argument first must be between 0 and " + strconv.Itoa(maxPageSize), Code: CodeBadInput}
+	}
+	if after, set := p.Args["after"].(string); set {
+		if conn.after, err = decodeCursor(after); err != nil {
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// summarize adds exp's total to the summary named name.
+func summarize(summaries map[string]*summary, name string, t expense.Total) {
+	s, ok := summaries[name]
+	if !ok {
+		s = &summary{Name: name}
+		summaries[name] = s
+	}
+	s.Count += t.Count
+	for i := range s.Totals {
+		if s.Totals[i].Currency == t.Currency {
+			s.Totals[i].Count += t.Count
+			s.Totals[i].Amount += t.Amount
+			return
+		}
+	}
+	s.Totals = append(s.Totals, t)
+}
+
+func sorted(summaries map[string]*summary) []summary {
+	list := []summary{}
+	for _, s := range summaries {
+		sort.Slice(s.Totals, func(i, j int) bool { return s.Totals[i].Currency < s.Totals[j].Currency })
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func resolveTags(p graphql.ResolveParams) (any, error) {
+	r := requestFrom(p.Context)
+	f, ok, err := r.filter(p.Args)
+	if err != nil || !ok {
+		return []summary{}, err
+	}
+	totals, err := expense.GetTagTotals(r.h.DB, f)
+	if err != nil {
+		return nil, err
+	}
+	summaries := map[string]*summary{}
+	for _, t := range totals {
+		summarize(summaries, t.Tag, t.Total)
+	}
+	return sorted(summaries), nil
+}
+
+func resolveCategories(p graphql.ResolveParams) (any, error) {
+	r := requestFrom(p.Context)
+	f, ok, err := r.filter(p.Args)
+	if err != nil || !ok {
+		return []summary{}, err
+	}
+	summaries := map[string]*summary{}
+	err = expense.EachExpense(p.Context, r.h.DB, f, func(exp expense.Expense) error {
+		summarize(summaries, r.h.Ledger.Account(exp.Tags), expense.Total{Currency: exp.ReportingCurrency, Count: 1, Amount: exp.ReportAmount()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sorted(summaries), nil
+}
+
+func expenseInput(args map[string]any) expense.Expense {
+	in := args["input"].(map[string]any)
+	exp := expense.Expense{Tags: []string{}}
+	exp.Title, _ = in["title"].(string)
+	exp.Amount, _ = in["amount"].(float64)
+	exp.Note, _ = in["note"].(string)
+	exp.Date, _ = in["date"].(string)
+	exp.Currency, _ = in["currency"].(string)
+	for _, tag := range asList(in["tags"]) {
+		exp.Tags = append(exp.Tags, tag.(string))
+	}
+	return exp
+}
+
+func resolveCreate(p graphql.ResolveParams) (any, error) {
+	r := requestFrom(p.Context)
+	exp := expenseInput(p.Args)
+	if err := expense.ValidateExpense(exp); err != nil {
+		return nil, &Error{Message: err.Error(), Code: CodeBadInput}
+	}
+	exp.ReportingCurrency = r.reportingCurrency()
+	exp, err := expense.CreateExpenseChecked(r.h.DB, audit.ActorFrom(r.c), r.h.Policy, exp, p.Args["force"].(bool))
+	if err != nil {
+		return nil, storeError(err)
+	}
+	return exp, nil
+}
+
+func resolveUpdate(p graphql.ResolveParams) (any, error) {
+	r := requestFrom(p.Context)
+	exp := expenseInput(p.Args)
+	exp.ID = p.Args["id"].(int)
+	if err := expense.ValidateUpdate(exp); err != nil {
+		return nil, &Error{Message: err.Error(), Code: CodeBadInput}
+	}
+	current, err := expense.GetExpenseByID(r.h.DB, exp.ID)
+	if err == nil && !r.visible(current) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, storeError(err)
+	}
+	exp.ReportingCurrency = r.reportingCurrency()
+	if exp, err = expense.UpdateExpenseChecked(r.h.DB, audit.ActorFrom(r.c), auth.UserFrom(r.c), r.h.Policy, exp); err != nil {
+		return nil, storeError(err)
+	}
+	return exp, nil
+}
@@ -0,0 +1,323 @@
+package group
+
+import (
+	"database/sql"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/gommon/log"
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/expense"
+	"github.com/phanbanchong/assessment/policy"
+)
+
+func InitDB(db *sql.DB) error {
+	commands := []string{
+		"CREATE TABLE IF NOT EXISTS groups ( id SERIAL PRIMARY KEY, name TEXT NOT NULL, currency TEXT NOT NULL, created_by TEXT NOT NULL, created_at TIMESTAMPTZ NOT NULL DEFAULT now());",
+		"CREATE TABLE IF NOT EXISTS group_members ( group_id INT NOT NULL REFERENCES groups (id) ON DELETE CASCADE, member TEXT NOT NULL, PRIMARY KEY (group_id, member));",
+		"CREATE TABLE IF NOT EXISTS group_expenses ( expense_id INT PRIMARY KEY REFERENCES expenses (id) ON DELETE CASCADE, group_id INT NOT NULL REFERENCES groups (id) ON DELETE CASCADE, paid_by TEXT NOT NULL, method TEXT NOT NULL, amount FLOAT NOT NULL);",
+		"CREATE TABLE IF NOT EXISTS group_shares ( expense_id INT NOT NULL REFERENCES group_expenses (expense_id) ON DELETE CASCADE, member TEXT NOT NULL, amount FLOAT NOT NULL, PRIMARY KEY (expense_id, member));",
+		"CREATE TABLE IF NOT EXISTS settlements ( id SERIAL PRIMARY KEY, group_id INT NOT NULL REFERENCES groups (id) ON DELETE CASCADE, from_member TEXT NOT NULL, to_member TEXT NOT NULL, amount FLOAT NOT NULL, created_by TEXT NOT NULL, created_at TIMESTAMPTZ NOT NULL DEFAULT now());",
+		"CREATE INDEX IF NOT EXISTS group_expenses_group_idx ON group_expenses (group_id);",
+		"CREATE INDEX IF NOT EXISTS settlements_group_idx ON settlements (group_id);",
+	}
+	for _, command := range commands {
+		if _, err := db.Exec(command); err != nil {
+			return err
+		}
+		log.Printf("Executed: %s", command)
+	}
+	return nil
+}
+
+// members trims, drops empty and duplicate names and sorts the rest.
+func members(names []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// CreateGroup creates a group with the members in req. The creator is always
+// a member.
+func CreateGroup(db expense.Querier, actor audit.Actor, req CreateRequest, currency string) (Group, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return Group{}, &Error{http.StatusBadRequest, "Field name is required"}
+	}
+	if req.Currency != "" {
+		currency = strings.ToUpper(req.Currency)
+	}
+	if len(currency) != 3 {
+		return Group{}, &Error{http.StatusBadRequest, "Field currency is invalid"}
+	}
+	g := Group{Name: strings.TrimSpace(req.Name), Currency: currency, CreatedBy: actor.Name, Members: members(append(req.Members, actor.Name))}
+	row := db.QueryRow("INSERT INTO groups (name, currency, created_by) values ($1, $2, $3) RETURNING id, created_at", g.Name, g.Currency, g.CreatedBy)
+	if err := row.Scan(&g.ID, &g.CreatedAt); err != nil {
+		return g, err
+	}
+	for _, m := range g.Members {
+		if err := AddMember(db, g.ID, m); err != nil {
+			return g, err
+		}
+	}
+	return g, nil
+}
+
+func AddMember(db expense.Querier, id int, member string) error {
+	_, err := db.Exec("INSERT INTO group_members (group_id, member) values ($1, $2) ON CONFLICT DO NOTHING", id, member)
+	return err
+}
+
+// GetGroup loads a group and its members, or sql.ErrNoRows.
+func GetGroup(db expense.Querier, id int) (Group, error) {
+	g := Group{}
+	row := db.QueryRow("SELECT id, name, currency, created_by, created_at FROM groups WHERE id = $1", id)
+	if err := row.Scan(&g.ID, &g.Name, &g.Currency, &g.CreatedBy, &g.CreatedAt); err != nil {
+		return g, err
+	}
+	rows, err := db.Query("SELECT member FROM group_members WHERE group_id = $1 ORDER BY member ASC", id)
+	if err != nil {
+		return g, err
+	}
+	defer rows.Close()
+	g.Members = []string{}
+	for rows.Next() {
+		var m string
+		if err := rows.Scan(&m); err != nil {
+			return g, err
+		}
+		g.Members = append(g.Members, m)
+	}
+	return g, rows.Err()
+}
+
+// GetGroups lists the groups member belongs to, or every group when member
+// is empty. Members are not loaded.
+func GetGroups(db *sql.DB, member string) ([]Group, error) {
+	query := "SELECT id, name, currency, created_by, created_at FROM groups"
+	args := []any{}
+	if member != "" {
+		query += " WHERE id IN (SELECT group_id FROM group_members WHERE member = $1)"
+		args = append(args, member)
+	}
+	rows, err := db.Query(query+" ORDER BY id ASC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	groups := []Group{}
+	for rows.Next() {
+		g := Group{}
+		if err := rows.Scan(&g.ID, &g.Name, &g.Currency, &g.CreatedBy, &g.CreatedAt); err != nil {
+			return groups, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+func (g Group) HasMember(member string) bool {
+	for _, m := range g.Members {
+		if m == member {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveMember takes member out of g. Members who still owe or are owed
+// money are refused so the group's balances keep adding up to zero.
+func RemoveMember(db expense.Querier, g Group, member string) error {
+	if !g.HasMember(member) {
+		return &Error{http.StatusNotFound, "Member not found"}
+	}
+	balances, err := GetBalances(db, g)
+	if err != nil {
+		return err
+	}
+	for _, b := range balances {
+		if b.Member == member && toCents(b.Balance) != 0 {
+			return &Error{http.StatusConflict, "Member must settle up before leaving"}
+		}
+	}
+	_, err = db.Exec("DELETE FROM group_members WHERE group_id = $1 AND member = $2", g.ID, member)
+	return err
+}
+
+// CreateGroupExpense creates the expense in req in the group's currency and
+// splits its converted amount between the members. It is checked for
+// duplicates and against cfg like any other new expense.
+func CreateGroupExpense(db expense.Querier, actor audit.Actor, cfg policy.Config, g Group, req ExpenseRequest, force bool) (Expense, error) {
+	if req.PaidBy == "" {
+		req.PaidBy = actor.Name
+	}
+	if !g.HasMember(req.PaidBy) {
+		return Expense{}, &Error{http.StatusBadRequest, req.PaidBy + " is not a member of the group"}
+	}
+	if req.Split.Method == "" {
+		req.Split.Method = SplitEqual
+	}
+	exp := req.Expense
+	exp.ReportingCurrency = g.Currency
+	if err := expense.ValidateExpense(exp); err != nil {
+		return Expense{}, &Error{http.StatusBadRequest, err.Error()}
+	}
+	exp, err := expense.CreateExpenseCheckedTx(db, actor, cfg, exp, force)
+	if err != nil {
+		return Expense{}, err
+	}
+	amount := exp.ReportAmount()
+	shares, err := req.Split.Shares(amount, g.Members)
+	if err != nil {
+		return Expense{}, &Error{http.StatusBadRequest, err.Error()}
+	}
+	ge := Expense{Expense: exp, PaidBy: req.PaidBy, Method: req.Split.Method, Amount: amount, Shares: shares}
+	if _, err := db.Exec("INSERT INTO group_expenses (expense_id, group_id, paid_by, method, amount) values ($1, $2, $3, $4, $5)", exp.ID, g.ID, ge.PaidBy, ge.Method, ge.Amount); err != nil {
+		return ge, err
+	}
+	for _, m := range members(keys(shares)) {
+		if _, err := db.Exec("INSERT INTO group_shares (expense_id, member, amount) values ($1, $2, $3)", exp.ID, m, shares[m]); err != nil {
+			return ge, err
+		}
+	}
+	return ge, nil
+}
+
+func keys(m map[string]float64) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
+
+// GetGroupExpenses lists the expenses shared in group id, oldest first.
+func GetGroupExpenses(db expense.Querier, id int) ([]Expense, error) {
+	rows, err := db.Query("SELECT g.expense_id, g.paid_by, g.method, g.amount, s.member, s.amount FROM group_expenses g JOIN group_shares s ON s.expense_id = g.expense_id WHERE g.group_id = $1 ORDER BY g.expense_id ASC, s.member ASC", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	shared := []Expense{}
+	ids := []int{}
+	for rows.Next() {
+		var ge Expense
+		var member string
+		var share float64
+		if err := rows.Scan(&ge.Expense.ID, &ge.PaidBy, &ge.Method, &ge.Amount, &member, &share); err != nil {
+			return shared, err
+		}
+		if n := len(shared); n == 0 || shared[n-1].Expense.ID != ge.Expense.ID {
+			ge.Shares = map[string]float64{}
+			shared = append(shared, ge)
+			ids = append(ids, ge.Expense.ID)
+		}
+		shared[len(shared)-1].Shares[member] = share
+	}
+	if err := rows.Err(); err != nil {
+		return shared, err
+	}
+	if len(ids) == 0 {
+		return shared, nil
+	}
+	expenses, err := expense.GetExpensesByID(db, ids)
+	if err != nil {
+		return shared, err
+	}
+	byID := map[int]expense.Expense{}
+	for _, exp := range expenses {
+		byID[exp.ID] = exp
+	}
+	for i := range shared {
+		shared[i].Expense = byID[shared[i].Expense.ID]
+	}
+	return shared, nil
+}
+
+// GetBalances sums what each member of g paid and received less what they
+// owe and paid out in settlements. Balances add up to zero across a group;
+// members without any activity have a zero balance.
+func GetBalances(db expense.Querier, g Group) ([]Balance, error) {
+	rows, err := db.Query(`SELECT member, sum(amount) FROM (
+		SELECT paid_by AS member, amount FROM group_expenses WHERE group_id = $1
+		UNION ALL SELECT s.member, -s.amount FROM group_shares s JOIN group_expenses g ON g.expense_id = s.expense_id WHERE g.group_id = $1
+		UNION ALL SELECT from_member, amount FROM settlements WHERE group_id = $1
+		UNION ALL SELECT to_member, -amount FROM settlements WHERE group_id = $1
+	) entries GROUP BY member ORDER BY member ASC`, g.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sums := map[string]float64{}
+	for rows.Next() {
+		var member string
+		var amount float64
+		if err := rows.Scan(&member, &amount); err != nil {
+			return nil, err
+		}
+		sums[member] = fromCents(toCents(amount))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, m := range g.Members {
+		if _, ok := sums[m]; !ok {
+			sums[m] = 0
+		}
+	}
+	balances := []Balance{}
+	for _, m := range members(keys(sums)) {
+		balances = append(balances, Balance{Member: m, Balance: sums[m]})
+	}
+	return balances, nil
+}
+
+// CreateSettlement records a payment from s.From to s.To in g.
+func CreateSettlement(db expense.Querier, actor audit.Actor, g Group, s Settlement) (Settlement, error) {
+	switch {
+	case !g.HasMember(s.From):
+		return s, &Error{http.StatusBadRequest, "Field from must be a member of the group"}
+	case !g.HasMember(s.To):
+		return s, &Error{http.StatusBadRequest, "Field to must be a member of the group"}
+	case s.From == s.To:
+		return s, &Error{http.StatusBadRequest, "Fields from and to must differ"}
+	case toCents(s.Amount) <= 0:
+		return s, &Error{http.StatusBadRequest, "Field amount must be positive"}
+	}
+	s.Amount = fromCents(toCents(s.Amount))
+	s.CreatedBy = actor.Name
+	row := db.QueryRow("INSERT INTO settlements (group_id, from_member, to_member, amount, created_by) values ($1, $2, $3, $4, $5) RETURNING id, created_at", g.ID, s.From, s.To, s.Amount, s.CreatedBy)
+	err := row.Scan(&s.ID, &s.CreatedAt)
+	return s, err
+}
+
+func GetSettlements(db expense.Querier, id int) ([]Settlement, error) {
+	rows, err := db.Query("SELECT id, from_member, to_member, amount, created_by, created_at FROM settlements WHERE group_id = $1 ORDER BY id ASC", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	settlements := []Settlement{}
+	for rows.Next() {
+		s := Settlement{}
+		if err := rows.Scan(&s.ID, &s.From, &s.To, &s.Amount, &s.CreatedBy, &s.CreatedAt); err != nil {
+			return settlements, err
+		}
+		settlements = append(settlements, s)
+	}
+	return settlements, rows.Err()
+}
+
+// lockGroup serialises writes that depend on a group's balances.
+func lockGroup(db expense.Querier, id int) error {
+	_, err := db.Exec("SELECT id FROM groups WHERE id = $1 FOR UPDATE", id)
+	return err
+}
@@ -0,0 +1,80 @@
+package group
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/phanbanchong/assessment/expense"
+	"github.com/phanbanchong/assessment/policy"
+)
+
+type handler struct {
+	DB       *sql.DB
+	Currency string
+	Policy   policy.Config
+}
+
+// NewApplication creates the groups handler. New groups keep their books in
+// REPORTING_CURRENCY unless they name another currency.
+func NewApplication(db *sql.DB) *handler {
+	return &handler{DB: db, Currency: expense.ReportingCurrency()}
+}
+
+// Group is a set of members sharing expenses. Every amount in a group is in
+// its currency.
+type Group struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Currency  string    `json:"currency"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	Members   []string  `json:"members"`
+}
+
+// Expense is an expense shared in a group: who paid it and what each member
+// owes of it.
+type Expense struct {
+	Expense expense.Expense    `json:"expense"`
+	PaidBy  string             `json:"paid_by"`
+	Method  string             `json:"method"`
+	Amount  float64            `json:"amount"`
+	Shares  map[string]float64 `json:"shares"`
+}
+
+// Settlement is money paid from one member to another to settle up.
+type Settlement struct {
+	ID        int       `json:"id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Amount    float64   `json:"amount"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateRequest struct {
+	Name     string   `json:"name"`
+	Currency string   `json:"currency"`
+	Members  []string `json:"members"`
+}
+
+type MemberRequest struct {
+	Member string `json:"member"`
+}
+
+// ExpenseRequest shares a new expense in a group. PaidBy defaults to the
+// caller and the split to an equal one between every member.
+type ExpenseRequest struct {
+	Expense expense.Expense `json:"expense"`
+	PaidBy  string          `json:"paid_by"`
+	Split   Split           `json:"split"`
+}
+
+// Error is a refused group operation and the HTTP status it maps to.
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
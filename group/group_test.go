@@ -0,0 +1,308 @@
+//go:build unit
+
+package group
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/auth"
+	"github.com/phanbanchong/assessment/expense"
+	"github.com/phanbanchong/assessment/policy"
+)
+
+var created = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+func groupContext(method, target, body, user string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("3")
+	auth.SetUser(c, auth.User{Name: user})
+	return c, rec
+}
+
+func expectGroup(mock sqlmock.Sqlmock, members ...string) {
+	mock.ExpectQuery("SELECT id, name, currency, created_by, created_at FROM groups WHERE id = (.+)").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "currency", "created_by", "created_at"}).AddRow(3, "Trip", "THB", "alice", created))
+	rows := sqlmock.NewRows([]string{"member"})
+	for _, m := range members {
+		rows.AddRow(m)
+	}
+	mock.ExpectQuery("SELECT member FROM group_members").WithArgs(3).WillReturnRows(rows)
+}
+
+var expenseColumns = []string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}
+
+func expectDuplicates(mock sqlmock.Sqlmock, rows *sqlmock.Rows) {
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT (.+) FROM expenses WHERE owner = (.+)").
+		WithArgs("alice", "THB", 100.0, sqlmock.AnyArg()).
+		WillReturnRows(rows)
+}
+
+func expectBalances(mock sqlmock.Sqlmock, balances ...Balance) {
+	rows := sqlmock.NewRows([]string{"member", "sum"})
+	for _, b := range balances {
+		rows.AddRow(b.Member, b.Balance)
+	}
+	mock.ExpectQuery("SELECT member, sum\\(amount\\) FROM").WithArgs(3).WillReturnRows(rows)
+}
+
+func TestCreateGroupExpenseHandler(t *testing.T) {
+	t.Run("Create group expense should split it between members", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		expectGroup(mock, "alice", "bob", "carol")
+		expectDuplicates(mock, sqlmock.NewRows(expenseColumns))
+		mock.ExpectQuery("INSERT INTO expenses").
+			WithArgs("dinner", 100.0, "", pq.Array([]string(nil)), nil, nil, "alice", "THB", 100.0, "THB", 1.0, nil, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT hash FROM expense_audit").WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+		mock.ExpectQuery("INSERT INTO expense_audit").
+			WithArgs(9, "create", "alice", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO outbox").WithArgs(expense.EventCreated, 9, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO expense_changes").WithArgs(9, sqlmock.AnyArg(), false, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO group_expenses").WithArgs(9, 3, "bob", "equal", 100.0).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO group_shares").WithArgs(9, "alice", 33.34).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO group_shares").WithArgs(9, "bob", 33.33).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO group_shares").WithArgs(9, "carol", 33.33).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		c, rec := groupContext(http.MethodPost, "/groups/3/expenses", `{"expense":{"title":"dinner","amount":100},"paid_by":"bob"}`, "alice")
+		if err := NewApplication(db).CreateGroupExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `"paid_by":"bob","method":"equal","amount":100,"shares":{"alice":33.34,"bob":33.33,"carol":33.33}}` + "\n"
+		if rec.Code != http.StatusCreated || !strings.HasSuffix(rec.Body.String(), want) {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Create group expense like an existing one should got conflict", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		expectGroup(mock, "alice", "bob")
+		expectDuplicates(mock, sqlmock.NewRows(expenseColumns).
+			AddRow(5, "Dinner", 100.0, "", pq.Array([]string{}), nil, nil, nil, "pending", "alice", nil, "THB", 100.0, "THB", 1.0, nil))
+		mock.ExpectRollback()
+
+		c, rec := groupContext(http.MethodPost, "/groups/3/expenses", `{"expense":{"title":"dinner","amount":100},"paid_by":"bob"}`, "alice")
+		if err := NewApplication(db).CreateGroupExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), `"candidates":[{"id":5,`) {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Create group expense over the policy limit should be unprocessable", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		expectGroup(mock, "alice", "bob")
+		mock.ExpectQuery("INSERT INTO expenses").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT hash FROM expense_audit").WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+		mock.ExpectQuery("INSERT INTO expense_audit").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO outbox").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO expense_changes").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectRollback()
+
+		h := NewApplication(db)
+		h.Policy = policy.Config{
+			Version: policy.Version,
+			Rules:   []policy.Rule{{Name: "limit", Kind: policy.KindMaxAmount, Severity: policy.SeverityError, Limit: 50}},
+		}
+		c, rec := groupContext(http.MethodPost, "/groups/3/expenses?force=true", `{"expense":{"title":"dinner","amount":100},"paid_by":"bob"}`, "alice")
+		if err := h.CreateGroupExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `"rule":"limit"`) {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Create group expense paid by a non-member should got bad request", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		expectGroup(mock, "alice", "bob")
+		mock.ExpectRollback()
+
+		c, rec := groupContext(http.MethodPost, "/groups/3/expenses", `{"expense":{"title":"dinner","amount":100},"paid_by":"zed"}`, "alice")
+		if err := NewApplication(db).CreateGroupExpenseHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusBadRequest || rec.Body.String() != `{"message":"zed is not a member of the group"}`+"\n" {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+func TestGetBalancesHandler(t *testing.T) {
+	t.Run("Balances should include members without activity", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		expectGroup(mock, "alice", "bob", "carol")
+		expectBalances(mock, Balance{"alice", 66.67}, Balance{"bob", -66.67})
+
+		c, rec := groupContext(http.MethodGet, "/groups/3/balances", "", "bob")
+		if err := NewApplication(db).GetBalancesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `[{"member":"alice","balance":66.67},{"member":"bob","balance":-66.67},{"member":"carol","balance":0}]` + "\n"
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Balances of another group should got forbidden", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		expectGroup(mock, "alice", "bob")
+
+		c, rec := groupContext(http.MethodGet, "/groups/3/balances", "", "mallory")
+		if err := NewApplication(db).GetBalancesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("should status forbidden but it got %v", rec.Code)
+		}
+	})
+
+	t.Run("Missing group should got not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT (.+) FROM groups").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		c, rec := groupContext(http.MethodGet, "/groups/3/balances", "", "alice")
+		if err := NewApplication(db).GetBalancesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusNotFound || rec.Body.String() != `{"message":"Group not found"}`+"\n" {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+func TestSettleUpHandler(t *testing.T) {
+	t.Run("Settle up should suggest transfers", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		expectGroup(mock, "alice", "bob", "carol")
+		expectBalances(mock, Balance{"alice", 20}, Balance{"bob", -15}, Balance{"carol", -5})
+
+		c, rec := groupContext(http.MethodGet, "/groups/3/settle-up", "", "alice")
+		if err := NewApplication(db).GetSettleUpHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"currency":"THB","transfers":[{"from":"bob","to":"alice","amount":15},{"from":"carol","to":"alice","amount":5}]}` + "\n"
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Settle up should record each transfer as a settlement", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		expectGroup(mock, "alice", "bob")
+		mock.ExpectExec("SELECT id FROM groups WHERE id = (.+) FOR UPDATE").WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectBalances(mock, Balance{"alice", 12.5}, Balance{"bob", -12.5})
+		mock.ExpectQuery("INSERT INTO settlements").
+			WithArgs(3, "bob", "alice", 12.5, "alice").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, created))
+		mock.ExpectCommit()
+
+		c, rec := groupContext(http.MethodPost, "/groups/3/settle-up", "", "alice")
+		if err := NewApplication(db).SettleUpHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `[{"id":1,"from":"bob","to":"alice","amount":12.5,"created_by":"alice","created_at":"2026-03-01T00:00:00Z"}]` + "\n"
+		if rec.Code != http.StatusCreated || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestRemoveMemberHandler(t *testing.T) {
+	t.Run("Remove member with an open balance should got conflict", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		expectGroup(mock, "alice", "bob")
+		mock.ExpectExec("SELECT id FROM groups WHERE id = (.+) FOR UPDATE").WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectBalances(mock, Balance{"alice", 5}, Balance{"bob", -5})
+		mock.ExpectRollback()
+
+		c, rec := groupContext(http.MethodDelete, "/groups/3/members/bob", "", "alice")
+		c.SetParamNames("id", "member")
+		c.SetParamValues("3", "bob")
+		if err := NewApplication(db).RemoveMemberHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusConflict {
+			t.Errorf("should status conflict but it got %v", rec.Code)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
@@ -0,0 +1,246 @@
+package group
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/auth"
+	"github.com/phanbanchong/assessment/expense"
+	"github.com/phanbanchong/assessment/fx"
+)
+
+// SettleUp is the suggested way to bring every balance in a group to zero.
+type SettleUp struct {
+	Currency  string     `json:"currency"`
+	Transfers []Transfer `json:"transfers"`
+}
+
+// respond maps the outcome of a group operation to a response.
+func respond(c echo.Context, status int, v any, err error) error {
+	var ge *Error
+	var violation *expense.PolicyError
+	var duplicate *expense.DuplicateError
+	switch {
+	case err == nil:
+		return c.JSON(status, v)
+	case err == sql.ErrNoRows:
+		return c.JSON(http.StatusNotFound, expense.Error{Message: "Group not found"})
+	case errors.As(err, &ge):
+		return c.JSON(ge.Status, expense.Error{Message: ge.Message})
+	case errors.As(err, &violation):
+		return c.JSON(http.StatusUnprocessableEntity, violation)
+	case errors.As(err, &duplicate):
+		return c.JSON(http.StatusConflict, duplicate)
+	case errors.Is(err, fx.ErrNoRate):
+		return c.JSON(http.StatusUnprocessableEntity, expense.Error{Message: err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: err.Error()})
+	}
+}
+
+// inTx runs fn in a transaction, committing only when it succeeds.
+func (h *handler) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// group loads the group named by the id parameter. Only its members and
+// admins may see or change a group.
+func group(c echo.Context, db expense.Querier) (Group, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return Group{}, &Error{http.StatusBadRequest, "ID is invalid"}
+	}
+	g, err := GetGroup(db, id)
+	if err != nil {
+		return g, err
+	}
+	if !g.HasMember(audit.ActorFrom(c).Name) && !auth.UserFrom(c).HasRole(auth.RoleAdmin) {
+		return g, &Error{http.StatusForbidden, "Not a member of this group"}
+	}
+	return g, nil
+}
+
+func (h *handler) CreateGroupHandler(c echo.Context) error {
+	req := CreateRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: err.Error()})
+	}
+	var g Group
+	err := h.inTx(func(tx *sql.Tx) (err error) {
+		g, err = CreateGroup(tx, audit.ActorFrom(c), req, h.Currency)
+		return err
+	})
+	return respond(c, http.StatusCreated, g, err)
+}
+
+// GetGroupsHandler lists the caller's groups, or every group for admins.
+func (h *handler) GetGroupsHandler(c echo.Context) error {
+	member := audit.ActorFrom(c).Name
+	if auth.UserFrom(c).HasRole(auth.RoleAdmin) {
+		member = ""
+	}
+	groups, err := GetGroups(h.DB, member)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: "Unable to get groups from database:" + err.Error()})
+	}
+	return c.JSON(http.StatusOK, groups)
+}
+
+func (h *handler) GetGroupHandler(c echo.Context) error {
+	g, err := group(c, h.DB)
+	return respond(c, http.StatusOK, g, err)
+}
+
+func (h *handler) AddMemberHandler(c echo.Context) error {
+	req := MemberRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: err.Error()})
+	}
+	if req.Member == "" {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "Field member is required"})
+	}
+	g, err := group(c, h.DB)
+	if err == nil {
+		err = AddMember(h.DB, g.ID, req.Member)
+		g.Members = members(append(g.Members, req.Member))
+	}
+	return respond(c, http.StatusOK, g, err)
+}
+
+func (h *handler) RemoveMemberHandler(c echo.Context) error {
+	var g Group
+	err := h.inTx(func(tx *sql.Tx) (err error) {
+		if g, err = group(c, tx); err != nil {
+			return err
+		}
+		if err = lockGroup(tx, g.ID); err != nil {
+			return err
+		}
+		return RemoveMember(tx, g, c.Param("member"))
+	})
+	if err != nil {
+		return respond(c, http.StatusOK, nil, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *handler) CreateGroupExpenseHandler(c echo.Context) error {
+	req := ExpenseRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: err.Error()})
+	}
+	force, _ := strconv.ParseBool(c.QueryParam("force"))
+	var ge Expense
+	err := h.inTx(func(tx *sql.Tx) error {
+		g, err := group(c, tx)
+		if err != nil {
+			return err
+		}
+		ge, err = CreateGroupExpense(tx, audit.ActorFrom(c), h.Policy, g, req, force)
+		return err
+	})
+	return respond(c, http.StatusCreated, ge, err)
+}
+
+func (h *handler) GetGroupExpensesHandler(c echo.Context) error {
+	g, err := group(c, h.DB)
+	if err != nil {
+		return respond(c, http.StatusOK, nil, err)
+	}
+	shared, err := GetGroupExpenses(h.DB, g.ID)
+	return respond(c, http.StatusOK, shared, err)
+}
+
+func (h *handler) GetBalancesHandler(c echo.Context) error {
+	g, err := group(c, h.DB)
+	if err != nil {
+		return respond(c, http.StatusOK, nil, err)
+	}
+	balances, err := GetBalances(h.DB, g)
+	return respond(c, http.StatusOK, balances, err)
+}
+
+// GetSettleUpHandler suggests the fewest transfers that settle the group.
+func (h *handler) GetSettleUpHandler(c echo.Context) error {
+	g, err := group(c, h.DB)
+	if err != nil {
+		return respond(c, http.StatusOK, nil, err)
+	}
+	balances, err := GetBalances(h.DB, g)
+	if err != nil {
+		return respond(c, http.StatusOK, nil, err)
+	}
+	return c.JSON(http.StatusOK, SettleUp{Currency: g.Currency, Transfers: nonNil(Settle(balances))})
+}
+
+// SettleUpHandler records the suggested transfers as settlements, settling
+// the whole group at once.
+func (h *handler) SettleUpHandler(c echo.Context) error {
+	settlements := []Settlement{}
+	err := h.inTx(func(tx *sql.Tx) error {
+		g, err := group(c, tx)
+		if err != nil {
+			return err
+		}
+		if err := lockGroup(tx, g.ID); err != nil {
+			return err
+		}
+		balances, err := GetBalances(tx, g)
+		if err != nil {
+			return err
+		}
+		for _, t := range Settle(balances) {
+			s, err := CreateSettlement(tx, audit.ActorFrom(c), g, Settlement{From: t.From, To: t.To, Amount: t.Amount})
+			if err != nil {
+				return err
+			}
+			settlements = append(settlements, s)
+		}
+		return nil
+	})
+	return respond(c, http.StatusCreated, settlements, err)
+}
+
+func (h *handler) CreateSettlementHandler(c echo.Context) error {
+	s := Settlement{}
+	if err := c.Bind(&s); err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: err.Error()})
+	}
+	err := h.inTx(func(tx *sql.Tx) error {
+		g, err := group(c, tx)
+		if err != nil {
+			return err
+		}
+		s, err = CreateSettlement(tx, audit.ActorFrom(c), g, s)
+		return err
+	})
+	return respond(c, http.StatusCreated, s, err)
+}
+
+func (h *handler) GetSettlementsHandler(c echo.Context) error {
+	g, err := group(c, h.DB)
+	if err != nil {
+		return respond(c, http.StatusOK, nil, err)
+	}
+	settlements, err := GetSettlements(h.DB, g.ID)
+	return respond(c, http.StatusOK, settlements, err)
+}
+
+func nonNil(transfers []Transfer) []Transfer {
+	if transfers == nil {
+		return []Transfer{}
+	}
+	return transfers
+}
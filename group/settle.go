@@ -0,0 +1,128 @@
+package group
+
+import "sort"
+
+// maxExactMembers bounds the subset search in Settle. Groups with more
+// members with a non-zero balance fall back to greedy matching, which needs
+// at most one transfer fewer than there are such members.
+const maxExactMembers = 16
+
+type Balance struct {
+	Member  string  `json:"member"`
+	Balance float64 `json:"balance"`
+}
+
+type Transfer struct {
+	From   string  `json:"from"`
+	To     string  `json:"to"`
+	Amount float64 `json:"amount"`
+}
+
+// Settle suggests the fewest transfers that bring every balance to zero. A
+// positive balance is owed money, a negative one owes it.
+//
+// Members whose balances cancel out among themselves can settle apart from
+// the rest, and a set of k members needs k-1 transfers, so the minimum is
+// the number of members less the largest number of disjoint zero-sum sets.
+// That number is found with a search over subsets and each set is then
+// settled greedily.
+func Settle(balances []Balance) []Transfer {
+	var names []string
+	var cents []int64
+	for _, b := range balances {
+		if c := toCents(b.Balance); c != 0 {
+			names = append(names, b.Member)
+			cents = append(cents, c)
+		}
+	}
+	if len(names) > maxExactMembers {
+		all := make([]int, len(names))
+		for i := range all {
+			all[i] = i
+		}
+		return settleGreedy(names, cents, all)
+	}
+
+	n := len(names)
+	size := 1 << n
+	sum := make([]int64, size)
+	best := make([]int, size)
+	for mask := 1; mask < size; mask++ {
+		low := 0
+		for mask&(1<<low) == 0 {
+			low++
+		}
+		sum[mask] = sum[mask&^(1<<low)] + cents[low]
+		for i := 0; i < n; i++ {
+			if mask&(1<<i) != 0 && best[mask&^(1<<i)] > best[mask] {
+				best[mask] = best[mask&^(1<<i)]
+			}
+		}
+		if sum[mask] == 0 {
+			best[mask]++
+		}
+	}
+
+	// Walk back from the full set, peeling one member at a time along an
+	// optimal path; every zero-sum set passed on the way closes a group.
+	var transfers []Transfer
+	var current []int
+	for mask := size - 1; mask != 0; {
+		zero := 0
+		if sum[mask] == 0 {
+			zero = 1
+			if len(current) > 0 {
+				transfers = append(transfers, settleGreedy(names, cents, current)...)
+				current = nil
+			}
+		}
+		for i := 0; i < n; i++ {
+			if mask&(1<<i) != 0 && best[mask&^(1<<i)]+zero == best[mask] {
+				current = append(current, i)
+				mask &^= 1 << i
+				break
+			}
+		}
+	}
+	if len(current) > 0 {
+		transfers = append(transfers, settleGreedy(names, cents, current)...)
+	}
+	sort.SliceStable(transfers, func(i, j int) bool {
+		if transfers[i].From != transfers[j].From {
+			return transfers[i].From < transfers[j].From
+		}
+		return transfers[i].To < transfers[j].To
+	})
+	return transfers
+}
+
+// settleGreedy repeatedly pays the largest creditor from the largest debtor
+// among the members at the given indexes.
+func settleGreedy(names []string, cents []int64, members []int) []Transfer {
+	left := map[int]int64{}
+	for _, i := range members {
+		left[i] = cents[i]
+	}
+	var transfers []Transfer
+	for {
+		debtor, creditor := -1, -1
+		for _, i := range members {
+			if left[i] < 0 && (debtor < 0 || left[i] < left[debtor] || left[i] == left[debtor] && names[i] < names[debtor]) {
+				debtor = i
+			}
+			if left[i] > 0 && (creditor < 0 || left[i] > left[creditor] || left[i] == left[creditor] && names[i] < names[creditor]) {
+				creditor = i
+			}
+		}
+		if debtor < 0 || creditor < 0 {
+			return transfers
+		}
+		amount := -left[debtor]
+		if left[creditor] < amount {
+			amount = left[creditor]
+		}
+		left[debtor] += amount
+		left[creditor] -= amount
+		transfers = append(transfers, Transfer{From: names[debtor], To: names[creditor], Amount: fromCents(amount)})
+	}
+}
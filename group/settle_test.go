@@ -0,0 +1,71 @@
+//go:build unit
+
+package group
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSettle(t *testing.T) {
+	t.Run("Settled group should need no transfers", func(t *testing.T) {
+		if got := Settle([]Balance{{"alice", 0}, {"bob", 0}}); len(got) != 0 {
+			t.Errorf("transfers were not expected got: %v", got)
+		}
+	})
+
+	t.Run("Debtors should pay the creditor", func(t *testing.T) {
+		got := Settle([]Balance{{"alice", 20}, {"bob", -10}, {"carol", -10}})
+		want := []Transfer{{"bob", "alice", 10}, {"carol", "alice", 10}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("transfers should be %v but it got %v", want, got)
+		}
+	})
+
+	t.Run("Pairs that cancel out should settle apart", func(t *testing.T) {
+		// Greedy matching pays the largest balances first and needs four
+		// transfers here; pairing the equal balances needs three.
+		got := Settle([]Balance{{"a", 6}, {"b", 5}, {"c", 4}, {"d", -6}, {"e", -5}, {"f", -4}})
+		want := []Transfer{{"d", "a", 6}, {"e", "b", 5}, {"f", "c", 4}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("transfers should be %v but it got %v", want, got)
+		}
+	})
+
+	t.Run("Transfers should use the fewest payments", func(t *testing.T) {
+		got := Settle([]Balance{{"a", 7}, {"b", 3}, {"c", -5}, {"d", -2}, {"e", -3}})
+		if len(got) != 3 {
+			t.Errorf("should need 3 transfers but it got %v", got)
+		}
+		assertSettles(t, []Balance{{"a", 7}, {"b", 3}, {"c", -5}, {"d", -2}, {"e", -3}}, got)
+	})
+
+	t.Run("Large group should still settle", func(t *testing.T) {
+		balances := []Balance{}
+		for i := 0; i < 20; i++ {
+			balances = append(balances, Balance{string(rune('a' + i)), float64(i) - 9.5})
+		}
+		got := Settle(balances)
+		if len(got) > 19 {
+			t.Errorf("should need at most 19 transfers but it got %d", len(got))
+		}
+		assertSettles(t, balances, got)
+	})
+}
+
+func assertSettles(t *testing.T, balances []Balance, transfers []Transfer) {
+	t.Helper()
+	left := map[string]int64{}
+	for _, b := range balances {
+		left[b.Member] = toCents(b.Balance)
+	}
+	for _, tr := range transfers {
+		left[tr.From] += toCents(tr.Amount)
+		left[tr.To] -= toCents(tr.Amount)
+	}
+	for member, cents := range left {
+		if cents != 0 {
+			t.Errorf("%s should be settled but it is left with %d cents", member, cents)
+		}
+	}
+}
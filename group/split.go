@@ -0,0 +1,130 @@
+package group
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	SplitEqual      = "equal"
+	SplitExact      = "exact"
+	SplitPercentage = "percentage"
+	SplitShares     = "shares"
+)
+
+// Split says how an expense is divided. Equal splits use Members, or every
+// group member when it is empty; the other methods take a value per member
+// in Values: the amount owed, a percentage or a number of shares.
+type Split struct {
+	Method  string             `json:"method"`
+	Members []string           `json:"members,omitempty"`
+	Values  map[string]float64 `json:"values,omitempty"`
+}
+
+func toCents(v float64) int64 {
+	return int64(math.Round(v * 100))
+}
+
+func fromCents(c int64) float64 {
+	return float64(c) / 100
+}
+
+// Shares divides amount between members according to s and returns what each
+// member owes. Amounts are worked out in cents and the cents left over by
+// rounding go to the members with the largest remainders, so the shares
+// always add up to amount exactly.
+func (s Split) Shares(amount float64, members []string) (map[string]float64, error) {
+	isMember := map[string]bool{}
+	for _, m := range members {
+		isMember[m] = true
+	}
+	total := toCents(amount)
+	if total <= 0 {
+		return nil, errors.New("Amount must be positive to split")
+	}
+
+	weights := map[string]float64{}
+	switch s.Method {
+	case SplitEqual, "":
+		names := s.Members
+		if len(names) == 0 {
+			names = members
+		}
+		for _, m := range names {
+			weights[m] = 1
+		}
+	case SplitExact, SplitPercentage, SplitShares:
+		for m, v := range s.Values {
+			if v < 0 {
+				return nil, fmt.Errorf("Split value for %s must not be negative", m)
+			}
+			if v > 0 {
+				weights[m] = v
+			}
+		}
+	default:
+		return nil, fmt.Errorf("Split method %q is invalid", s.Method)
+	}
+	if len(weights) == 0 {
+		return nil, errors.New("Split needs at least one member")
+	}
+	for m := range weights {
+		if !isMember[m] {
+			return nil, fmt.Errorf("%s is not a member of the group", m)
+		}
+	}
+
+	names := make([]string, 0, len(weights))
+	var sum float64
+	for m, w := range weights {
+		names = append(names, m)
+		sum += w
+	}
+	sort.Strings(names)
+
+	shares := map[string]float64{}
+	switch s.Method {
+	case SplitExact:
+		var cents int64
+		for _, m := range names {
+			cents += toCents(weights[m])
+			shares[m] = fromCents(toCents(weights[m]))
+		}
+		if cents != total {
+			return nil, fmt.Errorf("Split amounts add up to %.2f, not %.2f", fromCents(cents), fromCents(total))
+		}
+		return shares, nil
+	case SplitPercentage:
+		if math.Abs(sum-100) > 0.0001 {
+			return nil, fmt.Errorf("Split percentages add up to %g, not 100", sum)
+		}
+	}
+
+	type part struct {
+		name  string
+		cents int64
+		rest  float64
+	}
+	parts := make([]part, len(names))
+	var assigned int64
+	for i, m := range names {
+		exact := float64(total) * weights[m] / sum
+		parts[i] = part{name: m, cents: int64(math.Floor(exact)), rest: exact - math.Floor(exact)}
+		assigned += parts[i].cents
+	}
+	order := make([]int, len(parts))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return parts[order[a]].rest > parts[order[b]].rest })
+	for i := 0; assigned < total; i++ {
+		parts[order[i%len(order)]].cents++
+		assigned++
+	}
+	for _, p := range parts {
+		shares[p.name] = fromCents(p.cents)
+	}
+	return shares, nil
+}
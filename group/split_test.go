@@ -0,0 +1,52 @@
+//go:build unit
+
+package group
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShares(t *testing.T) {
+	members := []string{"alice", "bob", "carol"}
+	cases := []struct {
+		name   string
+		split  Split
+		amount float64
+		want   map[string]float64
+	}{
+		{"Equal split should give leftover cents to the first members", Split{Method: SplitEqual}, 100, map[string]float64{"alice": 33.34, "bob": 33.33, "carol": 33.33}},
+		{"Equal split should only use the listed members", Split{Method: SplitEqual, Members: []string{"bob", "carol"}}, 10, map[string]float64{"bob": 5, "carol": 5}},
+		{"Exact split should keep the amounts", Split{Method: SplitExact, Values: map[string]float64{"alice": 7.5, "bob": 2.5}}, 10, map[string]float64{"alice": 7.5, "bob": 2.5}},
+		{"Percentage split should round to cents", Split{Method: SplitPercentage, Values: map[string]float64{"alice": 50, "bob": 25, "carol": 25}}, 10.01, map[string]float64{"alice": 5.01, "bob": 2.5, "carol": 2.5}},
+		{"Shares split should weigh members", Split{Method: SplitShares, Values: map[string]float64{"alice": 2, "bob": 1}}, 30, map[string]float64{"alice": 20, "bob": 10}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.split.Shares(tc.amount, members)
+			if err != nil {
+				t.Errorf("should not return error but it got %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("shares should be %v but it got %v", tc.want, got)
+			}
+		})
+	}
+
+	invalid := map[string]Split{
+		"Exact split not adding up should got error":     {Method: SplitExact, Values: map[string]float64{"alice": 5}},
+		"Percentages not adding up should got error":     {Method: SplitPercentage, Values: map[string]float64{"alice": 50, "bob": 40}},
+		"Split with a non-member should got error":       {Method: SplitShares, Values: map[string]float64{"dave": 1}},
+		"Split with a negative value should got error":   {Method: SplitShares, Values: map[string]float64{"alice": -1, "bob": 2}},
+		"Split with an unknown method should got error":  {Method: "random"},
+		"Split without anybody to pay should got error":  {Method: SplitShares},
+		"Equal split with a non-member should got error": {Method: SplitEqual, Members: []string{"alice", "zed"}},
+	}
+	for name, split := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := split.Shares(10, members); err == nil {
+				t.Errorf("should return error")
+			}
+		})
+	}
+}
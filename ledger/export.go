@@ -0,0 +1,63 @@
+package ledger
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/expense"
+)
+
+var fileExtensions = map[string]string{
+	Ledger:    "ledger",
+	HLedger:   "journal",
+	Beancount: "beancount",
+}
+
+// ExportLedgerHandler renders the expenses matching the list filters as a
+// plain-text accounting journal. The configured mapping can be overridden
+// per request with account.<tag>, default_account, funding_account and
+// currency query parameters.
+func (h *handler) ExportLedgerHandler(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = Ledger
+	}
+	ext, ok := fileExtensions[format]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "Query format is invalid"})
+	}
+	f, err := expense.ParseFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: err.Error()})
+	}
+
+	cfg := h.Config
+	cfg.Accounts = map[string]string{}
+	for tag, account := range h.Config.Accounts {
+		cfg.Accounts[tag] = account
+	}
+	for name, values := range c.QueryParams() {
+		if tag := strings.TrimPrefix(name, "account."); tag != name && len(values) > 0 {
+			cfg.Accounts[tag] = values[0]
+		}
+	}
+	for name, dest := range map[string]*string{"default_account": &cfg.DefaultAccount, "funding_account": &cfg.FundingAccount, "currency": &cfg.Currency} {
+		if v := c.QueryParam(name); v != "" {
+			*dest = v
+		}
+	}
+
+	expenses, err := expense.GetExpenses(h.DB, f)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: "Unable to get expenses from database:" + err.Error()})
+	}
+	buf := &bytes.Buffer{}
+	if err := Write(buf, format, cfg, expenses); err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "Account mapping is invalid: " + err.Error()})
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="expenses.`+ext+`"`)
+	return c.Blob(http.StatusOK, echo.MIMETextPlainCharsetUTF8, buf.Bytes())
+}
@@ -0,0 +1,40 @@
+//go:build unit
+
+package ledger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/auth"
+)
+
+func TestExportLedgerHandler(t *testing.T) {
+	t.Run("Export should only post the caller's own expenses", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectPrepare("SELECT (.+) FROM expenses WHERE owner = \\$1 ORDER BY id ASC").ExpectQuery().
+			WithArgs("alice").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}))
+
+		e := echo.New()
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/expenses/export/ledger", nil), rec)
+		auth.SetUser(c, auth.User{Name: "alice"})
+		if err := NewApplication(db, DefaultConfig()).ExportLedgerHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusOK {
+			t.Errorf("should status ok but it got %d: %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package ledger
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/phanbanchong/assessment/expense"
+)
+
+const (
+	Ledger    = "ledger"
+	HLedger   = "hledger"
+	Beancount = "beancount"
+)
+
+type handler struct {
+	DB     *sql.DB
+	Config Config
+}
+
+func NewApplication(db *sql.DB, cfg Config) *handler {
+	return &handler{DB: db, Config: cfg}
+}
+
+// Config maps expenses to accounts. An expense is posted to the account of
+// its first mapped tag, or DefaultAccount when none of its tags is mapped,
+// and balanced against FundingAccount. Currency labels the amounts of
+// expenses stored before they had a reporting currency.
+type Config struct {
+	Accounts       map[string]string `json:"accounts"`
+	DefaultAccount string            `json:"default_account"`
+	FundingAccount string            `json:"funding_account"`
+	Currency       string            `json:"currency"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Accounts:       map[string]string{},
+		DefaultAccount: "Expenses:Uncategorized",
+		FundingAccount: "Assets:Cash",
+		Currency:       "THB",
+	}
+}
+
+// LoadConfig reads a JSON config file over the defaults. An empty path
+// returns the defaults.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, err
+	}
+	if cfg.Accounts == nil {
+		cfg.Accounts = map[string]string{}
+	}
+	return cfg, nil
+}
+
+// Account is the account an expense with the given tags is posted to.
+func (c Config) Account(tags []string) string {
+	for _, tag := range tags {
+		if account, ok := c.Accounts[strings.ToLower(tag)]; ok {
+			return account
+		}
+		if account, ok := c.Accounts[tag]; ok {
+			return account
+		}
+	}
+	return c.DefaultAccount
+}
+
+// commodity is the currency exp's reporting amount is in.
+func (c Config) commodity(exp expense.Expense) string {
+	if exp.ReportingCurrency != "" {
+		return strings.ToUpper(exp.ReportingCurrency)
+	}
+	return strings.ToUpper(c.Currency)
+}
@@ -0,0 +1,176 @@
+package ledger
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/phanbanchong/assessment/expense"
+)
+
+var (
+	beancountRoots   = map[string]bool{"Assets": true, "Liabilities": true, "Equity": true, "Income": true, "Expenses": true}
+	beancountInvalid = regexp.MustCompile(`[^A-Za-z0-9-]+`)
+	beancountTag     = regexp.MustCompile(`[^A-Za-z0-9_/.-]+`)
+	ledgerTag        = regexp.MustCompile(`[\s:,]+`)
+)
+
+// formatAmount renders an amount with exactly two decimals, which both
+// ledger and beancount read as an exact decimal.
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// Write renders expenses as a ledger, hledger or beancount journal, posting
+// each in its reporting currency. Expenses without a date cannot be posted
+// and are skipped.
+func Write(w io.Writer, format string, cfg Config, expenses []expense.Expense) error {
+	switch format {
+	case Ledger, HLedger:
+		return writeLedger(w, format, cfg, expenses)
+	case Beancount:
+		return writeBeancount(w, cfg, expenses)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+func writeLedger(w io.Writer, format string, cfg Config, expenses []expense.Expense) error {
+	bw := bufio.NewWriter(w)
+	for _, exp := range expenses {
+		if exp.Date == "" {
+			continue
+		}
+		fmt.Fprintf(bw, "%s * %s\n", exp.Date, singleLine(exp.Title))
+		fmt.Fprintf(bw, "    ; id: %d\n", exp.ID)
+		if tags := ledgerTags(exp.Tags); len(tags) > 0 {
+			if format == HLedger {
+				fmt.Fprintf(bw, "    ; %s:\n", strings.Join(tags, ":, "))
+			} else {
+				fmt.Fprintf(bw, "    ; :%s:\n", strings.Join(tags, ":"))
+			}
+		}
+		for _, line := range strings.Split(exp.Note, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				fmt.Fprintf(bw, "    ; %s\n", line)
+			}
+		}
+		fmt.Fprintf(bw, "    %s  %s %s\n", cfg.Account(exp.Tags), formatAmount(exp.ReportAmount()), cfg.commodity(exp))
+		fmt.Fprintf(bw, "    %s\n\n", cfg.FundingAccount)
+	}
+	return bw.Flush()
+}
+
+func writeBeancount(w io.Writer, cfg Config, expenses []expense.Expense) error {
+	funding, err := beancountAccount(cfg.FundingAccount)
+	if err != nil {
+		return err
+	}
+
+	opened := map[string]string{}
+	currencies := map[string]map[string]bool{}
+	accounts := make([]string, len(expenses))
+	for i, exp := range expenses {
+		if exp.Date == "" {
+			continue
+		}
+		if accounts[i], err = beancountAccount(cfg.Account(exp.Tags)); err != nil {
+			return err
+		}
+		for _, account := range []string{accounts[i], funding} {
+			if first, ok := opened[account]; !ok || exp.Date < first {
+				opened[account] = exp.Date
+			}
+			if currencies[account] == nil {
+				currencies[account] = map[string]bool{}
+			}
+			currencies[account][cfg.commodity(exp)] = true
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	names := make([]string, 0, len(opened))
+	for account := range opened {
+		names = append(names, account)
+	}
+	sort.Strings(names)
+	for _, account := range names {
+		constraint := make([]string, 0, len(currencies[account]))
+		for currency := range currencies[account] {
+			constraint = append(constraint, currency)
+		}
+		sort.Strings(constraint)
+		fmt.Fprintf(bw, "%s open %s %s\n", opened[account], account, strings.Join(constraint, ","))
+	}
+	if len(names) > 0 {
+		bw.WriteString("\n")
+	}
+
+	for i, exp := range expenses {
+		if exp.Date == "" {
+			continue
+		}
+		fmt.Fprintf(bw, "%s * %s", exp.Date, beancountString(exp.Title))
+		for _, tag := range exp.Tags {
+			if tag = strings.Trim(beancountTag.ReplaceAllString(tag, "-"), "-"); tag != "" {
+				fmt.Fprintf(bw, " #%s", tag)
+			}
+		}
+		bw.WriteString("\n")
+		fmt.Fprintf(bw, "  id: \"%d\"\n", exp.ID)
+		if exp.Note != "" {
+			fmt.Fprintf(bw, "  note: %s\n", beancountString(exp.Note))
+		}
+		fmt.Fprintf(bw, "  %s  %s %s\n", accounts[i], formatAmount(exp.ReportAmount()), cfg.commodity(exp))
+		fmt.Fprintf(bw, "  %s\n\n", funding)
+	}
+	return bw.Flush()
+}
+
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func ledgerTags(tags []string) []string {
+	out := []string{}
+	for _, tag := range tags {
+		if tag = strings.Trim(ledgerTag.ReplaceAllString(tag, "-"), "-"); tag != "" {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+// beancountAccount makes every component of account start with a capital
+// letter and contain only letters, digits and dashes, as beancount requires.
+func beancountAccount(account string) (string, error) {
+	parts := strings.Split(account, ":")
+	if !beancountRoots[parts[0]] {
+		return "", fmt.Errorf("account %q must start with one of Assets, Liabilities, Equity, Income or Expenses", account)
+	}
+	for i, part := range parts {
+		part = strings.Trim(beancountInvalid.ReplaceAllString(part, "-"), "-")
+		if part == "" {
+			return "", errors.New("account " + strconv.Quote(account) + " has an empty component")
+		}
+		r := []rune(part)
+		r[0] = unicode.ToUpper(r[0])
+		if !unicode.IsUpper(r[0]) && !unicode.IsDigit(r[0]) {
+			part = "X" + string(r)
+		} else {
+			part = string(r)
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, ":"), nil
+}
+
+func beancountString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ").Replace(s) + `"`
+}
@@ -0,0 +1,255 @@
+//go:build unit
+
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/phanbanchong/assessment/expense"
+)
+
+type posting struct {
+	account  string
+	amount   *big.Rat
+	currency string
+}
+
+type transaction struct {
+	date     string
+	payee    string
+	id       string
+	tags     []string
+	postings []posting
+}
+
+var (
+	headerLine  = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}) \* (.*)$`)
+	amountField = regexp.MustCompile(`^(-?\d+\.\d{2}) ([A-Z]+)$`)
+	metaLine    = regexp.MustCompile(`^([a-z][a-zA-Z0-9_-]*): (.*)$`)
+)
+
+// parseJournal is a small reference parser for the subset of ledger, hledger
+// and beancount syntax the exporter emits. It checks that every transaction
+// balances, filling in the single elided amount.
+func parseJournal(t *testing.T, format, journal string) ([]transaction, map[string]bool) {
+	t.Helper()
+	txns := []transaction{}
+	opened := map[string]bool{}
+	var cur *transaction
+	finish := func() {
+		if cur == nil {
+			return
+		}
+		sum := new(big.Rat)
+		elided := -1
+		for i, p := range cur.postings {
+			if p.amount == nil {
+				if elided >= 0 {
+					t.Fatalf("transaction %s has more than one elided amount", cur.id)
+				}
+				elided = i
+				continue
+			}
+			sum.Add(sum, p.amount)
+		}
+		if elided >= 0 {
+			cur.postings[elided].amount = new(big.Rat).Neg(sum)
+		} else if sum.Sign() != 0 {
+			t.Fatalf("transaction %s does not balance", cur.id)
+		}
+		txns = append(txns, *cur)
+		cur = nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(journal))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			finish()
+		case strings.HasPrefix(line, " "):
+			if cur == nil {
+				t.Fatalf("indented line outside a transaction: %q", line)
+			}
+			if strings.HasPrefix(trimmed, ";") {
+				comment := strings.TrimSpace(strings.TrimPrefix(trimmed, ";"))
+				switch {
+				case strings.HasPrefix(comment, "id: "):
+					cur.id = strings.TrimPrefix(comment, "id: ")
+				case format == Ledger && strings.HasPrefix(comment, ":") && strings.HasSuffix(comment, ":"):
+					cur.tags = strings.Split(strings.Trim(comment, ":"), ":")
+				case format == HLedger && strings.HasSuffix(comment, ":"):
+					cur.tags = strings.Split(strings.TrimSuffix(comment, ":"), ":, ")
+				}
+				continue
+			}
+			if format == Beancount {
+				if m := metaLine.FindStringSubmatch(trimmed); m != nil {
+					if m[1] == "id" {
+						cur.id, _ = strconv.Unquote(m[2])
+					}
+					continue
+				}
+			}
+			fields := regexp.MustCompile(`\s{2,}`).Split(trimmed, 2)
+			p := posting{account: fields[0]}
+			if format == Beancount && !opened[p.account] {
+				t.Fatalf("account %s used before it was opened", p.account)
+			}
+			if len(fields) == 2 {
+				m := amountField.FindStringSubmatch(fields[1])
+				if m == nil {
+					t.Fatalf("amount %q is not a two-decimal amount with a commodity", fields[1])
+				}
+				p.amount, _ = new(big.Rat).SetString(m[1])
+				p.currency = m[2]
+			}
+			cur.postings = append(cur.postings, p)
+		default:
+			finish()
+			if format == Beancount && strings.Contains(line, " open ") {
+				opened[strings.Fields(line)[2]] = true
+				continue
+			}
+			m := headerLine.FindStringSubmatch(line)
+			if m == nil {
+				t.Fatalf("unexpected line %q", line)
+			}
+			cur = &transaction{date: m[1], payee: m[2]}
+			if format == Beancount {
+				payee, rest, err := readQuoted(m[2])
+				if err != nil {
+					t.Fatalf("invalid narration in %q: %v", line, err)
+				}
+				cur.payee = payee
+				for _, tag := range strings.Fields(rest) {
+					cur.tags = append(cur.tags, strings.TrimPrefix(tag, "#"))
+				}
+			}
+		}
+	}
+	finish()
+	return txns, opened
+}
+
+func readQuoted(s string) (string, string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", "", fmt.Errorf("missing quote")
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			unquoted, err := strconv.Unquote(s[:i+1])
+			return unquoted, s[i+1:], err
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}
+
+var roundTripExpenses = []expense.Expense{
+	{ID: 1, Title: `taxi "airport"`, Amount: 12.5, Note: "line one\nline two", Tags: []string{"travel", "work trip"}, Date: "2026-01-02"},
+	{ID: 2, Title: "lunch", Amount: 0.1 + 0.2, Tags: []string{"food"}, Date: "2026-01-01"},
+	{ID: 3, Title: "refund", Amount: -5, Tags: []string{}, Date: "2026-01-03"},
+	{ID: 4, Title: "undated", Amount: 1, Tags: []string{}},
+	{ID: 5, Title: "beans", Amount: 1234567.891, Tags: []string{"coffee shop"}, Date: "2026-01-04"},
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Accounts = map[string]string{"food": "Expenses:Food", "coffee shop": "Expenses:coffee shop"}
+
+	for _, format := range []string{Ledger, HLedger, Beancount} {
+		t.Run("Round trip "+format, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := Write(buf, format, cfg, roundTripExpenses); err != nil {
+				t.Fatalf("should not return error but it got %v", err)
+			}
+			txns, opened := parseJournal(t, format, buf.String())
+			if len(txns) != 4 {
+				t.Fatalf("should parse 4 dated transactions but it got %d:\n%s", len(txns), buf.String())
+			}
+
+			want := []struct {
+				id, date, account, amount string
+			}{
+				{"1", "2026-01-02", "Expenses:Uncategorized", "12.50"},
+				{"2", "2026-01-01", "Expenses:Food", "0.30"},
+				{"3", "2026-01-03", "Expenses:Uncategorized", "-5.00"},
+				{"5", "2026-01-04", "Expenses:coffee shop", "1234567.89"},
+			}
+			if format == Beancount {
+				want[3].account = "Expenses:Coffee-shop"
+				for _, account := range []string{"Assets:Cash", "Expenses:Food", "Expenses:Uncategorized", "Expenses:Coffee-shop"} {
+					if !opened[account] {
+						t.Errorf("account %s should be opened", account)
+					}
+				}
+			}
+			for i, w := range want {
+				got := txns[i]
+				if got.id != w.id || got.date != w.date || len(got.postings) != 2 {
+					t.Fatalf("unexpected transaction %+v", got)
+				}
+				if got.postings[0].account != w.account || got.postings[0].amount.FloatString(2) != w.amount || got.postings[0].currency != "THB" {
+					t.Errorf("unexpected posting %s %s %s", got.postings[0].account, got.postings[0].amount.FloatString(2), got.postings[0].currency)
+				}
+				if got.postings[1].account != "Assets:Cash" || got.postings[1].amount.FloatString(2) != new(big.Rat).Neg(got.postings[0].amount).FloatString(2) {
+					t.Errorf("funding posting should balance %+v", got.postings[1])
+				}
+			}
+			if txns[0].payee != `taxi "airport"` {
+				t.Errorf("unexpected payee %q", txns[0].payee)
+			}
+			wantTags := "travel,work-trip"
+			if strings.Join(txns[0].tags, ",") != wantTags {
+				t.Errorf("want tags %s but it got %v", wantTags, txns[0].tags)
+			}
+		})
+	}
+}
+
+func TestWriteReportingCurrency(t *testing.T) {
+	expenses := []expense.Expense{
+		{ID: 1, Title: "hotel", Amount: 100, Currency: "USD", ReportingAmount: 3500, ReportingCurrency: "THB", Rate: 35, Tags: []string{}, Date: "2026-01-02"},
+		{ID: 2, Title: "dinner", Amount: 40, Currency: "EUR", ReportingAmount: 40, ReportingCurrency: "EUR", Rate: 1, Tags: []string{}, Date: "2026-01-03"},
+	}
+	for _, format := range []string{Ledger, HLedger, Beancount} {
+		t.Run("Postings of "+format+" should be labelled with the reporting currency", func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := Write(buf, format, DefaultConfig(), expenses); err != nil {
+				t.Fatalf("should not return error but it got %v", err)
+			}
+			txns, _ := parseJournal(t, format, buf.String())
+			if len(txns) != 2 {
+				t.Fatalf("should parse 2 transactions but it got %d:\n%s", len(txns), buf.String())
+			}
+			for i, want := range []string{"3500.00 THB", "40.00 EUR"} {
+				p := txns[i].postings[0]
+				if got := p.amount.FloatString(2) + " " + p.currency; got != want {
+					t.Errorf("should post %s but it got %s", want, got)
+				}
+			}
+			if format == Beancount && !strings.Contains(buf.String(), "2026-01-02 open Assets:Cash EUR,THB\n") {
+				t.Errorf("funding account should allow both currencies:\n%s", buf.String())
+			}
+		})
+	}
+}
+
+func TestWriteBeancountInvalidAccount(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.FundingAccount = "Cash"
+	if err := Write(&bytes.Buffer{}, Beancount, cfg, roundTripExpenses); err == nil {
+		t.Errorf("should reject an account outside the beancount root accounts")
+	}
+}
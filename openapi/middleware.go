@@ -0,0 +1,240 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/expense"
+)
+
+// Validation selects what Validator checks against Spec.
+type Validation struct {
+	Requests bool
+	// Strict rejects JSON fields the document does not list.
+	Strict bool
+	// Responses is meant for tests: a response that breaks the document is
+	// replaced by a 500 listing the violations.
+	Responses bool
+}
+
+// ParseValidation reads a comma-separated list of request, strict and
+// response. Strict implies request. An empty list turns validation off.
+func ParseValidation(s string) (Validation, error) {
+	v := Validation{}
+	for _, option := range strings.Split(s, ",") {
+		switch strings.TrimSpace(option) {
+		case "":
+		case "request":
+			v.Requests = true
+		case "strict":
+			v.Requests, v.Strict = true, true
+		case "response":
+			v.Responses = true
+		default:
+			return v, fmt.Errorf("unknown validation option %q", option)
+		}
+	}
+	return v, nil
+}
+
+// PathFromEcho turns an echo route path into its OpenAPI form, so
+// /expenses/:id is /expenses/{id} and /expenses\:batch is /expenses:batch.
+func PathFromEcho(path string) string {
+	segments := strings.Split(strings.ReplaceAll(path, "\\:", ":"), "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Validator checks requests, and optionally responses, of the routes Spec
+// documents. A request that breaks the document is refused with 400 and the
+// JSON pointers of the violations. Routes Spec does not know pass through.
+func Validator(v Validation) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			op := Spec.Paths[PathFromEcho(c.Path())][strings.ToLower(c.Request().Method)]
+			if op == nil {
+				return next(c)
+			}
+			if v.Requests {
+				violations, err := checkRequest(c, op, v.Strict)
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					return c.JSON(http.StatusRequestEntityTooLarge, expense.Error{Message: "Request body is too large"})
+				}
+				if err != nil {
+					return err
+				}
+				if len(violations) > 0 {
+					return c.JSON(http.StatusBadRequest, ValidationError{Message: "Request does not match the API contract", Violations: violations})
+				}
+			}
+			if !v.Responses || !jsonOnly(op) {
+				return next(c)
+			}
+			return checkResponse(c, op, v.Strict, next)
+		}
+	}
+}
+
+func checkRequest(c echo.Context, op *Operation, strict bool) ([]Violation, error) {
+	ck := &checker{doc: &Spec, strict: strict}
+	req := c.Request()
+	for _, p := range op.Parameters {
+		ck.in = p.In
+		switch p.In {
+		case "path":
+			ck.checkParam(p, nonEmpty(c.Param(p.Name)))
+		case "query":
+			ck.checkParam(p, c.QueryParams()[p.Name])
+		case "header":
+			ck.checkParam(p, req.Header.Values(p.Name))
+		}
+	}
+	if op.RequestBody == nil {
+		return ck.violations, nil
+	}
+
+	// Only JSON bodies are buffered, and no more of them than the handlers
+	// read; uploads and imports are checked by their media type alone.
+	ck.in = "body"
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType))
+	var body []byte
+	if mediaType == mimeJSON {
+		var err error
+		if body, err = io.ReadAll(http.MaxBytesReader(c.Response(), req.Body, expense.MaxBodySize)); err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if len(body) == 0 && (mediaType == mimeJSON || req.ContentLength == 0) {
+		if op.RequestBody.Required {
+			ck.fail("", "is required")
+		}
+		return ck.violations, nil
+	}
+	content, ok := op.RequestBody.Content[mediaType]
+	if !ok {
+		ck.in = "header"
+		ck.fail("/"+echo.HeaderContentType, "must be one of "+strings.Join(mediaTypes(op.RequestBody.Content), ", "))
+		return ck.violations, nil
+	}
+	if mediaType == mimeJSON {
+		ck.checkJSON(content.Schema, body)
+	}
+	return ck.violations, nil
+}
+
+func (ck *checker) checkJSON(s *Schema, body []byte) {
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		ck.fail("", "must be valid JSON")
+		return
+	}
+	ck.check(s, value, "")
+}
+
+// jsonOnly reports whether every success response of op is JSON. Only those
+// are buffered for response validation; streams and downloads are not.
+func jsonOnly(op *Operation) bool {
+	for status, r := range op.Responses {
+		if !strings.HasPrefix(status, "2") {
+			continue
+		}
+		for mediaType := range r.Content {
+			if mediaType != mimeJSON {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// bufferedWriter holds a response back until it has been validated.
+type bufferedWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func checkResponse(c echo.Context, op *Operation, strict bool, next echo.HandlerFunc) error {
+	res := c.Response()
+	w := &bufferedWriter{ResponseWriter: res.Writer, status: http.StatusOK}
+	res.Writer = w
+	err := next(c)
+	res.Writer = w.ResponseWriter
+	if !res.Committed {
+		return err
+	}
+
+	ck := &checker{doc: &Spec, in: "body", strict: strict}
+	documented, ok := op.Responses[strconv.Itoa(w.status)]
+	mediaType, _, _ := mime.ParseMediaType(res.Header().Get(echo.HeaderContentType))
+	switch content, hasContent := documented.Content[mediaType]; {
+	case !ok:
+		ck.fail("", "status "+strconv.Itoa(w.status)+" is not documented")
+	case w.body.Len() == 0 && len(documented.Content) == 0:
+	case !hasContent:
+		ck.in = "header"
+		ck.fail("/"+echo.HeaderContentType, mediaType+" is not documented")
+	case mediaType == mimeJSON:
+		ck.checkJSON(content.Schema, w.body.Bytes())
+	}
+	if len(ck.violations) == 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+		_, werr := w.ResponseWriter.Write(w.body.Bytes())
+		if err == nil {
+			err = werr
+		}
+		return err
+	}
+
+	b, merr := json.Marshal(ValidationError{Message: "Response does not match the API contract", Violations: ck.violations})
+	if merr != nil {
+		return merr
+	}
+	res.Status = http.StatusInternalServerError
+	res.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	res.Header().Del(echo.HeaderContentLength)
+	w.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+	_, werr := w.ResponseWriter.Write(append(b, '\n'))
+	return werr
+}
+
+func nonEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return []string{s}
+}
+
+func mediaTypes(content map[string]MediaType) []string {
+	types := []string{}
+	for t := range content {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
@@ -0,0 +1,165 @@
+//go:build unit
+
+package openapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/expense"
+)
+
+// serve sends one request through Validator to handler registered at route.
+func serve(v Validation, method, route, target, body string, handler echo.HandlerFunc) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.Use(Validator(v))
+	e.Add(method, route, handler)
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func created(c echo.Context) error {
+	return c.JSON(http.StatusCreated, expense.Expense{ID: 1, Title: "taxi", Tags: []string{}})
+}
+
+func TestValidatorRequests(t *testing.T) {
+	cases := []struct {
+		name   string
+		v      Validation
+		method string
+		route  string
+		target string
+		body   string
+		want   string
+	}{
+		{"Wrong body types should point at the fields", Validation{Requests: true}, http.MethodPost, "/expenses", "/expenses",
+			`{"title":"taxi","amount":"12","tags":["a",2]}`,
+			`{"message":"Request does not match the API contract","violations":[{"in":"body","pointer":"/amount","message":"must be number"},{"in":"body","pointer":"/tags/1","message":"must be string"}]}`},
+		{"Strict mode should reject unknown fields", Validation{Requests: true, Strict: true}, http.MethodPost, "/expenses", "/expenses",
+			`{"title":"taxi","colour":"red"}`,
+			`{"message":"Request does not match the API contract","violations":[{"in":"body","pointer":"/colour","message":"is not allowed"}]}`},
+		{"Missing body should be rejected", Validation{Requests: true}, http.MethodPost, "/expenses", "/expenses", "",
+			`{"message":"Request does not match the API contract","violations":[{"in":"body","pointer":"/","message":"is required"}]}`},
+		{"Invalid query parameter should be rejected", Validation{Requests: true}, http.MethodGet, "/expenses", "/expenses?min_amount=abc&from=2023-13-01", "",
+			`{"message":"Request does not match the API contract","violations":[{"in":"query","pointer":"/from","message":"must be a date"},{"in":"query","pointer":"/min_amount","message":"must be number"}]}`},
+		{"Invalid path parameter should be rejected", Validation{Requests: true}, http.MethodGet, "/expenses/:id", "/expenses/abc", "",
+			`{"message":"Request does not match the API contract","violations":[{"in":"path","pointer":"/id","message":"must be integer"}]}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			called := false
+			rec := serve(tc.v, tc.method, tc.route, tc.target, tc.body, func(c echo.Context) error { called = true; return created(c) })
+
+			if called || rec.Code != http.StatusBadRequest || rec.Body.String() != tc.want+"\n" {
+				t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("Body over the size limit should be too large", func(t *testing.T) {
+		called := false
+		body := `{"title":"` + strings.Repeat("x", expense.MaxBodySize) + `"}`
+		rec := serve(Validation{Requests: true}, http.MethodPost, "/expenses", "/expenses", body, func(c echo.Context) error { called = true; return created(c) })
+
+		if called || rec.Code != http.StatusRequestEntityTooLarge || rec.Body.String() != `{"message":"Request body is too large"}`+"\n" {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Multipart body should reach the handler unread", func(t *testing.T) {
+		e := echo.New()
+		e.Use(Validator(Validation{Requests: true}))
+		var got int
+		e.POST("/expenses/:id/attachments", func(c echo.Context) error {
+			b, _ := io.ReadAll(c.Request().Body)
+			got = len(b)
+			return c.NoContent(http.StatusCreated)
+		})
+		body := strings.Repeat("x", expense.MaxBodySize+1)
+		req := httptest.NewRequest(http.MethodPost, "/expenses/1/attachments", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, "multipart/form-data; boundary=x")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusCreated || got != len(body) {
+			t.Errorf("response error was not expected got: %d, read %d bytes", rec.Code, got)
+		}
+	})
+
+	t.Run("Valid requests should reach the handler", func(t *testing.T) {
+		rec := serve(Validation{Requests: true}, http.MethodPost, "/expenses", "/expenses", `{"title":"taxi","amount":12.5,"tags":["travel"],"colour":"red"}`, created)
+		if rec.Code != http.StatusCreated {
+			t.Errorf("unknown fields should pass outside strict mode but it got: %d %s", rec.Code, rec.Body.String())
+		}
+		rec = serve(Validation{Requests: true, Strict: true}, http.MethodPost, "/expenses/:id/submit", "/expenses/1/submit", "", func(c echo.Context) error {
+			return c.NoContent(http.StatusOK)
+		})
+		if rec.Code != http.StatusOK {
+			t.Errorf("optional body should be accepted but it got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+func TestValidatorResponses(t *testing.T) {
+	t.Run("Response of the real handler should match the document", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectPrepare("SELECT (.+) FROM expenses WHERE id = \\$1").ExpectQuery().WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}).
+				AddRow(2, "expense 2", 2.0, "note 2", pq.Array([]string{"tag1"}), nil, nil, nil, "draft", "anonymous", nil, nil, nil, nil, nil, nil))
+
+		rec := serve(Validation{Responses: true, Strict: true}, http.MethodGet, "/expenses/:id", "/expenses/2", "", expense.NewApplication(db).GetExpenseHandler)
+
+		want := `{"id":2,"title":"expense 2","amount":2,"note":"note 2","tags":["tag1"],"status":"draft","owner":"anonymous"}` + "\n"
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Response that breaks the document should be replaced", func(t *testing.T) {
+		rec := serve(Validation{Responses: true}, http.MethodGet, "/expenses/:id", "/expenses/2", "", func(c echo.Context) error {
+			return c.JSON(http.StatusOK, map[string]any{"id": "2", "title": "taxi"})
+		})
+
+		want := `{"message":"Response does not match the API contract","violations":[{"in":"body","pointer":"/amount","message":"is required"},{"in":"body","pointer":"/note","message":"is required"},{"in":"body","pointer":"/tags","message":"is required"},{"in":"body","pointer":"/id","message":"must be integer"}]}` + "\n"
+		if rec.Code != http.StatusInternalServerError || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Undocumented status should be reported", func(t *testing.T) {
+		rec := serve(Validation{Responses: true}, http.MethodGet, "/expenses/:id", "/expenses/2", "", func(c echo.Context) error {
+			return c.JSON(http.StatusTeapot, expense.Error{Message: "tea"})
+		})
+
+		if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "status 418 is not documented") {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+func TestParseValidation(t *testing.T) {
+	t.Run("Parse validation should read the options", func(t *testing.T) {
+		v, err := ParseValidation("strict, response")
+		if err != nil || v != (Validation{Requests: true, Strict: true, Responses: true}) {
+			t.Errorf("should parse every option but it got %+v %v", v, err)
+		}
+		if _, err := ParseValidation("loose"); err == nil {
+			t.Errorf("should return error for unknown options")
+		}
+	})
+}
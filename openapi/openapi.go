@@ -0,0 +1,226 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/expense"
+)
+
+// Document is the subset of an OpenAPI 3.1 document the API needs.
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps lower-case HTTP methods to the operations on one path.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Schema is a JSON Schema as used by OpenAPI 3.1. Type is a string, or a
+// list of strings for nullable values.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	In          string `json:"in"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// Spec is the document describing every route of the API.
+var Spec = Build()
+
+func GetSpecHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, Spec)
+}
+
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+<title>Expense API</title>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+</head>
+<body>
+<redoc spec-url="/openapi.json"></redoc>
+<script src="https://cdn.redoc.ly/redoc/latest/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`
+
+// GetDocsHandler renders the document as a browsable reference.
+func GetDocsHandler(c echo.Context) error {
+	return c.HTML(http.StatusOK, docsPage)
+}
+
+// pathParams describes the {name} segments of path. IDs are integers.
+func pathParams(path string) []Parameter {
+	params := []Parameter{}
+	for _, segment := range strings.Split(path, "/") {
+		if !strings.HasPrefix(segment, "{") {
+			continue
+		}
+		name := strings.Trim(segment, "{}")
+		schema := &Schema{Type: "string"}
+		if name == "id" || strings.HasSuffix(name, "ID") {
+			schema = &Schema{Type: "integer"}
+		}
+		params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	return params
+}
+
+func jsonContent(s *Schema) map[string]MediaType {
+	return map[string]MediaType{mimeJSON: {Schema: s}}
+}
+
+func content(schemas map[string]*Schema) map[string]MediaType {
+	m := map[string]MediaType{}
+	for mime, s := range schemas {
+		m[mime] = MediaType{Schema: s}
+	}
+	return m
+}
+
+// Build renders Routes as a document.
+func Build() Document {
+	g := newGenerator()
+	errorSchema := g.schema(reflect.TypeOf(expense.Error{}))
+	doc := Document{
+		OpenAPI: "3.1.0",
+		Info: Info{
+			Title:       "Expense API",
+			Version:     "1.0.0",
+			Description: "Track, approve, report and reimburse expenses.",
+		},
+		Paths: map[string]PathItem{},
+		Components: Components{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]SecurityScheme{
+				"token": {Type: "apiKey", In: "header", Name: "Authorization", Description: "The shared AUTHORIZATION token or a user token from AUTH_USERS, sent as is."},
+			},
+		},
+		Security: []map[string][]string{{"token": {}}},
+	}
+	for _, r := range Routes {
+		op := &Operation{
+			OperationID: r.ID,
+			Summary:     r.Summary,
+			Tags:        []string{r.Tag},
+			Parameters:  append(pathParams(r.Path), r.Params...),
+			Responses:   map[string]Response{},
+		}
+		if r.Role != "" {
+			op.Description = "Requires the " + r.Role + " role."
+		}
+		ok := Response{Description: http.StatusText(r.Status)}
+		if r.Response != nil {
+			ok.Content = jsonContent(g.schema(reflect.TypeOf(r.Response)))
+		}
+		if r.Content != nil {
+			if ok.Content == nil {
+				ok.Content = map[string]MediaType{}
+			}
+			for mime, s := range content(r.Content) {
+				ok.Content[mime] = s
+			}
+		}
+		op.Responses[strconv.Itoa(r.Status)] = ok
+
+		statuses := map[int]any{http.StatusInternalServerError: nil}
+		if r.Public {
+			op.Security = []map[string][]string{}
+		} else {
+			statuses[http.StatusUnauthorized] = nil
+		}
+		for status, body := range r.Errors {
+			statuses[status] = body
+		}
+		for status, body := range statuses {
+			s := errorSchema
+			if body != nil {
+				s = g.schema(reflect.TypeOf(body))
+			}
+			op.Responses[strconv.Itoa(status)] = Response{Description: http.StatusText(status), Content: jsonContent(s)}
+		}
+
+		if doc.Paths[r.Path] == nil {
+			doc.Paths[r.Path] = PathItem{}
+		}
+		doc.Paths[r.Path][strings.ToLower(r.Method)] = op
+	}
+	// Bodies come second so types shared with responses keep their plain
+	// component name for the output form.
+	g.input = true
+	for _, r := range Routes {
+		op := doc.Paths[r.Path][strings.ToLower(r.Method)]
+		switch {
+		case r.BodyContent != nil:
+			op.RequestBody = &RequestBody{Required: !r.OptionalBody, Content: content(r.BodyContent)}
+		case r.Body != nil:
+			op.RequestBody = &RequestBody{Required: !r.OptionalBody, Content: jsonContent(g.schema(reflect.TypeOf(r.Body)))}
+		}
+	}
+	return doc
+}
@@ -0,0 +1,83 @@
+//go:build unit
+
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestBuild(t *testing.T) {
+	doc := Build()
+
+	t.Run("Expense schema should follow its JSON encoding", func(t *testing.T) {
+		s := doc.Components.Schemas["Expense"]
+		if s == nil {
+			t.Fatalf("should have an Expense schema")
+		}
+		for _, field := range []string{"id", "title", "amount", "note", "tags"} {
+			if s.Properties[field] == nil {
+				t.Errorf("should have property %s", field)
+			}
+		}
+		if want := []string{"id", "title", "amount", "note", "tags"}; !reflect.DeepEqual(s.Required, want) {
+			t.Errorf("should require %v but it got %v", want, s.Required)
+		}
+		if s.Properties["tags"].Type != "array" || s.Properties["tags"].Items.Type != "string" {
+			t.Errorf("tags should be an array of strings but it got %+v", s.Properties["tags"])
+		}
+	})
+
+	t.Run("Create expense should describe its body, auth and errors", func(t *testing.T) {
+		op := doc.Paths["/expenses"]["post"]
+		if op == nil {
+			t.Fatalf("should document POST /expenses")
+		}
+		body := op.RequestBody.Content[mimeJSON].Schema.Ref
+		if body != "#/components/schemas/ExpenseInput" || doc.Components.Schemas["ExpenseInput"].Required != nil {
+			t.Errorf("body should be an input schema without required fields but it got %s", body)
+		}
+		if got := op.Responses["409"].Content[mimeJSON].Schema.Ref; got != "#/components/schemas/ExpenseDuplicateError" {
+			t.Errorf("409 should be a duplicate error but it got %s", got)
+		}
+		for _, status := range []string{"201", "401", "500"} {
+			if _, ok := op.Responses[status]; !ok {
+				t.Errorf("should document status %s", status)
+			}
+		}
+		if op.Security != nil {
+			t.Errorf("should use the default security but it got %v", op.Security)
+		}
+	})
+
+	t.Run("Public routes should not require a token", func(t *testing.T) {
+		op := doc.Paths["/openapi.json"]["get"]
+		if op == nil || op.Security == nil || len(op.Security) != 0 {
+			t.Errorf("should have empty security but it got %+v", op)
+		}
+		if _, ok := op.Responses["401"]; ok {
+			t.Errorf("should not document 401")
+		}
+	})
+}
+
+func TestGetSpecHandler(t *testing.T) {
+	t.Run("Get spec should return the document", func(t *testing.T) {
+		e := echo.New()
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/openapi.json", nil), rec)
+
+		if err := GetSpecHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		var doc map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil || doc["openapi"] != "3.1.0" {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+}
@@ -0,0 +1,281 @@
+package openapi
+
+import (
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/phanbanchong/assessment/attachment"
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/expense"
+	"github.com/phanbanchong/assessment/graph"
+	"github.com/phanbanchong/assessment/group"
+	"github.com/phanbanchong/assessment/health"
+	"github.com/phanbanchong/assessment/ledger"
+	"github.com/phanbanchong/assessment/recurring"
+	"github.com/phanbanchong/assessment/report"
+	"github.com/phanbanchong/assessment/statement"
+	"github.com/phanbanchong/assessment/webhook"
+)
+
+const (
+	mimeJSON      = "application/json"
+	mimeCSV       = "text/csv"
+	mimeMultipart = "multipart/form-data"
+	mimeBinary    = "application/octet-stream"
+)
+
+// route documents one registered echo route, with its path in OpenAPI
+// form. Body and Response are values of the JSON types read and written; nil
+// means no body. BodyContent and Content describe other media types and take
+// the place of the JSON body, which may be left out when OptionalBody is set.
+// Errors lists the error statuses besides 401
+// and 500 with a value of the body written for each, nil for the plain
+// Error.
+type route struct {
+	Method       string
+	Path         string
+	ID           string
+	Summary      string
+	Tag          string
+	Role         string
+	Params       []Parameter
+	Body         any
+	BodyContent  map[string]*Schema
+	OptionalBody bool
+	Status       int
+	Response     any
+	Content      map[string]*Schema
+	Errors       map[int]any
+	Public       bool
+}
+
+func errs(statuses ...int) map[int]any {
+	m := map[int]any{}
+	for _, s := range statuses {
+		m[s] = nil
+	}
+	return m
+}
+
+func query(name, typ, description string) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: &Schema{Type: typ}}
+}
+
+func enum(p Parameter, values ...string) Parameter {
+	p.Schema.Enum = values
+	return p
+}
+
+var filterParams = []Parameter{
+	{Name: "tag", In: "query", Description: "Repeatable; every tag must match.", Schema: &Schema{Type: "array", Items: &Schema{Type: "string"}}},
+	{Name: "from", In: "query", Description: "Earliest date, inclusive.", Schema: &Schema{Type: "string", Format: "date"}},
+	{Name: "to", In: "query", Description: "Latest date, inclusive.", Schema: &Schema{Type: "string", Format: "date"}},
+	query("min_amount", "number", ""),
+	query("max_amount", "number", ""),
+	query("q", "string", "Title substring, case-insensitive."),
+	query("status", "string", ""),
+	query("owner", "string", ""),
+}
+
+func withFilter(params ...Parameter) []Parameter {
+	return append(append([]Parameter{}, filterParams...), params...)
+}
+
+var (
+	text   = &Schema{Type: "string"}
+	binary = &Schema{Type: "string", Format: "binary"}
+	upload = &Schema{Type: "object", Properties: map[string]*Schema{"file": binary}, Required: []string{"file"}}
+)
+
+var transitionErrors = errs(http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict)
+
+func transition(action, summary string) route {
+	return route{Method: http.MethodPost, Path: "/expenses/{id}/" + action, ID: action + "Expense", Summary: summary, Tag: "workflow",
+		Body: expense.TransitionRequest{}, OptionalBody: true, Status: http.StatusOK, Response: expense.TransitionResponse{}, Errors: transitionErrors}
+}
+
+// submit also checks the expense against the policy, receipt rules included.
+var submit = func() route {
+	r := transition("submit", "Submit an expense for approval")
+	r.Errors = map[int]any{http.StatusBadRequest: nil, http.StatusForbidden: nil, http.StatusNotFound: nil, http.StatusConflict: nil, http.StatusUnprocessableEntity: expense.PolicyError{}}
+	return r
+}()
+
+// Routes lists every route server.go registers.
+var Routes = []route{
+	{Method: http.MethodGet, Path: "/health", ID: "getHealth", Summary: "Report that the service is up", Tag: "health", Status: http.StatusOK, Response: health.Health{}},
+	{Method: http.MethodGet, Path: "/openapi.json", ID: "getSpec", Summary: "This document", Tag: "docs", Status: http.StatusOK, Content: map[string]*Schema{mimeJSON: {Type: "object"}}, Public: true},
+	{Method: http.MethodGet, Path: "/docs", ID: "getDocs", Summary: "Browsable API reference", Tag: "docs", Status: http.StatusOK, Content: map[string]*Schema{"text/html": text}, Public: true},
+
+	{Method: http.MethodGet, Path: "/expenses", ID: "listExpenses", Summary: "List expenses", Tag: "expenses", Params: filterParams,
+		Status: http.StatusOK, Response: []expense.Expense{}, Errors: errs(http.StatusBadRequest)},
+	{Method: http.MethodGet, Path: "/expenses/duplicates", ID: "listDuplicates", Summary: "List groups of expenses that look like the same receipt", Tag: "expenses",
+		Status: http.StatusOK, Response: []expense.DuplicateGroup{}},
+	{Method: http.MethodGet, Path: "/expenses/stream", ID: "streamExpenses", Summary: "Stream expense changes as server-sent events", Tag: "expenses",
+		Params: withFilter(
+			Parameter{Name: "Last-Event-ID", In: "header", Description: "Resume after this event.", Schema: &Schema{Type: "integer", Format: "int64"}},
+			Parameter{Name: "last_event_id", In: "query", Description: "Used when the Last-Event-ID header cannot be set.", Schema: &Schema{Type: "integer", Format: "int64"}}),
+		Status: http.StatusOK, Content: map[string]*Schema{"text/event-stream": text}, Errors: errs(http.StatusBadRequest)},
+	{Method: http.MethodGet, Path: "/expenses/export", ID: "exportExpenses", Summary: "Download expenses as csv, jsonl or xlsx", Tag: "expenses",
+		Params: withFilter(enum(query("format", "string", "Defaults to csv."), "csv", "jsonl", "xlsx")),
+		Status: http.StatusOK, Content: map[string]*Schema{
+			mimeCSV:                text,
+			"application/x-ndjson": text,
+			"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": binary,
+		}, Errors: errs(http.StatusBadRequest)},
+	{Method: http.MethodGet, Path: "/expenses/export/ledger", ID: "exportLedger", Summary: "Download expenses as a plain-text accounting journal", Tag: "expenses",
+		Params: withFilter(
+			enum(query("format", "string", "Defaults to ledger."), ledger.Ledger, ledger.HLedger, ledger.Beancount),
+			query("default_account", "string", "Account of expenses without a mapped tag."),
+			query("funding_account", "string", "Account every posting is balanced against."),
+			query("currency", "string", ""),
+		),
+		Status: http.StatusOK, Content: map[string]*Schema{"text/plain": text}, Errors: errs(http.StatusBadRequest)},
+	{Method: http.MethodGet, Path: "/expenses/{id}", ID: "getExpense", Summary: "Get an expense", Tag: "expenses",
+		Status: http.StatusOK, Response: expense.Expense{}, Errors: errs(http.StatusNotFound)},
+	{Method: http.MethodGet, Path: "/expenses/{id}/history", ID: "getExpenseHistory", Summary: "List the audit trail of an expense", Tag: "audit",
+		Status: http.StatusOK, Response: []audit.Entry{}, Errors: errs(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodGet, Path: "/expenses/{id}/transitions", ID: "getExpenseTransitions", Summary: "List the workflow transitions of an expense", Tag: "workflow",
+		Status: http.StatusOK, Response: []expense.TransitionRecord{}, Errors: errs(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Path: "/expenses", ID: "createExpense", Summary: "Create an expense", Tag: "expenses",
+		Params: []Parameter{query("force", "boolean", "Create the expense even if it looks like a duplicate.")},
+		Body:   expense.Expense{}, Status: http.StatusCreated, Response: expense.Expense{},
+		Errors: map[int]any{http.StatusBadRequest: nil, http.StatusConflict: expense.DuplicateError{}, http.StatusUnprocessableEntity: expense.PolicyError{}}},
+	{Method: http.MethodPost, Path: "/expenses/import", ID: "importExpenses", Summary: "Import expenses from CSV", Tag: "expenses",
+		Params: []Parameter{
+			query("dry_run", "boolean", "Only validate the rows."),
+			query("batch_size", "integer", "Commit every this many rows."),
+			query("column.title", "string", "CSV header of the title column; the other fields are mapped the same way."),
+		},
+		BodyContent: map[string]*Schema{mimeCSV: text, mimeMultipart: upload},
+		Status:      http.StatusCreated, Response: expense.ImportResult{},
+		Errors: map[int]any{http.StatusBadRequest: nil, http.StatusUnprocessableEntity: expense.ImportResult{}}},
+	{Method: http.MethodPost, Path: "/expenses/import/statement", ID: "importStatement", Summary: "Import an OFX/QFX, QIF or camt.053 bank statement", Tag: "expenses",
+		Params: []Parameter{
+			enum(query("format", "string", "Detected from the file when not set."), statement.OFX, "qfx", statement.QIF, statement.CAMT, "camt"),
+			query("dry_run", "boolean", "Only preview how every transaction would be handled."),
+			query("include_credits", "boolean", ""),
+			query("include_duplicates", "boolean", ""),
+		},
+		BodyContent: map[string]*Schema{mimeBinary: binary, mimeMultipart: upload},
+		Status:      http.StatusCreated, Response: expense.StatementResult{},
+		Errors: map[int]any{http.StatusBadRequest: nil, http.StatusUnprocessableEntity: expense.StatementResult{}}},
+	{Method: http.MethodPost, Path: "/expenses:batch", ID: "batchExpenses", Summary: "Create, update and delete expenses in one request", Tag: "expenses",
+		Params: []Parameter{query("atomic", "boolean", "Apply all operations or none.")},
+		Body:   expense.BatchRequest{}, Status: http.StatusOK, Response: expense.BatchResponse{},
+		Errors: errs(http.StatusBadRequest, http.StatusRequestEntityTooLarge)},
+	{Method: http.MethodPut, Path: "/expenses/{id}", ID: "updateExpense", Summary: "Update an expense", Tag: "expenses",
+		Body: expense.Expense{}, Status: http.StatusOK, Response: expense.Expense{},
+		Errors: map[int]any{http.StatusBadRequest: nil, http.StatusForbidden: nil, http.StatusNotFound: nil, http.StatusConflict: nil, http.StatusUnprocessableEntity: expense.PolicyError{}}},
+	{Method: http.MethodDelete, Path: "/expenses/{id}", ID: "deleteExpense", Summary: "Delete an expense", Tag: "expenses",
+		Status: http.StatusNoContent, Errors: errs(http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict)},
+	submit,
+	transition("approve", "Approve a submitted expense"),
+	transition("reject", "Reject a submitted expense; reason is required"),
+	transition("reimburse", "Mark an approved expense reimbursed"),
+
+	{Method: http.MethodGet, Path: "/sync", ID: "pullChanges", Summary: "List expenses changed since a sync token", Tag: "sync",
+		Params: []Parameter{Parameter{Name: "since", In: "query", Description: "Token of the previous response.", Schema: &Schema{Type: "integer", Format: "int64"}}, query("limit", "integer", "At most 1000, 500 by default.")},
+		Status: http.StatusOK, Response: expense.SyncResponse{}, Errors: errs(http.StatusBadRequest, http.StatusGone)},
+	{Method: http.MethodPost, Path: "/sync", ID: "pushChanges", Summary: "Apply offline changes, reporting conflicts per change", Tag: "sync",
+		Body: expense.PushRequest{}, Status: http.StatusOK, Response: expense.PushResponse{},
+		Errors: errs(http.StatusBadRequest, http.StatusRequestEntityTooLarge)},
+
+	{Method: http.MethodGet, Path: "/graphql", ID: "queryGraphQL", Summary: "Run a GraphQL query", Tag: "graphql",
+		Params: []Parameter{{Name: "query", In: "query", Required: true, Schema: text}, query("operationName", "string", ""), query("variables", "string", "JSON object.")},
+		Status: http.StatusOK, Response: graphql.Result{}, Errors: map[int]any{http.StatusBadRequest: graphql.Result{}, http.StatusMethodNotAllowed: graphql.Result{}}},
+	{Method: http.MethodPost, Path: "/graphql", ID: "postGraphQL", Summary: "Run a GraphQL query or mutation", Tag: "graphql",
+		Body: graph.Request{}, Status: http.StatusOK, Response: graphql.Result{}, Errors: map[int]any{http.StatusBadRequest: graphql.Result{}}},
+
+	{Method: http.MethodGet, Path: "/expenses/{id}/attachments", ID: "listAttachments", Summary: "List the attachments of an expense", Tag: "attachments",
+		Status: http.StatusOK, Response: []attachment.Attachment{}, Errors: errs(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodGet, Path: "/expenses/{id}/attachments/{attachmentID}", ID: "downloadAttachment", Summary: "Download an attachment", Tag: "attachments",
+		Status: http.StatusOK, Content: map[string]*Schema{mimeBinary: binary}, Errors: errs(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Path: "/expenses/{id}/attachments", ID: "uploadAttachment", Summary: "Attach a receipt; uploading the same file again returns the existing attachment", Tag: "attachments",
+		BodyContent: map[string]*Schema{mimeMultipart: upload}, Status: http.StatusCreated, Response: attachment.Attachment{},
+		Errors: errs(http.StatusBadRequest, http.StatusNotFound, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType)},
+	{Method: http.MethodDelete, Path: "/expenses/{id}/attachments/{attachmentID}", ID: "deleteAttachment", Summary: "Delete an attachment", Tag: "attachments",
+		Status: http.StatusNoContent, Errors: errs(http.StatusBadRequest, http.StatusNotFound)},
+
+	{Method: http.MethodGet, Path: "/audit", ID: "getAuditFeed", Summary: "List audit entries across all expenses, newest first", Tag: "audit", Role: "admin",
+		Params: []Parameter{
+			query("expense_id", "integer", ""),
+			query("actor", "string", ""),
+			query("action", "string", ""),
+			{Name: "since", In: "query", Schema: &Schema{Type: "string", Format: "date-time"}},
+			{Name: "until", In: "query", Schema: &Schema{Type: "string", Format: "date-time"}},
+			query("limit", "integer", ""),
+			{Name: "before_id", In: "query", Schema: &Schema{Type: "integer", Format: "int64"}},
+		},
+		Status: http.StatusOK, Response: []audit.Entry{}, Errors: errs(http.StatusBadRequest, http.StatusForbidden)},
+
+	{Method: http.MethodGet, Path: "/webhooks", ID: "listWebhooks", Summary: "List webhook subscriptions", Tag: "webhooks", Role: "admin",
+		Status: http.StatusOK, Response: []webhook.Subscription{}, Errors: errs(http.StatusForbidden)},
+	{Method: http.MethodGet, Path: "/webhooks/{id}", ID: "getWebhook", Summary: "Get a webhook subscription", Tag: "webhooks", Role: "admin",
+		Status: http.StatusOK, Response: webhook.Subscription{}, Errors: errs(http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound)},
+	{Method: http.MethodPost, Path: "/webhooks", ID: "createWebhook", Summary: "Subscribe a URL to expense events", Tag: "webhooks", Role: "admin",
+		Body: webhook.Subscription{}, Status: http.StatusCreated, Response: webhook.Subscription{}, Errors: errs(http.StatusBadRequest, http.StatusForbidden)},
+	{Method: http.MethodDelete, Path: "/webhooks/{id}", ID: "deleteWebhook", Summary: "Delete a webhook subscription", Tag: "webhooks", Role: "admin",
+		Status: http.StatusNoContent, Errors: errs(http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound)},
+	{Method: http.MethodGet, Path: "/webhooks/{id}/deliveries", ID: "listDeliveries", Summary: "List the deliveries of a subscription", Tag: "webhooks", Role: "admin",
+		Params: []Parameter{enum(query("status", "string", ""), webhook.StatusPending, webhook.StatusDelivered, webhook.StatusDead)},
+		Status: http.StatusOK, Response: []webhook.Delivery{}, Errors: errs(http.StatusBadRequest, http.StatusForbidden)},
+	{Method: http.MethodGet, Path: "/webhooks/{id}/deliveries/{deliveryID}", ID: "getDelivery", Summary: "Get a delivery with its attempt log", Tag: "webhooks", Role: "admin",
+		Status: http.StatusOK, Response: webhook.Delivery{}, Errors: errs(http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound)},
+	{Method: http.MethodPost, Path: "/webhooks/{id}/deliveries/{deliveryID}/redeliver", ID: "redeliver", Summary: "Queue a delivery to be sent again", Tag: "webhooks", Role: "admin",
+		Status: http.StatusAccepted, Response: webhook.Delivery{}, Errors: errs(http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound)},
+
+	{Method: http.MethodGet, Path: "/reports", ID: "listReports", Summary: "List reports, newest first", Tag: "reports",
+		Params: []Parameter{query("status", "string", ""), query("submitter", "string", "")},
+		Status: http.StatusOK, Response: []report.Report{}},
+	{Method: http.MethodGet, Path: "/reports/{id}", ID: "getReport", Summary: "Get a report with its items", Tag: "reports",
+		Status: http.StatusOK, Response: report.Report{}, Errors: errs(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodGet, Path: "/reports/{id}/export", ID: "exportReport", Summary: "Download a report as json or a csv payout file", Tag: "reports",
+		Params: []Parameter{enum(query("format", "string", "Defaults to json."), "json", "csv")},
+		Status: http.StatusOK, Response: report.Report{}, Content: map[string]*Schema{mimeCSV: text}, Errors: errs(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Path: "/reports", ID: "createReport", Summary: "Bundle approved expenses into a report", Tag: "reports", Role: "approver",
+		Body: report.CreateRequest{}, Status: http.StatusCreated, Response: report.Report{},
+		Errors: errs(http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity)},
+	{Method: http.MethodPost, Path: "/reports/{id}/pay", ID: "payReport", Summary: "Pay a report, reimbursing its expenses", Tag: "reports", Role: "approver",
+		Status: http.StatusOK, Response: report.Report{}, Errors: errs(http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict)},
+	{Method: http.MethodPost, Path: "/reports/{id}/close", ID: "closeReport", Summary: "Close a report", Tag: "reports", Role: "approver",
+		Status: http.StatusOK, Response: report.Report{}, Errors: errs(http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict)},
+
+	{Method: http.MethodGet, Path: "/groups", ID: "listGroups", Summary: "List the caller's groups", Tag: "groups",
+		Status: http.StatusOK, Response: []group.Group{}},
+	{Method: http.MethodGet, Path: "/groups/{id}", ID: "getGroup", Summary: "Get a group", Tag: "groups",
+		Status: http.StatusOK, Response: group.Group{}, Errors: errs(http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound)},
+	{Method: http.MethodPost, Path: "/groups", ID: "createGroup", Summary: "Create a group", Tag: "groups",
+		Body: group.CreateRequest{}, Status: http.StatusCreated, Response: group.Group{}, Errors: errs(http.StatusBadRequest)},
+	{Method: http.MethodPost, Path: "/groups/{id}/members", ID: "addMember", Summary: "Add a member to a group", Tag: "groups",
+		Body: group.MemberRequest{}, Status: http.StatusOK, Response: group.Group{}, Errors: errs(http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound)},
+	{Method: http.MethodDelete, Path: "/groups/{id}/members/{member}", ID: "removeMember", Summary: "Remove a settled member from a group", Tag: "groups",
+		Status: http.StatusNoContent, Errors: errs(http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict)},
+	{Method: http.MethodGet, Path: "/groups/{id}/expenses", ID: "listGroupExpenses", Summary: "List the shared expenses of a group", Tag: "groups",
+		Status: http.StatusOK, Response: []group.Expense{}, Errors: errs(http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound)},
+	{Method: http.MethodPost, Path: "/groups/{id}/expenses", ID: "createGroupExpense", Summary: "Add a shared expense split between members", Tag: "groups",
+		Params: []Parameter{query("force", "boolean", "Create the expense even if it looks like a duplicate.")},
+		Body:   group.ExpenseRequest{}, Status: http.StatusCreated, Response: group.Expense{},
+		Errors: map[int]any{http.StatusBadRequest: nil, http.StatusForbidden: nil, http.StatusNotFound: nil, http.StatusConflict: expense.DuplicateError{}, http.StatusUnprocessableEntity: expense.PolicyError{}}},
+	{Method: http.MethodGet, Path: "/groups/{id}/balances", ID: "getBalances", Summary: "Get what each member is owed or owes", Tag: "groups",
+		Status: http.StatusOK, Response: []group.Balance{}, Errors: errs(http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound)},
+	{Method: http.MethodGet, Path: "/groups/{id}/settle-up", ID: "getSettleUp", Summary: "Suggest the fewest transfers that settle a group", Tag: "groups",
+		Status: http.StatusOK, Response: group.SettleUp{}, Errors: errs(http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound)},
+	{Method: http.MethodPost, Path: "/groups/{id}/settle-up", ID: "settleUp", Summary: "Record the suggested transfers as settlements", Tag: "groups",
+		Status: http.StatusCreated, Response: []group.Settlement{}, Errors: errs(http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound)},
+	{Method: http.MethodGet, Path: "/groups/{id}/settlements", ID: "listSettlements", Summary: "List the settlements of a group", Tag: "groups",
+		Status: http.StatusOK, Response: []group.Settlement{}, Errors: errs(http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound)},
+	{Method: http.MethodPost, Path: "/groups/{id}/settlements", ID: "createSettlement", Summary: "Record a payment between members", Tag: "groups",
+		Body: group.Settlement{}, Status: http.StatusCreated, Response: group.Settlement{}, Errors: errs(http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound)},
+
+	{Method: http.MethodGet, Path: "/recurring-expenses", ID: "listTemplates", Summary: "List recurring expense templates", Tag: "recurring",
+		Status: http.StatusOK, Response: []recurring.Template{}},
+	{Method: http.MethodGet, Path: "/recurring-expenses/{id}", ID: "getTemplate", Summary: "Get a recurring expense template", Tag: "recurring",
+		Status: http.StatusOK, Response: recurring.Template{}, Errors: errs(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodPost, Path: "/recurring-expenses", ID: "createTemplate", Summary: "Create a recurring expense from an RRULE", Tag: "recurring",
+		Body: recurring.Template{}, Status: http.StatusCreated, Response: recurring.Template{}, Errors: errs(http.StatusBadRequest)},
+	{Method: http.MethodPut, Path: "/recurring-expenses/{id}", ID: "updateTemplate", Summary: "Update a recurring expense template", Tag: "recurring",
+		Body: recurring.Template{}, Status: http.StatusOK, Response: recurring.Template{}, Errors: errs(http.StatusBadRequest, http.StatusNotFound)},
+	{Method: http.MethodDelete, Path: "/recurring-expenses/{id}", ID: "deleteTemplate", Summary: "Delete a recurring expense template", Tag: "recurring",
+		Status: http.StatusNoContent, Errors: errs(http.StatusBadRequest, http.StatusNotFound)},
+}
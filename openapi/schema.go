@@ -0,0 +1,146 @@
+package openapi
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaNames overrides the component name of types whose name does not
+// read well on its own or prefixed with their package.
+var schemaNames = map[string]string{
+	"expense.Error": "Error",
+}
+
+// generator derives schemas from Go types as encoding/json writes them.
+// Named structs become components referenced by $ref. In input mode no field
+// is required, since echo's binder leaves missing fields at their zero
+// value; a struct already described as output gets a separate component
+// with an Input suffix.
+type generator struct {
+	schemas map[string]*Schema
+	names   map[component]string
+	input   bool
+}
+
+type component struct {
+	t     reflect.Type
+	input bool
+}
+
+func newGenerator() *generator {
+	return &generator{schemas: map[string]*Schema{}, names: map[component]string{}}
+}
+
+// name is the component name of t: the type name, prefixed with its package
+// unless the type name already mentions it, so expense.Expense is Expense and
+// audit.Entry is AuditEntry.
+func name(t reflect.Type) string {
+	pkg := path.Base(t.PkgPath())
+	if n, ok := schemaNames[pkg+"."+t.Name()]; ok {
+		return n
+	}
+	if strings.Contains(strings.ToLower(t.Name()), strings.ToLower(pkg)) {
+		return t.Name()
+	}
+	return strings.ToUpper(pkg[:1]) + pkg[1:] + t.Name()
+}
+
+// schema returns the schema of values of type t.
+func (g *generator) schema(t reflect.Type) *Schema {
+	switch {
+	case t == nil:
+		return &Schema{}
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawJSONType:
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		return g.ref(t)
+	}
+	return &Schema{}
+}
+
+// ref registers the struct t as a component and refers to it.
+func (g *generator) ref(t reflect.Type) *Schema {
+	key := component{t, g.input}
+	n, ok := g.names[key]
+	if !ok {
+		n = name(t)
+		if _, taken := g.schemas[n]; taken && g.input {
+			n += "Input"
+		}
+		g.names[key] = n
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		g.schemas[n] = s
+		g.fields(t, s)
+	}
+	return &Schema{Ref: "#/components/schemas/" + n}
+}
+
+// fields adds the JSON fields of struct t to s. Embedded structs without a
+// JSON name are flattened as encoding/json does. Fields without omitempty
+// are always written and so are required; nil pointers among them are null.
+func (g *generator) fields(t reflect.Type, s *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || !f.IsExported() {
+			continue
+		}
+		key, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && key == "" && f.Type.Kind() == reflect.Struct {
+			g.fields(f.Type, s)
+			continue
+		}
+		if key == "" {
+			key = f.Name
+		}
+		fs := g.schema(f.Type)
+		omitempty := strings.Contains(","+opts+",", ",omitempty,")
+		if f.Type.Kind() == reflect.Pointer && !omitempty {
+			fs = nullable(fs)
+		}
+		s.Properties[key] = fs
+		if !omitempty && !g.input {
+			s.Required = append(s.Required, key)
+		}
+	}
+}
+
+func nullable(s *Schema) *Schema {
+	if s.Ref != "" {
+		return &Schema{OneOf: []*Schema{s, {Type: "null"}}}
+	}
+	if t, ok := s.Type.(string); ok {
+		n := *s
+		n.Type = []string{t, "null"}
+		return &n
+	}
+	return s
+}
@@ -0,0 +1,215 @@
+package openapi
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Violation is one place where a request or response breaks the document.
+// In is body, path, query or header; Pointer is a JSON pointer into the body,
+// or /name for a parameter.
+type Violation struct {
+	In      string `json:"in"`
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+// ValidationError is written instead of a request or response that does not
+// match the document.
+type ValidationError struct {
+	Message    string      `json:"message"`
+	Violations []Violation `json:"violations"`
+}
+
+// checker validates decoded JSON values against the schemas of doc. Strict
+// rejects properties an object schema does not list, unless it allows
+// additional properties.
+type checker struct {
+	doc        *Document
+	in         string
+	strict     bool
+	violations []Violation
+}
+
+func (ck *checker) fail(pointer, message string) {
+	if pointer == "" {
+		pointer = "/"
+	}
+	ck.violations = append(ck.violations, Violation{In: ck.in, Pointer: pointer, Message: message})
+}
+
+// resolve follows $ref to the component it names.
+func (ck *checker) resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = ck.doc.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// check validates v, decoded with UseNumber, at pointer against s.
+func (ck *checker) check(s *Schema, v any, pointer string) {
+	s = ck.resolve(s)
+	if s == nil {
+		return
+	}
+	if len(s.OneOf) > 0 {
+		matched := 0
+		for _, option := range s.OneOf {
+			sub := &checker{doc: ck.doc, in: ck.in, strict: ck.strict}
+			sub.check(option, v, pointer)
+			if len(sub.violations) == 0 {
+				matched++
+			}
+		}
+		if matched != 1 {
+			ck.fail(pointer, "must match exactly one schema")
+		}
+		return
+	}
+	if types := schemaTypes(s); len(types) > 0 && !hasType(types, jsonType(v)) {
+		ck.fail(pointer, "must be "+strings.Join(types, " or "))
+		return
+	}
+	switch v := v.(type) {
+	case string:
+		ck.checkString(s, v, pointer)
+	case []any:
+		for i, item := range v {
+			ck.check(s.Items, item, pointer+"/"+strconv.Itoa(i))
+		}
+	case map[string]any:
+		ck.checkObject(s, v, pointer)
+	}
+}
+
+func (ck *checker) checkString(s *Schema, v, pointer string) {
+	if len(s.Enum) > 0 && !hasType(s.Enum, v) {
+		ck.fail(pointer, "must be one of "+strings.Join(s.Enum, ", "))
+	}
+	switch s.Format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, v); err != nil {
+			ck.fail(pointer, "must be a date-time")
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", v); err != nil {
+			ck.fail(pointer, "must be a date")
+		}
+	}
+}
+
+func (ck *checker) checkObject(s *Schema, v map[string]any, pointer string) {
+	for _, name := range s.Required {
+		if _, ok := v[name]; !ok {
+			ck.fail(pointer+"/"+escapePointer(name), "is required")
+		}
+	}
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		at := pointer + "/" + escapePointer(k)
+		switch prop, ok := s.Properties[k]; {
+		case ok:
+			ck.check(prop, v[k], at)
+		case s.AdditionalProperties != nil:
+			ck.check(s.AdditionalProperties, v[k], at)
+		case ck.strict && s.Properties != nil:
+			ck.fail(at, "is not allowed")
+		}
+	}
+}
+
+// checkParam validates the raw values of a path, query or header parameter.
+func (ck *checker) checkParam(p Parameter, values []string) {
+	s := ck.resolve(p.Schema)
+	pointer := "/" + escapePointer(p.Name)
+	if len(values) == 0 {
+		if p.Required {
+			ck.fail(pointer, "is required")
+		}
+		return
+	}
+	if s == nil {
+		return
+	}
+	if hasType(schemaTypes(s), "array") {
+		for i, raw := range values {
+			ck.check(s.Items, paramValue(ck.resolve(s.Items), raw), pointer+"/"+strconv.Itoa(i))
+		}
+		return
+	}
+	ck.check(s, paramValue(s, values[0]), pointer)
+}
+
+// paramValue converts a raw parameter to the JSON value s expects, leaving it
+// a string when it does not parse so the type check reports it.
+func paramValue(s *Schema, raw string) any {
+	if s == nil {
+		return raw
+	}
+	types := schemaTypes(s)
+	switch {
+	case hasType(types, "integer"), hasType(types, "number"):
+		if _, err := strconv.ParseFloat(raw, 64); err == nil {
+			return json.Number(raw)
+		}
+	case hasType(types, "boolean"):
+		if b, err := strconv.ParseBool(raw); err == nil {
+			return b
+		}
+	}
+	return raw
+}
+
+func schemaTypes(s *Schema) []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	}
+	return nil
+}
+
+func hasType(types []string, t string) bool {
+	for _, candidate := range types {
+		if candidate == t || candidate == "number" && t == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType names the JSON type of v. Numbers without a fraction are
+// integers.
+func jsonType(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) && !strings.ContainsAny(v.String(), ".eE") {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return ""
+}
+
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
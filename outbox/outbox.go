@@ -0,0 +1,194 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/lib/pq"
+)
+
+// relayLock is the advisory lock key held by the relay that is publishing,
+// so replicas never publish the same expense's events out of order.
+const relayLock = 7283002
+
+const (
+	defaultBatchSize = 100
+	defaultRetention = 24 * time.Hour
+)
+
+// Querier is satisfied by both *sql.DB and *sql.Tx so events can be written
+// in the same transaction as the change they describe.
+type Querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// Message is an event as handed to sinks.
+type Message struct {
+	ID         int64           `json:"id"`
+	Event      string          `json:"event"`
+	ExpenseID  int             `json:"expense_id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Sink publishes messages somewhere. Publish returns once the message is
+// accepted; an error leaves it in the outbox to be published again, so sinks
+// must tolerate duplicates.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, m Message) error
+}
+
+func InitDB(db *sql.DB) error {
+	commands := []string{
+		"CREATE TABLE IF NOT EXISTS outbox ( id BIGSERIAL PRIMARY KEY, event TEXT NOT NULL, expense_id INT NOT NULL, payload JSONB NOT NULL, created_at TIMESTAMPTZ NOT NULL DEFAULT now(), published_at TIMESTAMPTZ);",
+		"CREATE INDEX IF NOT EXISTS outbox_unpublished_idx ON outbox (id) WHERE published_at IS NULL;",
+	}
+	for _, command := range commands {
+		if _, err := db.Exec(command); err != nil {
+			return err
+		}
+		log.Printf("Executed: %s", command)
+	}
+	return nil
+}
+
+// Write adds an event about expense id to the outbox. Run it in the
+// transaction making the change so the event exists exactly when the change
+// commits.
+func Write(db Querier, event string, id int, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("INSERT INTO outbox (event, expense_id, payload) values ($1, $2, $3)", event, id, payload)
+	return err
+}
+
+// Relay publishes outbox events to every sink in the order they were
+// written. An event is marked published once all sinks accepted it; when one
+// fails, later events of the same expense wait for the next round so each
+// expense's events arrive in order. Published events are deleted after
+// Retention.
+type Relay struct {
+	DB        *sql.DB
+	Sinks     []Sink
+	Interval  time.Duration
+	BatchSize int
+	Retention time.Duration
+	Now       func() time.Time
+}
+
+func NewRelay(db *sql.DB, interval time.Duration, sinks ...Sink) *Relay {
+	return &Relay{DB: db, Sinks: sinks, Interval: interval, BatchSize: defaultBatchSize, Retention: defaultRetention, Now: time.Now}
+}
+
+// Run publishes pending events immediately and then on every tick until ctx
+// is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.RelayPending(ctx); err != nil {
+			log.Errorf("Relay outbox error: %v", err)
+		}
+		if n, err := r.Cleanup(ctx); err != nil {
+			log.Errorf("Clean up outbox error: %v", err)
+		} else if n > 0 {
+			log.Printf("Removed %d published outbox events", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayPending publishes batches of pending events until none are left or a
+// sink fails, and returns how many were published. It does nothing while
+// another relay holds the lock.
+func (r *Relay) RelayPending(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		n, more, err := r.relayBatch(ctx)
+		total += n
+		if err != nil || !more {
+			return total, err
+		}
+	}
+}
+
+// relayBatch publishes one batch and reports whether another may be waiting.
+func (r *Relay) relayBatch(ctx context.Context) (int, bool, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", relayLock).Scan(&locked); err != nil || !locked {
+		return 0, false, err
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT id, event, expense_id, created_at, payload FROM outbox WHERE published_at IS NULL ORDER BY id ASC LIMIT $1", r.BatchSize)
+	if err != nil {
+		return 0, false, err
+	}
+	messages := []Message{}
+	for rows.Next() {
+		m := Message{}
+		var payload []byte
+		if err := rows.Scan(&m.ID, &m.Event, &m.ExpenseID, &m.OccurredAt, &payload); err != nil {
+			rows.Close()
+			return 0, false, err
+		}
+		m.Data = payload
+		messages = append(messages, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, false, err
+	}
+
+	published := []int64{}
+	blocked := map[int]bool{}
+	for _, m := range messages {
+		if blocked[m.ExpenseID] || ctx.Err() != nil {
+			blocked[m.ExpenseID] = true
+			continue
+		}
+		for _, sink := range r.Sinks {
+			if err := sink.Publish(ctx, m); err != nil {
+				log.Errorf("Publish outbox event %d to %s error: %v", m.ID, sink.Name(), err)
+				blocked[m.ExpenseID] = true
+				break
+			}
+		}
+		if !blocked[m.ExpenseID] {
+			published = append(published, m.ID)
+		}
+	}
+	if len(published) > 0 {
+		if _, err := tx.ExecContext(ctx, "UPDATE outbox SET published_at = $2 WHERE id = ANY($1)", pq.Array(published), r.Now()); err != nil {
+			return 0, false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, false, err
+	}
+	return len(published), len(messages) == r.BatchSize && len(blocked) == 0, nil
+}
+
+// Cleanup deletes events published longer than Retention ago.
+func (r *Relay) Cleanup(ctx context.Context) (int64, error) {
+	result, err := r.DB.ExecContext(ctx, "DELETE FROM outbox WHERE published_at < $1", r.Now().Add(-r.Retention))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
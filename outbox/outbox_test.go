@@ -0,0 +1,149 @@
+//go:build unit
+
+package outbox
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+var now = time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+
+// memorySink records what it was given and fails for the expenses in fail.
+type memorySink struct {
+	got  []int64
+	fail map[int]bool
+}
+
+func (s *memorySink) Name() string {
+	return "memory"
+}
+
+func (s *memorySink) Publish(ctx context.Context, m Message) error {
+	if s.fail[m.ExpenseID] {
+		return errors.New("unavailable")
+	}
+	s.got = append(s.got, m.ID)
+	return nil
+}
+
+func expectPending(mock sqlmock.Sqlmock, rows ...[]driver.Value) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT pg_try_advisory_xact_lock").WithArgs(relayLock).WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+	result := sqlmock.NewRows([]string{"id", "event", "expense_id", "created_at", "payload"})
+	for _, r := range rows {
+		result.AddRow(r...)
+	}
+	mock.ExpectQuery("SELECT (.+) FROM outbox WHERE published_at IS NULL").WithArgs(defaultBatchSize).WillReturnRows(result)
+}
+
+func TestWrite(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	mock.ExpectExec("INSERT INTO outbox").
+		WithArgs("expense.created", 4, []byte(`{"id":4}`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if err := Write(db, "expense.created", 4, map[string]int{"id": 4}); err != nil {
+		t.Errorf("should not return error but it got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRelayPending(t *testing.T) {
+	t.Run("Relay should publish in order and mark events published", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		expectPending(mock,
+			[]driver.Value{1, "expense.created", 4, now, []byte(`{}`)},
+			[]driver.Value{2, "expense.created", 5, now, []byte(`{}`)},
+			[]driver.Value{3, "expense.updated", 4, now, []byte(`{}`)})
+		mock.ExpectExec("UPDATE outbox SET published_at").WithArgs(pq.Array([]int64{1, 2, 3}), now).WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
+
+		sink := &memorySink{}
+		relay := NewRelay(db, time.Second, sink)
+		relay.Now = func() time.Time { return now }
+		n, err := relay.RelayPending(context.Background())
+		if err != nil || n != 3 {
+			t.Errorf("should publish 3 but it got %d %v", n, err)
+		}
+		if len(sink.got) != 3 || sink.got[0] != 1 || sink.got[2] != 3 {
+			t.Errorf("events were not expected got: %v", sink.got)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Failed event should hold back later events of the same expense", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		expectPending(mock,
+			[]driver.Value{1, "expense.created", 4, now, []byte(`{}`)},
+			[]driver.Value{2, "expense.created", 5, now, []byte(`{}`)},
+			[]driver.Value{3, "expense.updated", 4, now, []byte(`{}`)})
+		mock.ExpectExec("UPDATE outbox SET published_at").WithArgs(pq.Array([]int64{2}), now).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		sink := &memorySink{fail: map[int]bool{4: true}}
+		relay := NewRelay(db, time.Second, sink)
+		relay.Now = func() time.Time { return now }
+		if n, err := relay.RelayPending(context.Background()); err != nil || n != 1 {
+			t.Errorf("should publish 1 but it got %d %v", n, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Relay should wait while another holds the lock", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT pg_try_advisory_xact_lock").WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(false))
+		mock.ExpectRollback()
+
+		if n, err := NewRelay(db, time.Second, &memorySink{}).RelayPending(context.Background()); err != nil || n != 0 {
+			t.Errorf("should publish nothing but it got %d %v", n, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestCleanup(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	mock.ExpectExec("DELETE FROM outbox WHERE published_at < (.+)").WithArgs(now.Add(-defaultRetention)).WillReturnResult(sqlmock.NewResult(0, 7))
+
+	relay := NewRelay(db, time.Second)
+	relay.Now = func() time.Time { return now }
+	if n, err := relay.Cleanup(context.Background()); err != nil || n != 7 {
+		t.Errorf("should remove 7 but it got %d %v", n, err)
+	}
+}
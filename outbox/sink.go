@@ -0,0 +1,282 @@
+package outbox
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ParseSinks builds sinks from a comma-separated list such as
+// "stdout,file:/var/log/events.jsonl,nats://localhost:4222/expenses,kafka+http://localhost:8082/expenses".
+func ParseSinks(spec string) ([]Sink, error) {
+	sinks := []Sink{}
+	for _, s := range strings.Split(spec, ",") {
+		s = strings.TrimSpace(s)
+		switch {
+		case s == "":
+		case s == "stdout":
+			sinks = append(sinks, NewWriterSink("stdout", os.Stdout))
+		case strings.HasPrefix(s, "file:"):
+			sink, err := NewFileSink(strings.TrimPrefix(s, "file:"))
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case strings.HasPrefix(s, "nats://"):
+			u, err := url.Parse(s)
+			if err != nil || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+				return nil, fmt.Errorf("outbox sink %q needs a host and a subject", s)
+			}
+			sinks = append(sinks, NewNATSSink(u.Host, strings.Trim(u.Path, "/")))
+		case strings.HasPrefix(s, "kafka+http://"), strings.HasPrefix(s, "kafka+https://"):
+			u, err := url.Parse(strings.TrimPrefix(s, "kafka+"))
+			if err != nil || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+				return nil, fmt.Errorf("outbox sink %q needs a host and a topic", s)
+			}
+			topic := strings.Trim(u.Path, "/")
+			u.Path = "/topics/" + topic
+			sinks = append(sinks, NewKafkaRESTSink(u.String()))
+		default:
+			return nil, fmt.Errorf("outbox sink %q is not supported", s)
+		}
+	}
+	return sinks, nil
+}
+
+// WriterSink writes every message as a line of JSON.
+type WriterSink struct {
+	label string
+	mu    sync.Mutex
+	w     io.Writer
+}
+
+func NewWriterSink(label string, w io.Writer) *WriterSink {
+	return &WriterSink{label: label, w: w}
+}
+
+func (s *WriterSink) Name() string {
+	return s.label
+}
+
+func (s *WriterSink) Publish(ctx context.Context, m Message) error {
+	line, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+// FileSink appends messages as JSON lines to a file and syncs it after every
+// message.
+type FileSink struct {
+	path string
+	mu   sync.Mutex
+	f    *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{path: path, f: f}, nil
+}
+
+func (s *FileSink) Name() string {
+	return "file:" + s.path
+}
+
+func (s *FileSink) Publish(ctx context.Context, m Message) error {
+	line, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.f.Sync()
+}
+
+func (s *FileSink) Close() error {
+	return s.f.Close()
+}
+
+// NATSSink publishes to a NATS server with the core text protocol, on the
+// subject followed by the event name, e.g. expenses.expense.created. Every
+// PUB is followed by a PING so a message only counts as published once the
+// server has read it.
+type NATSSink struct {
+	Addr    string
+	Subject string
+	Timeout time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func NewNATSSink(addr, subject string) *NATSSink {
+	return &NATSSink{Addr: addr, Subject: subject, Timeout: 5 * time.Second}
+}
+
+func (s *NATSSink) Name() string {
+	return "nats://" + s.Addr + "/" + s.Subject
+}
+
+func (s *NATSSink) connect(ctx context.Context) error {
+	d := net.Dialer{Timeout: s.Timeout}
+	conn, err := d.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(s.Timeout))
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("nats: unexpected greeting %q: %v", strings.TrimSpace(line), err)
+	}
+	if _, err := io.WriteString(conn, `CONNECT {"verbose":false,"pedantic":false,"name":"expense-outbox"}`+"\r\n"); err != nil {
+		conn.Close()
+		return err
+	}
+	s.conn, s.r = conn, r
+	return nil
+}
+
+func (s *NATSSink) Publish(ctx context.Context, m Message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		if err := s.connect(ctx); err != nil {
+			return err
+		}
+	}
+	if err := s.publish(s.Subject+"."+m.Event, data); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *NATSSink) publish(subject string, data []byte) error {
+	s.conn.SetDeadline(time.Now().Add(s.Timeout))
+	var buf bytes.Buffer
+	buf.WriteString("PUB " + subject + " " + strconv.Itoa(len(data)) + "\r\n")
+	buf.Write(data)
+	buf.WriteString("\r\nPING\r\n")
+	if _, err := s.conn.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := io.WriteString(s.conn, "PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New("nats: " + line)
+		}
+	}
+}
+
+func (s *NATSSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// KafkaRESTSink produces to a topic through a Kafka REST proxy (v2 API). The
+// expense ID is the record key so every event of an expense lands on the
+// same partition, in order.
+type KafkaRESTSink struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewKafkaRESTSink(topicURL string) *KafkaRESTSink {
+	return &KafkaRESTSink{URL: topicURL, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *KafkaRESTSink) Name() string {
+	return "kafka+" + s.URL
+}
+
+type kafkaRecord struct {
+	Key   string  `json:"key"`
+	Value Message `json:"value"`
+}
+
+type kafkaResponse struct {
+	Offsets []struct {
+		Partition int     `json:"partition"`
+		Offset    int64   `json:"offset"`
+		ErrorCode *int    `json:"error_code"`
+		Error     *string `json:"error"`
+	} `json:"offsets"`
+}
+
+func (s *KafkaRESTSink) Publish(ctx context.Context, m Message) error {
+	body, err := json.Marshal(map[string][]kafkaRecord{"records": {{Key: strconv.Itoa(m.ExpenseID), Value: m}}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kafka rest proxy: %s", resp.Status)
+	}
+	result := kafkaResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	for _, o := range result.Offsets {
+		if o.Error != nil {
+			return fmt.Errorf("kafka rest proxy: %s", *o.Error)
+		}
+	}
+	return nil
+}
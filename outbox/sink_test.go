@@ -0,0 +1,149 @@
+//go:build unit
+
+package outbox
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var message = Message{ID: 1, Event: "expense.created", ExpenseID: 4, OccurredAt: now, Data: []byte(`{"id":4}`)}
+
+const messageJSON = `{"id":1,"event":"expense.created","expense_id":4,"occurred_at":"2026-05-01T09:00:00Z","data":{"id":4}}`
+
+func TestWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewWriterSink("stdout", &buf).Publish(context.Background(), message); err != nil {
+		t.Errorf("should not return error but it got %v", err)
+	}
+	if buf.String() != messageJSON+"\n" {
+		t.Errorf("output was not expected got: %s", buf.String())
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	sink.Publish(context.Background(), message)
+	sink.Publish(context.Background(), message)
+
+	data, _ := os.ReadFile(path)
+	if string(data) != messageJSON+"\n"+messageJSON+"\n" {
+		t.Errorf("file was not expected got: %s", data)
+	}
+}
+
+// natsServer is a stand-in NATS server that records every PUB and answers
+// PINGs.
+func natsServer(t *testing.T) (string, chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	got := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "INFO {\"server_id\":\"test\"}\r\n")
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch fields := strings.Fields(line); fields[0] {
+			case "PUB":
+				n, _ := strconv.Atoi(fields[2])
+				payload := make([]byte, n+2)
+				io.ReadFull(r, payload)
+				got <- fields[1] + " " + string(payload[:n])
+			case "PING":
+				io.WriteString(conn, "PONG\r\n")
+			}
+		}
+	}()
+	return ln.Addr().String(), got
+}
+
+func TestNATSSink(t *testing.T) {
+	addr, got := natsServer(t)
+	sink := NewNATSSink(addr, "expenses")
+	defer sink.Close()
+	if err := sink.Publish(context.Background(), message); err != nil {
+		t.Errorf("should not return error but it got %v", err)
+	}
+	if msg := <-got; msg != "expenses.expense.created "+messageJSON {
+		t.Errorf("message was not expected got: %s", msg)
+	}
+}
+
+func TestKafkaRESTSink(t *testing.T) {
+	t.Run("Kafka sink should key records by expense", func(t *testing.T) {
+		var body map[string][]struct {
+			Key   string          `json:"key"`
+			Value json.RawMessage `json:"value"`
+		}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/topics/expenses" || r.Header.Get("Content-Type") != "application/vnd.kafka.json.v2+json" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			io.WriteString(w, `{"offsets":[{"partition":0,"offset":3,"error_code":null,"error":null}]}`)
+		}))
+		defer srv.Close()
+
+		sinks, err := ParseSinks("kafka+" + srv.URL + "/expenses")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sinks[0].Publish(context.Background(), message); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if len(body["records"]) != 1 || body["records"][0].Key != "4" || string(body["records"][0].Value) != messageJSON {
+			t.Errorf("records were not expected got: %+v", body)
+		}
+	})
+
+	t.Run("Kafka sink should fail on a record error", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, `{"offsets":[{"partition":null,"offset":null,"error_code":50002,"error":"broker unavailable"}]}`)
+		}))
+		defer srv.Close()
+
+		if err := NewKafkaRESTSink(srv.URL+"/topics/expenses").Publish(context.Background(), message); err == nil {
+			t.Errorf("should return error")
+		}
+	})
+}
+
+func TestParseSinks(t *testing.T) {
+	sinks, err := ParseSinks("stdout, nats://localhost:4222/expenses")
+	if err != nil || len(sinks) != 2 || sinks[1].Name() != "nats://localhost:4222/expenses" {
+		t.Errorf("sinks were not expected got: %v %v", sinks, err)
+	}
+	for _, spec := range []string{"redis://localhost", "nats://localhost:4222", "kafka+http://localhost:8082"} {
+		if _, err := ParseSinks(spec); err == nil {
+			t.Errorf("%s should return error", spec)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultGapTimeout = time.Minute
+	maxGaps           = 1000
+)
+
+// Tail hands every event written to the outbox to a sink, whether or not
+// this process holds the relay lock, so in-process sinks such as the stream
+// hub see the same events on every replica. IDs are taken when an event is
+// written, not when it commits, so an event may show up after a higher one
+// was read. Tail keeps looking for the IDs it skipped until GapTimeout, after
+// which they are taken to be rolled back writes. The sink must tolerate
+// duplicates and events slightly out of order.
+type Tail struct {
+	DB         *sql.DB
+	Sink       Sink
+	Interval   time.Duration
+	BatchSize  int
+	GapTimeout time.Duration
+	Now        func() time.Time
+
+	started bool
+	last    int64
+	gaps    map[int64]time.Time
+}
+
+func NewTail(db *sql.DB, interval time.Duration, sink Sink) *Tail {
+	return &Tail{DB: db, Sink: sink, Interval: interval, BatchSize: defaultBatchSize, GapTimeout: defaultGapTimeout, Now: time.Now, gaps: map[int64]time.Time{}}
+}
+
+// Run polls the outbox immediately and then on every tick until ctx is
+// cancelled.
+func (t *Tail) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := t.Poll(ctx); err != nil {
+			log.Errorf("Tail outbox error: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll hands the events written since the last poll, and any skipped ones
+// that have committed since, to the sink and returns how many it read. The
+// first poll only notes where the outbox ends, so events written before the
+// process started are not handed over.
+func (t *Tail) Poll(ctx context.Context) (int, error) {
+	if !t.started {
+		if err := t.DB.QueryRowContext(ctx, "SELECT coalesce(max(id), 0) FROM outbox").Scan(&t.last); err != nil {
+			return 0, err
+		}
+		t.started = true
+		return 0, nil
+	}
+	now := t.Now()
+	for id, skipped := range t.gaps {
+		if now.Sub(skipped) > t.GapTimeout {
+			delete(t.gaps, id)
+		}
+	}
+	total := 0
+	for {
+		messages, err := t.read(ctx)
+		if err != nil {
+			return total, err
+		}
+		for _, m := range messages {
+			if m.ID > t.last {
+				for id := t.last + 1; id < m.ID && len(t.gaps) < maxGaps; id++ {
+					t.gaps[id] = now
+				}
+				t.last = m.ID
+			} else {
+				delete(t.gaps, m.ID)
+			}
+			// The sink only sees events once they have committed, so a
+			// failure would not go away by trying again.
+			if err := t.Sink.Publish(ctx, m); err != nil {
+				log.Errorf("Publish outbox event %d to %s error: %v", m.ID, t.Sink.Name(), err)
+			}
+		}
+		total += len(messages)
+		if len(messages) < t.BatchSize || ctx.Err() != nil {
+			return total, nil
+		}
+	}
+}
+
+// read returns the next batch of events after the last one read, together
+// with any skipped ones that have committed since.
+func (t *Tail) read(ctx context.Context) ([]Message, error) {
+	gaps := make([]int64, 0, len(t.gaps))
+	for id := range t.gaps {
+		gaps = append(gaps, id)
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+	rows, err := t.DB.QueryContext(ctx, "SELECT id, event, expense_id, created_at, payload FROM outbox WHERE id > $1 OR id = ANY($2) ORDER BY id ASC LIMIT $3", t.last, pq.Array(gaps), t.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	messages := []Message{}
+	for rows.Next() {
+		m := Message{}
+		var payload []byte
+		if err := rows.Scan(&m.ID, &m.Event, &m.ExpenseID, &m.OccurredAt, &payload); err != nil {
+			return nil, err
+		}
+		m.Data = payload
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
@@ -0,0 +1,106 @@
+//go:build unit
+
+package outbox
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func expectTail(mock sqlmock.Sqlmock, last int64, gaps []int64, rows ...[]driver.Value) {
+	result := sqlmock.NewRows([]string{"id", "event", "expense_id", "created_at", "payload"})
+	for _, r := range rows {
+		result.AddRow(r...)
+	}
+	mock.ExpectQuery("SELECT (.+) FROM outbox WHERE id > (.+) OR id = ANY").
+		WithArgs(last, pq.Array(gaps), defaultBatchSize).
+		WillReturnRows(result)
+}
+
+func TestTailPoll(t *testing.T) {
+	t.Run("Tail should start at the end of the outbox", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT coalesce\\(max\\(id\\), 0\\) FROM outbox").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(4))
+		expectTail(mock, 4, []int64{},
+			[]driver.Value{5, "expense.created", 4, now, []byte(`{}`)})
+
+		sink := &memorySink{}
+		tail := NewTail(db, time.Second, sink)
+		if n, err := tail.Poll(context.Background()); err != nil || n != 0 {
+			t.Errorf("should read nothing but it got %d %v", n, err)
+		}
+		if n, err := tail.Poll(context.Background()); err != nil || n != 1 {
+			t.Errorf("should read 1 but it got %d %v", n, err)
+		}
+		if len(sink.got) != 1 || sink.got[0] != 5 {
+			t.Errorf("events were not expected got: %v", sink.got)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Tail should pick up an event that commits after a later one", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT coalesce").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(0))
+		expectTail(mock, 0, []int64{},
+			[]driver.Value{1, "expense.created", 4, now, []byte(`{}`)},
+			[]driver.Value{3, "expense.created", 5, now, []byte(`{}`)})
+		expectTail(mock, 3, []int64{2},
+			[]driver.Value{2, "expense.created", 6, now, []byte(`{}`)})
+		expectTail(mock, 3, []int64{})
+
+		sink := &memorySink{}
+		tail := NewTail(db, time.Second, sink)
+		tail.Now = func() time.Time { return now }
+		for i := 0; i < 4; i++ {
+			if _, err := tail.Poll(context.Background()); err != nil {
+				t.Errorf("should not return error but it got %v", err)
+			}
+		}
+		if len(sink.got) != 3 || sink.got[0] != 1 || sink.got[1] != 3 || sink.got[2] != 2 {
+			t.Errorf("events were not expected got: %v", sink.got)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Tail should stop looking for a skipped event after the timeout", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT coalesce").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(0))
+		expectTail(mock, 0, []int64{},
+			[]driver.Value{2, "expense.created", 4, now, []byte(`{}`)})
+		expectTail(mock, 2, []int64{})
+
+		clock := now
+		tail := NewTail(db, time.Second, &memorySink{})
+		tail.Now = func() time.Time { return clock }
+		tail.Poll(context.Background())
+		tail.Poll(context.Background())
+		clock = clock.Add(2 * defaultGapTimeout)
+		if _, err := tail.Poll(context.Background()); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
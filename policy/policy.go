@@ -0,0 +1,209 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Version is the policy file format this package understands.
+const Version = 1
+
+const (
+	KindMaxAmount      = "max_amount"
+	KindReceipt        = "receipt_required"
+	KindBannedKeywords = "banned_keywords"
+	KindWeekend        = "weekend"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+const dateLayout = "2006-01-02"
+
+// Rule is a single policy. Tags limits the rule to expenses carrying at least
+// one of them; an empty list applies it to every expense. Limit is the maximum
+// amount for max_amount and the amount above which receipt_required and
+// weekend rules apply.
+type Rule struct {
+	Name     string   `json:"name"`
+	Kind     string   `json:"kind"`
+	Severity string   `json:"severity"`
+	Tags     []string `json:"tags,omitempty"`
+	Limit    float64  `json:"limit,omitempty"`
+	Keywords []string `json:"keywords,omitempty"`
+	Message  string   `json:"message,omitempty"`
+}
+
+// Config is the policy file. Version must match the Version this build
+// understands so an older server never half-applies a newer file.
+type Config struct {
+	Version int    `json:"version"`
+	Rules   []Rule `json:"rules"`
+}
+
+// Subject is what a policy sees of an expense. Receipts is the number of
+// attachments already stored for it. New marks an expense being created,
+// which cannot have a receipt yet, so receipt rules only warn about it until
+// it is updated or submitted.
+type Subject struct {
+	Title    string
+	Amount   float64
+	Tags     []string
+	Date     string
+	Receipts int
+	New      bool
+}
+
+type Finding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+type Result struct {
+	Violations []Finding
+	Warnings   []Finding
+}
+
+func (r Result) Failed() bool {
+	return len(r.Violations) > 0
+}
+
+// LoadConfig reads and validates a JSON policy file. An empty path returns a
+// config without rules.
+func LoadConfig(path string) (Config, error) {
+	cfg := Config{Version: Version}
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.Validate()
+}
+
+func (c Config) Validate() error {
+	if c.Version != Version {
+		return fmt.Errorf("policy version %d is not supported, expected %d", c.Version, Version)
+	}
+	names := map[string]bool{}
+	for i, r := range c.Rules {
+		if r.Name == "" {
+			return fmt.Errorf("rule %d has no name", i)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("rule %q is defined twice", r.Name)
+		}
+		names[r.Name] = true
+		if r.Severity != SeverityError && r.Severity != SeverityWarning {
+			return fmt.Errorf("rule %q has invalid severity %q", r.Name, r.Severity)
+		}
+		switch r.Kind {
+		case KindMaxAmount:
+			if r.Limit <= 0 {
+				return fmt.Errorf("rule %q needs a positive limit", r.Name)
+			}
+		case KindBannedKeywords:
+			if len(r.Keywords) == 0 {
+				return fmt.Errorf("rule %q needs keywords", r.Name)
+			}
+		case KindReceipt, KindWeekend:
+		default:
+			return fmt.Errorf("rule %q has unknown kind %q", r.Name, r.Kind)
+		}
+	}
+	return nil
+}
+
+// NeedsReceipts reports whether evaluating s depends on its receipt count, so
+// callers only look attachments up when a rule will read them.
+func (c Config) NeedsReceipts(s Subject) bool {
+	for _, r := range c.Rules {
+		if r.Kind == KindReceipt && !s.New && r.applies(s) && s.Amount > r.Limit {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate runs every rule against s. Rules with error severity produce
+// violations, the rest warnings. Receipt rules always warn about new
+// expenses.
+func (c Config) Evaluate(s Subject) Result {
+	result := Result{}
+	for _, r := range c.Rules {
+		if !r.applies(s) {
+			continue
+		}
+		message, broken := r.check(s)
+		if !broken {
+			continue
+		}
+		if r.Message != "" {
+			message = r.Message
+		}
+		severity := r.Severity
+		if s.New && r.Kind == KindReceipt {
+			severity = SeverityWarning
+		}
+		f := Finding{Rule: r.Name, Severity: severity, Message: message}
+		if severity == SeverityError {
+			result.Violations = append(result.Violations, f)
+		} else {
+			result.Warnings = append(result.Warnings, f)
+		}
+	}
+	return result
+}
+
+func (r Rule) applies(s Subject) bool {
+	if len(r.Tags) == 0 {
+		return true
+	}
+	for _, want := range r.Tags {
+		for _, tag := range s.Tags {
+			if strings.EqualFold(want, tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r Rule) check(s Subject) (string, bool) {
+	switch r.Kind {
+	case KindMaxAmount:
+		if s.Amount > r.Limit {
+			return fmt.Sprintf("Amount %.2f exceeds the limit of %.2f", s.Amount, r.Limit), true
+		}
+	case KindReceipt:
+		if s.Amount > r.Limit && s.Receipts == 0 {
+			return fmt.Sprintf("A receipt is required for amounts above %.2f", r.Limit), true
+		}
+	case KindBannedKeywords:
+		title := strings.ToLower(s.Title)
+		for _, keyword := range r.Keywords {
+			if strings.Contains(title, strings.ToLower(keyword)) {
+				return fmt.Sprintf("Title contains banned keyword %q", keyword), true
+			}
+		}
+	case KindWeekend:
+		date, err := time.Parse(dateLayout, s.Date)
+		if err != nil || s.Amount <= r.Limit {
+			return "", false
+		}
+		if day := date.Weekday(); day == time.Saturday || day == time.Sunday {
+			return fmt.Sprintf("Expense is dated on a %s", day), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,122 @@
+//go:build unit
+
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var testConfig = Config{
+	Version: Version,
+	Rules: []Rule{
+		{Name: "taxi-limit", Kind: KindMaxAmount, Severity: SeverityError, Tags: []string{"taxi"}, Limit: 500},
+		{Name: "receipt", Kind: KindReceipt, Severity: SeverityError, Limit: 1000},
+		{Name: "no-alcohol", Kind: KindBannedKeywords, Severity: SeverityError, Keywords: []string{"beer", "wine"}, Message: "Alcohol is not reimbursable"},
+		{Name: "weekend", Kind: KindWeekend, Severity: SeverityWarning},
+	},
+}
+
+func TestEvaluate(t *testing.T) {
+	cases := []struct {
+		name       string
+		subject    Subject
+		violations []string
+		warnings   []string
+	}{
+		{"Compliant expense should pass", Subject{Title: "Taxi", Amount: 100, Tags: []string{"taxi"}, Date: "2026-01-05"}, nil, nil},
+		{"Taxi over limit should fail", Subject{Title: "Taxi", Amount: 10000, Tags: []string{"TAXI"}, Receipts: 1}, []string{"taxi-limit"}, nil},
+		{"Limit should only apply to tagged expenses", Subject{Title: "Hotel", Amount: 900, Tags: []string{"travel"}}, nil, nil},
+		{"Large expense without receipt should fail", Subject{Title: "Laptop", Amount: 2000}, []string{"receipt"}, nil},
+		{"Large expense with receipt should pass", Subject{Title: "Laptop", Amount: 2000, Receipts: 1}, nil, nil},
+		{"New large expense without receipt should only warn", Subject{Title: "Laptop", Amount: 2000, New: true}, nil, []string{"receipt"}},
+		{"New taxi over limit should still fail", Subject{Title: "Taxi", Amount: 10000, Tags: []string{"taxi"}, New: true}, []string{"taxi-limit"}, []string{"receipt"}},
+		{"Banned keyword should fail case-insensitively", Subject{Title: "Team BEER night", Amount: 50}, []string{"no-alcohol"}, nil},
+		{"Weekend expense should warn", Subject{Title: "Lunch", Amount: 50, Date: "2026-01-03"}, nil, []string{"weekend"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := testConfig.Evaluate(tc.subject)
+			if got := names(result.Violations); !reflect.DeepEqual(got, tc.violations) {
+				t.Errorf("violations should be %v but it got %v", tc.violations, got)
+			}
+			if got := names(result.Warnings); !reflect.DeepEqual(got, tc.warnings) {
+				t.Errorf("warnings should be %v but it got %v", tc.warnings, got)
+			}
+			if result.Failed() != (len(tc.violations) > 0) {
+				t.Errorf("failed should be %v", len(tc.violations) > 0)
+			}
+		})
+	}
+
+	t.Run("Custom message should replace the default", func(t *testing.T) {
+		result := testConfig.Evaluate(Subject{Title: "wine"})
+		if len(result.Violations) != 1 || result.Violations[0].Message != "Alcohol is not reimbursable" {
+			t.Errorf("violation was not expected got: %+v", result.Violations)
+		}
+	})
+}
+
+func TestNeedsReceipts(t *testing.T) {
+	if testConfig.NeedsReceipts(Subject{Amount: 10}) {
+		t.Errorf("small expense should not need receipts")
+	}
+	if !testConfig.NeedsReceipts(Subject{Amount: 1001}) {
+		t.Errorf("large expense should need receipts")
+	}
+	if testConfig.NeedsReceipts(Subject{Amount: 1001, New: true}) {
+		t.Errorf("new expense should not need receipts")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	write := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "policy.json")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("Empty path should load no rules", func(t *testing.T) {
+		cfg, err := LoadConfig("")
+		if err != nil || len(cfg.Rules) != 0 {
+			t.Errorf("should load empty config but it got %+v %v", cfg, err)
+		}
+	})
+
+	t.Run("Valid file should load rules", func(t *testing.T) {
+		cfg, err := LoadConfig(write(t, `{"version":1,"rules":[{"name":"cap","kind":"max_amount","severity":"error","limit":10}]}`))
+		if err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if len(cfg.Rules) != 1 || cfg.Rules[0].Limit != 10 {
+			t.Errorf("rules were not expected got: %+v", cfg.Rules)
+		}
+	})
+
+	invalid := map[string]string{
+		"Unsupported version should got error": `{"version":2,"rules":[]}`,
+		"Unknown kind should got error":        `{"version":1,"rules":[{"name":"a","kind":"magic","severity":"error"}]}`,
+		"Invalid severity should got error":    `{"version":1,"rules":[{"name":"a","kind":"weekend","severity":"fatal"}]}`,
+		"Duplicate name should got error":      `{"version":1,"rules":[{"name":"a","kind":"weekend","severity":"warning"},{"name":"a","kind":"weekend","severity":"warning"}]}`,
+		"Missing limit should got error":       `{"version":1,"rules":[{"name":"a","kind":"max_amount","severity":"error"}]}`,
+	}
+	for name, content := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadConfig(write(t, content)); err == nil {
+				t.Errorf("should return error")
+			}
+		})
+	}
+}
+
+func names(findings []Finding) []string {
+	var out []string
+	for _, f := range findings {
+		out = append(out, f.Rule)
+	}
+	return out
+}
@@ -0,0 +1,35 @@
+package recurring
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/auth"
+	"github.com/phanbanchong/assessment/expense"
+)
+
+func (h *handler) CreateTemplateHandler(c echo.Context) error {
+	t := Template{}
+	if err := c.Bind(&t); err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: err.Error()})
+	}
+	if t.ID != 0 {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "Field ID is invalid"})
+	}
+	t.Generated = 0
+	t.Owner = audit.ActorFrom(c).Name
+	t.ReportingCurrency = expense.ReportingCurrency()
+	if currency := auth.UserFrom(c).Currency; currency != "" {
+		t.ReportingCurrency = currency
+	}
+	if err := t.schedule(); err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "Schedule is invalid: " + err.Error()})
+	}
+
+	t, err := CreateTemplate(h.DB, t)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: err.Error()})
+	}
+	return c.JSON(http.StatusCreated, t)
+}
@@ -0,0 +1,64 @@
+//go:build unit
+
+package recurring
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+func TestCreateTemplateHandler(t *testing.T) {
+	t.Run("Create recurring expense should be success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("INSERT INTO recurring_expenses").
+			WithArgs("rent", 500.0, "flat", pq.Array([]string{"home"}), "FREQ=MONTHLY;BYMONTHDAY=1", "2026-01-15", "2026-02-01", "anonymous", "THB").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).FromCSVString("1"))
+
+		h := NewApplication(db)
+		e := echo.New()
+		body := `{"title":"rent","amount":500,"note":"flat","tags":["home"],"rule":"FREQ=MONTHLY;BYMONTHDAY=1","start_date":"2026-01-15"}`
+		req := httptest.NewRequest(http.MethodPost, "/recurring-expenses", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err = h.CreateTemplateHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"id":1,"title":"rent","amount":500,"note":"flat","tags":["home"],"rule":"FREQ=MONTHLY;BYMONTHDAY=1","start_date":"2026-01-15","next_date":"2026-02-01","generated":0,"owner":"anonymous","reporting_currency":"THB"}` + "\n"
+		if rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %s", rec.Body.String())
+		}
+	})
+
+	t.Run("Create recurring expense with invalid rule should got error", func(t *testing.T) {
+		h := NewApplication(nil)
+		e := echo.New()
+		body := `{"title":"rent","amount":500,"rule":"FREQ=HOURLY","start_date":"2026-01-15"}`
+		req := httptest.NewRequest(http.MethodPost, "/recurring-expenses", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := h.CreateTemplateHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if c.Response().Status != http.StatusBadRequest {
+			t.Errorf("should status bad request but it got %v", c.Response().Status)
+		}
+		want := `{"message":"Schedule is invalid: unsupported FREQ \"HOURLY\""}` + "\n"
+		if rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %s", rec.Body.String())
+		}
+	})
+}
@@ -0,0 +1,196 @@
+package recurring
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/expense"
+)
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func InitDB(db *sql.DB) error {
+	commands := []string{
+		"CREATE TABLE IF NOT EXISTS recurring_expenses ( id SERIAL PRIMARY KEY, title TEXT, amount FLOAT, note TEXT, tags TEXT[], rule TEXT NOT NULL, start_date DATE NOT NULL, next_date DATE, generated INT NOT NULL DEFAULT 0);",
+		"CREATE INDEX IF NOT EXISTS recurring_expenses_next_date_idx ON recurring_expenses (next_date);",
+		"ALTER TABLE recurring_expenses ADD COLUMN IF NOT EXISTS owner TEXT;",
+		"ALTER TABLE recurring_expenses ADD COLUMN IF NOT EXISTS reporting_currency TEXT;",
+	}
+	for _, command := range commands {
+		if _, err := db.Exec(command); err != nil {
+			return err
+		}
+		log.Printf("Executed: %s", command)
+	}
+	return nil
+}
+
+func nullDate(date string) any {
+	return nullString(date)
+}
+
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func scanTemplate(row scanner) (Template, error) {
+	t := Template{}
+	var start time.Time
+	var next sql.NullTime
+	var owner, reportingCurrency sql.NullString
+	err := row.Scan(&t.ID, &t.Title, &t.Amount, &t.Note, pq.Array(&t.Tags), &t.Rule, &start, &next, &t.Generated, &owner, &reportingCurrency)
+	t.StartDate = start.Format(expense.DateLayout)
+	t.Owner, t.ReportingCurrency = owner.String, reportingCurrency.String
+	if next.Valid {
+		t.NextDate = next.Time.Format(expense.DateLayout)
+	}
+	return t, err
+}
+
+func GetTemplateByID(db *sql.DB, id int) (Template, error) {
+	row := db.QueryRow("SELECT id, title, amount, note, tags, rule, start_date, next_date, generated, owner, reporting_currency FROM recurring_expenses WHERE id = $1", id)
+	return scanTemplate(row)
+}
+
+func GetTemplates(db *sql.DB) ([]Template, error) {
+	templates := []Template{}
+	rows, err := db.Query("SELECT id, title, amount, note, tags, rule, start_date, next_date, generated, owner, reporting_currency FROM recurring_expenses ORDER BY id ASC")
+	if err != nil {
+		return templates, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return templates, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+func CreateTemplate(db *sql.DB, t Template) (Template, error) {
+	row := db.QueryRow("INSERT INTO recurring_expenses (title, amount, note, tags, rule, start_date, next_date, owner, reporting_currency) values ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id",
+		t.Title, t.Amount, t.Note, pq.Array(t.Tags), t.Rule, t.StartDate, nullDate(t.NextDate), nullString(t.Owner), nullString(t.ReportingCurrency))
+	if err := row.Scan(&t.ID); err != nil {
+		log.Errorf("Insert recurring expense error: %v", err)
+		return t, err
+	}
+	return t, nil
+}
+
+func UpdateTemplate(db *sql.DB, t Template) (Template, error) {
+	res, err := db.Exec("UPDATE recurring_expenses SET title=$2, amount=$3, note=$4, tags=$5, rule=$6, start_date=$7, next_date=$8 WHERE id = $1",
+		t.ID, t.Title, t.Amount, t.Note, pq.Array(t.Tags), t.Rule, t.StartDate, nullDate(t.NextDate))
+	if err != nil {
+		log.Errorf("Update recurring expense error: %v", err)
+		return t, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return t, sql.ErrNoRows
+	}
+	return t, nil
+}
+
+// DeleteTemplate removes a template and detaches the expenses it generated.
+func DeleteTemplate(db *sql.DB, id int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM recurring_expenses WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec("UPDATE expenses SET recurring_id = NULL WHERE recurring_id = $1", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GeneratorActor is recorded in the audit trail for expenses generated from
+// templates created before templates had an owner.
+var GeneratorActor = audit.Actor{Name: "recurring"}
+
+// actor is who the expenses of t are created for.
+func (t Template) actor() audit.Actor {
+	if t.Owner == "" {
+		return GeneratorActor
+	}
+	return audit.Actor{Name: t.Owner}
+}
+
+// GenerateDue materialises every occurrence due on or before today, catching
+// up on any missed while the server was down. Templates are locked with SKIP
+// LOCKED so replicas never work on the same template at once, which also
+// makes the check for an already generated occurrence safe. Expenses are
+// created as the template owner would create them: converted to their
+// reporting currency, audited and published to the outbox.
+func GenerateDue(ctx context.Context, db *sql.DB, today time.Time) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, title, amount, note, tags, rule, start_date, next_date, generated, owner, reporting_currency FROM recurring_expenses WHERE next_date <= $1 ORDER BY id FOR UPDATE SKIP LOCKED", truncate(today))
+	if err != nil {
+		return 0, err
+	}
+	templates := []Template{}
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		templates = append(templates, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, t := range templates {
+		if t.ReportingCurrency == "" {
+			t.ReportingCurrency = expense.ReportingCurrency()
+		}
+		for t.NextDate != "" && t.NextDate <= truncate(today).Format(expense.DateLayout) {
+			var exists bool
+			if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM expenses WHERE recurring_id = $1 AND date = $2)", t.ID, t.NextDate).Scan(&exists); err != nil {
+				return 0, err
+			}
+			if !exists {
+				exp := expense.Expense{Title: t.Title, Amount: t.Amount, Note: t.Note, Tags: t.Tags, Date: t.NextDate, RecurringID: t.ID, ReportingCurrency: t.ReportingCurrency}
+				if _, err := expense.CreateExpenseAudited(tx, t.actor(), exp); err != nil {
+					return 0, err
+				}
+				created++
+			}
+			t.Generated++
+			if err := t.schedule(); err != nil {
+				return 0, err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE recurring_expenses SET next_date=$2, generated=$3 WHERE id = $1", t.ID, nullDate(t.NextDate), t.Generated); err != nil {
+			return 0, err
+		}
+	}
+	return created, tx.Commit()
+}
@@ -0,0 +1,75 @@
+//go:build unit
+
+package recurring
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/expense"
+)
+
+func TestGenerateDue(t *testing.T) {
+	t.Run("Generate should catch up missed occurrences", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT (.+) FROM recurring_expenses WHERE next_date <= (.+) FOR UPDATE SKIP LOCKED").
+			WithArgs(date("2026-03-05")).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags", "rule", "start_date", "next_date", "generated", "owner", "reporting_currency"}).
+				AddRow(7, "rent", 500.0, "flat", pq.Array([]string{"home"}), "FREQ=MONTHLY;BYMONTHDAY=1;COUNT=3", date("2026-01-01"), date("2026-02-01"), 1, "alice", "THB"))
+		mock.ExpectQuery("SELECT EXISTS").WithArgs(7, "2026-02-01").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectQuery("INSERT INTO expenses").
+			WithArgs("rent", 500.0, "flat", pq.Array([]string{"home"}), "2026-02-01", nil, "alice", "THB", 500.0, "THB", 1.0, nil, 7).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(31))
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT hash FROM expense_audit").WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+		mock.ExpectQuery("INSERT INTO expense_audit").
+			WithArgs(31, "create", "alice", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO outbox").WithArgs(expense.EventCreated, 31, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO expense_changes").WithArgs(31, sqlmock.AnyArg(), false, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT EXISTS").WithArgs(7, "2026-03-01").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectExec("UPDATE recurring_expenses SET next_date").
+			WithArgs(7, nil, 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		n, err := GenerateDue(context.Background(), db, date("2026-03-05"))
+		if err != nil {
+			t.Errorf("error was not expected while generating expenses: %s", err)
+		}
+		if n != 1 {
+			t.Errorf("should create 1 expense but it got %d", n)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Generate with nothing due should only commit", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT (.+) FROM recurring_expenses").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags", "rule", "start_date", "next_date", "generated", "owner", "reporting_currency"}))
+		mock.ExpectCommit()
+
+		if _, err := GenerateDue(context.Background(), db, date("2026-03-05")); err != nil {
+			t.Errorf("error was not expected while generating expenses: %s", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
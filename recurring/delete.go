@@ -0,0 +1,25 @@
+package recurring
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/expense"
+)
+
+func (h *handler) DeleteTemplateHandler(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "ID is invalid"})
+	}
+	switch err := DeleteTemplate(h.DB, id); err {
+	case nil:
+		return c.NoContent(http.StatusNoContent)
+	case sql.ErrNoRows:
+		return c.JSON(http.StatusNotFound, expense.Error{Message: "Recurring expense not found"})
+	default:
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: err.Error()})
+	}
+}
@@ -0,0 +1,40 @@
+package recurring
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/labstack/gommon/log"
+)
+
+type Generator struct {
+	DB       *sql.DB
+	Interval time.Duration
+	Now      func() time.Time
+}
+
+func NewGenerator(db *sql.DB, interval time.Duration) *Generator {
+	return &Generator{DB: db, Interval: interval, Now: time.Now}
+}
+
+// Run generates due expenses immediately and then on every tick until ctx is
+// cancelled.
+func (g *Generator) Run(ctx context.Context) {
+	ticker := time.NewTicker(g.Interval)
+	defer ticker.Stop()
+	for {
+		n, err := GenerateDue(ctx, g.DB, g.Now())
+		if err != nil {
+			log.Errorf("Generate recurring expenses error: %v", err)
+		} else if n > 0 {
+			log.Printf("Generated %d recurring expenses", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
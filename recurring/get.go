@@ -0,0 +1,34 @@
+package recurring
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/expense"
+)
+
+func (h *handler) GetTemplateHandler(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "ID is invalid"})
+	}
+	t, err := GetTemplateByID(h.DB, id)
+	switch err {
+	case sql.ErrNoRows:
+		return c.JSON(http.StatusNotFound, expense.Error{Message: "Recurring expense not found"})
+	case nil:
+		return c.JSON(http.StatusOK, t)
+	default:
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: "Unable to scan recurring expense:" + err.Error()})
+	}
+}
+
+func (h *handler) GetTemplatesHandler(c echo.Context) error {
+	templates, err := GetTemplates(h.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: "Unable to get recurring expenses from database:" + err.Error()})
+	}
+	return c.JSON(http.StatusOK, templates)
+}
@@ -0,0 +1,46 @@
+package recurring
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/expense"
+)
+
+func (h *handler) UpdateTemplateHandler(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "Field ID is invalid"})
+	}
+	current, err := GetTemplateByID(h.DB, id)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		return c.JSON(http.StatusNotFound, expense.Error{Message: "Recurring expense not found"})
+	default:
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: err.Error()})
+	}
+
+	t := Template{}
+	if err := c.Bind(&t); err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: err.Error()})
+	}
+	t.ID = id
+	t.Generated = current.Generated
+	t.Owner, t.ReportingCurrency = current.Owner, current.ReportingCurrency
+	if err := t.schedule(); err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "Schedule is invalid: " + err.Error()})
+	}
+
+	t, err = UpdateTemplate(h.DB, t)
+	switch err {
+	case nil:
+		return c.JSON(http.StatusOK, t)
+	case sql.ErrNoRows:
+		return c.JSON(http.StatusNotFound, expense.Error{Message: "Recurring expense not found"})
+	default:
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: err.Error()})
+	}
+}
@@ -0,0 +1,52 @@
+package recurring
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/phanbanchong/assessment/expense"
+)
+
+type handler struct {
+	DB *sql.DB
+}
+
+func NewApplication(db *sql.DB) *handler {
+	return &handler{db}
+}
+
+// Template generates an expense on every occurrence of its rule. Owner and
+// ReportingCurrency are those of the user who created it; the expenses it
+// generates are theirs.
+type Template struct {
+	ID                int      `json:"id"`
+	Title             string   `json:"title"`
+	Amount            float64  `json:"amount"`
+	Note              string   `json:"note"`
+	Tags              []string `json:"tags"`
+	Rule              string   `json:"rule"`
+	StartDate         string   `json:"start_date"`
+	NextDate          string   `json:"next_date,omitempty"`
+	Generated         int      `json:"generated"`
+	Owner             string   `json:"owner,omitempty"`
+	ReportingCurrency string   `json:"reporting_currency,omitempty"`
+}
+
+// schedule validates the template's rule and start date and fills in the
+// next due date from the number of expenses generated so far.
+func (t *Template) schedule() error {
+	rule, err := ParseRule(t.Rule)
+	if err != nil {
+		return err
+	}
+	start, err := time.Parse(expense.DateLayout, t.StartDate)
+	if err != nil {
+		return err
+	}
+	t.Rule = rule.String()
+	t.NextDate = ""
+	if next, ok := rule.Occurrence(start, t.Generated); ok {
+		t.NextDate = next.Format(expense.DateLayout)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package recurring
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/phanbanchong/assessment/expense"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+const untilLayout = "20060102"
+
+// Rule is the subset of an RFC 5545 RRULE supported for recurring expenses,
+// e.g. "FREQ=MONTHLY;BYMONTHDAY=1;COUNT=12".
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	ByMonthDay int
+	Until      time.Time
+	Count      int
+}
+
+func ParseRule(s string) (Rule, error) {
+	r := Rule{Interval: 1}
+	if strings.TrimSpace(s) == "" {
+		return r, errors.New("rule is empty")
+	}
+	for _, part := range strings.Split(strings.TrimPrefix(s, "RRULE:"), ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return r, fmt.Errorf("malformed part %q", part)
+		}
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = Frequency(strings.ToUpper(value))
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = strconv.Atoi(value)
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+		case "UNTIL":
+			r.Until, err = time.Parse(untilLayout, value)
+			if err != nil {
+				r.Until, err = time.Parse(expense.DateLayout, value)
+			}
+		default:
+			return r, fmt.Errorf("unsupported part %q", key)
+		}
+		if err != nil {
+			return r, fmt.Errorf("invalid %s: %q", key, value)
+		}
+	}
+	return r, r.validate()
+}
+
+func (r Rule) validate() error {
+	switch r.Freq {
+	case Daily, Weekly, Monthly:
+	case "":
+		return errors.New("FREQ is required")
+	default:
+		return fmt.Errorf("unsupported FREQ %q", r.Freq)
+	}
+	if r.Interval < 1 {
+		return errors.New("INTERVAL must be positive")
+	}
+	if r.ByMonthDay != 0 && r.Freq != Monthly {
+		return errors.New("BYMONTHDAY requires FREQ=MONTHLY")
+	}
+	if r.ByMonthDay < 0 || r.ByMonthDay > 31 {
+		return errors.New("BYMONTHDAY must be between 1 and 31")
+	}
+	if r.Count < 0 {
+		return errors.New("COUNT must be positive")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return errors.New("COUNT and UNTIL are mutually exclusive")
+	}
+	return nil
+}
+
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.ByMonthDay != 0 {
+		parts = append(parts, "BYMONTHDAY="+strconv.Itoa(r.ByMonthDay))
+	}
+	if r.Count != 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.Format(untilLayout))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Occurrence returns the n-th (zero-based) date of the schedule starting at
+// start, or false once the schedule has ended. A BYMONTHDAY past the end of a
+// month falls on that month's last day.
+func (r Rule) Occurrence(start time.Time, n int) (time.Time, bool) {
+	if n < 0 || (r.Count > 0 && n >= r.Count) {
+		return time.Time{}, false
+	}
+	start = truncate(start)
+	var occ time.Time
+	switch r.Freq {
+	case Daily:
+		occ = start.AddDate(0, 0, n*r.Interval)
+	case Weekly:
+		occ = start.AddDate(0, 0, 7*n*r.Interval)
+	case Monthly:
+		day := r.ByMonthDay
+		if day == 0 {
+			day = start.Day()
+		}
+		month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if dayOfMonth(month, day).Before(start) {
+			month = month.AddDate(0, 1, 0)
+		}
+		occ = dayOfMonth(month.AddDate(0, n*r.Interval, 0), day)
+	default:
+		return time.Time{}, false
+	}
+	if !r.Until.IsZero() && occ.After(r.Until) {
+		return time.Time{}, false
+	}
+	return occ, true
+}
+
+func dayOfMonth(month time.Time, day int) time.Time {
+	last := month.AddDate(0, 1, -1).Day()
+	if day > last {
+		day = last
+	}
+	return time.Date(month.Year(), month.Month(), day, 0, 0, 0, 0, time.UTC)
+}
+
+func truncate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,91 @@
+//go:build unit
+
+package recurring
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseRule(t *testing.T) {
+	t.Run("Parse monthly rule should be success", func(t *testing.T) {
+		r, err := ParseRule("FREQ=MONTHLY;BYMONTHDAY=31;COUNT=12")
+		if err != nil {
+			t.Fatalf("should not return error but it got %v", err)
+		}
+		if r.Freq != Monthly || r.ByMonthDay != 31 || r.Count != 12 || r.Interval != 1 {
+			t.Errorf("unexpected rule %+v", r)
+		}
+		if r.String() != "FREQ=MONTHLY;BYMONTHDAY=31;COUNT=12" {
+			t.Errorf("unexpected rule string %s", r.String())
+		}
+	})
+
+	invalid := []string{
+		"",
+		"FREQ=YEARLY",
+		"INTERVAL=2",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=DAILY;COUNT=2;UNTIL=20260101",
+		"FREQ=DAILY;BYDAY=MO",
+		"FREQ",
+	}
+	for _, rule := range invalid {
+		if _, err := ParseRule(rule); err == nil {
+			t.Errorf("rule %q should got error", rule)
+		}
+	}
+}
+
+func TestRuleOccurrence(t *testing.T) {
+	cases := []struct {
+		rule  string
+		start string
+		n     int
+		want  string
+	}{
+		{"FREQ=DAILY", "2026-01-30", 3, "2026-02-02"},
+		{"FREQ=WEEKLY;INTERVAL=2", "2026-01-01", 2, "2026-01-29"},
+		{"FREQ=MONTHLY", "2026-01-15", 1, "2026-02-15"},
+		{"FREQ=MONTHLY;BYMONTHDAY=31", "2026-01-01", 1, "2026-02-28"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1", "2026-01-15", 0, "2026-02-01"},
+		{"FREQ=MONTHLY;BYMONTHDAY=10;INTERVAL=3", "2026-01-10", 2, "2026-07-10"},
+	}
+	for _, c := range cases {
+		r, err := ParseRule(c.rule)
+		if err != nil {
+			t.Fatalf("should not return error but it got %v", err)
+		}
+		got, ok := r.Occurrence(date(c.start), c.n)
+		if !ok || !got.Equal(date(c.want)) {
+			t.Errorf("%s from %s #%d: want %s but it got %s", c.rule, c.start, c.n, c.want, got.Format("2006-01-02"))
+		}
+	}
+
+	t.Run("Occurrence after COUNT should end", func(t *testing.T) {
+		r, _ := ParseRule("FREQ=DAILY;COUNT=2")
+		if _, ok := r.Occurrence(date("2026-01-01"), 2); ok {
+			t.Errorf("should end after 2 occurrences")
+		}
+	})
+
+	t.Run("Occurrence after UNTIL should end", func(t *testing.T) {
+		r, _ := ParseRule("FREQ=WEEKLY;UNTIL=20260115")
+		if _, ok := r.Occurrence(date("2026-01-01"), 2); !ok {
+			t.Errorf("occurrence on UNTIL should be included")
+		}
+		if _, ok := r.Occurrence(date("2026-01-01"), 3); ok {
+			t.Errorf("occurrence after UNTIL should end")
+		}
+	})
+}
@@ -0,0 +1,300 @@
+package report
+
+import (
+	"database/sql"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/auth"
+	"github.com/phanbanchong/assessment/expense"
+)
+
+const reportColumns = "id, title, submitter, status, total, created_by, created_at, paid_at, closed_at, currency"
+
+func InitDB(db *sql.DB) error {
+	commands := []string{
+		"CREATE TABLE IF NOT EXISTS reports ( id SERIAL PRIMARY KEY, title TEXT, submitter TEXT NOT NULL, status TEXT NOT NULL DEFAULT 'open', total FLOAT NOT NULL, created_by TEXT NOT NULL, created_at TIMESTAMPTZ NOT NULL DEFAULT now(), paid_at TIMESTAMPTZ, closed_at TIMESTAMPTZ);",
+		"CREATE TABLE IF NOT EXISTS report_items ( report_id INT NOT NULL REFERENCES reports (id) ON DELETE CASCADE, expense_id INT NOT NULL REFERENCES expenses (id), title TEXT, date DATE, amount FLOAT NOT NULL, PRIMARY KEY (report_id, expense_id));",
+		"ALTER TABLE reports ADD COLUMN IF NOT EXISTS currency TEXT;",
+		"ALTER TABLE report_items ADD COLUMN IF NOT EXISTS currency TEXT;",
+		"ALTER TABLE report_items ADD COLUMN IF NOT EXISTS original_amount FLOAT;",
+		"ALTER TABLE report_items ADD COLUMN IF NOT EXISTS rate FLOAT;",
+	}
+	for _, command := range commands {
+		if _, err := db.Exec(command); err != nil {
+			return err
+		}
+		log.Printf("Executed: %s", command)
+	}
+	return nil
+}
+
+func scanReport(row interface{ Scan(...any) error }) (Report, error) {
+	r := Report{}
+	var title, currency sql.NullString
+	var paidAt, closedAt sql.NullTime
+	err := row.Scan(&r.ID, &title, &r.Submitter, &r.Status, &r.Total, &r.CreatedBy, &r.CreatedAt, &paidAt, &closedAt, &currency)
+	r.Title = title.String
+	r.Currency = currency.String
+	if paidAt.Valid {
+		r.PaidAt = &paidAt.Time
+	}
+	if closedAt.Valid {
+		r.ClosedAt = &closedAt.Time
+	}
+	return r, err
+}
+
+// roundCents keeps totals summed from floats at two decimals.
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// CreateReport bundles the approved expenses in req into an open report. All
+// of them must belong to the same submitter and reporting currency and none
+// may be in another report; the expenses are locked while the report exists.
+// The total is summed in the reporting currency, so expenses stored before
+// currencies were tracked only share a report with each other.
+func CreateReport(tx *sql.Tx, actor audit.Actor, req CreateRequest) (Report, error) {
+	if len(req.ExpenseIDs) == 0 {
+		return Report{}, &Error{http.StatusBadRequest, "Field expense_ids is required"}
+	}
+	expenses, err := expense.LockExpenses(tx, req.ExpenseIDs)
+	if err != nil {
+		return Report{}, err
+	}
+	found := map[int]expense.Expense{}
+	for _, exp := range expenses {
+		found[exp.ID] = exp
+	}
+
+	r := Report{Title: req.Title, Status: StatusOpen, CreatedBy: actor.Name, Items: []Item{}}
+	seen := map[int]bool{}
+	for _, id := range req.ExpenseIDs {
+		exp, ok := found[id]
+		switch {
+		case seen[id]:
+			return r, &Error{http.StatusBadRequest, "Expense " + strconv.Itoa(id) + " is listed twice"}
+		case !ok:
+			return r, &Error{http.StatusNotFound, "Expense " + strconv.Itoa(id) + " not found"}
+		case exp.ReportID != 0:
+			return r, &Error{http.StatusConflict, "Expense " + strconv.Itoa(id) + " is already in report " + strconv.Itoa(exp.ReportID)}
+		case exp.Status != expense.StatusApproved:
+			return r, &Error{http.StatusUnprocessableEntity, "Expense " + strconv.Itoa(id) + " is not approved"}
+		case r.Submitter != "" && exp.Owner != r.Submitter:
+			return r, &Error{http.StatusUnprocessableEntity, "Expenses must belong to one submitter"}
+		case len(r.Items) > 0 && (r.Currency == "" || exp.ReportingCurrency == "") && exp.ReportingCurrency != r.Currency:
+			return r, &Error{http.StatusUnprocessableEntity, "Expenses without a reporting currency cannot share a report with converted ones"}
+		case len(r.Items) > 0 && exp.ReportingCurrency != r.Currency:
+			return r, &Error{http.StatusUnprocessableEntity, "Expenses must share one reporting currency"}
+		}
+		r.Submitter = exp.Owner
+		r.Currency = exp.ReportingCurrency
+		r.Total += exp.ReportAmount()
+		item := Item{ExpenseID: exp.ID, Title: exp.Title, Date: exp.Date, Amount: exp.ReportAmount()}
+		if exp.Currency != exp.ReportingCurrency {
+			item.OriginalAmount, item.Currency, item.Rate = exp.Amount, exp.Currency, exp.Rate
+		}
+		r.Items = append(r.Items, item)
+		seen[id] = true
+	}
+	r.Total = roundCents(r.Total)
+
+	err = tx.QueryRow("INSERT INTO reports (title, submitter, total, created_by, currency) values ($1, $2, $3, $4, $5) RETURNING id, created_at",
+		r.Title, r.Submitter, r.Total, r.CreatedBy, nullString(r.Currency)).Scan(&r.ID, &r.CreatedAt)
+	if err != nil {
+		log.Errorf("Insert report error: %v", err)
+		return r, err
+	}
+	for _, item := range r.Items {
+		if _, err := tx.Exec("INSERT INTO report_items (report_id, expense_id, title, date, amount, currency, original_amount, rate) values ($1, $2, $3, $4, $5, $6, $7, $8)",
+			r.ID, item.ExpenseID, item.Title, nullDate(item.Date), item.Amount, nullString(item.Currency), nullFloat(item.OriginalAmount), nullFloat(item.Rate)); err != nil {
+			return r, err
+		}
+	}
+	return r, setReport(tx, actor, expenses, r.ID)
+}
+
+// setReport points the expenses at reportID (0 to release them) and records
+// the change in the audit trail and the outbox.
+func setReport(tx *sql.Tx, actor audit.Actor, expenses []expense.Expense, reportID int) error {
+	ids := []int{}
+	for _, exp := range expenses {
+		ids = append(ids, exp.ID)
+	}
+	var id any
+	if reportID != 0 {
+		id = reportID
+	}
+	if _, err := tx.Exec("UPDATE expenses SET report_id=$2 WHERE id = ANY($1)", pq.Array(ids), id); err != nil {
+		return err
+	}
+	for _, before := range expenses {
+		after := before
+		after.ReportID = reportID
+		if _, err := audit.Record(tx, actor, "report", before.ID, before, after); err != nil {
+			return err
+		}
+		if err := expense.WriteEvent(tx, expense.EventUpdated, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func nullDate(date string) any {
+	return nullString(date)
+}
+
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nullFloat(v float64) any {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+func GetReport(db *sql.DB, id int) (Report, error) {
+	r, err := scanReport(db.QueryRow("SELECT "+reportColumns+" FROM reports WHERE id = $1", id))
+	if err != nil {
+		return r, err
+	}
+	r.Items, err = getItems(db, id)
+	return r, err
+}
+
+// GetReportsByID loads the reports with the given IDs without their items.
+// Missing IDs are left out.
+func GetReportsByID(db *sql.DB, ids []int) ([]Report, error) {
+	reports := []Report{}
+	rows, err := db.Query("SELECT "+reportColumns+" FROM reports WHERE id = ANY($1) ORDER BY id ASC", pq.Array(ids))
+	if err != nil {
+		return reports, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r, err := scanReport(rows)
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, r)
+	}
+	return reports, rows.Err()
+}
+
+func lockReport(tx *sql.Tx, id int) (Report, error) {
+	return scanReport(tx.QueryRow("SELECT "+reportColumns+" FROM reports WHERE id = $1 FOR UPDATE", id))
+}
+
+func getItems(db expense.Querier, id int) ([]Item, error) {
+	items := []Item{}
+	rows, err := db.Query("SELECT expense_id, title, date, amount, currency, original_amount, rate FROM report_items WHERE report_id = $1 ORDER BY expense_id ASC", id)
+	if err != nil {
+		return items, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		item := Item{}
+		var title, currency sql.NullString
+		var date sql.NullTime
+		var originalAmount, rate sql.NullFloat64
+		if err := rows.Scan(&item.ExpenseID, &title, &date, &item.Amount, &currency, &originalAmount, &rate); err != nil {
+			return items, err
+		}
+		item.Title = title.String
+		item.Currency = currency.String
+		item.OriginalAmount = originalAmount.Float64
+		item.Rate = rate.Float64
+		if date.Valid {
+			item.Date = date.Time.Format(expense.DateLayout)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// GetReports lists reports newest first, optionally narrowed by status and
+// submitter.
+func GetReports(db *sql.DB, status, submitter string) ([]Report, error) {
+	reports := []Report{}
+	rows, err := db.Query("SELECT "+reportColumns+" FROM reports WHERE ($1 = '' OR status = $1) AND ($2 = '' OR submitter = $2) ORDER BY id DESC", status, submitter)
+	if err != nil {
+		return reports, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r, err := scanReport(rows)
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, r)
+	}
+	return reports, rows.Err()
+}
+
+// PayReport marks an open report paid and reimburses every expense in it
+// through the approval workflow.
+func PayReport(tx *sql.Tx, actor audit.Actor, user auth.User, id int) (Report, error) {
+	r, err := lockReport(tx, id)
+	if err != nil {
+		return r, err
+	}
+	if r.Status != StatusOpen {
+		return r, &Error{http.StatusConflict, "Cannot pay a report that is " + r.Status}
+	}
+	if r.Items, err = getItems(tx, id); err != nil {
+		return r, err
+	}
+	for _, item := range r.Items {
+		if _, _, err := expense.TransitionExpense(tx, actor, user, item.ExpenseID, expense.ActionReimburse, "Paid in report "+strconv.Itoa(id), id); err != nil {
+			return r, err
+		}
+	}
+	now := time.Now()
+	r.Status, r.PaidAt = StatusPaid, &now
+	_, err = tx.Exec("UPDATE reports SET status=$2, paid_at=$3 WHERE id = $1", id, r.Status, now)
+	return r, err
+}
+
+// CloseReport closes a report. Closing a paid report archives it; closing an
+// open one cancels it and releases its expenses for another report.
+func CloseReport(tx *sql.Tx, actor audit.Actor, id int) (Report, error) {
+	r, err := lockReport(tx, id)
+	if err != nil {
+		return r, err
+	}
+	if r.Status == StatusClosed {
+		return r, &Error{http.StatusConflict, "Report is already closed"}
+	}
+	if r.Items, err = getItems(tx, id); err != nil {
+		return r, err
+	}
+	if r.Status == StatusOpen {
+		ids := []int{}
+		for _, item := range r.Items {
+			ids = append(ids, item.ExpenseID)
+		}
+		expenses, err := expense.LockExpenses(tx, ids)
+		if err != nil {
+			return r, err
+		}
+		if err := setReport(tx, actor, expenses, 0); err != nil {
+			return r, err
+		}
+	}
+	now := time.Now()
+	r.Status, r.ClosedAt = StatusClosed, &now
+	_, err = tx.Exec("UPDATE reports SET status=$2, closed_at=$3 WHERE id = $1", id, r.Status, now)
+	return r, err
+}
@@ -0,0 +1,60 @@
+package report
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/expense"
+)
+
+var payoutColumns = []string{"report_id", "submitter", "expense_id", "date", "title", "amount", "currency", "original_amount", "original_currency", "rate"}
+
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// ExportReportHandler renders a report as a payout file, JSON by default or
+// CSV with format=csv. The CSV has one row per expense followed by a row with
+// an empty expense_id, the title "Total" and the report total. Amounts are in
+// the report currency; the original columns are only filled for expenses
+// converted from another currency.
+func (h *handler) ExportReportHandler(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "ID is invalid"})
+	}
+	format := c.QueryParam("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "Query format is invalid"})
+	}
+	r, err := getVisibleReport(c, h.DB, id)
+	if err != nil {
+		return respond(c, http.StatusOK, r, err)
+	}
+
+	filename := "report-" + strconv.Itoa(id) + "." + format
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	if format == "json" {
+		return c.JSON(http.StatusOK, r)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=UTF-8")
+	c.Response().WriteHeader(http.StatusOK)
+	w := csv.NewWriter(c.Response())
+	w.Write(payoutColumns)
+	for _, item := range r.Items {
+		original, rate := "", ""
+		if item.Currency != "" {
+			original, rate = formatAmount(item.OriginalAmount), strconv.FormatFloat(item.Rate, 'f', -1, 64)
+		}
+		w.Write([]string{strconv.Itoa(r.ID), r.Submitter, strconv.Itoa(item.ExpenseID), item.Date, item.Title, formatAmount(item.Amount), r.Currency, original, item.Currency, rate})
+	}
+	w.Write([]string{strconv.Itoa(r.ID), r.Submitter, "", "", "Total", formatAmount(r.Total), r.Currency, "", "", ""})
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,114 @@
+package report
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/auth"
+	"github.com/phanbanchong/assessment/expense"
+)
+
+// respond maps the outcome of a report operation to a response.
+func respond(c echo.Context, status int, r Report, err error) error {
+	var re *Error
+	var te *expense.TransitionError
+	switch {
+	case err == nil:
+		return c.JSON(status, r)
+	case err == sql.ErrNoRows:
+		return c.JSON(http.StatusNotFound, expense.Error{Message: "Report not found"})
+	case errors.As(err, &re):
+		return c.JSON(re.Status, expense.Error{Message: re.Message})
+	case errors.As(err, &te):
+		return c.JSON(te.Status, expense.Error{Message: te.Message})
+	default:
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: err.Error()})
+	}
+}
+
+// inTx runs fn in a transaction, committing only when it succeeds.
+func (h *handler) inTx(fn func(tx *sql.Tx) (Report, error)) (Report, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return Report{}, err
+	}
+	defer tx.Rollback()
+	r, err := fn(tx)
+	if err != nil {
+		return r, err
+	}
+	return r, tx.Commit()
+}
+
+// getVisibleReport reads report id when the caller may see it: approvers and
+// admins see every report, everyone else only those they submitted.
+func getVisibleReport(c echo.Context, db *sql.DB, id int) (Report, error) {
+	r, err := GetReport(db, id)
+	if owner := expense.VisibleOwner(c); err == nil && owner != "" && r.Submitter != owner {
+		return Report{}, sql.ErrNoRows
+	}
+	return r, err
+}
+
+func (h *handler) CreateReportHandler(c echo.Context) error {
+	req := CreateRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: err.Error()})
+	}
+	r, err := h.inTx(func(tx *sql.Tx) (Report, error) {
+		return CreateReport(tx, audit.ActorFrom(c), req)
+	})
+	return respond(c, http.StatusCreated, r, err)
+}
+
+func (h *handler) GetReportHandler(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "ID is invalid"})
+	}
+	r, err := getVisibleReport(c, h.DB, id)
+	return respond(c, http.StatusOK, r, err)
+}
+
+// GetReportsHandler lists reports, filtered by the status and submitter
+// query parameters. Callers who are not approvers only see their own.
+func (h *handler) GetReportsHandler(c echo.Context) error {
+	submitter := c.QueryParam("submitter")
+	if owner := expense.VisibleOwner(c); owner != "" {
+		if submitter != "" && submitter != owner {
+			return c.JSON(http.StatusOK, []Report{})
+		}
+		submitter = owner
+	}
+	reports, err := GetReports(h.DB, c.QueryParam("status"), submitter)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: "Unable to get reports from database:" + err.Error()})
+	}
+	return c.JSON(http.StatusOK, reports)
+}
+
+func (h *handler) PayReportHandler(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "ID is invalid"})
+	}
+	r, err := h.inTx(func(tx *sql.Tx) (Report, error) {
+		return PayReport(tx, audit.ActorFrom(c), auth.UserFrom(c), id)
+	})
+	return respond(c, http.StatusOK, r, err)
+}
+
+func (h *handler) CloseReportHandler(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "ID is invalid"})
+	}
+	r, err := h.inTx(func(tx *sql.Tx) (Report, error) {
+		return CloseReport(tx, audit.ActorFrom(c), id)
+	})
+	return respond(c, http.StatusOK, r, err)
+}
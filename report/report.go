@@ -0,0 +1,63 @@
+package report
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	StatusOpen   = "open"
+	StatusPaid   = "paid"
+	StatusClosed = "closed"
+)
+
+type handler struct {
+	DB *sql.DB
+}
+
+func NewApplication(db *sql.DB) *handler {
+	return &handler{db}
+}
+
+// Report bundles approved expenses of one submitter for a single payout.
+type Report struct {
+	ID        int        `json:"id"`
+	Title     string     `json:"title"`
+	Submitter string     `json:"submitter"`
+	Status    string     `json:"status"`
+	Total     float64    `json:"total"`
+	CreatedBy string     `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	PaidAt    *time.Time `json:"paid_at,omitempty"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty"`
+	Currency  string     `json:"currency,omitempty"`
+	Items     []Item     `json:"items,omitempty"`
+}
+
+// Item is an expense in a report. Amount is in the report currency; expenses
+// filed in another currency also keep their original amount, currency and
+// the rate they were converted at.
+type Item struct {
+	ExpenseID      int     `json:"expense_id"`
+	Title          string  `json:"title"`
+	Date           string  `json:"date,omitempty"`
+	Amount         float64 `json:"amount"`
+	OriginalAmount float64 `json:"original_amount,omitempty"`
+	Currency       string  `json:"currency,omitempty"`
+	Rate           float64 `json:"rate,omitempty"`
+}
+
+type CreateRequest struct {
+	Title      string `json:"title"`
+	ExpenseIDs []int  `json:"expense_ids"`
+}
+
+// Error is a refused report operation and the HTTP status it maps to.
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
@@ -0,0 +1,347 @@
+//go:build unit
+
+package report
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/auth"
+	"github.com/phanbanchong/assessment/expense"
+)
+
+var (
+	expenseColumns = []string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}
+	itemColumns    = []string{"expense_id", "title", "date", "amount", "currency", "original_amount", "rate"}
+	reportRows     = []string{"id", "title", "submitter", "status", "total", "created_by", "created_at", "paid_at", "closed_at", "currency"}
+	created        = time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func expectAudit(mock sqlmock.Sqlmock, id int, action string) {
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT hash FROM expense_audit").WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+	mock.ExpectQuery("INSERT INTO expense_audit").
+		WithArgs(id, action, "bob", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+}
+
+func expectEvent(mock sqlmock.Sqlmock, id int) {
+	mock.ExpectExec("INSERT INTO outbox").WithArgs(expense.EventUpdated, id, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO expense_changes").WithArgs(id, sqlmock.AnyArg(), false, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func reportContext(method, target, body string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("5")
+	auth.SetUser(c, auth.User{Name: "bob", Roles: []string{auth.RoleApprover}})
+	return c, rec
+}
+
+func TestCreateReportHandler(t *testing.T) {
+	t.Run("Create report should bundle approved expenses and lock them", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id = ANY(.+) FOR UPDATE").
+			WithArgs(pq.Array([]int{1, 2})).
+			WillReturnRows(sqlmock.NewRows(expenseColumns).
+				AddRow(1, "taxi", 10.1, "", pq.Array([]string{}), created, nil, nil, "approved", "alice", nil, "THB", 10.1, "THB", 1.0, nil).
+				AddRow(2, "hotel", 0.6, "", pq.Array([]string{}), nil, nil, nil, "approved", "alice", nil, "USD", 20.2, "THB", 33.666667, created))
+		mock.ExpectQuery("INSERT INTO reports").
+			WithArgs("February", "alice", 30.3, "bob", "THB").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(5, created))
+		mock.ExpectExec("INSERT INTO report_items").WithArgs(5, 1, "taxi", "2026-02-01", 10.1, nil, nil, nil).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO report_items").WithArgs(5, 2, "hotel", nil, 20.2, "USD", 0.6, 33.666667).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE expenses SET report_id").WithArgs(pq.Array([]int{1, 2}), 5).WillReturnResult(sqlmock.NewResult(0, 2))
+		expectAudit(mock, 1, "report")
+		expectEvent(mock, 1)
+		expectAudit(mock, 2, "report")
+		expectEvent(mock, 2)
+		mock.ExpectCommit()
+
+		c, rec := reportContext(http.MethodPost, "/reports", `{"title":"February","expense_ids":[1,2]}`)
+		if err := NewApplication(db).CreateReportHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"id":5,"title":"February","submitter":"alice","status":"open","total":30.3,"created_by":"bob","created_at":"2026-02-01T00:00:00Z","currency":"THB",` +
+			`"items":[{"expense_id":1,"title":"taxi","date":"2026-02-01","amount":10.1},{"expense_id":2,"title":"hotel","amount":20.2,"original_amount":0.6,"currency":"USD","rate":33.666667}]}` + "\n"
+		if rec.Code != http.StatusCreated || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	cases := []struct {
+		name string
+		rows *sqlmock.Rows
+		code int
+		want string
+	}{
+		{"Create report with expense in another report should be a conflict",
+			sqlmock.NewRows(expenseColumns).AddRow(1, "taxi", 10.0, "", pq.Array([]string{}), nil, nil, nil, "approved", "alice", 3, nil, nil, nil, nil, nil),
+			http.StatusConflict, "Expense 1 is already in report 3"},
+		{"Create report with unapproved expense should be unprocessable",
+			sqlmock.NewRows(expenseColumns).AddRow(1, "taxi", 10.0, "", pq.Array([]string{}), nil, nil, nil, "submitted", "alice", nil, nil, nil, nil, nil, nil),
+			http.StatusUnprocessableEntity, "Expense 1 is not approved"},
+		{"Create report across submitters should be unprocessable",
+			sqlmock.NewRows(expenseColumns).
+				AddRow(1, "taxi", 10.0, "", pq.Array([]string{}), nil, nil, nil, "approved", "alice", nil, nil, nil, nil, nil, nil).
+				AddRow(2, "hotel", 20.0, "", pq.Array([]string{}), nil, nil, nil, "approved", "carol", nil, nil, nil, nil, nil, nil),
+			http.StatusUnprocessableEntity, "Expenses must belong to one submitter"},
+		{"Create report across reporting currencies should be unprocessable",
+			sqlmock.NewRows(expenseColumns).
+				AddRow(1, "taxi", 10.0, "", pq.Array([]string{}), nil, nil, nil, "approved", "alice", nil, "THB", 10.0, "THB", 1.0, nil).
+				AddRow(2, "hotel", 20.0, "", pq.Array([]string{}), nil, nil, nil, "approved", "alice", nil, "USD", 20.0, "USD", 1.0, nil),
+			http.StatusUnprocessableEntity, "Expenses must share one reporting currency"},
+		{"Create report mixing unconverted and converted expenses should be unprocessable",
+			sqlmock.NewRows(expenseColumns).
+				AddRow(1, "taxi", 10.0, "", pq.Array([]string{}), nil, nil, nil, "approved", "alice", nil, nil, nil, nil, nil, nil).
+				AddRow(2, "hotel", 20.0, "", pq.Array([]string{}), nil, nil, nil, "approved", "alice", nil, "USD", 700.0, "THB", 35.0, nil),
+			http.StatusUnprocessableEntity, "Expenses without a reporting currency cannot share a report with converted ones"},
+		{"Create report mixing converted and unconverted expenses should be unprocessable",
+			sqlmock.NewRows(expenseColumns).
+				AddRow(1, "hotel", 20.0, "", pq.Array([]string{}), nil, nil, nil, "approved", "alice", nil, "USD", 700.0, "THB", 35.0, nil).
+				AddRow(2, "taxi", 10.0, "", pq.Array([]string{}), nil, nil, nil, "approved", "alice", nil, nil, nil, nil, nil, nil),
+			http.StatusUnprocessableEntity, "Expenses without a reporting currency cannot share a report with converted ones"},
+		{"Create report with missing expense should got not found",
+			sqlmock.NewRows(expenseColumns).AddRow(1, "taxi", 10.0, "", pq.Array([]string{}), nil, nil, nil, "approved", "alice", nil, nil, nil, nil, nil, nil),
+			http.StatusNotFound, "Expense 2 not found"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id = ANY(.+) FOR UPDATE").WillReturnRows(tc.rows)
+			mock.ExpectRollback()
+
+			c, rec := reportContext(http.MethodPost, "/reports", `{"expense_ids":[1,2]}`)
+			if err := NewApplication(db).CreateReportHandler(c); err != nil {
+				t.Errorf("should not return error but it got %v", err)
+			}
+			if rec.Code != tc.code || rec.Body.String() != `{"message":"`+tc.want+`"}`+"\n" {
+				t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestPayReportHandler(t *testing.T) {
+	t.Run("Pay report should reimburse every expense", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT (.+) FROM reports WHERE id = (.+) FOR UPDATE").
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows(reportRows).AddRow(5, "February", "alice", "open", 10.0, "bob", created, nil, nil, nil))
+		mock.ExpectQuery("SELECT (.+) FROM report_items").
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows(itemColumns).AddRow(1, "taxi", nil, 10.0, nil, nil, nil))
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id = (.+) FOR UPDATE").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows(expenseColumns).AddRow(1, "taxi", 10.0, "", pq.Array([]string{}), nil, nil, nil, "approved", "alice", 5, nil, nil, nil, nil, nil))
+		mock.ExpectExec("UPDATE expenses SET status").WithArgs(1, "reimbursed", "alice").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO expense_transitions").
+			WithArgs(1, "approved", "reimbursed", "bob", "Paid in report 5", nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(9, created))
+		expectAudit(mock, 1, "reimburse")
+		expectEvent(mock, 1)
+		mock.ExpectExec("UPDATE reports SET status").WithArgs(5, "paid", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		c, rec := reportContext(http.MethodPost, "/reports/5/pay", "")
+		if err := NewApplication(db).PayReportHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"paid"`) {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Pay closed report should be a conflict", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT (.+) FROM reports WHERE id = (.+) FOR UPDATE").
+			WillReturnRows(sqlmock.NewRows(reportRows).AddRow(5, nil, "alice", "closed", 10.0, "bob", created, nil, created, nil))
+		mock.ExpectRollback()
+
+		c, rec := reportContext(http.MethodPost, "/reports/5/pay", "")
+		if err := NewApplication(db).PayReportHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusConflict || rec.Body.String() != `{"message":"Cannot pay a report that is closed"}`+"\n" {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+func TestCloseReportHandler(t *testing.T) {
+	t.Run("Close open report should release its expenses", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT (.+) FROM reports WHERE id = (.+) FOR UPDATE").
+			WillReturnRows(sqlmock.NewRows(reportRows).AddRow(5, nil, "alice", "open", 10.0, "bob", created, nil, nil, nil))
+		mock.ExpectQuery("SELECT (.+) FROM report_items").
+			WillReturnRows(sqlmock.NewRows(itemColumns).AddRow(1, "taxi", nil, 10.0, nil, nil, nil))
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id = ANY(.+) FOR UPDATE").
+			WithArgs(pq.Array([]int{1})).
+			WillReturnRows(sqlmock.NewRows(expenseColumns).AddRow(1, "taxi", 10.0, "", pq.Array([]string{}), nil, nil, nil, "approved", "alice", 5, nil, nil, nil, nil, nil))
+		mock.ExpectExec("UPDATE expenses SET report_id").WithArgs(pq.Array([]int{1}), nil).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit(mock, 1, "report")
+		expectEvent(mock, 1)
+		mock.ExpectExec("UPDATE reports SET status").WithArgs(5, "closed", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		c, rec := reportContext(http.MethodPost, "/reports/5/close", "")
+		if err := NewApplication(db).CloseReportHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"closed"`) {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestExportReportHandler(t *testing.T) {
+	t.Run("Export CSV should write one row per expense and a total", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT (.+) FROM reports WHERE id").
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows(reportRows).AddRow(5, "February", "alice", "paid", 30.3, "bob", created, created, nil, "THB"))
+		mock.ExpectQuery("SELECT (.+) FROM report_items").
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows(itemColumns).
+				AddRow(1, "taxi", created, 10.1, nil, nil, nil).
+				AddRow(2, "hotel, city", nil, 20.2, "USD", 0.6, 33.666667))
+
+		c, rec := reportContext(http.MethodGet, "/reports/5/export?format=csv", "")
+		if err := NewApplication(db).ExportReportHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := "report_id,submitter,expense_id,date,title,amount,currency,original_amount,original_currency,rate\n" +
+			"5,alice,1,2026-02-01,taxi,10.10,THB,,,\n" +
+			"5,alice,2,,\"hotel, city\",20.20,THB,0.60,USD,33.666667\n" +
+			"5,alice,,,Total,30.30,THB,,,\n"
+		if rec.Body.String() != want || rec.Header().Get(echo.HeaderContentDisposition) != `attachment; filename="report-5.csv"` {
+			t.Errorf("response error was not expected got: %s", rec.Body.String())
+		}
+	})
+}
+
+func TestOtherSubmittersReports(t *testing.T) {
+	routes := []struct {
+		name    string
+		target  string
+		handler func(h *handler) echo.HandlerFunc
+	}{
+		{"Get", "/reports/5", func(h *handler) echo.HandlerFunc { return h.GetReportHandler }},
+		{"Export", "/reports/5/export?format=csv", func(h *handler) echo.HandlerFunc { return h.ExportReportHandler }},
+	}
+	for _, r := range routes {
+		t.Run(r.name+" another submitter's report should not found", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			mock.ExpectQuery("SELECT (.+) FROM reports WHERE id").
+				WithArgs(5).
+				WillReturnRows(sqlmock.NewRows(reportRows).AddRow(5, "February", "alice", "open", 30.3, "bob", created, nil, nil, "THB"))
+			mock.ExpectQuery("SELECT (.+) FROM report_items").
+				WithArgs(5).
+				WillReturnRows(sqlmock.NewRows(itemColumns))
+
+			c, rec := reportContext(http.MethodGet, r.target, "")
+			auth.SetUser(c, auth.User{Name: "carol"})
+			if err := r.handler(NewApplication(db))(c); err != nil {
+				t.Errorf("should not return error but it got %v", err)
+			}
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("should status not found but it got %d: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("List reports should only show the caller's own", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT (.+) FROM reports WHERE (.+) ORDER BY id DESC").
+			WithArgs("", "carol").
+			WillReturnRows(sqlmock.NewRows(reportRows))
+
+		c, rec := reportContext(http.MethodGet, "/reports", "")
+		auth.SetUser(c, auth.User{Name: "carol"})
+		if err := NewApplication(db).GetReportsHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusOK || rec.Body.String() != "[]\n" {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("List another submitter's reports should show nothing", func(t *testing.T) {
+		db, _, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		c, rec := reportContext(http.MethodGet, "/reports?submitter=alice", "")
+		auth.SetUser(c, auth.User{Name: "carol"})
+		if err := NewApplication(db).GetReportsHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusOK || rec.Body.String() != "[]\n" {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+}
@@ -0,0 +1,223 @@
+//go:build unit
+
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/auth"
+	"github.com/phanbanchong/assessment/rpc/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var expenseColumns = []string{"id", "title", "amount", "note", "tags", "date", "recurring_id", "external_ref", "status", "owner", "report_id", "currency", "reporting_amount", "reporting_currency", "rate", "rate_date"}
+
+// dial serves a handler over db on an in-process listener and returns a
+// connection to it.
+func dial(t *testing.T, db *sql.DB) *grpc.ClientConn {
+	if err := auth.LoadUsers(`{"alice-token":{"name":"alice"},"admin-token":{"name":"admin","roles":["admin"]}}`); err != nil {
+		t.Fatalf("an error '%s' was not expected when loading users", err)
+	}
+	t.Cleanup(func() { auth.LoadUsers("") })
+	lis := bufconn.Listen(1 << 20)
+	s := NewApplication(db).NewServer()
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when dialing the server", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func as(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", token)
+}
+
+func TestExpenseService(t *testing.T) {
+	t.Run("Call without token should be unauthenticated", func(t *testing.T) {
+		client := pb.NewExpenseServiceClient(dial(t, nil))
+
+		_, err := client.GetExpense(context.Background(), &pb.GetExpenseRequest{Id: 1})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("should be unauthenticated but it got %v", err)
+		}
+	})
+
+	t.Run("Get expense of another owner should be not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectPrepare("SELECT (.+) FROM expenses WHERE id = \\$1").ExpectQuery().
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows(expenseColumns).
+				AddRow(3, "taxi", 100.0, "", pq.Array([]string{"travel"}), nil, nil, nil, "draft", "bob", nil, nil, nil, nil, nil, nil))
+		client := pb.NewExpenseServiceClient(dial(t, db))
+
+		_, err = client.GetExpense(as("alice-token"), &pb.GetExpenseRequest{Id: 3})
+		if status.Code(err) != codes.NotFound || status.Convert(err).Message() != "Expense not found" {
+			t.Errorf("should be not found but it got %v", err)
+		}
+	})
+
+	t.Run("List expenses should stream the caller's expenses", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE tags @> \\$1 AND amount >= \\$2 AND owner = \\$3 ORDER BY id ASC").
+			WithArgs(pq.Array([]string{"travel"}), 50.0, "alice").
+			WillReturnRows(sqlmock.NewRows(expenseColumns).
+				AddRow(1, "taxi", 100.0, "", pq.Array([]string{"travel"}), time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), nil, nil, "draft", "alice", nil, "THB", 100.0, "THB", 1.0, nil).
+				AddRow(2, "train", 200.0, "", pq.Array([]string{"travel"}), nil, nil, nil, "approved", "alice", 5, nil, nil, nil, nil, nil))
+		client := pb.NewExpenseServiceClient(dial(t, db))
+		min := 50.0
+
+		stream, err := client.ListExpenses(as("alice-token"), &pb.ListExpensesRequest{Tags: []string{"travel"}, MinAmount: &min})
+		if err != nil {
+			t.Fatalf("should not return error but it got %v", err)
+		}
+		got := []*pb.Expense{}
+		for {
+			exp, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("should not return error but it got %v", err)
+			}
+			got = append(got, exp)
+		}
+		if len(got) != 2 || got[0].Date != "2023-03-01" || got[0].ReportingCurrency != "THB" || got[1].ReportId != 5 {
+			t.Errorf("response error was not expected got: %v", got)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("List expenses of another owner should be empty", func(t *testing.T) {
+		client := pb.NewExpenseServiceClient(dial(t, nil))
+
+		stream, err := client.ListExpenses(as("alice-token"), &pb.ListExpensesRequest{Owner: "bob"})
+		if err != nil {
+			t.Fatalf("should not return error but it got %v", err)
+		}
+		if _, err := stream.Recv(); err != io.EOF {
+			t.Errorf("should end the stream but it got %v", err)
+		}
+	})
+
+	t.Run("Create expense with invalid input should be invalid argument", func(t *testing.T) {
+		client := pb.NewExpenseServiceClient(dial(t, nil))
+
+		_, err := client.CreateExpense(as("alice-token"), &pb.CreateExpenseRequest{Expense: &pb.ExpenseInput{Title: "lunch", Amount: 10, Date: "yesterday"}})
+		if status.Code(err) != codes.InvalidArgument || status.Convert(err).Message() != "Field date is invalid" {
+			t.Errorf("should be invalid argument but it got %v", err)
+		}
+	})
+
+	t.Run("Create expense should be success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE owner = \\$1").WillReturnRows(sqlmock.NewRows(expenseColumns))
+		mock.ExpectQuery("INSERT INTO expenses").
+			WithArgs("lunch", 10.0, "", pq.Array([]string{"food"}), nil, nil, "alice", "THB", 10.0, "THB", 1.0, nil, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT hash FROM expense_audit").WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+		mock.ExpectQuery("INSERT INTO expense_audit").
+			WithArgs(4, "create", "alice", "req-1", sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO outbox").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO expense_changes").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		client := pb.NewExpenseServiceClient(dial(t, db))
+		ctx := metadata.AppendToOutgoingContext(as("alice-token"), "x-request-id", "req-1")
+
+		exp, err := client.CreateExpense(ctx, &pb.CreateExpenseRequest{Expense: &pb.ExpenseInput{Title: "lunch", Amount: 10, Tags: []string{"food"}}})
+		if err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if exp.GetId() != 4 || exp.GetOwner() != "alice" || exp.GetStatus() != "draft" || exp.GetReportingAmount() != 10 {
+			t.Errorf("response error was not expected got: %v", exp)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Delete final expense should be failed precondition", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectPrepare("SELECT (.+) FROM expenses WHERE id = \\$1").ExpectQuery().
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows(expenseColumns).
+				AddRow(3, "taxi", 100.0, "", pq.Array([]string{}), nil, nil, nil, "reimbursed", "bob", nil, nil, nil, nil, nil, nil))
+		mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id = \\$1 FOR UPDATE").
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows(expenseColumns).
+				AddRow(3, "taxi", 100.0, "", pq.Array([]string{}), nil, nil, nil, "reimbursed", "bob", nil, nil, nil, nil, nil, nil))
+		mock.ExpectRollback()
+		client := pb.NewExpenseServiceClient(dial(t, db))
+
+		_, err = client.DeleteExpense(as("admin-token"), &pb.DeleteExpenseRequest{Id: 3})
+		if status.Code(err) != codes.FailedPrecondition {
+			t.Errorf("should be failed precondition but it got %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Reflection should list the expense service", func(t *testing.T) {
+		client := rpb.NewServerReflectionClient(dial(t, nil))
+
+		stream, err := client.ServerReflectionInfo(as("admin-token"))
+		if err != nil {
+			t.Fatalf("should not return error but it got %v", err)
+		}
+		if err := stream.Send(&rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_ListServices{}}); err != nil {
+			t.Fatalf("should not return error but it got %v", err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("should not return error but it got %v", err)
+		}
+		found := false
+		for _, s := range resp.GetListServicesResponse().GetService() {
+			found = found || s.Name == "assessment.expense.v1.ExpenseService"
+		}
+		if !found {
+			t.Errorf("response error was not expected got: %v", resp)
+		}
+	})
+}
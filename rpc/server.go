@@ -0,0 +1,277 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/auth"
+	"github.com/phanbanchong/assessment/expense"
+	"github.com/phanbanchong/assessment/fx"
+	"github.com/phanbanchong/assessment/policy"
+	"github.com/phanbanchong/assessment/rpc/pb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+type handler struct {
+	pb.UnimplementedExpenseServiceServer
+	DB       *sql.DB
+	Policy   policy.Config
+	Currency string
+}
+
+func NewApplication(db *sql.DB) *handler {
+	return &handler{DB: db, Policy: policy.Config{Version: policy.Version}, Currency: expense.ReportingCurrency()}
+}
+
+// NewServer serves ExpenseService and server reflection. Every call, the
+// reflection service included, must carry a token AuthMiddleware accepts.
+func (h *handler) NewServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.UnaryInterceptor(unaryAuth), grpc.StreamInterceptor(streamAuth))
+	s := grpc.NewServer(opts...)
+	pb.RegisterExpenseServiceServer(s, h)
+	reflection.Register(s)
+	return s
+}
+
+type contextKey struct{}
+
+// authenticate resolves the authorization metadata of ctx to a user, as
+// AuthMiddleware does for the Authorization header.
+func authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	token := md.Get("authorization")
+	if len(token) == 0 {
+		return ctx, status.Error(codes.Unauthenticated, "Authorization is required")
+	}
+	user, ok := auth.Lookup(token[0])
+	if !ok {
+		return ctx, status.Error(codes.Unauthenticated, "Authorization is invalid")
+	}
+	return context.WithValue(ctx, contextKey{}, user), nil
+}
+
+func unaryAuth(ctx context.Context, req any, _ *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+	ctx, err := authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return next(ctx, req)
+}
+
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s authStream) Context() context.Context {
+	return s.ctx
+}
+
+func streamAuth(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, next grpc.StreamHandler) error {
+	ctx, err := authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return next(srv, authStream{ss, ctx})
+}
+
+func userFrom(ctx context.Context) auth.User {
+	user, _ := ctx.Value(contextKey{}).(auth.User)
+	return user
+}
+
+// actorFrom is audit.ActorFrom for gRPC calls; the request ID comes from the
+// x-request-id metadata.
+func actorFrom(ctx context.Context) audit.Actor {
+	actor := audit.Actor{Name: userFrom(ctx).Name}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get("x-request-id"); len(ids) > 0 {
+			actor.RequestID = ids[0]
+		}
+	}
+	if actor.Name == "" {
+		actor.Name = "anonymous"
+	}
+	return actor
+}
+
+// visibleOwner is expense.VisibleOwner for gRPC calls.
+func visibleOwner(ctx context.Context) string {
+	user := userFrom(ctx)
+	if user.HasRole(auth.RoleAdmin) || user.HasRole(auth.RoleApprover) {
+		return ""
+	}
+	return actorFrom(ctx).Name
+}
+
+func (h *handler) reportingCurrency(ctx context.Context) string {
+	if currency := userFrom(ctx).Currency; currency != "" {
+		return currency
+	}
+	return h.Currency
+}
+
+// statusError maps store errors to the gRPC codes matching the REST status
+// codes for the same failures.
+func statusError(err error) error {
+	var violation *expense.PolicyError
+	var duplicate *expense.DuplicateError
+	switch {
+	case err == sql.ErrNoRows:
+		return status.Error(codes.NotFound, "Expense not found")
+	case err == expense.ErrLocked, err == expense.ErrGroupExpense:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case err == expense.ErrNotOwner:
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.As(err, &duplicate):
+		return status.Error(codes.AlreadyExists, duplicate.Message)
+	case errors.As(err, &violation):
+		failure := &errdetails.PreconditionFailure{}
+		for _, f := range violation.Violations {
+			failure.Violations = append(failure.Violations, &errdetails.PreconditionFailure_Violation{Type: "POLICY", Subject: f.Rule, Description: f.Message})
+		}
+		st, _ := status.New(codes.FailedPrecondition, violation.Message).WithDetails(failure)
+		return st.Err()
+	case errors.Is(err, fx.ErrNoRate):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// getVisible loads the expense with id, reporting one the caller may not see
+// as missing.
+func (h *handler) getVisible(ctx context.Context, db expense.Querier, id int) (expense.Expense, error) {
+	exp, err := expense.GetExpenseByID(db, id)
+	if err == nil {
+		if owner := visibleOwner(ctx); owner != "" && owner != exp.Owner {
+			err = sql.ErrNoRows
+		}
+	}
+	return exp, err
+}
+
+func (h *handler) GetExpense(ctx context.Context, req *pb.GetExpenseRequest) (*pb.Expense, error) {
+	exp, err := h.getVisible(ctx, h.DB, int(req.Id))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toProto(exp), nil
+}
+
+func (h *handler) ListExpenses(req *pb.ListExpensesRequest, stream pb.ExpenseService_ListExpensesServer) error {
+	f := expense.Filter{Tags: req.Tags, From: req.From, To: req.To, MinAmount: req.MinAmount, MaxAmount: req.MaxAmount, Query: req.Q, Status: req.Status, Owner: req.Owner}
+	if !expense.ValidDate(f.From) {
+		return status.Error(codes.InvalidArgument, "Field from is invalid")
+	}
+	if !expense.ValidDate(f.To) {
+		return status.Error(codes.InvalidArgument, "Field to is invalid")
+	}
+	if owner := visibleOwner(stream.Context()); owner != "" {
+		if f.Owner != "" && f.Owner != owner {
+			return nil
+		}
+		f.Owner = owner
+	}
+	err := expense.EachExpense(stream.Context(), h.DB, f, func(exp expense.Expense) error {
+		return stream.Send(toProto(exp))
+	})
+	// Send failures already carry a status; only store errors need mapping.
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return statusError(err)
+}
+
+func (h *handler) CreateExpense(ctx context.Context, req *pb.CreateExpenseRequest) (*pb.Expense, error) {
+	if req.Expense == nil {
+		return nil, status.Error(codes.InvalidArgument, "Field expense is required")
+	}
+	exp := fromInput(req.Expense)
+	if err := expense.ValidateExpense(exp); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	exp.ReportingCurrency = h.reportingCurrency(ctx)
+	exp, err := expense.CreateExpenseChecked(h.DB, actorFrom(ctx), h.Policy, exp, req.Force)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toProto(exp), nil
+}
+
+func (h *handler) UpdateExpense(ctx context.Context, req *pb.UpdateExpenseRequest) (*pb.Expense, error) {
+	if req.Expense == nil {
+		return nil, status.Error(codes.InvalidArgument, "Field expense is required")
+	}
+	exp := fromInput(req.Expense)
+	exp.ID = int(req.Id)
+	if err := expense.ValidateUpdate(exp); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if _, err := h.getVisible(ctx, h.DB, exp.ID); err != nil {
+		return nil, statusError(err)
+	}
+	exp.ReportingCurrency = h.reportingCurrency(ctx)
+	exp, err := expense.UpdateExpenseChecked(h.DB, actorFrom(ctx), userFrom(ctx), h.Policy, exp)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toProto(exp), nil
+}
+
+func (h *handler) DeleteExpense(ctx context.Context, req *pb.DeleteExpenseRequest) (*pb.DeleteExpenseResponse, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return nil, statusError(err)
+	}
+	defer tx.Rollback()
+	if _, err := h.getVisible(ctx, tx, int(req.Id)); err != nil {
+		return nil, statusError(err)
+	}
+	if _, err := expense.DeleteExpenseAudited(tx, actorFrom(ctx), userFrom(ctx), int(req.Id)); err != nil {
+		return nil, statusError(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.DeleteExpenseResponse{}, nil
+}
+
+func fromInput(in *pb.ExpenseInput) expense.Expense {
+	exp := expense.Expense{Title: in.Title, Amount: in.Amount, Note: in.Note, Tags: in.Tags, Date: in.Date, Currency: in.Currency}
+	if exp.Tags == nil {
+		exp.Tags = []string{}
+	}
+	return exp
+}
+
+func toProto(exp expense.Expense) *pb.Expense {
+	out := &pb.Expense{
+		Id:                int64(exp.ID),
+		Title:             exp.Title,
+		Amount:            exp.Amount,
+		Note:              exp.Note,
+		Tags:              exp.Tags,
+		Date:              exp.Date,
+		Status:            exp.Status,
+		Owner:             exp.Owner,
+		RecurringId:       int64(exp.RecurringID),
+		ExternalRef:       exp.ExternalRef,
+		ReportId:          int64(exp.ReportID),
+		Currency:          exp.Currency,
+		ReportingAmount:   exp.ReportingAmount,
+		ReportingCurrency: exp.ReportingCurrency,
+		Rate:              exp.Rate,
+		RateDate:          exp.RateDate,
+	}
+	for _, w := range exp.Warnings {
+		out.Warnings = append(out.Warnings, &pb.PolicyFinding{Rule: w.Rule, Severity: w.Severity, Message: w.Message})
+	}
+	return out
+}
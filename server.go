@@ -4,18 +4,34 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/labstack/gommon/log"
+	"github.com/phanbanchong/assessment/attachment"
+	"github.com/phanbanchong/assessment/audit"
 	"github.com/phanbanchong/assessment/auth"
 	"github.com/phanbanchong/assessment/expense"
+	"github.com/phanbanchong/assessment/fx"
+	"github.com/phanbanchong/assessment/graph"
+	"github.com/phanbanchong/assessment/group"
 	"github.com/phanbanchong/assessment/health"
+	"github.com/phanbanchong/assessment/ledger"
+	"github.com/phanbanchong/assessment/openapi"
+	"github.com/phanbanchong/assessment/outbox"
+	"github.com/phanbanchong/assessment/policy"
+	"github.com/phanbanchong/assessment/recurring"
+	"github.com/phanbanchong/assessment/report"
+	"github.com/phanbanchong/assessment/rpc"
+	"github.com/phanbanchong/assessment/stream"
+	"github.com/phanbanchong/assessment/webhook"
 )
 
 func ContextDB(db *sql.DB) echo.MiddlewareFunc {
@@ -27,25 +43,217 @@ func ContextDB(db *sql.DB) echo.MiddlewareFunc {
 	}
 }
 
-func main() {
-	db, err := expense.InitDB()
-	if err != nil {
-		log.Fatal("Unable to initialze database")
-	}
-	h := expense.NewApplication(db)
+// app holds what the HTTP routes depend on.
+type app struct {
+	db       *sql.DB
+	store    attachment.Store
+	ledger   ledger.Config
+	policies policy.Config
+	hub      *stream.Hub
+	// validation checks requests and responses against the OpenAPI
+	// document; off unless OPENAPI_VALIDATION is set.
+	validation openapi.Validation
+}
+
+// server builds the HTTP API with every route registered.
+func (a app) server() *echo.Echo {
+	h := expense.NewApplication(a.db)
+	h.Policy = a.policies
+	rh := recurring.NewApplication(a.db)
+	ah := attachment.NewApplication(a.db, a.store)
+	lh := ledger.NewApplication(a.db, a.ledger)
+	auh := audit.NewApplication(a.db)
+	rph := report.NewApplication(a.db)
+	gh := group.NewApplication(a.db)
+	gh.Policy = a.policies
+	wh := webhook.NewApplication(a.db)
+	sh := stream.NewApplication(a.hub)
+	gqh := graph.NewApplication(a.db, a.ledger)
+	gqh.Policy = a.policies
 	e := echo.New()
 	e.Logger.SetLevel(log.INFO)
 
+	e.Use(middleware.RequestID())
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
-	e.Use(auth.AuthMiddleware())
+	e.Use(auth.AuthMiddleware("/openapi.json", "/docs"))
+	if a.validation.Requests || a.validation.Responses {
+		e.Use(openapi.Validator(a.validation))
+	}
 
 	e.GET("/health", health.GetHealthHandler)
+	e.GET("/openapi.json", openapi.GetSpecHandler)
+	e.GET("/docs", openapi.GetDocsHandler)
 
 	e.GET("/expenses", h.GetExpensesHandler)
+	e.GET("/expenses/duplicates", h.GetDuplicatesHandler)
+	e.GET("/expenses/stream", sh.StreamExpensesHandler)
+	e.GET("/expenses/export", h.ExportExpensesHandler)
+	e.GET("/expenses/export/ledger", lh.ExportLedgerHandler)
 	e.GET("/expenses/:id", h.GetExpenseHandler)
+	e.GET("/expenses/:id/history", auh.GetHistoryHandler)
+	e.GET("/expenses/:id/transitions", h.GetTransitionsHandler)
 	e.POST("/expenses", h.CreateExpenseHandler)
+	e.POST("/expenses/import", h.ImportExpensesHandler)
+	e.POST("/expenses/import/statement", h.ImportStatementHandler)
+	e.POST("/expenses\\:batch", h.BatchExpensesHandler)
 	e.PUT("/expenses/:id", h.UpdateExpenseHandler)
+	e.DELETE("/expenses/:id", h.DeleteExpenseHandler)
+	e.POST("/expenses/:id/submit", h.SubmitExpenseHandler)
+	e.POST("/expenses/:id/approve", h.ApproveExpenseHandler)
+	e.POST("/expenses/:id/reject", h.RejectExpenseHandler)
+	e.POST("/expenses/:id/reimburse", h.ReimburseExpenseHandler)
+
+	e.GET("/sync", h.SyncHandler)
+	e.POST("/sync", h.PushHandler)
+
+	e.GET("/graphql", gqh.GraphQLHandler)
+	e.POST("/graphql", gqh.GraphQLHandler)
+
+	e.GET("/expenses/:id/attachments", ah.GetAttachmentsHandler)
+	e.GET("/expenses/:id/attachments/:attachmentID", ah.DownloadAttachmentHandler)
+	e.POST("/expenses/:id/attachments", ah.UploadAttachmentHandler)
+	e.DELETE("/expenses/:id/attachments/:attachmentID", ah.DeleteAttachmentHandler)
+
+	e.GET("/audit", auh.GetFeedHandler, auth.RequireRole(auth.RoleAdmin))
+
+	e.GET("/webhooks", wh.GetSubscriptionsHandler, auth.RequireRole(auth.RoleAdmin))
+	e.GET("/webhooks/:id", wh.GetSubscriptionHandler, auth.RequireRole(auth.RoleAdmin))
+	e.POST("/webhooks", wh.CreateSubscriptionHandler, auth.RequireRole(auth.RoleAdmin))
+	e.DELETE("/webhooks/:id", wh.DeleteSubscriptionHandler, auth.RequireRole(auth.RoleAdmin))
+	e.GET("/webhooks/:id/deliveries", wh.GetDeliveriesHandler, auth.RequireRole(auth.RoleAdmin))
+	e.GET("/webhooks/:id/deliveries/:deliveryID", wh.GetDeliveryHandler, auth.RequireRole(auth.RoleAdmin))
+	e.POST("/webhooks/:id/deliveries/:deliveryID/redeliver", wh.RedeliverHandler, auth.RequireRole(auth.RoleAdmin))
+
+	e.GET("/reports", rph.GetReportsHandler)
+	e.GET("/reports/:id", rph.GetReportHandler)
+	e.GET("/reports/:id/export", rph.ExportReportHandler)
+	e.POST("/reports", rph.CreateReportHandler, auth.RequireRole(auth.RoleApprover))
+	e.POST("/reports/:id/pay", rph.PayReportHandler, auth.RequireRole(auth.RoleApprover))
+	e.POST("/reports/:id/close", rph.CloseReportHandler, auth.RequireRole(auth.RoleApprover))
+
+	e.GET("/groups", gh.GetGroupsHandler)
+	e.GET("/groups/:id", gh.GetGroupHandler)
+	e.POST("/groups", gh.CreateGroupHandler)
+	e.POST("/groups/:id/members", gh.AddMemberHandler)
+	e.DELETE("/groups/:id/members/:member", gh.RemoveMemberHandler)
+	e.GET("/groups/:id/expenses", gh.GetGroupExpensesHandler)
+	e.POST("/groups/:id/expenses", gh.CreateGroupExpenseHandler)
+	e.GET("/groups/:id/balances", gh.GetBalancesHandler)
+	e.GET("/groups/:id/settle-up", gh.GetSettleUpHandler)
+	e.POST("/groups/:id/settle-up", gh.SettleUpHandler)
+	e.GET("/groups/:id/settlements", gh.GetSettlementsHandler)
+	e.POST("/groups/:id/settlements", gh.CreateSettlementHandler)
+
+	e.GET("/recurring-expenses", rh.GetTemplatesHandler)
+	e.GET("/recurring-expenses/:id", rh.GetTemplateHandler)
+	e.POST("/recurring-expenses", rh.CreateTemplateHandler)
+	e.PUT("/recurring-expenses/:id", rh.UpdateTemplateHandler)
+	e.DELETE("/recurring-expenses/:id", rh.DeleteTemplateHandler)
+
+	return e
+}
+
+func main() {
+	db, err := expense.InitDB()
+	if err != nil {
+		log.Fatal("Unable to initialze database")
+	}
+	if err := recurring.InitDB(db); err != nil {
+		log.Fatal("Unable to initialze recurring expenses", err)
+	}
+	if err := attachment.InitDB(db); err != nil {
+		log.Fatal("Unable to initialze attachments", err)
+	}
+	if err := report.InitDB(db); err != nil {
+		log.Fatal("Unable to initialze reports", err)
+	}
+	if err := group.InitDB(db); err != nil {
+		log.Fatal("Unable to initialze groups", err)
+	}
+	if err := outbox.InitDB(db); err != nil {
+		log.Fatal("Unable to initialze outbox", err)
+	}
+	if err := webhook.InitDB(db); err != nil {
+		log.Fatal("Unable to initialze webhooks", err)
+	}
+	if err := audit.InitDB(db); err != nil {
+		log.Fatal("Unable to initialze audit trail", err)
+	}
+	if err := fx.InitDB(db); err != nil {
+		log.Fatal("Unable to initialze exchange rates", err)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify-audit" {
+		os.Exit(verifyAudit(db))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import-rates" {
+		os.Exit(importRates(db, os.Args[2:]))
+	}
+	if err := auth.LoadUsers(os.Getenv("AUTH_USERS")); err != nil {
+		log.Fatal("Unable to load AUTH_USERS", err)
+	}
+	signingKey, err := audit.SigningKeyFromEnv()
+	if err != nil {
+		log.Fatal("Unable to load audit signing key", err)
+	}
+	store, err := attachment.NewStoreFromEnv()
+	if err != nil {
+		log.Fatal("Unable to initialze attachment storage", err)
+	}
+	ledgerConfig, err := ledger.LoadConfig(os.Getenv("LEDGER_CONFIG"))
+	if err != nil {
+		log.Fatal("Unable to load ledger account mapping", err)
+	}
+	policies, err := policy.LoadConfig(os.Getenv("POLICY_CONFIG"))
+	if err != nil {
+		log.Fatal("Unable to load expense policies", err)
+	}
+	webhookInterval, err := time.ParseDuration(os.Getenv("WEBHOOK_INTERVAL"))
+	if err != nil {
+		webhookInterval = 10 * time.Second
+	}
+	dispatcher := webhook.NewDispatcher(db, webhookInterval)
+	sinks, err := outbox.ParseSinks(os.Getenv("OUTBOX_SINKS"))
+	if err != nil {
+		log.Fatal("Unable to configure outbox sinks", err)
+	}
+	relayInterval, err := time.ParseDuration(os.Getenv("OUTBOX_INTERVAL"))
+	if err != nil {
+		relayInterval = time.Second
+	}
+	streamBuffer, _ := strconv.Atoi(os.Getenv("STREAM_BUFFER"))
+	hub := stream.NewHub(streamBuffer)
+	relay := outbox.NewRelay(db, relayInterval, append(sinks, dispatcher)...)
+	if retention, err := time.ParseDuration(os.Getenv("OUTBOX_RETENTION")); err == nil {
+		relay.Retention = retention
+	}
+	validation, err := openapi.ParseValidation(os.Getenv("OPENAPI_VALIDATION"))
+	if err != nil {
+		log.Fatal("Unable to configure OpenAPI validation", err)
+	}
+	rpch := rpc.NewApplication(db)
+	rpch.Policy = policies
+	e := app{db: db, store: store, ledger: ledgerConfig, policies: policies, hub: hub, validation: validation}.server()
+
+	interval, err := time.ParseDuration(os.Getenv("RECURRING_INTERVAL"))
+	if err != nil {
+		interval = time.Minute
+	}
+	workers, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go recurring.NewGenerator(db, interval).Run(workers)
+	go relay.Run(workers)
+	// Only one replica relays at a time, so each one tails the outbox to
+	// feed its own streams.
+	go outbox.NewTail(db, relayInterval, hub).Run(workers)
+	go dispatcher.Run(workers)
+	if signingKey != nil {
+		checkpointInterval, err := time.ParseDuration(os.Getenv("AUDIT_CHECKPOINT_INTERVAL"))
+		if err != nil {
+			checkpointInterval = time.Hour
+		}
+		go audit.NewCheckpointer(db, signingKey, checkpointInterval).Run(workers)
+	}
 
 	go func(e *echo.Echo) {
 		if err := e.Start(fmt.Sprintf(":%s", os.Getenv("PORT"))); err != nil && err != http.ErrServerClosed {
@@ -53,12 +261,85 @@ func main() {
 		}
 	}(e)
 
+	// The gRPC API listens on its own port and only when GRPC_PORT is set.
+	grpcServer := rpch.NewServer()
+	if port := os.Getenv("GRPC_PORT"); port != "" {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
+		if err != nil {
+			log.Fatal("Unable to listen for gRPC", err)
+		}
+		go func() {
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Errorf("gRPC server stopped: %v", err)
+			}
+		}()
+	}
+
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGINT)
 	<-shutdown
+	stopWorkers()
+	// Open streams never go idle, so end them before waiting on connections.
+	hub.Close()
+	grpcServer.GracefulStop()
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := e.Shutdown(ctx); err != nil {
 		e.Logger.Fatal(err)
 	}
 }
+
+// verifyAudit walks the audit hash chain and prints the first broken link.
+// It returns the process exit code: 0 when the chain is intact, 1 when it is
+// broken and 2 when it could not be checked.
+func verifyAudit(db *sql.DB) int {
+	key, err := audit.PublicKeyFromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if key == nil {
+		fmt.Println("No AUDIT_PUBLIC_KEY or AUDIT_SIGNING_KEY set, checkpoint signatures are not checked")
+	}
+	report, err := audit.Verify(context.Background(), db, key)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Unable to verify audit log:", err)
+		return 2
+	}
+	fmt.Printf("Checked %d records (%d unchained) and %d checkpoints\n", report.Records, report.Unchained, report.Checkpoints)
+	if report.Broken != nil {
+		fmt.Printf("Chain broken at record %d: %s\n", report.Broken.AuditID, report.Broken.Reason)
+		return 1
+	}
+	fmt.Printf("Chain intact, head %s\n", report.Head)
+	return 0
+}
+
+// importRates loads ECB reference rate files, CSV or XML, into the
+// exchange_rates table and returns the process exit code.
+func importRates(db *sql.DB, paths []string) int {
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: import-rates FILE...")
+		return 2
+	}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		rates, err := fx.Parse(path, f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to parse %s: %v\n", path, err)
+			return 1
+		}
+		n, err := fx.Import(db, rates)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to import %s: %v\n", path, err)
+			return 1
+		}
+		fmt.Printf("Imported %d rates from %s\n", n, path)
+	}
+	return 0
+}
@@ -0,0 +1,21 @@
+//go:build unit
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/phanbanchong/assessment/openapi"
+)
+
+func TestRoutesDocumented(t *testing.T) {
+	t.Run("Every registered route should be in the OpenAPI document", func(t *testing.T) {
+		for _, r := range (app{}).server().Routes() {
+			path := openapi.PathFromEcho(r.Path)
+			if openapi.Spec.Paths[path][strings.ToLower(r.Method)] == nil {
+				t.Errorf("should document %s %s but it is missing", r.Method, path)
+			}
+		}
+	})
+}
@@ -1,4 +1,56 @@
 CREATE TABLE IF NOT EXISTS expenses ( id SERIAL PRIMARY KEY, title TEXT, amount FLOAT, note TEXT, tags TEXT[]);
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS date DATE;
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS recurring_id INT;
+CREATE UNIQUE INDEX IF NOT EXISTS expenses_recurring_id_date_key ON expenses (recurring_id, date);
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS external_ref TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS expenses_external_ref_key ON expenses (external_ref);
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'draft';
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS owner TEXT;
+CREATE TABLE IF NOT EXISTS expense_transitions ( id SERIAL PRIMARY KEY, expense_id INT NOT NULL REFERENCES expenses (id) ON DELETE CASCADE, from_status TEXT NOT NULL, to_status TEXT NOT NULL, actor TEXT NOT NULL, reason TEXT, request_id TEXT, created_at TIMESTAMPTZ NOT NULL DEFAULT now());
+CREATE INDEX IF NOT EXISTS expense_transitions_expense_id_idx ON expense_transitions (expense_id, id);
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS report_id INT;
+CREATE INDEX IF NOT EXISTS expenses_owner_date_idx ON expenses (owner, date);
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS currency TEXT;
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS reporting_amount FLOAT;
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS reporting_currency TEXT;
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS rate FLOAT;
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS rate_date DATE;
+CREATE TABLE IF NOT EXISTS reports ( id SERIAL PRIMARY KEY, title TEXT, submitter TEXT NOT NULL, status TEXT NOT NULL DEFAULT 'open', total FLOAT NOT NULL, created_by TEXT NOT NULL, created_at TIMESTAMPTZ NOT NULL DEFAULT now(), paid_at TIMESTAMPTZ, closed_at TIMESTAMPTZ);
+CREATE TABLE IF NOT EXISTS report_items ( report_id INT NOT NULL REFERENCES reports (id) ON DELETE CASCADE, expense_id INT NOT NULL REFERENCES expenses (id), title TEXT, date DATE, amount FLOAT NOT NULL, PRIMARY KEY (report_id, expense_id));
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS currency TEXT;
+ALTER TABLE report_items ADD COLUMN IF NOT EXISTS currency TEXT;
+ALTER TABLE report_items ADD COLUMN IF NOT EXISTS original_amount FLOAT;
+ALTER TABLE report_items ADD COLUMN IF NOT EXISTS rate FLOAT;
+CREATE TABLE IF NOT EXISTS recurring_expenses ( id SERIAL PRIMARY KEY, title TEXT, amount FLOAT, note TEXT, tags TEXT[], rule TEXT NOT NULL, start_date DATE NOT NULL, next_date DATE, generated INT NOT NULL DEFAULT 0);
+CREATE INDEX IF NOT EXISTS recurring_expenses_next_date_idx ON recurring_expenses (next_date);
+ALTER TABLE recurring_expenses ADD COLUMN IF NOT EXISTS owner TEXT;
+ALTER TABLE recurring_expenses ADD COLUMN IF NOT EXISTS reporting_currency TEXT;
+CREATE TABLE IF NOT EXISTS attachments ( id SERIAL PRIMARY KEY, expense_id INT NOT NULL REFERENCES expenses (id) ON DELETE CASCADE, filename TEXT, content_type TEXT, size BIGINT, sha256 TEXT NOT NULL, created_at TIMESTAMPTZ NOT NULL DEFAULT now(), UNIQUE (expense_id, sha256));
+CREATE INDEX IF NOT EXISTS attachments_sha256_idx ON attachments (sha256);
+CREATE TABLE IF NOT EXISTS expense_audit ( id BIGSERIAL PRIMARY KEY, expense_id INT NOT NULL, action TEXT NOT NULL, actor TEXT NOT NULL, request_id TEXT, changes JSONB NOT NULL, created_at TIMESTAMPTZ NOT NULL DEFAULT now());
+CREATE INDEX IF NOT EXISTS expense_audit_expense_id_idx ON expense_audit (expense_id, id);
+ALTER TABLE expense_audit ADD COLUMN IF NOT EXISTS prev_hash TEXT;
+ALTER TABLE expense_audit ADD COLUMN IF NOT EXISTS hash TEXT;
+CREATE TABLE IF NOT EXISTS audit_checkpoints ( id SERIAL PRIMARY KEY, audit_id BIGINT NOT NULL, hash TEXT NOT NULL, signature TEXT NOT NULL, created_at TIMESTAMPTZ NOT NULL DEFAULT now());
+CREATE TABLE IF NOT EXISTS exchange_rates ( date DATE NOT NULL, currency TEXT NOT NULL, rate FLOAT NOT NULL, PRIMARY KEY (currency, date));
+CREATE TABLE IF NOT EXISTS groups ( id SERIAL PRIMARY KEY, name TEXT NOT NULL, currency TEXT NOT NULL, created_by TEXT NOT NULL, created_at TIMESTAMPTZ NOT NULL DEFAULT now());
+CREATE TABLE IF NOT EXISTS group_members ( group_id INT NOT NULL REFERENCES groups (id) ON DELETE CASCADE, member TEXT NOT NULL, PRIMARY KEY (group_id, member));
+CREATE TABLE IF NOT EXISTS group_expenses ( expense_id INT PRIMARY KEY REFERENCES expenses (id) ON DELETE CASCADE, group_id INT NOT NULL REFERENCES groups (id) ON DELETE CASCADE, paid_by TEXT NOT NULL, method TEXT NOT NULL, amount FLOAT NOT NULL);
+CREATE TABLE IF NOT EXISTS group_shares ( expense_id INT NOT NULL REFERENCES group_expenses (expense_id) ON DELETE CASCADE, member TEXT NOT NULL, amount FLOAT NOT NULL, PRIMARY KEY (expense_id, member));
+CREATE TABLE IF NOT EXISTS settlements ( id SERIAL PRIMARY KEY, group_id INT NOT NULL REFERENCES groups (id) ON DELETE CASCADE, from_member TEXT NOT NULL, to_member TEXT NOT NULL, amount FLOAT NOT NULL, created_by TEXT NOT NULL, created_at TIMESTAMPTZ NOT NULL DEFAULT now());
+CREATE INDEX IF NOT EXISTS group_expenses_group_idx ON group_expenses (group_id);
+CREATE INDEX IF NOT EXISTS settlements_group_idx ON settlements (group_id);
+CREATE TABLE IF NOT EXISTS webhook_subscriptions ( id SERIAL PRIMARY KEY, url TEXT NOT NULL, secret TEXT NOT NULL, events TEXT[] NOT NULL, active BOOLEAN NOT NULL DEFAULT true, created_by TEXT NOT NULL, created_at TIMESTAMPTZ NOT NULL DEFAULT now());
+CREATE TABLE IF NOT EXISTS webhook_deliveries ( id BIGSERIAL PRIMARY KEY, subscription_id INT NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE, event TEXT NOT NULL, expense_id INT NOT NULL, payload JSONB NOT NULL, status TEXT NOT NULL DEFAULT 'pending', attempts INT NOT NULL DEFAULT 0, next_attempt_at TIMESTAMPTZ, last_status_code INT, last_error TEXT, created_at TIMESTAMPTZ NOT NULL DEFAULT now(), delivered_at TIMESTAMPTZ);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS outbox_id BIGINT;
+CREATE UNIQUE INDEX IF NOT EXISTS webhook_deliveries_outbox_idx ON webhook_deliveries (subscription_id, outbox_id);
+CREATE TABLE IF NOT EXISTS webhook_attempts ( id BIGSERIAL PRIMARY KEY, delivery_id BIGINT NOT NULL REFERENCES webhook_deliveries (id) ON DELETE CASCADE, attempt INT NOT NULL, status_code INT, error TEXT, duration_ms BIGINT NOT NULL, attempted_at TIMESTAMPTZ NOT NULL);
+CREATE TABLE IF NOT EXISTS outbox ( id BIGSERIAL PRIMARY KEY, event TEXT NOT NULL, expense_id INT NOT NULL, payload JSONB NOT NULL, created_at TIMESTAMPTZ NOT NULL DEFAULT now(), published_at TIMESTAMPTZ);
+CREATE INDEX IF NOT EXISTS outbox_unpublished_idx ON outbox (id) WHERE published_at IS NULL;
+CREATE SEQUENCE IF NOT EXISTS expense_change_seq;
+CREATE TABLE IF NOT EXISTS expense_changes ( expense_id INT PRIMARY KEY, version BIGINT NOT NULL, owner TEXT, deleted BOOLEAN NOT NULL DEFAULT false, changed_at TIMESTAMPTZ NOT NULL DEFAULT now());
+CREATE INDEX IF NOT EXISTS expense_changes_version_idx ON expense_changes (version);
 INSERT INTO expenses (id, title, amount, note, tags) VALUES(1,'test-title1', 13, 'test-note1', ARRAY['tag1', 'tag2']);
 INSERT INTO expenses (id, title, amount, note, tags) VALUES(2,'test-title2', 14, 'test-note2', ARRAY['tag3', 'tag4']);
-ALTER TABLE expenses ALTER COLUMN id SET DEFAULT 3;
\ No newline at end of file
+ALTER TABLE expenses ALTER COLUMN id SET DEFAULT 3;
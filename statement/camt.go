@@ -0,0 +1,134 @@
+package statement
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type camtDocument struct {
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStatement struct {
+	IBAN    string      `xml:"Acct>Id>IBAN"`
+	OtherID string      `xml:"Acct>Id>Othr>Id"`
+	Ccy     string      `xml:"Acct>Ccy"`
+	Entries []camtEntry `xml:"Ntry"`
+}
+
+type camtEntry struct {
+	NtryRef string `xml:"NtryRef"`
+	Amt     struct {
+		Value string `xml:",chardata"`
+		Ccy   string `xml:"Ccy,attr"`
+	} `xml:"Amt"`
+	CdtDbtInd    string        `xml:"CdtDbtInd"`
+	BookgDt      camtDate      `xml:"BookgDt"`
+	ValDt        camtDate      `xml:"ValDt"`
+	AcctSvcrRef  string        `xml:"AcctSvcrRef"`
+	AddtlNtryInf string        `xml:"AddtlNtryInf"`
+	Details      []camtDetails `xml:"NtryDtls>TxDtls"`
+}
+
+type camtDate struct {
+	Dt   string `xml:"Dt"`
+	DtTm string `xml:"DtTm"`
+}
+
+type camtParty struct {
+	Nm    string `xml:"Nm"`
+	PtyNm string `xml:"Pty>Nm"`
+}
+
+func (p camtParty) name() string {
+	if p.Nm != "" {
+		return p.Nm
+	}
+	return p.PtyNm
+}
+
+type camtDetails struct {
+	EndToEndID string    `xml:"Refs>EndToEndId"`
+	Creditor   camtParty `xml:"RltdPties>Cdtr"`
+	Debtor     camtParty `xml:"RltdPties>Dbtr"`
+	Ustrd      []string  `xml:"RmtInf>Ustrd"`
+}
+
+// ParseCAMT053 reads an ISO 20022 camt.053 bank-to-customer statement. Only
+// the first statement's account is reported; entries of all statements are
+// returned.
+func ParseCAMT053(r io.Reader) (Statement, error) {
+	doc := camtDocument{}
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return Statement{}, fmt.Errorf("camt.053: %w", err)
+	}
+	if len(doc.Statements) == 0 {
+		return Statement{}, fmt.Errorf("camt.053: no statement found")
+	}
+
+	s := Statement{Format: CAMT, Currency: doc.Statements[0].Ccy, Account: doc.Statements[0].IBAN}
+	if s.Account == "" {
+		s.Account = doc.Statements[0].OtherID
+	}
+	for _, stmt := range doc.Statements {
+		for _, e := range stmt.Entries {
+			txn, err := e.transaction()
+			if err != nil {
+				return s, err
+			}
+			s.Transactions = append(s.Transactions, txn)
+		}
+	}
+	return s, nil
+}
+
+func (e camtEntry) transaction() (Transaction, error) {
+	txn := Transaction{Memo: e.AddtlNtryInf}
+	amount, err := parseAmount(e.Amt.Value)
+	if err != nil {
+		return txn, fmt.Errorf("camt.053: invalid amount %q", e.Amt.Value)
+	}
+	txn.Amount = amount
+	if e.CdtDbtInd == "DBIT" {
+		txn.Amount = -amount
+	}
+
+	date := e.BookgDt
+	if date.Dt == "" && date.DtTm == "" {
+		date = e.ValDt
+	}
+	switch {
+	case date.Dt != "":
+		txn.Date, err = time.Parse("2006-01-02", date.Dt)
+	case len(date.DtTm) >= 10:
+		txn.Date, err = time.Parse("2006-01-02", date.DtTm[:10])
+	default:
+		err = fmt.Errorf("missing booking date")
+	}
+	if err != nil {
+		return txn, fmt.Errorf("camt.053: %w", err)
+	}
+
+	txn.Reference = e.AcctSvcrRef
+	if txn.Reference == "" {
+		txn.Reference = e.NtryRef
+	}
+	if len(e.Details) > 0 {
+		d := e.Details[0]
+		if txn.Reference == "" && d.EndToEndID != "NOTPROVIDED" {
+			txn.Reference = d.EndToEndID
+		}
+		if e.CdtDbtInd == "DBIT" {
+			txn.Payee = d.Creditor.name()
+		} else {
+			txn.Payee = d.Debtor.name()
+		}
+		if len(d.Ustrd) > 0 {
+			txn.Memo = strings.Join(d.Ustrd, " ")
+		}
+	}
+	return txn, nil
+}
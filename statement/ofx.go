@@ -0,0 +1,100 @@
+package statement
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+)
+
+// ParseOFX reads OFX 1.x (SGML, as used by QFX) and OFX 2.x (XML) bank and
+// credit card statements. Leaf elements in SGML are not closed, so the parser
+// treats the text after every start tag as that element's value.
+func ParseOFX(r io.Reader) (Statement, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Statement{}, err
+	}
+	body := string(data)
+	start := strings.Index(strings.ToUpper(body), "<OFX>")
+	if start < 0 {
+		return Statement{}, fmt.Errorf("ofx: missing <OFX> element")
+	}
+	body = body[start:]
+
+	s := Statement{Format: OFX}
+	var txn *Transaction
+	for len(body) > 0 {
+		open := strings.IndexByte(body, '<')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(body[open:], '>')
+		if end < 0 {
+			return s, fmt.Errorf("ofx: unterminated tag")
+		}
+		tag := strings.ToUpper(body[open+1 : open+end])
+		body = body[open+end+1:]
+		next := strings.IndexByte(body, '<')
+		if next < 0 {
+			next = len(body)
+		}
+		value := strings.TrimSpace(html.UnescapeString(body[:next]))
+
+		switch tag {
+		case "STMTTRN":
+			txn = &Transaction{}
+		case "/STMTTRN":
+			if txn != nil {
+				if txn.Date.IsZero() {
+					return s, fmt.Errorf("ofx: transaction %q without DTPOSTED", txn.Reference)
+				}
+				s.Transactions = append(s.Transactions, *txn)
+			}
+			txn = nil
+		case "ACCTID":
+			if s.Account == "" {
+				s.Account = value
+			}
+		case "CURDEF":
+			s.Currency = value
+		}
+		if txn == nil {
+			continue
+		}
+		switch tag {
+		case "DTPOSTED":
+			if txn.Date, err = parseOFXDate(value); err != nil {
+				return s, err
+			}
+		case "TRNAMT":
+			if txn.Amount, err = parseAmount(value); err != nil {
+				return s, fmt.Errorf("ofx: invalid TRNAMT %q", value)
+			}
+		case "FITID":
+			txn.Reference = value
+		case "CHECKNUM":
+			if txn.Reference == "" {
+				txn.Reference = value
+			}
+		case "NAME":
+			txn.Payee = value
+		case "MEMO":
+			txn.Memo = value
+		}
+	}
+	return s, nil
+}
+
+// parseOFXDate reads the date part of YYYYMMDD[HHMMSS[.XXX]][[offset:TZ]].
+func parseOFXDate(s string) (time.Time, error) {
+	if len(s) < 8 {
+		return time.Time{}, fmt.Errorf("ofx: invalid date %q", s)
+	}
+	t, err := time.Parse("20060102", s[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ofx: invalid date %q", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,80 @@
+package statement
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+var qifDateLayouts = []string{"01/02/2006", "1/2/2006", "01/02/06", "1/2/06", "2006-01-02", "01-02-2006"}
+
+// ParseQIF reads bank, cash and credit card QIF files. Dates are read
+// month-first, as written by US-localised exporters; the apostrophe form
+// ("1/2'26") is accepted too.
+func ParseQIF(r io.Reader) (Statement, error) {
+	s := Statement{Format: QIF}
+	txn := Transaction{}
+	started := false
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		code, value := text[0], strings.TrimSpace(text[1:])
+		var err error
+		switch code {
+		case '!':
+			if strings.HasPrefix(strings.ToLower(text), "!type:") {
+				switch strings.ToLower(strings.TrimSpace(text[6:])) {
+				case "bank", "cash", "ccard", "oth a", "oth l":
+				default:
+					return s, fmt.Errorf("qif: unsupported %s", text)
+				}
+			}
+		case 'D':
+			txn.Date, err = parseQIFDate(value)
+		case 'T', 'U':
+			txn.Amount, err = parseAmount(value)
+		case 'P':
+			txn.Payee = value
+		case 'M':
+			txn.Memo = value
+		case 'N':
+			txn.Reference = value
+		case 'L':
+			txn.Category = strings.Trim(value, "[]")
+		case '^':
+			if started {
+				if txn.Date.IsZero() {
+					return s, fmt.Errorf("qif: line %d: transaction without date", line)
+				}
+				s.Transactions = append(s.Transactions, txn)
+			}
+			txn = Transaction{}
+			started = false
+			continue
+		}
+		if err != nil {
+			return s, fmt.Errorf("qif: line %d: %w", line, err)
+		}
+		if code != '!' {
+			started = true
+		}
+	}
+	return s, scanner.Err()
+}
+
+func parseQIFDate(s string) (time.Time, error) {
+	s = strings.ReplaceAll(strings.ReplaceAll(s, "'", "/"), " ", "")
+	for _, layout := range qifDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
@@ -0,0 +1,124 @@
+package statement
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	OFX  = "ofx"
+	QIF  = "qif"
+	CAMT = "camt053"
+)
+
+var ErrUnknownFormat = errors.New("unknown statement format")
+
+// Transaction is a single booked line of a bank statement. Amount is negative
+// for money leaving the account.
+type Transaction struct {
+	Date      time.Time
+	Amount    float64
+	Payee     string
+	Memo      string
+	Category  string
+	Reference string
+}
+
+type Statement struct {
+	Format       string
+	Account      string
+	Currency     string
+	Transactions []Transaction
+}
+
+// Parse reads a statement in the given format. An empty format is detected
+// from the file name and content.
+func Parse(format, filename string, r io.Reader) (Statement, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Statement{}, err
+	}
+	if format == "" {
+		format = Detect(filename, data)
+	}
+
+	var s Statement
+	switch format {
+	case OFX, "qfx":
+		s, err = ParseOFX(bytes.NewReader(data))
+	case QIF:
+		s, err = ParseQIF(bytes.NewReader(data))
+	case CAMT, "camt":
+		s, err = ParseCAMT053(bytes.NewReader(data))
+	default:
+		return Statement{}, ErrUnknownFormat
+	}
+	return s, err
+}
+
+func Detect(filename string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".ofx", ".qfx":
+		return OFX
+	case ".qif":
+		return QIF
+	}
+	head := string(data)
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	switch {
+	case strings.Contains(head, "OFXHEADER") || strings.Contains(strings.ToUpper(head), "<OFX>"):
+		return OFX
+	case strings.HasPrefix(strings.TrimSpace(head), "!"):
+		return QIF
+	case strings.Contains(head, "camt.053"):
+		return CAMT
+	}
+	return ""
+}
+
+// ID returns a reference that identifies the transaction across re-imports:
+// the bank's own reference when there is one, otherwise a digest of its
+// booked fields.
+func (t Transaction) ID() string {
+	if t.Reference != "" {
+		return t.Reference
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%s", t.Date.Format("2006-01-02"), formatAmount(t.Amount), t.Payee, t.Memo)))
+	return hex.EncodeToString(sum[:8])
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// parseAmount reads an amount written with a decimal point or a decimal
+// comma. Whichever of the two comes last separates the decimals, unless it
+// appears more than once; the other one only groups thousands.
+func parseAmount(s string) (float64, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), " ", "")
+	decimal := strings.LastIndexAny(s, ".,")
+	if decimal >= 0 && strings.Count(s, s[decimal:decimal+1]) > 1 {
+		decimal = -1
+	}
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case i == decimal:
+			b.WriteByte('.')
+		case r == '.' || r == ',':
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return strconv.ParseFloat(b.String(), 64)
+}
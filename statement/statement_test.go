@@ -0,0 +1,123 @@
+//go:build unit
+
+package statement
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseFile(t *testing.T, name string) Statement {
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	s, err := Parse("", name, f)
+	if err != nil {
+		t.Fatalf("should not return error but it got %v", err)
+	}
+	return s
+}
+
+func assertTransaction(t *testing.T, got, want Transaction) {
+	t.Helper()
+	if got != want {
+		t.Errorf("want %+v but it got %+v", want, got)
+	}
+}
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseOFX(t *testing.T) {
+	t.Run("Parse SGML QFX should be success", func(t *testing.T) {
+		s := parseFile(t, "statement.qfx")
+		if s.Format != OFX || s.Account != "000123456" || s.Currency != "USD" || len(s.Transactions) != 2 {
+			t.Fatalf("unexpected statement %+v", s)
+		}
+		assertTransaction(t, s.Transactions[0], Transaction{Date: date(2026, 1, 2), Amount: -12.5, Payee: "CITY TAXI & CO", Memo: "airport", Reference: "T1001"})
+		assertTransaction(t, s.Transactions[1], Transaction{Date: date(2026, 1, 3), Amount: 1000, Payee: "PAYROLL", Reference: "T1002"})
+	})
+
+	t.Run("Parse XML OFX should be success", func(t *testing.T) {
+		s := parseFile(t, "statement.ofx")
+		if s.Account != "4111XXXX1111" || s.Currency != "EUR" || len(s.Transactions) != 1 {
+			t.Fatalf("unexpected statement %+v", s)
+		}
+		assertTransaction(t, s.Transactions[0], Transaction{Date: date(2026, 2, 10), Amount: -1234.56, Payee: "Hotel", Reference: "CC-9"})
+	})
+}
+
+func TestParseQIF(t *testing.T) {
+	s := parseFile(t, "statement.qif")
+	if s.Format != QIF || len(s.Transactions) != 2 {
+		t.Fatalf("unexpected statement %+v", s)
+	}
+	assertTransaction(t, s.Transactions[0], Transaction{Date: date(2026, 1, 2), Amount: -12.5, Payee: "City Taxi", Memo: "Airport run", Category: "Travel:Taxi", Reference: "1001"})
+	assertTransaction(t, s.Transactions[1], Transaction{Date: date(2026, 1, 3), Amount: -4.2, Payee: "Coffee Shop"})
+}
+
+func TestParseCAMT053(t *testing.T) {
+	s := parseFile(t, "camt053.xml")
+	if s.Format != CAMT || s.Account != "DE89370400440532013000" || s.Currency != "EUR" || len(s.Transactions) != 2 {
+		t.Fatalf("unexpected statement %+v", s)
+	}
+	assertTransaction(t, s.Transactions[0], Transaction{Date: date(2026, 1, 2), Amount: -12.5, Payee: "City Taxi", Memo: "Invoice 42 airport", Reference: "REF-1"})
+	assertTransaction(t, s.Transactions[1], Transaction{Date: date(2026, 1, 3), Amount: 100, Payee: "ACME Ltd"})
+}
+
+func TestTransactionID(t *testing.T) {
+	withRef := Transaction{Reference: "T1"}
+	if withRef.ID() != "T1" {
+		t.Errorf("should use bank reference but it got %s", withRef.ID())
+	}
+	a := Transaction{Date: date(2026, 1, 3), Amount: -4.2, Payee: "Coffee Shop"}
+	b := a
+	if a.ID() != b.ID() || len(a.ID()) != 16 {
+		t.Errorf("digest should be stable but it got %s and %s", a.ID(), b.ID())
+	}
+	b.Amount = -4.3
+	if a.ID() == b.ID() {
+		t.Errorf("digest should differ for different amounts")
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	cases := []struct {
+		in   string
+		want float64
+	}{
+		{"12.50", 12.5},
+		{"-4.20", -4.2},
+		{"1,234.56", 1234.56},
+		{"1,234,567", 1234567},
+		{"12,50", 12.5},
+		{"-1.234,56", -1234.56},
+		{"1.234.567,89", 1234567.89},
+		{"1 234,56", 1234.56},
+		{"80", 80},
+	}
+	for _, tc := range cases {
+		t.Run(tc.in+" should parse", func(t *testing.T) {
+			got, err := parseAmount(tc.in)
+			if err != nil || got != tc.want {
+				t.Errorf("should be %v but it got %v %v", tc.want, got, err)
+			}
+		})
+	}
+
+	if _, err := parseAmount("12,50abc"); err == nil {
+		t.Errorf("invalid amount should got error")
+	}
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	if _, err := Parse("", "statement.txt", strings.NewReader("hello")); err != ErrUnknownFormat {
+		t.Errorf("should return ErrUnknownFormat but it got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/expense"
+)
+
+const (
+	// EventReset tells a resuming client that events were missed and it
+	// should reload the list before applying further events.
+	EventReset = "reset"
+
+	defaultHeartbeat = 15 * time.Second
+	retryMillis      = 3000
+)
+
+type handler struct {
+	Hub       *Hub
+	Heartbeat time.Duration
+}
+
+func NewApplication(hub *Hub) *handler {
+	return &handler{Hub: hub, Heartbeat: defaultHeartbeat}
+}
+
+func lastEventID(c echo.Context) (int64, error) {
+	v := c.Request().Header.Get("Last-Event-ID")
+	if v == "" {
+		v = c.QueryParam("last_event_id")
+	}
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+// StreamExpensesHandler pushes expense changes as server-sent events. It
+// takes the list filter query parameters and resumes after the Last-Event-ID
+// header, or last_event_id query parameter, from the hub's buffer. A comment
+// line is sent every Heartbeat so idle connections stay open.
+func (h *handler) StreamExpensesHandler(c echo.Context) error {
+	f, err := expense.ParseFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: err.Error()})
+	}
+	lastID, err := lastEventID(c)
+	if err != nil || lastID < 0 {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "Last-Event-ID is invalid"})
+	}
+	owner := expense.VisibleOwner(c)
+
+	sub, replay, complete := h.Hub.Subscribe(lastID)
+	defer h.Hub.Unsubscribe(sub)
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	fmt.Fprintf(res, "retry: %d\n\n", retryMillis)
+	if !complete {
+		fmt.Fprintf(res, "event: %s\ndata: {}\n\n", EventReset)
+	}
+
+	send := func(ev Event) error {
+		if (owner != "" && ev.Expense.Owner != owner) || !f.Matches(ev.Expense) {
+			return nil
+		}
+		data, err := json.Marshal(ev.Expense)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Event, data)
+		return err
+	}
+	for _, ev := range replay {
+		if err := send(ev); err != nil {
+			return nil
+		}
+	}
+	res.Flush()
+
+	heartbeat := time.NewTicker(h.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case ev, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			if err := send(ev); err != nil {
+				return nil
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+		}
+		res.Flush()
+	}
+}
@@ -0,0 +1,144 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/phanbanchong/assessment/expense"
+	"github.com/phanbanchong/assessment/outbox"
+)
+
+const (
+	defaultBufferSize = 1000
+	subscriberBuffer  = 64
+)
+
+// Event is an expense change as streamed to clients. ID is the outbox event
+// ID. IDs are handed out when events are written, not when they commit, so
+// they may arrive slightly out of order.
+type Event struct {
+	ID      int64
+	Event   string
+	Expense expense.Expense
+}
+
+// Hub fans expense events out to the open streams and keeps the latest ones,
+// in ID order, for clients resuming with Last-Event-ID. It is an outbox sink
+// fed by an outbox.Tail, so every replica sees every event.
+type Hub struct {
+	mu          sync.Mutex
+	size        int
+	buffer      []Event
+	seen        map[int64]struct{}
+	seenOrder   []int64
+	subscribers map[*Subscriber]struct{}
+	closed      bool
+}
+
+// Subscriber receives events on C until it is unsubscribed, falls too far
+// behind or the hub closes, which all close C.
+type Subscriber struct {
+	C chan Event
+}
+
+func NewHub(size int) *Hub {
+	if size <= 0 {
+		size = defaultBufferSize
+	}
+	return &Hub{size: size, seen: map[int64]struct{}{}, subscribers: map[*Subscriber]struct{}{}}
+}
+
+func (h *Hub) Name() string {
+	return "stream"
+}
+
+// Publish buffers an outbox event and hands it to every subscriber. Events
+// the hub has already seen are ignored, as the outbox may repeat them. The
+// hub remembers twice as many IDs as it buffers, which is plenty to catch a
+// repeat of an event that has just been evicted.
+func (h *Hub) Publish(ctx context.Context, m outbox.Message) error {
+	ev := Event{ID: m.ID, Event: m.Event}
+	if err := json.Unmarshal(m.Data, &ev.Expense); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.seen[ev.ID]; h.closed || ok {
+		return nil
+	}
+	h.remember(ev.ID)
+	i := sort.Search(len(h.buffer), func(i int) bool { return h.buffer[i].ID > ev.ID })
+	h.buffer = append(h.buffer, Event{})
+	copy(h.buffer[i+1:], h.buffer[i:])
+	h.buffer[i] = ev
+	if len(h.buffer) > h.size {
+		h.buffer = h.buffer[len(h.buffer)-h.size:]
+	}
+	for s := range h.subscribers {
+		select {
+		case s.C <- ev:
+		default:
+			// A client this far behind reconnects and resumes from the
+			// buffer rather than holding up everyone else.
+			delete(h.subscribers, s)
+			close(s.C)
+		}
+	}
+	return nil
+}
+
+// remember adds id to the seen IDs, forgetting the oldest one once there are
+// more than twice the buffer size.
+func (h *Hub) remember(id int64) {
+	h.seen[id] = struct{}{}
+	h.seenOrder = append(h.seenOrder, id)
+	if len(h.seenOrder) > 2*h.size {
+		delete(h.seen, h.seenOrder[0])
+		h.seenOrder = h.seenOrder[1:]
+	}
+}
+
+// Subscribe opens a subscriber and returns the buffered events after
+// lastID. complete is false when the hub cannot tell that the buffer holds
+// every event after lastID, because they have been evicted or were relayed
+// before this process started, so the client has to reload instead.
+func (h *Hub) Subscribe(lastID int64) (s *Subscriber, replay []Event, complete bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s = &Subscriber{C: make(chan Event, subscriberBuffer)}
+	if h.closed {
+		close(s.C)
+		return s, nil, true
+	}
+	h.subscribers[s] = struct{}{}
+	complete = lastID == 0 || (len(h.buffer) > 0 && h.buffer[0].ID <= lastID+1)
+	for _, ev := range h.buffer {
+		if ev.ID > lastID {
+			replay = append(replay, ev)
+		}
+	}
+	return s, replay, complete
+}
+
+func (h *Hub) Unsubscribe(s *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[s]; ok {
+		delete(h.subscribers, s)
+		close(s.C)
+	}
+}
+
+// Close ends every open stream and refuses new ones. Register it to run on
+// server shutdown so streams do not hold the shutdown up.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for s := range h.subscribers {
+		delete(h.subscribers, s)
+		close(s.C)
+	}
+}
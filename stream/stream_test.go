@@ -0,0 +1,231 @@
+//go:build unit
+
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/auth"
+	"github.com/phanbanchong/assessment/expense"
+	"github.com/phanbanchong/assessment/outbox"
+)
+
+func message(id int64, event string, exp expense.Expense) outbox.Message {
+	data, _ := json.Marshal(exp)
+	return outbox.Message{ID: id, Event: event, ExpenseID: exp.ID, Data: data}
+}
+
+func TestHub(t *testing.T) {
+	t.Run("Subscribe should replay buffered events after last ID", func(t *testing.T) {
+		hub := NewHub(10)
+		for i := int64(1); i <= 3; i++ {
+			if err := hub.Publish(context.Background(), message(i, expense.EventCreated, expense.Expense{ID: int(i)})); err != nil {
+				t.Errorf("should not return error but it got %v", err)
+			}
+		}
+		hub.Publish(context.Background(), message(2, expense.EventCreated, expense.Expense{ID: 2}))
+
+		_, replay, complete := hub.Subscribe(1)
+		if !complete || len(replay) != 2 || replay[0].ID != 2 || replay[1].ID != 3 {
+			t.Errorf("replay was not expected got: %+v, %v", replay, complete)
+		}
+	})
+
+	t.Run("Subscribe after evicted events should not be complete", func(t *testing.T) {
+		hub := NewHub(2)
+		for i := int64(1); i <= 4; i++ {
+			hub.Publish(context.Background(), message(i, expense.EventCreated, expense.Expense{ID: int(i)}))
+		}
+
+		if _, replay, complete := hub.Subscribe(1); complete || len(replay) != 2 {
+			t.Errorf("replay was not expected got: %+v, %v", replay, complete)
+		}
+		if _, _, complete := hub.Subscribe(2); !complete {
+			t.Errorf("should be complete after the last evicted event")
+		}
+		if _, _, complete := hub.Subscribe(0); !complete {
+			t.Errorf("should be complete without last ID")
+		}
+	})
+
+	t.Run("Publish out of order should keep the buffer in ID order", func(t *testing.T) {
+		hub := NewHub(10)
+		for _, id := range []int64{1, 3, 2} {
+			hub.Publish(context.Background(), message(id, expense.EventCreated, expense.Expense{ID: int(id)}))
+		}
+
+		_, replay, complete := hub.Subscribe(1)
+		if !complete || len(replay) != 2 || replay[0].ID != 2 || replay[1].ID != 3 {
+			t.Errorf("replay was not expected got: %+v, %v", replay, complete)
+		}
+	})
+
+	t.Run("Publish should ignore a repeat of an evicted event", func(t *testing.T) {
+		hub := NewHub(2)
+		for i := int64(1); i <= 3; i++ {
+			hub.Publish(context.Background(), message(i, expense.EventCreated, expense.Expense{ID: int(i)}))
+		}
+		sub, _, _ := hub.Subscribe(0)
+		hub.Publish(context.Background(), message(1, expense.EventCreated, expense.Expense{ID: 1}))
+
+		if len(sub.C) != 0 {
+			t.Errorf("should not send the repeated event")
+		}
+	})
+
+	t.Run("Publish should drop subscribers that fall behind", func(t *testing.T) {
+		hub := NewHub(0)
+		sub, _, _ := hub.Subscribe(0)
+		for i := int64(1); i <= subscriberBuffer+1; i++ {
+			hub.Publish(context.Background(), message(i, expense.EventCreated, expense.Expense{ID: 1}))
+		}
+
+		n := 0
+		for range sub.C {
+			n++
+		}
+		if n != subscriberBuffer {
+			t.Errorf("should receive %d events before being dropped but it got %d", subscriberBuffer, n)
+		}
+	})
+
+	t.Run("Close should end every subscriber", func(t *testing.T) {
+		hub := NewHub(0)
+		sub, _, _ := hub.Subscribe(0)
+		hub.Close()
+
+		if _, ok := <-sub.C; ok {
+			t.Errorf("should close the subscriber channel")
+		}
+		late, _, _ := hub.Subscribe(0)
+		if _, ok := <-late.C; ok {
+			t.Errorf("should close subscribers opened after close")
+		}
+	})
+}
+
+func serve(h *handler, user auth.User, target string, header http.Header, until func(hub *Hub)) *httptest.ResponseRecorder {
+	e := echo.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	auth.SetUser(c, user)
+
+	done := make(chan error)
+	go func() { done <- h.StreamExpensesHandler(c) }()
+	until(h.Hub)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+	return rec
+}
+
+func TestStreamExpensesHandler(t *testing.T) {
+	alice := expense.Expense{ID: 1, Title: "lunch", Amount: 100, Tags: []string{"food"}, Status: "draft", Owner: "alice"}
+	bob := expense.Expense{ID: 2, Title: "taxi", Amount: 200, Tags: []string{"travel"}, Status: "draft", Owner: "bob"}
+
+	t.Run("Stream should push only the caller's own expenses", func(t *testing.T) {
+		h := NewApplication(NewHub(0))
+		rec := serve(h, auth.User{Name: "alice"}, "/expenses/stream", nil, func(hub *Hub) {
+			time.Sleep(20 * time.Millisecond)
+			hub.Publish(context.Background(), message(1, expense.EventCreated, alice))
+			hub.Publish(context.Background(), message(2, expense.EventCreated, bob))
+		})
+
+		body := rec.Body.String()
+		want := "retry: 3000\n\nid: 1\nevent: expense.created\ndata: " + `{"id":1,"title":"lunch","amount":100,"note":"","tags":["food"],"status":"draft","owner":"alice"}` + "\n\n"
+		if body != want {
+			t.Errorf("response error was not expected got: %q", body)
+		}
+		if rec.Header().Get(echo.HeaderContentType) != "text/event-stream" {
+			t.Errorf("should be an event stream but it got %s", rec.Header().Get(echo.HeaderContentType))
+		}
+	})
+
+	t.Run("Stream should replay after Last-Event-ID with the list filter", func(t *testing.T) {
+		hub := NewHub(0)
+		hub.Publish(context.Background(), message(1, expense.EventCreated, alice))
+		hub.Publish(context.Background(), message(2, expense.EventCreated, bob))
+		hub.Publish(context.Background(), message(3, expense.EventDeleted, alice))
+		h := NewApplication(hub)
+		rec := serve(h, auth.User{Name: "carol", Roles: []string{auth.RoleApprover}}, "/expenses/stream?tag=food", http.Header{"Last-Event-Id": {"1"}}, func(*Hub) {})
+
+		body := rec.Body.String()
+		if strings.Contains(body, "id: 1\n") || strings.Contains(body, "id: 2\n") || !strings.Contains(body, "id: 3\nevent: expense.deleted\n") {
+			t.Errorf("response error was not expected got: %q", body)
+		}
+	})
+
+	t.Run("Stream resuming past the buffer should send reset", func(t *testing.T) {
+		hub := NewHub(1)
+		hub.Publish(context.Background(), message(5, expense.EventCreated, alice))
+		h := NewApplication(hub)
+		rec := serve(h, auth.User{Name: "alice"}, "/expenses/stream?last_event_id=2", nil, func(*Hub) {})
+
+		if !strings.HasPrefix(rec.Body.String(), "retry: 3000\n\nevent: reset\ndata: {}\n\nid: 5\n") {
+			t.Errorf("response error was not expected got: %q", rec.Body.String())
+		}
+	})
+
+	t.Run("Stream should send heartbeats", func(t *testing.T) {
+		h := NewApplication(NewHub(0))
+		h.Heartbeat = 10 * time.Millisecond
+		rec := serve(h, auth.User{Name: "alice"}, "/expenses/stream", nil, func(*Hub) {
+			time.Sleep(30 * time.Millisecond)
+		})
+
+		if !strings.Contains(rec.Body.String(), ": heartbeat\n\n") {
+			t.Errorf("response error was not expected got: %q", rec.Body.String())
+		}
+	})
+
+	t.Run("Stream should end when the hub closes", func(t *testing.T) {
+		hub := NewHub(0)
+		h := NewApplication(hub)
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/expenses/stream", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		done := make(chan error)
+		go func() { done <- h.StreamExpensesHandler(c) }()
+		time.Sleep(20 * time.Millisecond)
+		hub.Close()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("should not return error but it got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("should end the stream when the hub closes")
+		}
+	})
+
+	t.Run("Stream with invalid Last-Event-ID should got error", func(t *testing.T) {
+		h := NewApplication(NewHub(0))
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/expenses/stream", nil)
+		req.Header.Set("Last-Event-ID", "abc")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := h.StreamExpensesHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"message":"Last-Event-ID is invalid"}` + "\n"
+		if rec.Code != http.StatusBadRequest || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %s", rec.Body.String())
+		}
+	})
+}
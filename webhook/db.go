@@ -0,0 +1,286 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/lib/pq"
+)
+
+const deliveryColumns = "id, subscription_id, event, expense_id, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at"
+
+func InitDB(db *sql.DB) error {
+	commands := []string{
+		"CREATE TABLE IF NOT EXISTS webhook_subscriptions ( id SERIAL PRIMARY KEY, url TEXT NOT NULL, secret TEXT NOT NULL, events TEXT[] NOT NULL, active BOOLEAN NOT NULL DEFAULT true, created_by TEXT NOT NULL, created_at TIMESTAMPTZ NOT NULL DEFAULT now());",
+		"CREATE TABLE IF NOT EXISTS webhook_deliveries ( id BIGSERIAL PRIMARY KEY, subscription_id INT NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE, event TEXT NOT NULL, expense_id INT NOT NULL, payload JSONB NOT NULL, status TEXT NOT NULL DEFAULT 'pending', attempts INT NOT NULL DEFAULT 0, next_attempt_at TIMESTAMPTZ, last_status_code INT, last_error TEXT, created_at TIMESTAMPTZ NOT NULL DEFAULT now(), delivered_at TIMESTAMPTZ);",
+		"CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';",
+		"ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS outbox_id BIGINT;",
+		"CREATE UNIQUE INDEX IF NOT EXISTS webhook_deliveries_outbox_idx ON webhook_deliveries (subscription_id, outbox_id);",
+		"CREATE TABLE IF NOT EXISTS webhook_attempts ( id BIGSERIAL PRIMARY KEY, delivery_id BIGINT NOT NULL REFERENCES webhook_deliveries (id) ON DELETE CASCADE, attempt INT NOT NULL, status_code INT, error TEXT, duration_ms BIGINT NOT NULL, attempted_at TIMESTAMPTZ NOT NULL);",
+	}
+	for _, command := range commands {
+		if _, err := db.Exec(command); err != nil {
+			return err
+		}
+		log.Printf("Executed: %s", command)
+	}
+	return nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validate checks the URL and events of s, defaulting to every event.
+func (s *Subscription) validate() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return &Error{http.StatusBadRequest, "Field url is invalid"}
+	}
+	if len(s.Events) == 0 {
+		s.Events = Events
+	}
+	known := map[string]bool{}
+	for _, e := range Events {
+		known[e] = true
+	}
+	seen := map[string]bool{}
+	events := []string{}
+	for _, e := range s.Events {
+		if !known[e] {
+			return &Error{http.StatusBadRequest, "Event " + e + " is invalid"}
+		}
+		if !seen[e] {
+			seen[e] = true
+			events = append(events, e)
+		}
+	}
+	sort.Strings(events)
+	s.Events = events
+	return nil
+}
+
+// CreateSubscription stores s, generating a secret when it has none.
+func CreateSubscription(db *sql.DB, s Subscription) (Subscription, error) {
+	if err := s.validate(); err != nil {
+		return s, err
+	}
+	if s.Secret == "" {
+		secret, err := newSecret()
+		if err != nil {
+			return s, err
+		}
+		s.Secret = secret
+	}
+	s.Active = true
+	row := db.QueryRow("INSERT INTO webhook_subscriptions (url, secret, events, created_by) values ($1, $2, $3, $4) RETURNING id, created_at", s.URL, s.Secret, pq.Array(s.Events), s.CreatedBy)
+	err := row.Scan(&s.ID, &s.CreatedAt)
+	return s, err
+}
+
+func scanSubscription(row interface{ Scan(...any) error }) (Subscription, error) {
+	s := Subscription{}
+	err := row.Scan(&s.ID, &s.URL, pq.Array(&s.Events), &s.Active, &s.CreatedBy, &s.CreatedAt)
+	return s, err
+}
+
+func GetSubscription(db *sql.DB, id int) (Subscription, error) {
+	row := db.QueryRow("SELECT id, url, events, active, created_by, created_at FROM webhook_subscriptions WHERE id = $1", id)
+	return scanSubscription(row)
+}
+
+func GetSubscriptions(db *sql.DB) ([]Subscription, error) {
+	subs := []Subscription{}
+	rows, err := db.Query("SELECT id, url, events, active, created_by, created_at FROM webhook_subscriptions ORDER BY id ASC")
+	if err != nil {
+		return subs, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			return subs, err
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+// DeleteSubscription removes a subscription along with its delivery log.
+func DeleteSubscription(db *sql.DB, id int) error {
+	result, err := db.Exec("DELETE FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Enqueue queues payload for every active subscription to event and returns
+// how many deliveries were created. Outbox events relayed more than once are
+// only queued once per subscription.
+func Enqueue(db *sql.DB, outboxID int64, event string, expenseID int, payload []byte, now time.Time) (int64, error) {
+	result, err := db.Exec("INSERT INTO webhook_deliveries (subscription_id, outbox_id, event, expense_id, payload, next_attempt_at) SELECT id, $1, $2, $3, $4, $5 FROM webhook_subscriptions WHERE active AND $2 = ANY(events) ON CONFLICT (subscription_id, outbox_id) DO NOTHING",
+		outboxID, event, expenseID, payload, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// job is a claimed delivery with what is needed to send it.
+type job struct {
+	ID       int64
+	Event    string
+	Payload  []byte
+	Attempts int
+	URL      string
+	Secret   string
+}
+
+// claim picks up to limit due deliveries and pushes their next attempt back
+// by lease, so another dispatcher does not send them while this one is.
+func claim(db *sql.DB, now time.Time, lease time.Duration, limit int) ([]job, error) {
+	rows, err := db.Query(`UPDATE webhook_deliveries d SET next_attempt_at = $2 FROM webhook_subscriptions s
+		WHERE s.id = d.subscription_id AND d.id IN (SELECT id FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= $1 ORDER BY id ASC LIMIT $3 FOR UPDATE SKIP LOCKED)
+		RETURNING d.id, d.event, d.payload, d.attempts, s.url, s.secret`, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	jobs := []job{}
+	for rows.Next() {
+		j := job{}
+		if err := rows.Scan(&j.ID, &j.Event, &j.Payload, &j.Attempts, &j.URL, &j.Secret); err != nil {
+			return jobs, err
+		}
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].ID < jobs[b].ID })
+	return jobs, rows.Err()
+}
+
+// recordAttempt logs a and moves the delivery to status, retrying at next
+// while it is pending.
+func recordAttempt(db *sql.DB, id int64, a Attempt, status string, next *time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("INSERT INTO webhook_attempts (delivery_id, attempt, status_code, error, duration_ms, attempted_at) values ($1, $2, $3, $4, $5, $6)",
+		id, a.Attempt, nullInt(a.StatusCode), nullString(a.Error), a.DurationMS, a.AttemptedAt); err != nil {
+		return err
+	}
+	var delivered any
+	if status == StatusDelivered {
+		delivered = a.AttemptedAt
+	}
+	if _, err := tx.Exec("UPDATE webhook_deliveries SET status = $2, attempts = $3, next_attempt_at = $4, last_status_code = $5, last_error = $6, delivered_at = $7 WHERE id = $1",
+		id, status, a.Attempt, next, nullInt(a.StatusCode), nullString(a.Error), delivered); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func nullInt(v int) any {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func scanDelivery(row interface{ Scan(...any) error }) (Delivery, error) {
+	d := Delivery{}
+	var payload []byte
+	var next, delivered sql.NullTime
+	var code sql.NullInt64
+	var lastError sql.NullString
+	err := row.Scan(&d.ID, &d.SubscriptionID, &d.Event, &d.ExpenseID, &payload, &d.Status, &d.Attempts, &next, &code, &lastError, &d.CreatedAt, &delivered)
+	d.Payload = payload
+	d.LastStatusCode, d.LastError = int(code.Int64), lastError.String
+	if next.Valid && d.Status == StatusPending {
+		d.NextAttemptAt = &next.Time
+	}
+	if delivered.Valid {
+		d.DeliveredAt = &delivered.Time
+	}
+	return d, err
+}
+
+// GetDeliveries lists the deliveries of a subscription, newest first,
+// optionally only those in status.
+func GetDeliveries(db *sql.DB, subscriptionID int, status string) ([]Delivery, error) {
+	query := "SELECT " + deliveryColumns + " FROM webhook_deliveries WHERE subscription_id = $1"
+	args := []any{subscriptionID}
+	if status != "" {
+		query += " AND status = $2"
+		args = append(args, status)
+	}
+	deliveries := []Delivery{}
+	rows, err := db.Query(query+" ORDER BY id DESC LIMIT 100", args...)
+	if err != nil {
+		return deliveries, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return deliveries, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// GetDelivery loads a delivery of a subscription with its attempt log.
+func GetDelivery(db *sql.DB, subscriptionID int, id int64) (Delivery, error) {
+	row := db.QueryRow("SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id = $1 AND subscription_id = $2", id, subscriptionID)
+	d, err := scanDelivery(row)
+	if err != nil {
+		return d, err
+	}
+	rows, err := db.Query("SELECT attempt, status_code, error, duration_ms, attempted_at FROM webhook_attempts WHERE delivery_id = $1 ORDER BY id ASC", id)
+	if err != nil {
+		return d, err
+	}
+	defer rows.Close()
+	d.Log = []Attempt{}
+	for rows.Next() {
+		a := Attempt{}
+		var code sql.NullInt64
+		var attemptError sql.NullString
+		if err := rows.Scan(&a.Attempt, &code, &attemptError, &a.DurationMS, &a.AttemptedAt); err != nil {
+			return d, err
+		}
+		a.StatusCode, a.Error = int(code.Int64), attemptError.String
+		d.Log = append(d.Log, a)
+	}
+	return d, rows.Err()
+}
+
+// Redeliver queues a delivery again with a fresh set of attempts, whatever
+// its status. Earlier attempts stay in its log.
+func Redeliver(db *sql.DB, subscriptionID int, id int64, now time.Time) (Delivery, error) {
+	row := db.QueryRow("UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = $3, delivered_at = NULL WHERE id = $1 AND subscription_id = $2 RETURNING "+deliveryColumns, id, subscriptionID, now)
+	return scanDelivery(row)
+}
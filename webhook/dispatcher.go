@@ -0,0 +1,189 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/phanbanchong/assessment/outbox"
+)
+
+const (
+	defaultMaxAttempts = 8
+	defaultBaseDelay   = 30 * time.Second
+	defaultMaxDelay    = 6 * time.Hour
+	batchSize          = 50
+)
+
+// Dispatcher queues expense events for subscribers and sends them. A failed
+// delivery is retried after BaseDelay, doubling up to MaxDelay, and goes dead
+// after MaxAttempts.
+type Dispatcher struct {
+	DB          *sql.DB
+	Client      *http.Client
+	Interval    time.Duration
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Now         func() time.Time
+
+	wake chan struct{}
+}
+
+func NewDispatcher(db *sql.DB, interval time.Duration) *Dispatcher {
+	return &Dispatcher{
+		DB:          db,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		Interval:    interval,
+		MaxAttempts: defaultMaxAttempts,
+		BaseDelay:   defaultBaseDelay,
+		MaxDelay:    defaultMaxDelay,
+		Now:         time.Now,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Sign is the X-Webhook-Signature of body sent at timestamp: "sha256=" and
+// the hex HMAC-SHA256 of the timestamp, a dot and the body, keyed with the
+// subscription secret. Receivers should recompute it and compare in
+// constant time.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *Dispatcher) Name() string {
+	return "webhooks"
+}
+
+// Publish queues an outbox event for every subscriber and wakes the
+// dispatcher, making the dispatcher an outbox sink.
+func (d *Dispatcher) Publish(ctx context.Context, m outbox.Message) error {
+	payload, err := json.Marshal(Payload{Event: m.Event, OccurredAt: m.OccurredAt.UTC(), Data: m.Data})
+	if err != nil {
+		return err
+	}
+	n, err := Enqueue(d.DB, m.ID, m.Event, m.ExpenseID, payload, d.Now())
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Run sends due deliveries immediately, then on every tick or publish until
+// ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.DeliverDue(ctx); err != nil {
+			log.Errorf("Deliver webhooks error: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// DeliverDue sends every delivery that is due and returns how many were
+// attempted.
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		jobs, err := claim(d.DB, d.Now(), d.Client.Timeout+time.Minute, batchSize)
+		if err != nil {
+			return total, err
+		}
+		for _, j := range jobs {
+			if ctx.Err() != nil {
+				return total, nil
+			}
+			a := d.send(ctx, j)
+			status, next := d.outcome(a)
+			if err := recordAttempt(d.DB, j.ID, a, status, next); err != nil {
+				return total, err
+			}
+			total++
+		}
+		if len(jobs) < batchSize {
+			return total, nil
+		}
+	}
+}
+
+// send makes one attempt at j. Any 2xx response counts as delivered.
+func (d *Dispatcher) send(ctx context.Context, j job) Attempt {
+	now := d.Now()
+	a := Attempt{Attempt: j.Attempts + 1, AttemptedAt: now.UTC()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.URL, bytes.NewReader(j.Payload))
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "assessment-webhooks")
+	req.Header.Set(HeaderEvent, j.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(j.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(j.Secret, now.Unix(), j.Payload))
+
+	start := time.Now()
+	resp, err := d.Client.Do(req)
+	a.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	a.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		a.Error = resp.Status
+	}
+	return a
+}
+
+// outcome decides what happens to a delivery after attempt a.
+func (d *Dispatcher) outcome(a Attempt) (string, *time.Time) {
+	if a.Error == "" {
+		return StatusDelivered, nil
+	}
+	if a.Attempt >= d.MaxAttempts {
+		return StatusDead, nil
+	}
+	next := a.AttemptedAt.Add(d.Backoff(a.Attempt))
+	return StatusPending, &next
+}
+
+// Backoff is the wait after the given failed attempt.
+func (d *Dispatcher) Backoff(attempt int) time.Duration {
+	delay := d.BaseDelay
+	for i := 1; i < attempt && delay < d.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > d.MaxDelay {
+		delay = d.MaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,178 @@
+//go:build unit
+
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/phanbanchong/assessment/expense"
+	"github.com/phanbanchong/assessment/outbox"
+)
+
+var now = time.Date(2026, 4, 1, 12, 0, 0, 0, time.UTC)
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is a stand-in subscriber answering every delivery with status.
+func receiver(t *testing.T, status int) (*httptest.Server, chan received) {
+	got := make(chan received, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{r.Header.Clone(), body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, got
+}
+
+func testDispatcher(db *sql.DB) *Dispatcher {
+	d := NewDispatcher(db, time.Minute)
+	d.Now = func() time.Time { return now }
+	return d
+}
+
+func expectClaim(mock sqlmock.Sqlmock, url string, attempts int) {
+	mock.ExpectQuery("UPDATE webhook_deliveries d SET next_attempt_at").
+		WithArgs(now, sqlmock.AnyArg(), batchSize).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event", "payload", "attempts", "url", "secret"}).
+			AddRow(7, expense.EventCreated, []byte(`{"event":"expense.created"}`), attempts, url, "s3cret"))
+}
+
+func TestDeliverDue(t *testing.T) {
+	t.Run("Delivery should be signed and marked delivered", func(t *testing.T) {
+		srv, got := receiver(t, http.StatusNoContent)
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		expectClaim(mock, srv.URL, 0)
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO webhook_attempts").
+			WithArgs(int64(7), 1, http.StatusNoContent, nil, sqlmock.AnyArg(), now).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE webhook_deliveries SET status").
+			WithArgs(int64(7), StatusDelivered, 1, nil, http.StatusNoContent, nil, now).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		n, err := testDispatcher(db).DeliverDue(context.Background())
+		if err != nil || n != 1 {
+			t.Errorf("should deliver 1 but it got %d %v", n, err)
+		}
+		r := <-got
+		if string(r.body) != `{"event":"expense.created"}` {
+			t.Errorf("body was not expected got: %s", r.body)
+		}
+		ts, _ := strconv.ParseInt(r.header.Get(HeaderTimestamp), 10, 64)
+		if r.header.Get(HeaderSignature) != Sign("s3cret", ts, r.body) || ts != now.Unix() {
+			t.Errorf("signature was not expected got: %s at %d", r.header.Get(HeaderSignature), ts)
+		}
+		if r.header.Get(HeaderEvent) != expense.EventCreated || r.header.Get(HeaderDelivery) != "7" {
+			t.Errorf("headers were not expected got: %v", r.header)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Failed delivery should be retried with backoff", func(t *testing.T) {
+		srv, got := receiver(t, http.StatusServiceUnavailable)
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		expectClaim(mock, srv.URL, 2)
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO webhook_attempts").
+			WithArgs(int64(7), 3, http.StatusServiceUnavailable, "503 Service Unavailable", sqlmock.AnyArg(), now).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE webhook_deliveries SET status").
+			WithArgs(int64(7), StatusPending, 3, now.Add(2*time.Minute), http.StatusServiceUnavailable, "503 Service Unavailable", nil).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if _, err := testDispatcher(db).DeliverDue(context.Background()); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		<-got
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Delivery out of attempts should go dead", func(t *testing.T) {
+		srv, _ := receiver(t, http.StatusInternalServerError)
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		expectClaim(mock, srv.URL, defaultMaxAttempts-1)
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO webhook_attempts").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE webhook_deliveries SET status").
+			WithArgs(int64(7), StatusDead, defaultMaxAttempts, nil, http.StatusInternalServerError, sqlmock.AnyArg(), nil).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if _, err := testDispatcher(db).DeliverDue(context.Background()); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil, time.Minute)
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute}
+	for i, w := range want {
+		if got := d.Backoff(i + 1); got != w {
+			t.Errorf("backoff after attempt %d should be %v but it got %v", i+1, w, got)
+		}
+	}
+	if got := d.Backoff(20); got != defaultMaxDelay {
+		t.Errorf("backoff should be capped at %v but it got %v", defaultMaxDelay, got)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	t.Run("Publish should queue an outbox event for subscribers", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectExec("INSERT INTO webhook_deliveries (.+) FROM webhook_subscriptions WHERE active (.+) ON CONFLICT").
+			WithArgs(int64(12), expense.EventDeleted, 4, []byte(`{"event":"expense.deleted","occurred_at":"2026-04-01T12:00:00Z","data":{"id":4,"title":"taxi"}}`), now).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		d := testDispatcher(db)
+		m := outbox.Message{ID: 12, Event: expense.EventDeleted, ExpenseID: 4, OccurredAt: now, Data: []byte(`{"id":4,"title":"taxi"}`)}
+		if err := d.Publish(context.Background(), m); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+		select {
+		case <-d.wake:
+		default:
+			t.Errorf("publish should wake the dispatcher")
+		}
+	})
+}
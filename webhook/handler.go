@@ -0,0 +1,115 @@
+package webhook
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/phanbanchong/assessment/audit"
+	"github.com/phanbanchong/assessment/expense"
+)
+
+// respond maps the outcome of a webhook operation to a response.
+func respond(c echo.Context, status int, v any, err error, notFound string) error {
+	var we *Error
+	switch {
+	case err == nil:
+		return c.JSON(status, v)
+	case err == sql.ErrNoRows:
+		return c.JSON(http.StatusNotFound, expense.Error{Message: notFound})
+	case errors.As(err, &we):
+		return c.JSON(we.Status, expense.Error{Message: we.Message})
+	default:
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: err.Error()})
+	}
+}
+
+func (h *handler) CreateSubscriptionHandler(c echo.Context) error {
+	s := Subscription{}
+	if err := c.Bind(&s); err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: err.Error()})
+	}
+	s.CreatedBy = audit.ActorFrom(c).Name
+	s, err := CreateSubscription(h.DB, s)
+	return respond(c, http.StatusCreated, s, err, "")
+}
+
+func (h *handler) GetSubscriptionsHandler(c echo.Context) error {
+	subs, err := GetSubscriptions(h.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: "Unable to get webhooks from database:" + err.Error()})
+	}
+	return c.JSON(http.StatusOK, subs)
+}
+
+func (h *handler) GetSubscriptionHandler(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "ID is invalid"})
+	}
+	s, err := GetSubscription(h.DB, id)
+	return respond(c, http.StatusOK, s, err, "Webhook not found")
+}
+
+func (h *handler) DeleteSubscriptionHandler(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "ID is invalid"})
+	}
+	if err := DeleteSubscription(h.DB, id); err != nil {
+		return respond(c, http.StatusOK, nil, err, "Webhook not found")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetDeliveriesHandler is the delivery log of a subscription, filtered by the
+// status query parameter.
+func (h *handler) GetDeliveriesHandler(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "ID is invalid"})
+	}
+	status := c.QueryParam("status")
+	if status != "" && status != StatusPending && status != StatusDelivered && status != StatusDead {
+		return c.JSON(http.StatusBadRequest, expense.Error{Message: "Query status is invalid"})
+	}
+	deliveries, err := GetDeliveries(h.DB, id, status)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, expense.Error{Message: "Unable to get deliveries from database:" + err.Error()})
+	}
+	return c.JSON(http.StatusOK, deliveries)
+}
+
+func deliveryParams(c echo.Context) (int, int64, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, 0, &Error{http.StatusBadRequest, "ID is invalid"}
+	}
+	deliveryID, err := strconv.ParseInt(c.Param("deliveryID"), 10, 64)
+	if err != nil {
+		return 0, 0, &Error{http.StatusBadRequest, "Delivery ID is invalid"}
+	}
+	return id, deliveryID, nil
+}
+
+func (h *handler) GetDeliveryHandler(c echo.Context) error {
+	id, deliveryID, err := deliveryParams(c)
+	if err != nil {
+		return respond(c, http.StatusOK, nil, err, "")
+	}
+	d, err := GetDelivery(h.DB, id, deliveryID)
+	return respond(c, http.StatusOK, d, err, "Delivery not found")
+}
+
+// RedeliverHandler queues a delivery to be sent again.
+func (h *handler) RedeliverHandler(c echo.Context) error {
+	id, deliveryID, err := deliveryParams(c)
+	if err != nil {
+		return respond(c, http.StatusOK, nil, err, "")
+	}
+	d, err := Redeliver(h.DB, id, deliveryID, time.Now())
+	return respond(c, http.StatusAccepted, d, err, "Delivery not found")
+}
@@ -0,0 +1,110 @@
+//go:build unit
+
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/phanbanchong/assessment/auth"
+)
+
+func webhookContext(method, target, body string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	auth.SetUser(c, auth.User{Name: "root", Roles: []string{auth.RoleAdmin}})
+	return c, rec
+}
+
+func TestCreateSubscriptionHandler(t *testing.T) {
+	t.Run("Create webhook should default to every event", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("INSERT INTO webhook_subscriptions").
+			WithArgs("https://example.com/hook", "s3cret", pq.Array([]string{"expense.created", "expense.deleted", "expense.updated"}), "root").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, now))
+
+		c, rec := webhookContext(http.MethodPost, "/webhooks", `{"url":"https://example.com/hook","secret":"s3cret"}`)
+		if err := NewApplication(db).CreateSubscriptionHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"id":1,"url":"https://example.com/hook","events":["expense.created","expense.deleted","expense.updated"],"secret":"s3cret","active":true,"created_by":"root","created_at":"2026-04-01T12:00:00Z"}` + "\n"
+		if rec.Code != http.StatusCreated || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	invalid := map[string]string{
+		"Create webhook with invalid url should got bad request":   `{"url":"ftp://example.com"}`,
+		"Create webhook with unknown event should got bad request": `{"url":"https://example.com","events":["expense.viewed"]}`,
+	}
+	for name, body := range invalid {
+		t.Run(name, func(t *testing.T) {
+			c, rec := webhookContext(http.MethodPost, "/webhooks", body)
+			if err := NewApplication(nil).CreateSubscriptionHandler(c); err != nil {
+				t.Errorf("should not return error but it got %v", err)
+			}
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("should status bad request but it got %v", rec.Code)
+			}
+		})
+	}
+}
+
+func TestRedeliverHandler(t *testing.T) {
+	t.Run("Redeliver should queue a dead delivery again", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("UPDATE webhook_deliveries SET status = 'pending', attempts = 0").
+			WithArgs(int64(7), 1, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "subscription_id", "event", "expense_id", "payload", "status", "attempts", "next_attempt_at", "last_status_code", "last_error", "created_at", "delivered_at"}).
+				AddRow(7, 1, "expense.created", 4, []byte(`{}`), StatusPending, 0, now, 500, "500 Internal Server Error", now, nil))
+
+		c, rec := webhookContext(http.MethodPost, "/webhooks/1/deliveries/7/redeliver", "")
+		c.SetParamNames("id", "deliveryID")
+		c.SetParamValues("1", "7")
+		if err := NewApplication(db).RedeliverHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		want := `{"id":7,"subscription_id":1,"event":"expense.created","expense_id":4,"payload":{},"status":"pending","attempts":0,"next_attempt_at":"2026-04-01T12:00:00Z","last_status_code":500,"last_error":"500 Internal Server Error","created_at":"2026-04-01T12:00:00Z"}` + "\n"
+		if rec.Code != http.StatusAccepted || rec.Body.String() != want {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Redeliver missing delivery should got not found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		mock.ExpectQuery("UPDATE webhook_deliveries").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		c, rec := webhookContext(http.MethodPost, "/webhooks/1/deliveries/9/redeliver", "")
+		c.SetParamNames("id", "deliveryID")
+		c.SetParamValues("1", "9")
+		if err := NewApplication(db).RedeliverHandler(c); err != nil {
+			t.Errorf("should not return error but it got %v", err)
+		}
+		if rec.Code != http.StatusNotFound || rec.Body.String() != `{"message":"Delivery not found"}`+"\n" {
+			t.Errorf("response error was not expected got: %d %s", rec.Code, rec.Body.String())
+		}
+	})
+}